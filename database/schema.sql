@@ -15,11 +15,80 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     last_login TIMESTAMP NULL,
     is_active BOOLEAN DEFAULT TRUE,
+    role VARCHAR(20) DEFAULT 'player',
+    -- 双因素认证 (TOTP)
+    totp_secret VARCHAR(64) DEFAULT '',
+    totp_enabled BOOLEAN DEFAULT FALSE,
+    totp_last_step BIGINT DEFAULT 0,
+    recovery_codes TEXT,
+    require_two_factor BOOLEAN DEFAULT FALSE,
+    -- 封禁状态
+    is_banned BOOLEAN DEFAULT FALSE,
+    ban_reason VARCHAR(255) DEFAULT '',
+    -- 多角色：player_id 为注册时创建的首个角色，角色列表见 players.username
+    character_slots INT DEFAULT 3,
     INDEX idx_username (username),
     INDEX idx_player_id (player_id),
     INDEX idx_created_at (created_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
+-- 认证审计表 - 记录登录、注册、改密、封禁等认证事件及来源IP/设备
+CREATE TABLE IF NOT EXISTS auth_events (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    username VARCHAR(50) DEFAULT '',
+    player_id VARCHAR(64) DEFAULT '',
+    event_type VARCHAR(32) NOT NULL,
+    success BOOLEAN DEFAULT TRUE,
+    ip VARCHAR(64) DEFAULT '',
+    user_agent VARCHAR(255) DEFAULT '',
+    detail VARCHAR(255) DEFAULT '',
+    actor VARCHAR(50) DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_username (username),
+    INDEX idx_player_id (player_id),
+    INDEX idx_event_type (event_type),
+    INDEX idx_ip (ip),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 数据请求表 - 账号数据导出与删除（冷静期后由 Persist 执行）
+CREATE TABLE IF NOT EXISTS data_requests (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    request_id VARCHAR(64) NOT NULL UNIQUE,
+    username VARCHAR(50) NOT NULL,
+    type VARCHAR(16) DEFAULT '',
+    status VARCHAR(16) DEFAULT 'pending',
+    requested_by VARCHAR(50) DEFAULT '',
+    scheduled_at TIMESTAMP NULL,
+    completed_at TIMESTAMP NULL,
+    archive LONGTEXT,
+    error VARCHAR(255) DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_username (username),
+    INDEX idx_type (type),
+    INDEX idx_status (status),
+    INDEX idx_scheduled_at (scheduled_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- API密钥表 - 服务账号/自动化工具的只读访问，只保存密钥哈希
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    key_id VARCHAR(32) NOT NULL UNIQUE,
+    name VARCHAR(64) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes VARCHAR(255) DEFAULT '',
+    rate_limit INT DEFAULT 60,
+    created_by VARCHAR(50) DEFAULT '',
+    expires_at TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_expires_at (expires_at),
+    INDEX idx_revoked_at (revoked_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
 -- 玩家表 - 存储玩家游戏数据
 CREATE TABLE IF NOT EXISTS players (
     id BIGINT PRIMARY KEY AUTO_INCREMENT,
@@ -36,15 +105,42 @@ CREATE TABLE IF NOT EXISTS players (
     -- 预留字段用于将来的游戏功能
     total_playtime BIGINT DEFAULT 0,
     login_count INT DEFAULT 0,
+    -- 多角色：username 为所属账号，删除角色先进入冷静期
+    -- name 为全服唯一的显示名称，公开展示时不使用 username
+    name VARCHAR(32) NOT NULL,
+    name_changed_at TIMESTAMP NULL,
+    aptitude JSON,
+    aptitude_rerolls INT DEFAULT 0,
+    last_selected_at TIMESTAMP NULL,
+    delete_scheduled_at TIMESTAMP NULL,
+    is_deleted BOOLEAN DEFAULT FALSE,
     INDEX idx_player_id (player_id),
     INDEX idx_username (username),
     INDEX idx_level (level),
     INDEX idx_exp (exp),
     INDEX idx_is_online (is_online),
-    INDEX idx_last_save_time (last_save_time)
+    INDEX idx_delete_scheduled_at (delete_scheduled_at),
+    INDEX idx_is_deleted (is_deleted),
+    INDEX idx_last_save_time (last_save_time),
+    UNIQUE INDEX idx_players_name (name)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
--- 游戏进度表 - 为将来的游戏功能预留
+-- 改名记录表
+CREATE TABLE IF NOT EXISTS name_history (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    player_id VARCHAR(64) NOT NULL,
+    username VARCHAR(50) DEFAULT '',
+    old_name VARCHAR(32) DEFAULT '',
+    new_name VARCHAR(32) DEFAULT '',
+    cost BIGINT DEFAULT 0,
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_player_id (player_id),
+    INDEX idx_username (username),
+    INDEX idx_new_name (new_name),
+    INDEX idx_changed_at (changed_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 游戏进度表 - 成就解锁记录与累计计数等，由 Game 服务通过 Persist 读写
 CREATE TABLE IF NOT EXISTS game_progress (
     id BIGINT PRIMARY KEY AUTO_INCREMENT,
     player_id VARCHAR(64) NOT NULL,
@@ -59,13 +155,160 @@ CREATE TABLE IF NOT EXISTS game_progress (
     FOREIGN KEY (player_id) REFERENCES players(player_id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
+-- 玩家邮件表 - 附件（物品与货币）由 Game 服务领取，claimed_at 非空表示已领取
+CREATE TABLE IF NOT EXISTS mails (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    mail_id VARCHAR(64) NOT NULL UNIQUE,
+    player_id VARCHAR(64) NOT NULL,
+    sender VARCHAR(32) DEFAULT '',
+    subject VARCHAR(64) DEFAULT '',
+    body VARCHAR(512) DEFAULT '',
+    attachments JSON,
+    expires_at TIMESTAMP NOT NULL,
+    claimed_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_player_id (player_id),
+    INDEX idx_expires_at (expires_at),
+    INDEX idx_created_at (created_at),
+    FOREIGN KEY (player_id) REFERENCES players(player_id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 商店购买记录表 - 同一玩家的 request_id 唯一，重试的购买请求不会重复记录
+CREATE TABLE IF NOT EXISTS purchase_history (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    player_id VARCHAR(64) NOT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    shop_id VARCHAR(64) DEFAULT '',
+    offer_id VARCHAR(64) DEFAULT '',
+    item_id VARCHAR(64) DEFAULT '',
+    count INT DEFAULT 0,
+    price JSON,
+    purchased_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_purchase (player_id, request_id),
+    INDEX idx_shop_id (shop_id),
+    INDEX idx_purchased_at (purchased_at),
+    FOREIGN KEY (player_id) REFERENCES players(player_id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 玩家市场挂单表（挂单物品与最高出价处于托管状态，直到成交、撤单或到期）
+CREATE TABLE IF NOT EXISTS market_listings (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    listing_id VARCHAR(64) UNIQUE NOT NULL,
+    seller_id VARCHAR(64) NOT NULL,
+    item_id VARCHAR(64) NOT NULL,
+    item_type VARCHAR(32) DEFAULT '',
+    count INT NOT NULL,
+    currency VARCHAR(32) NOT NULL,
+    buyout_price BIGINT DEFAULT 0,
+    start_price BIGINT DEFAULT 0,
+    current_bid BIGINT DEFAULT 0,
+    bidder_id VARCHAR(64) DEFAULT '',
+    buyer_id VARCHAR(64) DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    expires_at TIMESTAMP NULL,
+    closed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_seller_id (seller_id),
+    INDEX idx_item_id (item_id),
+    INDEX idx_item_type (item_type),
+    INDEX idx_buyout_price (buyout_price),
+    INDEX idx_bidder_id (bidder_id),
+    INDEX idx_status (status),
+    INDEX idx_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 玩家市场出价表（bid_id 由 Game 生成，重复提交同一出价时返回原结果）
+CREATE TABLE IF NOT EXISTS market_bids (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    bid_id VARCHAR(64) UNIQUE NOT NULL,
+    listing_id VARCHAR(64) NOT NULL,
+    bidder_id VARCHAR(64) NOT NULL,
+    amount BIGINT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_listing_id (listing_id),
+    INDEX idx_bidder_id (bidder_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 玩家市场成交记录表（trade_id 唯一，每笔交易只执行一次）
+CREATE TABLE IF NOT EXISTS market_trades (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    trade_id VARCHAR(64) UNIQUE NOT NULL,
+    listing_id VARCHAR(64) NOT NULL,
+    seller_id VARCHAR(64) DEFAULT '',
+    buyer_id VARCHAR(64) DEFAULT '',
+    item_id VARCHAR(64) DEFAULT '',
+    count INT DEFAULT 0,
+    currency VARCHAR(32) DEFAULT '',
+    price BIGINT DEFAULT 0,
+    fee BIGINT DEFAULT 0,
+    kind VARCHAR(16) DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_listing_id (listing_id),
+    INDEX idx_seller_id (seller_id),
+    INDEX idx_buyer_id (buyer_id),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 宗门表（sect_id 由 Game 生成，名称唯一）
+CREATE TABLE IF NOT EXISTS sects (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    sect_id VARCHAR(64) UNIQUE NOT NULL,
+    name VARCHAR(64) UNIQUE NOT NULL,
+    leader_id VARCHAR(64) NOT NULL,
+    level INT DEFAULT 1,
+    exp BIGINT DEFAULT 0,
+    treasury BIGINT DEFAULT 0,
+    notice VARCHAR(512) DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_leader_id (leader_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 宗门成员表（每个玩家最多加入一个宗门）
+CREATE TABLE IF NOT EXISTS sect_members (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    sect_id VARCHAR(64) NOT NULL,
+    player_id VARCHAR(64) UNIQUE NOT NULL,
+    `rank` VARCHAR(16) NOT NULL,
+    contribution BIGINT DEFAULT 0,
+    joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_sect_id (sect_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 宗门入门申请表
+CREATE TABLE IF NOT EXISTS sect_applications (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    sect_id VARCHAR(64) NOT NULL,
+    player_id VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_sect_application (sect_id, player_id),
+    INDEX idx_player_id (player_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 宗门宝库流水表（op_id 唯一，重复提交同一捐献或拨发时不重复记账）
+CREATE TABLE IF NOT EXISTS sect_treasury_logs (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    op_id VARCHAR(64) UNIQUE NOT NULL,
+    sect_id VARCHAR(64) NOT NULL,
+    player_id VARCHAR(64) DEFAULT '',
+    target_id VARCHAR(64) DEFAULT '',
+    kind VARCHAR(16) NOT NULL,
+    amount BIGINT DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_sect_id (sect_id),
+    INDEX idx_player_id (player_id),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
 -- 创建示例用户（开发测试用）
 -- 注意：这里的密码是 'password123' 的 bcrypt 哈希值
 INSERT IGNORE INTO users (username, password_hash, player_id) VALUES
 ('admin', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'player_admin_001'),
 ('testuser', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'player_test_001');
 
--- 为示例用户创建对应的玩家记录
-INSERT IGNORE INTO players (player_id, username, game_data) VALUES
-('player_admin_001', 'admin', '{"rank": "admin", "privileges": ["all"]}'),
-('player_test_001', 'testuser', '{"rank": "user", "tutorial_completed": true}');
\ No newline at end of file
+-- 为示例用户创建对应的玩家记录（name 全服唯一，每行需不同的显示名称）
+INSERT IGNORE INTO players (player_id, username, name, game_data) VALUES
+('player_admin_001', 'admin', 'Player_admin001', '{"rank": "admin", "privileges": ["all"]}'),
+('player_test_001', 'testuser', 'Player_test001', '{"rank": "user", "tutorial_completed": true}');
\ No newline at end of file
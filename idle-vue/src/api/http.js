@@ -1,7 +1,31 @@
 import axios from 'axios'
 
+// 浏览器使用 Cookie 会话模式：令牌保存在 HttpOnly Cookie 中，页面脚本不接触令牌
+const CSRF_COOKIE = 'idle_csrf'
+const CSRF_HEADER = 'X-CSRF-Token'
+
 const http = axios.create({
     baseURL: 'http://localhost:8005',  // 修改为Gateway服务端口
     timeout: 5000,
+    withCredentials: true,
+    headers: { 'X-Auth-Mode': 'cookie' },
 })
+
+function readCookie(name) {
+    const match = document.cookie.split('; ').find((item) => item.startsWith(name + '='))
+    return match ? decodeURIComponent(match.slice(name.length + 1)) : ''
+}
+
+// 写操作回传 CSRF Cookie（双重提交校验）
+http.interceptors.request.use((config) => {
+    const method = (config.method || 'get').toLowerCase()
+    if (!['get', 'head', 'options'].includes(method)) {
+        const csrf = readCookie(CSRF_COOKIE)
+        if (csrf) {
+            config.headers[CSRF_HEADER] = csrf
+        }
+    }
+    return config
+})
+
 export default http
@@ -5,23 +5,18 @@ const emitter = mitt()
 let ws = null
 let heartbeatTimer = null
 
+// connectWS 建立 WebSocket 连接
+// 浏览器会在握手时自动携带 HttpOnly 会话 Cookie，网关据此完成认证，URL 中不再携带令牌；
+// 仅在传入 token（非 Cookie 模式的旧客户端）时才发送 C_Login
 export function connectWS(token) {
-    console.log('Connecting WebSocket with token:', token ? 'present' : 'MISSING');
-
-    if (!token) {
-        console.error('No token provided for WebSocket connection');
-        return;
-    }
-
-    const url = `ws://localhost:8005/ws?token=${token}`  // 修改为Gateway服务端口
+    const url = `ws://localhost:8005/ws`  // 修改为Gateway服务端口
     ws = new WebSocket(url)
 
     ws.onopen = () => {
         console.log('[WS] connected')
-        send({ type: 'C_Login', token })
-
-        const player = useUserStore()
-        console.log('WS connected')
+        if (token) {
+            send({ type: 'C_Login', token })
+        }
 
         clearInterval(heartbeatTimer)
         heartbeatTimer = setInterval(() => {
@@ -46,8 +41,10 @@ export function connectWS(token) {
     ws.onclose = () => {
         clearInterval(heartbeatTimer)
         console.warn('[WS] closed, retrying...')
-        const player = useUserStore()
-        console.log('WS closed, retrying...')
+        const user = useUserStore()
+        if (!user.loggedIn) {
+            return
+        }
         setTimeout(() => connectWS(token), 2000)
     }
 }
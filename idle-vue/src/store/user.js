@@ -1,18 +1,19 @@
 import { defineStore } from "pinia";
 
+// 令牌由网关写入 HttpOnly Cookie，前端只记录登录状态
 export const useUserStore = defineStore("user", {
     state: () => ({
-        token: "",
         username: "",
+        loggedIn: false,
     }),
     actions: {
-        setUser(name, token) {
+        setUser(name) {
             this.username = name;
-            this.token = token;
+            this.loggedIn = true;
         },
         logout() {
             this.username = "";
-            this.token = "";
+            this.loggedIn = false;
         }
     }
 });
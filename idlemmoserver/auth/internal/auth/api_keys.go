@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+// ============ API密钥（服务账号与自动化工具） ============
+// 密钥格式为 ik_<key_id>_<secret>，key_id 公开用于查找，数据库只保存完整密钥的 SHA-256 哈希
+// 密钥本身是高熵随机值，无需使用慢哈希
+
+// createAPIKey 管理员签发API密钥，明文密钥只在此时返回一次
+func (s *Service) createAPIKey(token, name string, scopes []string, rateLimit, expiresInHours int, client *common.ClientInfo) (*common.MsgAPIKeyResult, error) {
+	caller, err := s.requireAdmin(token)
+	if err != nil {
+		return &common.MsgAPIKeyResult{Success: false, Message: err.Error()}, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 {
+		return &common.MsgAPIKeyResult{Success: false, Message: "Name must be 1-64 characters"}, nil
+	}
+
+	scopes, err = normalizeAPIKeyScopes(scopes)
+	if err != nil {
+		return &common.MsgAPIKeyResult{Success: false, Message: err.Error()}, nil
+	}
+
+	if rateLimit == 0 {
+		rateLimit = common.APIKeyDefaultRateLimit
+	}
+	if rateLimit < 0 || rateLimit > common.APIKeyMaxRateLimit {
+		return &common.MsgAPIKeyResult{Success: false, Message: fmt.Sprintf("Rate limit must be 1-%d requests per minute", common.APIKeyMaxRateLimit)}, nil
+	}
+	if expiresInHours < 0 {
+		return &common.MsgAPIKeyResult{Success: false, Message: "Expiry must not be negative"}, nil
+	}
+
+	keyID, key, err := generateAPIKey()
+	if err != nil {
+		log.Printf("Failed to generate API key: %v", err)
+		return nil, fmt.Errorf("api key service error")
+	}
+
+	apiKey := &database.APIKey{
+		KeyID:     keyID,
+		Name:      name,
+		KeyHash:   hashAPIKey(key),
+		Scopes:    strings.Join(scopes, ","),
+		RateLimit: rateLimit,
+		CreatedBy: caller.Username,
+	}
+	if expiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(expiresInHours) * time.Hour)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := s.apiKeyRepo.CreateKey(context.Background(), apiKey); err != nil {
+		log.Printf("Failed to create API key %s: %v", name, err)
+		return nil, fmt.Errorf("api key service error")
+	}
+
+	log.Printf("Auth: API key %s (%s) issued by %s with scopes %v", keyID, name, caller.Username, scopes)
+	s.recordAuthEventBy(common.AuthEventAPIKeyCreate, caller.Username, caller.PlayerID, true, client, keyID, caller.Username)
+
+	info := apiKey.ToAPIKeyInfo()
+	return &common.MsgAPIKeyResult{Success: true, Message: "API key created", Key: key, APIKey: &info}, nil
+}
+
+// listAPIKeys 管理员查看全部API密钥
+func (s *Service) listAPIKeys(token, _ string, _ *common.ClientInfo) (*common.MsgAPIKeyResult, error) {
+	if _, err := s.requireAdmin(token); err != nil {
+		return &common.MsgAPIKeyResult{Success: false, Message: err.Error()}, nil
+	}
+
+	keys, err := s.apiKeyRepo.ListKeys(context.Background())
+	if err != nil {
+		log.Printf("Failed to list API keys: %v", err)
+		return nil, fmt.Errorf("api key service error")
+	}
+
+	infos := make([]common.APIKeyInfo, 0, len(keys))
+	for i := range keys {
+		infos = append(infos, keys[i].ToAPIKeyInfo())
+	}
+	return &common.MsgAPIKeyResult{Success: true, Message: "OK", APIKeys: infos}, nil
+}
+
+// revokeAPIKey 管理员吊销API密钥，立即生效
+func (s *Service) revokeAPIKey(token, keyID string, client *common.ClientInfo) (*common.MsgAPIKeyResult, error) {
+	caller, err := s.requireAdmin(token)
+	if err != nil {
+		return &common.MsgAPIKeyResult{Success: false, Message: err.Error()}, nil
+	}
+
+	if err := s.apiKeyRepo.RevokeKey(context.Background(), keyID); err != nil {
+		if errors.Is(err, database.ErrAPIKeyNotFound) {
+			return &common.MsgAPIKeyResult{Success: false, Message: "API key not found"}, nil
+		}
+		log.Printf("Failed to revoke API key %s: %v", keyID, err)
+		return nil, fmt.Errorf("api key service error")
+	}
+
+	log.Printf("Auth: API key %s revoked by %s", keyID, caller.Username)
+	s.recordAuthEventBy(common.AuthEventAPIKeyRevoke, caller.Username, caller.PlayerID, true, client, keyID, caller.Username)
+	return &common.MsgAPIKeyResult{Success: true, Message: "API key revoked"}, nil
+}
+
+// validateAPIKey 校验密钥、权限范围和每分钟请求数
+func (s *Service) validateAPIKey(key, scope string) (*common.MsgAPIKeyResult, error) {
+	keyID, ok := parseAPIKeyID(key)
+	if !ok {
+		return &common.MsgAPIKeyResult{Success: false, Message: "Invalid API key"}, nil
+	}
+
+	ctx := context.Background()
+	apiKey, err := s.apiKeyRepo.GetKey(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, database.ErrAPIKeyNotFound) {
+			return &common.MsgAPIKeyResult{Success: false, Message: "Invalid API key"}, nil
+		}
+		log.Printf("Failed to load API key %s: %v", keyID, err)
+		return nil, fmt.Errorf("api key service error")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(apiKey.KeyHash)) != 1 {
+		return &common.MsgAPIKeyResult{Success: false, Message: "Invalid API key"}, nil
+	}
+	if apiKey.RevokedAt != nil {
+		return &common.MsgAPIKeyResult{Success: false, Message: "API key has been revoked"}, nil
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		return &common.MsgAPIKeyResult{Success: false, Message: "API key has expired"}, nil
+	}
+	if !apiKey.HasScope(scope) {
+		return &common.MsgAPIKeyResult{Success: false, Message: "API key does not have scope " + scope}, nil
+	}
+
+	count, err := s.redis.IncrAPIKeyUsage(ctx, keyID, time.Now())
+	if err != nil {
+		log.Printf("Failed to update rate limit for API key %s: %v", keyID, err)
+		return nil, fmt.Errorf("api key service error")
+	}
+	if count > int64(apiKey.RateLimit) {
+		return &common.MsgAPIKeyResult{Success: false, Message: common.APIKeyRateLimitedMessage}, nil
+	}
+
+	// 最后使用时间只需分钟精度，避免每个请求都写数据库
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) >= time.Minute {
+		if err := s.apiKeyRepo.MarkUsed(ctx, keyID); err != nil {
+			log.Printf("Failed to mark API key %s as used: %v", keyID, err)
+		}
+	}
+
+	info := apiKey.ToAPIKeyInfo()
+	return &common.MsgAPIKeyResult{
+		Success:   true,
+		Message:   "OK",
+		APIKey:    &info,
+		Remaining: apiKey.RateLimit - int(count),
+	}, nil
+}
+
+// ============ 辅助方法 ============
+
+// requireAdmin 校验令牌属于管理员
+func (s *Service) requireAdmin(token string) (*common.UserData, error) {
+	caller, err := s.userFromToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if caller.Role != common.RoleAdmin {
+		log.Printf("Auth: User %s (role %s) attempted an admin-only operation", caller.Username, caller.Role)
+		return nil, fmt.Errorf("permission denied")
+	}
+
+	return caller, nil
+}
+
+// normalizeAPIKeyScopes 校验并去重权限范围
+func normalizeAPIKeyScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !common.APIKeyScopes[scope] {
+			return nil, fmt.Errorf("unknown scope: %s", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	return normalized, nil
+}
+
+// generateAPIKey 生成密钥，返回公开标识和完整密钥
+func generateAPIKey() (string, string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	keyID := hex.EncodeToString(id)
+	return keyID, common.APIKeyPrefix + keyID + "_" + hex.EncodeToString(secret), nil
+}
+
+// parseAPIKeyID 从完整密钥中解析公开标识
+func parseAPIKeyID(key string) (string, bool) {
+	if !strings.HasPrefix(key, common.APIKeyPrefix) {
+		return "", false
+	}
+	keyID, secret, ok := strings.Cut(strings.TrimPrefix(key, common.APIKeyPrefix), "_")
+	if !ok || keyID == "" || secret == "" {
+		return "", false
+	}
+	return keyID, true
+}
+
+// hashAPIKey 计算密钥的 SHA-256 哈希
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+// ============ 认证审计与账号管理 ============
+
+// recordAuthEvent 记录认证审计事件，写入失败只记录日志不影响主流程
+func (s *Service) recordAuthEvent(eventType, username, playerID string, success bool, client *common.ClientInfo, detail string) {
+	s.recordAuthEventBy(eventType, username, playerID, success, client, detail, "")
+}
+
+// recordAuthEventBy 记录由客服/GM发起的审计事件
+func (s *Service) recordAuthEventBy(eventType, username, playerID string, success bool, client *common.ClientInfo, detail, actor string) {
+	if s.eventRepo == nil {
+		return
+	}
+
+	event := &database.AuthEvent{
+		Username:  username,
+		PlayerID:  playerID,
+		EventType: eventType,
+		Success:   success,
+		Detail:    truncate(detail, 255),
+		Actor:     actor,
+	}
+	if client != nil {
+		event.IP = truncate(client.IP, 64)
+		event.UserAgent = truncate(client.UserAgent, 255)
+	}
+
+	if err := s.eventRepo.RecordEvent(context.Background(), event); err != nil {
+		log.Printf("Failed to record auth event %s for %s: %v", eventType, username, err)
+	}
+}
+
+// completeLogin 登录成功后的收尾：更新最后登录时间并记录审计事件
+func (s *Service) completeLogin(userData *common.UserData, client *common.ClientInfo, detail string) {
+	if err := s.userRepo.UpdateLastLogin(context.Background(), userData.PlayerID); err != nil {
+		log.Printf("Failed to update last login for %s: %v", userData.Username, err)
+	}
+	s.recordAuthEvent(common.AuthEventLoginSuccess, userData.Username, userData.PlayerID, true, client, detail)
+}
+
+// refreshToken 用仍然有效的令牌换取新令牌
+func (s *Service) refreshToken(token string, client *common.ClientInfo) (*common.MsgAccountResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgAccountResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	if userData.Banned {
+		s.recordAuthEvent(common.AuthEventTokenRefresh, userData.Username, userData.PlayerID, false, client, "account banned")
+		return &common.MsgAccountResult{Success: false, Message: "Account is banned"}, nil
+	}
+
+	// 保持令牌绑定的角色，角色已不可用时回到账号默认角色
+	playerID := ""
+	if claims, err := s.parseJWT(token); err == nil {
+		playerID, _ = claims["playerID"].(string)
+	}
+	if playerID != "" {
+		if player, err := s.characterRepo.GetCharacter(context.Background(), userData.Username, playerID); err != nil || player.DeleteScheduledAt != nil {
+			playerID = ""
+		}
+	}
+	if playerID == "" {
+		players, err := s.characterRepo.ListCharacters(context.Background(), userData.Username)
+		if err != nil {
+			log.Printf("Failed to list characters for %s: %v", userData.Username, err)
+			return nil, fmt.Errorf("failed to generate authentication token")
+		}
+		playerID = defaultCharacterID(players)
+	}
+
+	session, err := s.renewSession(token, userData, playerID, client)
+	if err != nil {
+		log.Printf("Failed to renew session for %s: %v", userData.Username, err)
+		return &common.MsgAccountResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	newToken, err := s.generateJWT(userData, playerID, session.SessionID)
+	if err != nil {
+		log.Printf("Failed to generate JWT: %v", err)
+		return nil, fmt.Errorf("failed to generate authentication token")
+	}
+
+	s.recordAuthEvent(common.AuthEventTokenRefresh, userData.Username, userData.PlayerID, true, client, "")
+	return &common.MsgAccountResult{
+		Success: true,
+		Message: "Token refreshed",
+		Token:   newToken,
+	}, nil
+}
+
+// changePassword 校验旧密码后修改密码
+func (s *Service) changePassword(token, oldPassword, newPassword string, client *common.ClientInfo) (*common.MsgAccountResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgAccountResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	if ok, _, _ := database.VerifyPassword(userData.Password, oldPassword); !ok {
+		s.recordAuthEvent(common.AuthEventPasswordChange, userData.Username, userData.PlayerID, false, client, "invalid old password")
+		return &common.MsgAccountResult{Success: false, Message: "Invalid password"}, nil
+	}
+
+	if newPassword == "" {
+		return &common.MsgAccountResult{Success: false, Message: "New password must not be empty"}, nil
+	}
+
+	if err := s.userRepo.UpdatePassword(context.Background(), userData.Username, newPassword); err != nil {
+		log.Printf("Failed to change password for %s: %v", userData.Username, err)
+		return nil, fmt.Errorf("failed to change password")
+	}
+
+	// 密码泄露后修改密码应让其他设备上的会话失效，保留当前会话
+	if _, err := s.revokeUserSessions(userData.Username, s.currentSessionID(token), "password_changed"); err != nil {
+		log.Printf("Failed to revoke sessions after password change for %s: %v", userData.Username, err)
+	}
+
+	log.Printf("Auth: Password changed for user %s", userData.Username)
+	s.recordAuthEvent(common.AuthEventPasswordChange, userData.Username, userData.PlayerID, true, client, "")
+	return &common.MsgAccountResult{Success: true, Message: "Password changed"}, nil
+}
+
+// banUser 客服/GM封禁或解封账号
+func (s *Service) banUser(token, username string, banned bool, reason string, client *common.ClientInfo) (*common.MsgAccountResult, error) {
+	caller, err := s.requireStaff(token)
+	if err != nil {
+		return &common.MsgAccountResult{Success: false, Message: err.Error()}, nil
+	}
+
+	target, err := s.getUserData(username)
+	if err != nil {
+		return &common.MsgAccountResult{Success: false, Message: "User does not exist"}, nil
+	}
+
+	if err := s.userRepo.SetBanned(context.Background(), username, banned, reason); err != nil {
+		log.Printf("Failed to update ban status for %s: %v", username, err)
+		return nil, fmt.Errorf("failed to update ban status")
+	}
+
+	if banned {
+		if _, err := s.revokeUserSessions(username, "", "banned"); err != nil {
+			log.Printf("Failed to revoke sessions of banned user %s: %v", username, err)
+		}
+	}
+
+	eventType := common.AuthEventBan
+	message := "User banned"
+	if !banned {
+		eventType = common.AuthEventUnban
+		message = "User unbanned"
+	}
+
+	log.Printf("Auth: %s changed ban status of %s to %t", caller.Username, username, banned)
+	s.recordAuthEventBy(eventType, username, target.PlayerID, true, client, reason, caller.Username)
+	return &common.MsgAccountResult{Success: true, Message: message}, nil
+}
+
+// queryAuditEvents 客服查询账号的认证事件
+func (s *Service) queryAuditEvents(token string, filters map[string]interface{}) (interface{}, error) {
+	if _, err := s.requireStaff(token); err != nil {
+		return nil, err
+	}
+
+	query, err := parseAuditQuery(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	events, total, err := s.eventRepo.ListEvents(context.Background(), query)
+	if err != nil {
+		log.Printf("Failed to query auth events: %v", err)
+		return nil, fmt.Errorf("failed to query auth events")
+	}
+
+	return map[string]interface{}{
+		"events": events,
+		"total":  total,
+		"limit":  query.Limit,
+		"offset": query.Offset,
+	}, nil
+}
+
+// queryDeviceHistory 客服查询账号的设备和IP历史
+func (s *Service) queryDeviceHistory(token string, filters map[string]interface{}) (interface{}, error) {
+	if _, err := s.requireStaff(token); err != nil {
+		return nil, err
+	}
+
+	query, err := parseAuditQuery(filters)
+	if err != nil {
+		return nil, err
+	}
+	if query.Username == "" && query.PlayerID == "" {
+		return nil, fmt.Errorf("username or player_id is required")
+	}
+
+	devices, err := s.eventRepo.GetDeviceHistory(context.Background(), query)
+	if err != nil {
+		log.Printf("Failed to query device history: %v", err)
+		return nil, fmt.Errorf("failed to query device history")
+	}
+
+	result := map[string]interface{}{
+		"username":  query.Username,
+		"player_id": query.PlayerID,
+		"devices":   devices,
+	}
+
+	// 附带账号的最后登录时间
+	var userData *common.UserData
+	if query.Username != "" {
+		userData, err = s.getUserData(query.Username)
+	} else {
+		userData, err = s.userRepo.GetUserByPlayerID(context.Background(), query.PlayerID)
+	}
+	if err == nil {
+		result["username"] = userData.Username
+		result["player_id"] = userData.PlayerID
+		result["last_login"] = userData.LastLogin
+		result["banned"] = userData.Banned
+	}
+
+	return result, nil
+}
+
+// ============ 辅助方法 ============
+
+// requireStaff 校验令牌属于客服/GM/管理员
+func (s *Service) requireStaff(token string) (*common.UserData, error) {
+	caller, err := s.userFromToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if !common.StaffRoles[caller.Role] {
+		log.Printf("Auth: User %s (role %s) attempted a staff-only operation", caller.Username, caller.Role)
+		return nil, fmt.Errorf("permission denied")
+	}
+
+	return caller, nil
+}
+
+// parseAuditQuery 解析审计查询条件
+func parseAuditQuery(filters map[string]interface{}) (database.AuthEventQuery, error) {
+	query := database.AuthEventQuery{}
+
+	query.Username, _ = filters["username"].(string)
+	query.PlayerID, _ = filters["player_id"].(string)
+	query.EventType, _ = filters["event_type"].(string)
+
+	if since, ok := filters["since"].(string); ok && since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return query, fmt.Errorf("invalid since: %w", err)
+		}
+		query.Since = t
+	}
+	if until, ok := filters["until"].(string); ok && until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return query, fmt.Errorf("invalid until: %w", err)
+		}
+		query.Until = t
+	}
+
+	if limit, ok := filters["limit"].(float64); ok {
+		query.Limit = int(limit)
+	}
+	if offset, ok := filters["offset"].(float64); ok && offset > 0 {
+		query.Offset = int(offset)
+	}
+
+	return query, nil
+}
+
+// truncate 截断超出数据库字段长度的字符串
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	return value[:max]
+}
@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+// ============ 多角色管理 ============
+// 令牌中的 playerID 为当前选择的角色，username 为账号
+
+// listCharacters 获取账号下的角色列表
+func (s *Service) listCharacters(token, _ string, _ *common.ClientInfo) (*common.MsgCharacterResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	characters, err := s.characterInfos(userData.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &common.MsgCharacterResult{
+		Success:    true,
+		Message:    "OK",
+		Characters: characters,
+		MaxSlots:   characterSlots(userData),
+	}, nil
+}
+
+// createCharacter 在账号下创建新角色
+func (s *Service) createCharacter(token, name string, client *common.ClientInfo) (*common.MsgCharacterResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if err := validateDisplayName(name, userData.Username); err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: err.Error()}, nil
+	}
+
+	player, err := s.characterRepo.CreateCharacter(context.Background(), userData.Username, name, characterSlots(userData))
+	if err != nil {
+		if errors.Is(err, database.ErrCharacterSlotsFull) {
+			return &common.MsgCharacterResult{Success: false, Message: "Character slots are full"}, nil
+		}
+		if errors.Is(err, database.ErrDisplayNameTaken) {
+			return &common.MsgCharacterResult{Success: false, Message: "Display name is already taken"}, nil
+		}
+		log.Printf("Failed to create character for %s: %v", userData.Username, err)
+		return nil, fmt.Errorf("failed to create character")
+	}
+
+	s.recordAuthEvent(common.AuthEventCharacterCreate, userData.Username, player.PlayerID, true, client, name)
+	return s.characterResult(userData, player, "Character created")
+}
+
+// deleteCharacter 删除角色，进入冷静期后才真正删除
+func (s *Service) deleteCharacter(token, playerID string, client *common.ClientInfo) (*common.MsgCharacterResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	ctx := context.Background()
+	player, err := s.characterRepo.GetCharacter(ctx, userData.Username, playerID)
+	if err != nil {
+		return s.characterError(err)
+	}
+	if player.DeleteScheduledAt != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Character is already pending deletion"}, nil
+	}
+
+	deleteAt := time.Now().Add(common.CharacterDeleteGraceHours * time.Hour)
+	if err := s.characterRepo.ScheduleDeletion(ctx, userData.Username, playerID, deleteAt); err != nil {
+		return s.characterError(err)
+	}
+	player.DeleteScheduledAt = &deleteAt
+
+	log.Printf("Auth: Character %s of %s scheduled for deletion at %s", playerID, userData.Username, deleteAt.Format(time.RFC3339))
+	s.recordAuthEvent(common.AuthEventCharacterDelete, userData.Username, playerID, true, client, "")
+	return s.characterResult(userData, player, "Character scheduled for deletion")
+}
+
+// restoreCharacter 冷静期内撤销删除
+func (s *Service) restoreCharacter(token, playerID string, client *common.ClientInfo) (*common.MsgCharacterResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	ctx := context.Background()
+	player, err := s.characterRepo.GetCharacter(ctx, userData.Username, playerID)
+	if err != nil {
+		return s.characterError(err)
+	}
+	if player.DeleteScheduledAt == nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Character is not pending deletion"}, nil
+	}
+
+	if err := s.characterRepo.CancelDeletion(ctx, userData.Username, playerID); err != nil {
+		return s.characterError(err)
+	}
+	player.DeleteScheduledAt = nil
+
+	s.recordAuthEvent(common.AuthEventCharacterRestore, userData.Username, playerID, true, client, "")
+	return s.characterResult(userData, player, "Character restored")
+}
+
+// selectCharacter 选择角色，返回绑定到该角色的新令牌
+func (s *Service) selectCharacter(token, playerID string, client *common.ClientInfo) (*common.MsgCharacterResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Invalid token"}, nil
+	}
+	if userData.Banned {
+		return &common.MsgCharacterResult{Success: false, Message: "Account is banned"}, nil
+	}
+
+	ctx := context.Background()
+	player, err := s.characterRepo.GetCharacter(ctx, userData.Username, playerID)
+	if err != nil {
+		return s.characterError(err)
+	}
+	if player.DeleteScheduledAt != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Character is pending deletion"}, nil
+	}
+
+	if err := s.characterRepo.MarkSelected(ctx, userData.Username, playerID); err != nil {
+		log.Printf("Failed to mark character %s as selected: %v", playerID, err)
+	}
+
+	session, err := s.renewSession(token, userData, playerID, client)
+	if err != nil {
+		log.Printf("Failed to renew session for %s: %v", userData.Username, err)
+		return &common.MsgCharacterResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	newToken, err := s.generateJWT(userData, playerID, session.SessionID)
+	if err != nil {
+		log.Printf("Failed to generate JWT: %v", err)
+		return nil, fmt.Errorf("failed to generate authentication token")
+	}
+
+	result, err := s.characterResult(userData, player, "Character selected")
+	if err != nil {
+		return nil, err
+	}
+	result.Token = newToken
+	return result, nil
+}
+
+// rerollAptitude 重新随机角色的灵根资质，次数有限且仅限尚未开始修炼的角色
+func (s *Service) rerollAptitude(token, playerID string, client *common.ClientInfo) (*common.MsgCharacterResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	player, err := s.characterRepo.RerollAptitude(context.Background(), userData.Username, playerID)
+	if err != nil {
+		if errors.Is(err, database.ErrNoRerollsLeft) {
+			return &common.MsgCharacterResult{Success: false, Message: "No aptitude rerolls left"}, nil
+		}
+		return s.characterError(err)
+	}
+
+	aptitude := player.GetAptitude()
+	detail := ""
+	if aptitude != nil {
+		detail = aptitude.Grade
+		s.syncAptitude(playerID, aptitude)
+	}
+	s.recordAuthEvent(common.AuthEventAptitudeReroll, userData.Username, playerID, true, client, detail)
+	return s.characterResult(userData, player, "Aptitude rerolled")
+}
+
+// syncAptitude 通知 Game 更新玩家 Actor 持有的资质，否则在线 Actor 继续使用旧资质并在保存时写回缓存
+func (s *Service) syncAptitude(playerID string, aptitude *common.Aptitude) {
+	req := map[string]interface{}{
+		"type":      "C_PlayerAptitude",
+		"player_id": playerID,
+		"aptitude":  aptitude,
+	}
+
+	var response struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	err := s.natsManager.RequestWithReply(common.GamePlayerAptitudeSubject, req, &response, common.PlayerActorServiceTimeout*time.Second)
+	if err == nil && !response.Success {
+		err = errors.New(response.Error)
+	}
+	if err != nil {
+		log.Printf("Failed to sync aptitude of %s to game service: %v", playerID, err)
+	}
+}
+
+// validateToken 校验令牌及其绑定的角色，供 Gateway 绑定 WebSocket 连接
+func (s *Service) validateToken(token string) (*common.MsgVerifyTokenResult, error) {
+	claims, err := s.parseJWT(token)
+	if err != nil {
+		return &common.MsgVerifyTokenResult{Success: false, Error: "Invalid token"}, nil
+	}
+
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgVerifyTokenResult{Success: false, Error: "Invalid token"}, nil
+	}
+	if userData.Banned {
+		return &common.MsgVerifyTokenResult{Success: false, Error: "Account is banned"}, nil
+	}
+
+	playerID, _ := claims["playerID"].(string)
+	if playerID == "" {
+		return &common.MsgVerifyTokenResult{Success: false, Error: "No character selected"}, nil
+	}
+
+	player, err := s.characterRepo.GetCharacter(context.Background(), userData.Username, playerID)
+	if err != nil || player.DeleteScheduledAt != nil {
+		return &common.MsgVerifyTokenResult{Success: false, Error: "Character is not available"}, nil
+	}
+
+	return &common.MsgVerifyTokenResult{
+		Success:   true,
+		PlayerID:  playerID,
+		Username:  userData.Username,
+		Role:      userData.Role,
+		SessionID: sessionIDFromClaims(claims),
+		Name:      player.Name,
+	}, nil
+}
+
+// issueLoginToken 登录成功后为当前设备创建会话并签发令牌，默认进入最近选择的角色
+func (s *Service) issueLoginToken(userData *common.UserData, client *common.ClientInfo) (*common.MsgAuthenticateUserResult, error) {
+	players, err := s.characterRepo.ListCharacters(context.Background(), userData.Username)
+	if err != nil {
+		log.Printf("Failed to list characters for %s: %v", userData.Username, err)
+		return nil, fmt.Errorf("authentication service error")
+	}
+
+	playerID := defaultCharacterID(players)
+
+	session, err := s.createSession(userData, playerID, client)
+	if err != nil {
+		log.Printf("Failed to create session for %s: %v", userData.Username, err)
+		return nil, fmt.Errorf("authentication service error")
+	}
+
+	log.Printf("Auth: Generating JWT for %s (PlayerID: %s)", userData.Username, playerID)
+	token, err := s.generateJWT(userData, playerID, session.SessionID)
+	if err != nil {
+		log.Printf("Failed to generate JWT: %v", err)
+		return nil, fmt.Errorf("failed to generate authentication token")
+	}
+
+	characters := make([]common.CharacterInfo, 0, len(players))
+	for i := range players {
+		characters = append(characters, players[i].ToCharacterInfo())
+	}
+
+	return &common.MsgAuthenticateUserResult{
+		Success:    true,
+		Message:    "Login successful",
+		PlayerID:   playerID,
+		Token:      token,
+		Characters: characters,
+	}, nil
+}
+
+// ============ 辅助方法 ============
+
+// characterResult 构造包含最新角色列表的结果
+func (s *Service) characterResult(userData *common.UserData, player *database.Player, message string) (*common.MsgCharacterResult, error) {
+	characters, err := s.characterInfos(userData.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	info := player.ToCharacterInfo()
+	return &common.MsgCharacterResult{
+		Success:    true,
+		Message:    message,
+		Characters: characters,
+		Character:  &info,
+		MaxSlots:   characterSlots(userData),
+	}, nil
+}
+
+// characterError 将仓库错误转换为角色操作结果
+func (s *Service) characterError(err error) (*common.MsgCharacterResult, error) {
+	if errors.Is(err, database.ErrCharacterNotFound) {
+		return &common.MsgCharacterResult{Success: false, Message: "Character not found"}, nil
+	}
+	log.Printf("Character operation failed: %v", err)
+	return nil, fmt.Errorf("character service error")
+}
+
+// characterInfos 获取账号下的角色摘要列表
+func (s *Service) characterInfos(username string) ([]common.CharacterInfo, error) {
+	players, err := s.characterRepo.ListCharacters(context.Background(), username)
+	if err != nil {
+		log.Printf("Failed to list characters for %s: %v", username, err)
+		return nil, fmt.Errorf("character service error")
+	}
+
+	characters := make([]common.CharacterInfo, 0, len(players))
+	for i := range players {
+		characters = append(characters, players[i].ToCharacterInfo())
+	}
+	return characters, nil
+}
+
+// characterSlots 账号可用的角色栏位数
+func characterSlots(userData *common.UserData) int {
+	slots := userData.CharacterSlots
+	if slots <= 0 {
+		slots = common.DefaultCharacterSlots
+	}
+	if slots > common.MaxCharacterSlots {
+		slots = common.MaxCharacterSlots
+	}
+	return slots
+}
+
+// defaultCharacterID 选出登录时默认进入的角色：最近选择过的角色，否则最早创建的角色
+// 处于删除冷静期的角色不会被选中；没有可用角色时返回空字符串
+func defaultCharacterID(players []database.Player) string {
+	var selected *database.Player
+	for i := range players {
+		player := &players[i]
+		if player.DeleteScheduledAt != nil {
+			continue
+		}
+		if selected == nil {
+			selected = player
+			continue
+		}
+		if player.LastSelectedAt != nil && (selected.LastSelectedAt == nil || player.LastSelectedAt.After(*selected.LastSelectedAt)) {
+			selected = player
+		}
+	}
+
+	if selected == nil {
+		return ""
+	}
+	return selected.PlayerID
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+// ============ 数据导出与删除（被遗忘权） ============
+// Auth 负责校验身份并登记请求，实际的导出与删除由 Persist 在请求到期后执行
+
+// requestDataExport 申请导出账号数据
+func (s *Service) requestDataExport(token, _ string, client *common.ClientInfo) (*common.MsgDataRequestResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgDataRequestResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	ctx := context.Background()
+
+	// 已有未完成的导出请求时直接返回，避免重复生成档案
+	active, err := s.dataRequestRepo.FindActiveRequest(ctx, userData.Username, common.DataRequestTypeExport)
+	if err != nil {
+		log.Printf("Failed to check data export requests for %s: %v", userData.Username, err)
+		return nil, fmt.Errorf("data request service error")
+	}
+	if active != nil {
+		info := active.ToDataRequestInfo()
+		return &common.MsgDataRequestResult{Success: true, Message: "Export already in progress", Request: &info}, nil
+	}
+
+	request, err := s.createDataRequest(ctx, userData.Username, common.DataRequestTypeExport, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyPersistDataRequests()
+	s.recordAuthEvent(common.AuthEventDataExport, userData.Username, userData.PlayerID, true, client, request.RequestID)
+
+	info := request.ToDataRequestInfo()
+	return &common.MsgDataRequestResult{Success: true, Message: "Export requested", Request: &info}, nil
+}
+
+// requestDataErasure 申请删除账号，冷静期结束后删除全部数据
+func (s *Service) requestDataErasure(token, password string, client *common.ClientInfo) (*common.MsgDataRequestResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgDataRequestResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	// 删除账号需要再次确认密码
+	if ok, _, _ := database.VerifyPassword(userData.Password, password); !ok {
+		s.recordAuthEvent(common.AuthEventDataErasure, userData.Username, userData.PlayerID, false, client, "invalid password")
+		return &common.MsgDataRequestResult{Success: false, Message: "Invalid password"}, nil
+	}
+
+	ctx := context.Background()
+
+	active, err := s.dataRequestRepo.FindActiveRequest(ctx, userData.Username, common.DataRequestTypeErasure)
+	if err != nil {
+		log.Printf("Failed to check data erasure requests for %s: %v", userData.Username, err)
+		return nil, fmt.Errorf("data request service error")
+	}
+	if active != nil {
+		info := active.ToDataRequestInfo()
+		return &common.MsgDataRequestResult{Success: true, Message: "Erasure already scheduled", Request: &info}, nil
+	}
+
+	scheduledAt := time.Now().Add(common.ErasureCoolingOffHours * time.Hour)
+	request, err := s.createDataRequest(ctx, userData.Username, common.DataRequestTypeErasure, scheduledAt)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Auth: Account erasure scheduled for %s at %s", userData.Username, scheduledAt.Format(time.RFC3339))
+	s.recordAuthEvent(common.AuthEventDataErasure, userData.Username, userData.PlayerID, true, client, request.RequestID)
+
+	info := request.ToDataRequestInfo()
+	return &common.MsgDataRequestResult{Success: true, Message: "Erasure scheduled", Request: &info}, nil
+}
+
+// cancelDataErasure 冷静期内撤销删除
+func (s *Service) cancelDataErasure(token, requestID string, client *common.ClientInfo) (*common.MsgDataRequestResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgDataRequestResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	ctx := context.Background()
+	if err := s.dataRequestRepo.CancelRequest(ctx, userData.Username, requestID); err != nil {
+		if errors.Is(err, database.ErrDataRequestNotCancellable) {
+			return &common.MsgDataRequestResult{Success: false, Message: "Request cannot be cancelled"}, nil
+		}
+		log.Printf("Failed to cancel data request %s: %v", requestID, err)
+		return nil, fmt.Errorf("data request service error")
+	}
+
+	s.recordAuthEvent(common.AuthEventDataErasureCancel, userData.Username, userData.PlayerID, true, client, requestID)
+
+	request, err := s.dataRequestRepo.GetRequest(ctx, userData.Username, requestID)
+	if err != nil {
+		return &common.MsgDataRequestResult{Success: true, Message: "Erasure cancelled"}, nil
+	}
+	info := request.ToDataRequestInfo()
+	return &common.MsgDataRequestResult{Success: true, Message: "Erasure cancelled", Request: &info}, nil
+}
+
+// dataRequestStatus 查询请求状态；不带 requestID 时返回账号的全部请求
+// 已完成的导出请求会附带完整档案
+func (s *Service) dataRequestStatus(token, requestID string, _ *common.ClientInfo) (*common.MsgDataRequestResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgDataRequestResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	ctx := context.Background()
+
+	if requestID == "" {
+		requests, err := s.dataRequestRepo.ListRequests(ctx, userData.Username)
+		if err != nil {
+			log.Printf("Failed to list data requests for %s: %v", userData.Username, err)
+			return nil, fmt.Errorf("data request service error")
+		}
+
+		infos := make([]common.DataRequestInfo, 0, len(requests))
+		for i := range requests {
+			infos = append(infos, requests[i].ToDataRequestInfo())
+		}
+		return &common.MsgDataRequestResult{Success: true, Message: "OK", Requests: infos}, nil
+	}
+
+	request, err := s.dataRequestRepo.GetRequest(ctx, userData.Username, requestID)
+	if err != nil {
+		if errors.Is(err, database.ErrDataRequestNotFound) {
+			return &common.MsgDataRequestResult{Success: false, Message: "Request not found"}, nil
+		}
+		log.Printf("Failed to get data request %s: %v", requestID, err)
+		return nil, fmt.Errorf("data request service error")
+	}
+
+	info := request.ToDataRequestInfo()
+	result := &common.MsgDataRequestResult{Success: true, Message: "OK", Request: &info}
+	if request.Type == common.DataRequestTypeExport && request.Status == common.DataRequestStatusCompleted {
+		if request.Archive == "" {
+			result.Message = "Export archive has expired"
+		} else {
+			result.Archive = json.RawMessage(request.Archive)
+		}
+	}
+	return result, nil
+}
+
+// ============ 辅助方法 ============
+
+// createDataRequest 登记数据请求
+func (s *Service) createDataRequest(ctx context.Context, username, requestType string, scheduledAt time.Time) (*database.DataRequest, error) {
+	requestID, err := generateDataRequestID()
+	if err != nil {
+		log.Printf("Failed to generate data request ID: %v", err)
+		return nil, fmt.Errorf("data request service error")
+	}
+
+	request := &database.DataRequest{
+		RequestID:   requestID,
+		Username:    username,
+		Type:        requestType,
+		RequestedBy: username,
+		ScheduledAt: scheduledAt,
+	}
+	if err := s.dataRequestRepo.CreateRequest(ctx, request); err != nil {
+		log.Printf("Failed to create %s request for %s: %v", requestType, username, err)
+		return nil, fmt.Errorf("data request service error")
+	}
+	return request, nil
+}
+
+// notifyPersistDataRequests 通知 Persist 立即处理到期请求，失败时等待其定时扫描
+func (s *Service) notifyPersistDataRequests() {
+	msg := map[string]interface{}{
+		"type": "C_ProcessDataRequests",
+	}
+	if err := s.natsManager.Publish(common.PersistDataRequestSubject, msg); err != nil {
+		log.Printf("Failed to notify persist service of data request: %v", err)
+	}
+}
+
+// generateDataRequestID 生成请求ID
+func generateDataRequestID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "dr_" + hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+// ============ 显示名称 ============
+// 角色名即显示名称，全服唯一，与登录用户名分离；排行榜、聊天和公开资料只使用显示名称
+
+// renameCharacter 修改角色显示名称：首次免费，之后受冷却时间限制并消耗金币。
+// 费用经 Game 由玩家 Actor 扣除并确认存档写入后才提交新名称，提交失败时按同一代扣ID退还
+func (s *Service) renameCharacter(token, playerID, name string, client *common.ClientInfo) (*common.MsgCharacterResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if err := validateDisplayName(name, userData.Username); err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: err.Error()}, nil
+	}
+
+	ctx := context.Background()
+	player, err := s.characterRepo.GetCharacter(ctx, userData.Username, playerID)
+	if err != nil {
+		return s.characterError(err)
+	}
+	if player.DeleteScheduledAt != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Character is pending deletion"}, nil
+	}
+	if player.Name == name {
+		return &common.MsgCharacterResult{Success: false, Message: "Display name is unchanged"}, nil
+	}
+	oldName := player.Name
+
+	// 先检查冷却与名称占用，避免收费后才失败；提交时在行锁内再次检查
+	cooldown := common.DisplayNameRenameCooldownHours * time.Hour
+	var cost int64
+	if player.NameChangedAt != nil {
+		if time.Since(*player.NameChangedAt) < cooldown {
+			return &common.MsgCharacterResult{Success: false, Message: "Display name was changed recently, try again later"}, nil
+		}
+		cost = common.DisplayNameRenameCost
+	}
+	taken, err := s.characterRepo.DisplayNameTaken(ctx, name, playerID)
+	if err != nil {
+		return s.characterError(err)
+	}
+	if taken {
+		return &common.MsgCharacterResult{Success: false, Message: "Display name is already taken"}, nil
+	}
+
+	var chargeID string
+	if cost > 0 {
+		if chargeID, err = generateChargeID(); err != nil {
+			return s.characterError(err)
+		}
+		charged, err := s.chargePlayer(common.PlayerCharge{
+			PlayerID: playerID,
+			ChargeID: chargeID,
+			Resource: common.DisplayNameRenameCurrency,
+			Amount:   cost,
+			Source:   common.CurrencySourceRename,
+		})
+		if err != nil {
+			// 超时时扣费可能已执行，退还后再报告失败
+			s.refundRenameFee(playerID, chargeID)
+			log.Printf("Failed to charge rename fee for %s: %v", playerID, err)
+			return nil, fmt.Errorf("character service error")
+		}
+		if !charged.Success {
+			if charged.Insufficient {
+				return &common.MsgCharacterResult{Success: false, Message: fmt.Sprintf("Renaming costs %d gold", cost)}, nil
+			}
+			log.Printf("Failed to charge rename fee for %s: %s", playerID, charged.Message)
+			return nil, fmt.Errorf("character service error")
+		}
+	}
+
+	player, err = s.characterRepo.RenameCharacter(ctx, userData.Username, playerID, name, cost, cooldown)
+	if err != nil {
+		if cost > 0 {
+			s.refundRenameFee(playerID, chargeID)
+		}
+		switch {
+		case errors.Is(err, database.ErrDisplayNameTaken):
+			return &common.MsgCharacterResult{Success: false, Message: "Display name is already taken"}, nil
+		case errors.Is(err, database.ErrRenameCooldown):
+			return &common.MsgCharacterResult{Success: false, Message: "Display name was changed recently, try again later"}, nil
+		}
+		return s.characterError(err)
+	}
+
+	s.recordAuthEvent(common.AuthEventCharacterRename, userData.Username, playerID, true, client,
+		fmt.Sprintf("%s -> %s (cost %d)", oldName, name, cost))
+	return s.characterResult(userData, player, "Display name changed")
+}
+
+// chargePlayer 请求 Game 由玩家 Actor 扣除或退还货币，扣费在存档确认写入后才回复成功
+func (s *Service) chargePlayer(charge common.PlayerCharge) (*common.MsgPlayerChargeResult, error) {
+	req := map[string]interface{}{
+		"type":   "C_PlayerCharge",
+		"charge": charge,
+	}
+
+	var response struct {
+		Success bool                         `json:"success"`
+		Error   string                       `json:"error"`
+		Data    common.MsgPlayerChargeResult `json:"data"`
+	}
+	if err := s.natsManager.RequestWithReply(common.GamePlayerChargeSubject, req, &response, common.PlayerActorServiceTimeout*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to request charge: %w", err)
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("charge failed: %s", response.Error)
+	}
+	return &response.Data, nil
+}
+
+// refundRenameFee 改名未能提交时退还费用，退还失败时记录日志以便人工补偿
+func (s *Service) refundRenameFee(playerID, chargeID string) {
+	result, err := s.chargePlayer(common.PlayerCharge{PlayerID: playerID, ChargeID: chargeID, Refund: true})
+	if err == nil && !result.Success {
+		err = errors.New(result.Message)
+	}
+	if err != nil {
+		log.Printf("Failed to refund rename fee %s for %s: %v", chargeID, playerID, err)
+	}
+}
+
+// generateChargeID 生成改名费用的代扣ID
+func generateChargeID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "rn_" + hex.EncodeToString(bytes), nil
+}
+
+// characterNames 查询角色的改名记录
+func (s *Service) characterNames(token, playerID string, _ *common.ClientInfo) (*common.MsgCharacterResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgCharacterResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	ctx := context.Background()
+	player, err := s.characterRepo.GetCharacter(ctx, userData.Username, playerID)
+	if err != nil {
+		return s.characterError(err)
+	}
+
+	history, err := s.characterRepo.ListNameHistory(ctx, playerID, common.DisplayNameHistoryLimit)
+	if err != nil {
+		log.Printf("Failed to list name history for %s: %v", playerID, err)
+		return nil, fmt.Errorf("character service error")
+	}
+
+	result, err := s.characterResult(userData, player, "OK")
+	if err != nil {
+		return nil, err
+	}
+	result.Names = make([]common.NameChange, 0, len(history))
+	for i := range history {
+		result.Names = append(result.Names, history[i].ToNameChange())
+	}
+	return result, nil
+}
+
+// validateDisplayName 校验显示名称：长度限制，只允许汉字、英文字母、数字和下划线，
+// 不能与登录用户名相同，不能包含屏蔽词
+func validateDisplayName(name, username string) error {
+	length := utf8.RuneCountInString(name)
+	if length < common.CharacterNameMinLength || length > common.CharacterNameMaxLength {
+		return fmt.Errorf("display name must be %d-%d characters", common.CharacterNameMinLength, common.CharacterNameMaxLength)
+	}
+
+	for _, r := range name {
+		if !unicode.Is(unicode.Han, r) && !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))) && r != '_' {
+			return fmt.Errorf("display name contains invalid characters")
+		}
+	}
+	if strings.HasPrefix(name, "_") || strings.HasSuffix(name, "_") {
+		return fmt.Errorf("display name cannot start or end with an underscore")
+	}
+	if strings.EqualFold(name, username) {
+		return fmt.Errorf("display name cannot be the same as your username")
+	}
+	if strings.HasPrefix(strings.ToLower(name), strings.ToLower(common.DisplayNamePlaceholderPrefix)) {
+		return fmt.Errorf("display name is reserved")
+	}
+
+	normalized := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	for _, word := range common.DisplayNameBlockedWords {
+		if strings.Contains(normalized, word) {
+			return fmt.Errorf("display name contains blocked words")
+		}
+	}
+	return nil
+}
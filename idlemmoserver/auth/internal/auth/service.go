@@ -16,18 +16,22 @@ import (
 	"github.com/idle-server/common/nats"
 	"github.com/idle-server/common/service"
 	natsio "github.com/nats-io/nats.go"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Service 统一的认证服务
 type Service struct {
 	*service.BaseServiceImpl
-	natsManager *nats.Manager
-	processor   *handler.MessageProcessor
-	jwtSecret   []byte
-	gormDB      *database.GORM
-	redis       *database.Redis
-	userRepo    *database.GORMUserRepository
+	natsManager     *nats.Manager
+	processor       *handler.MessageProcessor
+	jwtSecret       []byte
+	gormDB          *database.GORM
+	redis           *database.Redis
+	userRepo        *database.GORMUserRepository
+	eventRepo       *database.GORMAuthEventRepository
+	characterRepo   *database.GORMCharacterRepository
+	dataRequestRepo *database.GORMDataRequestRepository
+	apiKeyRepo      *database.GORMAPIKeyRepository
+	bootstrapToken  string // 引导管理员首次绑定2FA的令牌，见 common.TwoFactorBootstrapTokenEnv
 }
 
 // NewService 创建新的认证服务
@@ -35,6 +39,7 @@ func NewService() service.Service {
 	return &Service{
 		BaseServiceImpl: service.NewBaseService("Auth"),
 		jwtSecret:       []byte("your-secret-key-change-in-production"),
+		bootstrapToken:  bootstrapTokenFromEnv(),
 	}
 }
 
@@ -63,17 +68,33 @@ func (s *Service) Start(ctx context.Context) error {
 	}
 	s.redis = redis
 
+	// players.name 建立唯一索引前先整理存量角色名
+	if err := database.PrepareDisplayNames(gormDB.GetDB()); err != nil {
+		return fmt.Errorf("failed to prepare display names: %w", err)
+	}
+
 	// 使用GORM的AutoMigrate功能运行数据库迁移
 	if err := gormDB.AutoMigrate(
 		&database.User{},
 		&database.Player{},
 		&database.GameProgress{},
+		&database.AuthEvent{},
+		&database.DataRequest{},
+		&database.APIKey{},
+		&database.NameHistory{},
 	); err != nil {
 		return fmt.Errorf("failed to run database migrations: %w", err)
 	}
+	if err := database.BackfillAptitudes(gormDB.GetDB()); err != nil {
+		return fmt.Errorf("failed to backfill aptitudes: %w", err)
+	}
 
 	// 创建GORM仓库
 	s.userRepo = database.NewGORMUserRepository(gormDB.GetDB(), redis)
+	s.eventRepo = database.NewGORMAuthEventRepository(gormDB.GetDB())
+	s.characterRepo = database.NewGORMCharacterRepository(gormDB.GetDB(), redis)
+	s.dataRequestRepo = database.NewGORMDataRequestRepository(gormDB.GetDB())
+	s.apiKeyRepo = database.NewGORMAPIKeyRepository(gormDB.GetDB())
 
 	// 初始化 NATS 管理器
 	s.natsManager, err = nats.NewManager(common.NATSURL)
@@ -140,24 +161,95 @@ func (s *Service) registerHandlers() error {
 	registerHandler := handler.NewRegisterHandler(s.natsManager, s.registerUser)
 	s.processor.RegisterHandler(registerHandler)
 
+	// 注册双因素认证处理器
+	s.processor.RegisterHandler(handler.NewTwoFactorVerifyHandler(s.natsManager, s.verifyTwoFactorLogin))
+	s.processor.RegisterHandler(handler.NewTwoFactorSetupHandler(s.natsManager, s.setupTwoFactor))
+	s.processor.RegisterHandler(handler.NewTwoFactorEnableHandler(s.natsManager, s.enableTwoFactor))
+	s.processor.RegisterHandler(handler.NewTwoFactorDisableHandler(s.natsManager, s.disableTwoFactor))
+	s.processor.RegisterHandler(handler.NewTwoFactorRequireHandler(s.natsManager, s.setTwoFactorRequirement))
+
+	// 注册账号管理与审计处理器
+	s.processor.RegisterHandler(handler.NewTokenRefreshHandler(s.natsManager, s.refreshToken))
+	s.processor.RegisterHandler(handler.NewChangePasswordHandler(s.natsManager, s.changePassword))
+	s.processor.RegisterHandler(handler.NewBanUserHandler(s.natsManager, s.banUser))
+	s.processor.RegisterHandler(handler.NewAuditEventsHandler(s.natsManager, s.queryAuditEvents))
+	s.processor.RegisterHandler(handler.NewAuditDevicesHandler(s.natsManager, s.queryDeviceHistory))
+
+	// 注册角色管理与令牌校验处理器
+	s.processor.RegisterHandler(handler.NewCharacterListHandler(s.natsManager, s.listCharacters))
+	s.processor.RegisterHandler(handler.NewCharacterCreateHandler(s.natsManager, s.createCharacter))
+	s.processor.RegisterHandler(handler.NewCharacterDeleteHandler(s.natsManager, s.deleteCharacter))
+	s.processor.RegisterHandler(handler.NewCharacterRestoreHandler(s.natsManager, s.restoreCharacter))
+	s.processor.RegisterHandler(handler.NewCharacterSelectHandler(s.natsManager, s.selectCharacter))
+	s.processor.RegisterHandler(handler.NewCharacterRenameHandler(s.natsManager, s.renameCharacter))
+	s.processor.RegisterHandler(handler.NewCharacterNamesHandler(s.natsManager, s.characterNames))
+	s.processor.RegisterHandler(handler.NewCharacterRerollHandler(s.natsManager, s.rerollAptitude))
+	s.processor.RegisterHandler(handler.NewValidateTokenHandler(s.natsManager, s.validateToken))
+
+	// 注册登录会话管理处理器
+	s.processor.RegisterHandler(handler.NewSessionListHandler(s.natsManager, s.listSessions))
+	s.processor.RegisterHandler(handler.NewSessionRevokeHandler(s.natsManager, s.revokeSession))
+	s.processor.RegisterHandler(handler.NewSessionRevokeOthersHandler(s.natsManager, s.revokeOtherSessions))
+
+	// 注册API密钥处理器
+	s.processor.RegisterHandler(handler.NewAPIKeyCreateHandler(s.natsManager, s.createAPIKey))
+	s.processor.RegisterHandler(handler.NewAPIKeyListHandler(s.natsManager, s.listAPIKeys))
+	s.processor.RegisterHandler(handler.NewAPIKeyRevokeHandler(s.natsManager, s.revokeAPIKey))
+	s.processor.RegisterHandler(handler.NewAPIKeyValidateHandler(s.natsManager, s.validateAPIKey))
+
+	// 注册数据导出与删除处理器
+	s.processor.RegisterHandler(handler.NewDataExportHandler(s.natsManager, s.requestDataExport))
+	s.processor.RegisterHandler(handler.NewDataErasureHandler(s.natsManager, s.requestDataErasure))
+	s.processor.RegisterHandler(handler.NewDataErasureCancelHandler(s.natsManager, s.cancelDataErasure))
+	s.processor.RegisterHandler(handler.NewDataRequestStatusHandler(s.natsManager, s.dataRequestStatus))
+
 	log.Printf("Auth handlers registered successfully")
 	return nil
 }
 
 // registerNATSSubscriptions 注册 NATS 订阅
 func (s *Service) registerNATSSubscriptions() error {
-	// 使用统一的消息处理器订阅登录主题
-	if _, err := s.natsManager.Subscribe(common.AuthLoginSubject, &natsMessageAdapter{
-		processor: s.processor,
-	}); err != nil {
-		return fmt.Errorf("failed to subscribe to login subject: %w", err)
+	adapter := &natsMessageAdapter{processor: s.processor}
+
+	subjects := []string{
+		common.AuthLoginSubject,
+		common.AuthRegisterSubject,
+		common.AuthTwoFactorVerifySubject,
+		common.AuthTwoFactorSetupSubject,
+		common.AuthTwoFactorEnableSubject,
+		common.AuthTwoFactorDisableSubject,
+		common.AuthTwoFactorRequireSubject,
+		common.AuthRefreshTokenSubject,
+		common.AuthChangePasswordSubject,
+		common.AuthBanUserSubject,
+		common.AuthAuditEventsSubject,
+		common.AuthAuditDevicesSubject,
+		common.AuthCharacterListSubject,
+		common.AuthCharacterCreateSubject,
+		common.AuthCharacterDeleteSubject,
+		common.AuthCharacterRestoreSubject,
+		common.AuthCharacterSelectSubject,
+		common.AuthCharacterRenameSubject,
+		common.AuthCharacterNamesSubject,
+		common.AuthCharacterRerollSubject,
+		common.AuthValidateTokenSubject,
+		common.AuthSessionListSubject,
+		common.AuthSessionRevokeSubject,
+		common.AuthSessionRevokeOthersSubject,
+		common.AuthAPIKeyCreateSubject,
+		common.AuthAPIKeyListSubject,
+		common.AuthAPIKeyRevokeSubject,
+		common.AuthAPIKeyValidateSubject,
+		common.AuthDataExportSubject,
+		common.AuthDataErasureSubject,
+		common.AuthDataErasureCancelSubject,
+		common.AuthDataRequestStatusSubject,
 	}
 
-	// 使用统一的消息处理器订阅注册主题
-	if _, err := s.natsManager.Subscribe(common.AuthRegisterSubject, &natsMessageAdapter{
-		processor: s.processor,
-	}); err != nil {
-		return fmt.Errorf("failed to subscribe to register subject: %w", err)
+	for _, subject := range subjects {
+		if _, err := s.natsManager.Subscribe(subject, adapter); err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+		}
 	}
 
 	log.Printf("Auth NATS subscriptions registered successfully")
@@ -178,7 +270,7 @@ func (a *natsMessageAdapter) Handle(msg *natsio.Msg) error {
 }
 
 // authenticateUser 认证用户业务逻辑
-func (s *Service) authenticateUser(username, password string) (*common.MsgAuthenticateUserResult, error) {
+func (s *Service) authenticateUser(username, password string, client *common.ClientInfo) (*common.MsgAuthenticateUserResult, error) {
 	log.Printf("Processing login request for user: %s", username)
 
 	// 检查用户是否存在
@@ -189,6 +281,7 @@ func (s *Service) authenticateUser(username, password string) (*common.MsgAuthen
 	}
 
 	if !userExists {
+		s.recordAuthEvent(common.AuthEventLoginFailure, username, "", false, client, "unknown user")
 		return &common.MsgAuthenticateUserResult{
 			Success: false,
 			Message: "User does not exist",
@@ -202,9 +295,14 @@ func (s *Service) authenticateUser(username, password string) (*common.MsgAuthen
 		return nil, fmt.Errorf("authentication service error")
 	}
 
-	// 使用bcrypt验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(userData.Password), []byte(password)); err != nil {
-		log.Printf("Auth: Password verification failed for user %s: %v", userData.Username, err)
+	// 验证密码，支持 argon2id 和旧的 bcrypt 哈希
+	ok, needsRehash, err := database.VerifyPassword(userData.Password, password)
+	if err != nil {
+		log.Printf("Auth: Password verification error for user %s: %v", userData.Username, err)
+	}
+	if !ok {
+		log.Printf("Auth: Password verification failed for user %s", userData.Username)
+		s.recordAuthEvent(common.AuthEventLoginFailure, userData.Username, userData.PlayerID, false, client, "invalid password")
 		return &common.MsgAuthenticateUserResult{
 			Success: false,
 			Message: "Invalid password",
@@ -212,27 +310,46 @@ func (s *Service) authenticateUser(username, password string) (*common.MsgAuthen
 	}
 	log.Printf("Auth: Password verification successful for user %s", userData.Username)
 
-	// 生成JWT令牌
-	log.Printf("Auth: Generating JWT for PlayerID: %s", userData.PlayerID)
-	token, err := s.generateJWT(userData.PlayerID)
+	// 旧算法或旧参数的哈希在登录成功后静默升级，失败不影响登录
+	if needsRehash {
+		if err := s.userRepo.RehashPassword(context.Background(), userData.Username, userData.Password, password); err != nil {
+			log.Printf("Auth: Failed to rehash password for user %s: %v", userData.Username, err)
+		}
+	}
+
+	if userData.Banned {
+		s.recordAuthEvent(common.AuthEventLoginFailure, userData.Username, userData.PlayerID, false, client, "account banned")
+		return &common.MsgAuthenticateUserResult{
+			Success: false,
+			Message: "Account is banned",
+		}, nil
+	}
+
+	// 需要双因素认证的账号先返回挑战令牌
+	twoFactorState, err := s.userRepo.GetTwoFactorState(context.Background(), username)
 	if err != nil {
-		log.Printf("Failed to generate JWT: %v", err)
-		return nil, fmt.Errorf("failed to generate authentication token")
+		log.Printf("Failed to load two-factor state: %v", err)
+		return nil, fmt.Errorf("authentication service error")
+	}
+	if needsTwoFactor(userData, twoFactorState) {
+		s.recordAuthEvent(common.AuthEventTwoFactorChallenge, userData.Username, userData.PlayerID, true, client, "")
+		return s.beginTwoFactorChallenge(userData, twoFactorState)
 	}
-	log.Printf("Auth: JWT generated successfully: %s...", token[:50])
 
-	result := &common.MsgAuthenticateUserResult{
-		Success:  true,
-		Message:  "Login successful",
-		PlayerID: userData.PlayerID,
-		Token:    token,
+	// 生成绑定到默认角色的JWT令牌
+	result, err := s.issueLoginToken(userData, client)
+	if err != nil {
+		return nil, err
 	}
+
+	s.completeLogin(userData, client, "")
+
 	log.Printf("Auth: Login successful, returning result: Success=%t, PlayerID=%s", result.Success, result.PlayerID)
 	return result, nil
 }
 
 // registerUser 注册用户业务逻辑
-func (s *Service) registerUser(username, password string) (*common.MsgRegisterUserResult, error) {
+func (s *Service) registerUser(username, password string, client *common.ClientInfo) (*common.MsgRegisterUserResult, error) {
 	log.Printf("Processing registration request for user: %s", username)
 
 	// 检查用户是否已存在
@@ -243,6 +360,7 @@ func (s *Service) registerUser(username, password string) (*common.MsgRegisterUs
 	}
 
 	if userExists {
+		s.recordAuthEvent(common.AuthEventRegister, username, "", false, client, "username already exists")
 		return &common.MsgRegisterUserResult{
 			Success: false,
 			Message: "Username already exists",
@@ -266,29 +384,34 @@ func (s *Service) registerUser(username, password string) (*common.MsgRegisterUs
 	}
 
 	// 保存用户数据
-	if err := s.saveUserData(userData); err != nil {
+	savedUserData, err := s.saveUserData(userData)
+	if err != nil {
 		log.Printf("Failed to save user data: %v", err)
 		return nil, fmt.Errorf("failed to create user account")
 	}
 
 	// 注册成功，记录日志
-	log.Printf("User %s registered successfully with playerID: %s", username, playerID)
+	log.Printf("User %s registered successfully with playerID: %s", username, savedUserData.PlayerID)
+	s.recordAuthEvent(common.AuthEventRegister, username, savedUserData.PlayerID, true, client, "")
 
 	// 注意：Token 将在登录接口中生成
 	return &common.MsgRegisterUserResult{
 		Success:  true,
 		Message:  "Registration successful",
-		PlayerID: playerID,
+		PlayerID: savedUserData.PlayerID,
 	}, nil
 }
 
 // ============ 辅助方法 ============
 
-// generateJWT 生成JWT令牌
-func (s *Service) generateJWT(playerID string) (string, error) {
+// generateJWT 生成绑定到指定角色和登录会话的JWT令牌
+func (s *Service) generateJWT(userData *common.UserData, playerID, sessionID string) (string, error) {
 	claims := jwt.MapClaims{
 		"playerID": playerID,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // 24小时过期
+		"username": userData.Username,
+		"role":     userData.Role,
+		"sid":      sessionID,
+		"exp":      time.Now().Add(common.SessionTTLHours * time.Hour).Unix(),
 		"iat":      time.Now().Unix(),
 	}
 
@@ -296,6 +419,54 @@ func (s *Service) generateJWT(playerID string) (string, error) {
 	return token.SignedString(s.jwtSecret)
 }
 
+// parseJWT 校验并解析JWT令牌
+func (s *Service) parseJWT(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return s.jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
+
+// userFromToken 根据JWT令牌获取用户数据，令牌绑定的会话已被撤销时返回错误
+func (s *Service) userFromToken(tokenString string) (*common.UserData, error) {
+	claims, err := s.parseJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	var userData *common.UserData
+	if username, ok := claims["username"].(string); ok && username != "" {
+		userData, err = s.getUserData(username)
+	} else {
+		// 兼容旧版本只包含 playerID 的令牌
+		playerID, ok := claims["playerID"].(string)
+		if !ok || playerID == "" {
+			return nil, fmt.Errorf("token missing subject")
+		}
+		userData, err = s.userRepo.GetUserByPlayerID(context.Background(), playerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.verifySession(claims, userData.Username); err != nil {
+		return nil, err
+	}
+	return userData, nil
+}
+
 // generatePlayerID 生成新的玩家ID
 func (s *Service) generatePlayerID() (string, error) {
 	bytes := make([]byte, 16)
@@ -340,19 +511,19 @@ func (s *Service) getUserData(username string) (*common.UserData, error) {
 	return userData, nil
 }
 
-// saveUserData 保存用户数据
-func (s *Service) saveUserData(userData *common.UserData) error {
+// saveUserData 保存用户数据，返回数据库中实际生成的用户数据
+func (s *Service) saveUserData(userData *common.UserData) (*common.UserData, error) {
 	log.Printf("Auth: Saving user data directly to database for user: %s", userData.Username)
 
 	// 使用GORM仓库直接保存用户到数据库
 	savedUserData, err := s.userRepo.CreateUser(context.Background(), userData.Username, userData.Password)
 	if err != nil {
 		log.Printf("Failed to save user %s: %v", userData.Username, err)
-		return fmt.Errorf("failed to save user data: %w", err)
+		return nil, fmt.Errorf("failed to save user data: %w", err)
 	}
 
 	log.Printf("User data saved successfully for: %s (Generated PlayerID: %s)", savedUserData.Username, savedUserData.PlayerID)
-	return nil
+	return savedUserData, nil
 }
 
 // Auth 服务重构完成
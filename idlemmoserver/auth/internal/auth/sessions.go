@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+// ============ 登录会话与设备管理 ============
+// 每次登录创建一条会话记录，令牌通过 sid 声明绑定会话；会话被撤销后令牌立即失效，
+// 并通知 Gateway 关闭该会话的 WebSocket 连接
+
+// createSession 登录成功后为当前设备创建会话
+func (s *Service) createSession(userData *common.UserData, playerID string, client *common.ClientInfo) (*common.SessionInfo, error) {
+	sessionID, err := generateSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	now := time.Now()
+	session := &common.SessionInfo{
+		SessionID: sessionID,
+		Username:  userData.Username,
+		CreatedAt: now,
+	}
+	if err := s.saveSession(session, playerID, client); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Auth: Session %s created for %s (%s)", sessionID, userData.Username, session.DeviceName)
+	return session, nil
+}
+
+// saveSession 更新会话绑定的角色和来源信息，有效期顺延到新令牌的过期时间
+func (s *Service) saveSession(session *common.SessionInfo, playerID string, client *common.ClientInfo) error {
+	session.PlayerID = playerID
+	session.LastSeenAt = time.Now()
+	if client != nil && client.IP != "" {
+		session.IP = client.IP
+		session.UserAgent = client.UserAgent
+		session.DeviceName = deviceName(client)
+	}
+	if session.DeviceName == "" {
+		session.DeviceName = "Unknown device"
+	}
+
+	if err := s.redis.SetUserSession(context.Background(), session, common.SessionTTLHours*time.Hour); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// verifySession 校验令牌绑定的会话仍然有效，并按间隔刷新最后活跃时间
+// 会话功能上线后签发的令牌都带 sid，不带 sid 的旧令牌无法被撤销，一律拒绝，需重新登录
+func (s *Service) verifySession(claims jwt.MapClaims, username string) (*common.SessionInfo, error) {
+	sessionID := sessionIDFromClaims(claims)
+	if sessionID == "" {
+		return nil, fmt.Errorf("token has no session, please log in again")
+	}
+
+	ctx := context.Background()
+	session, err := s.redis.GetUserSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, database.ErrSessionNotFound) {
+			return nil, fmt.Errorf("session has been revoked")
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if session.Username != username {
+		return nil, fmt.Errorf("session does not belong to token subject")
+	}
+
+	if time.Since(session.LastSeenAt) >= common.SessionTouchInterval*time.Second {
+		session.LastSeenAt = time.Now()
+		if err := s.redis.TouchUserSession(ctx, session); err != nil && !errors.Is(err, database.ErrSessionNotFound) {
+			log.Printf("Failed to touch session %s: %v", sessionID, err)
+		}
+	}
+
+	return session, nil
+}
+
+// renewSession 刷新令牌或切换角色时更新会话
+func (s *Service) renewSession(token string, userData *common.UserData, playerID string, client *common.ClientInfo) (*common.SessionInfo, error) {
+	claims, err := s.parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.verifySession(claims, userData.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.saveSession(session, playerID, client); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// listSessions 获取账号的全部登录会话，最近活跃的在前
+func (s *Service) listSessions(token, _ string, _ *common.ClientInfo) (*common.MsgSessionResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgSessionResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	sessions, err := s.redis.ListUserSessions(context.Background(), userData.Username)
+	if err != nil {
+		log.Printf("Failed to list sessions for %s: %v", userData.Username, err)
+		return nil, fmt.Errorf("session service error")
+	}
+
+	currentID := s.currentSessionID(token)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].SessionID == currentID
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return &common.MsgSessionResult{Success: true, Message: "OK", Sessions: sessions}, nil
+}
+
+// revokeSession 撤销指定会话；撤销当前会话等同于退出登录
+func (s *Service) revokeSession(token, sessionID string, client *common.ClientInfo) (*common.MsgSessionResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgSessionResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	ctx := context.Background()
+	session, err := s.redis.GetUserSession(ctx, sessionID)
+	if err != nil && !errors.Is(err, database.ErrSessionNotFound) {
+		log.Printf("Failed to load session %s: %v", sessionID, err)
+		return nil, fmt.Errorf("session service error")
+	}
+	if session == nil || session.Username != userData.Username {
+		return &common.MsgSessionResult{Success: false, Message: "Session not found"}, nil
+	}
+
+	if err := s.redis.DeleteUserSession(ctx, userData.Username, sessionID); err != nil {
+		log.Printf("Failed to revoke session %s: %v", sessionID, err)
+		return nil, fmt.Errorf("session service error")
+	}
+
+	s.notifySessionsRevoked([]string{sessionID}, "revoked")
+	s.recordAuthEvent(common.AuthEventSessionRevoke, userData.Username, session.PlayerID, true, client, sessionID)
+	return &common.MsgSessionResult{Success: true, Message: "Session revoked", Revoked: 1}, nil
+}
+
+// revokeOtherSessions 撤销当前会话以外的全部会话
+func (s *Service) revokeOtherSessions(token, _ string, client *common.ClientInfo) (*common.MsgSessionResult, error) {
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgSessionResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	revoked, err := s.revokeUserSessions(userData.Username, s.currentSessionID(token), "revoked")
+	if err != nil {
+		return nil, fmt.Errorf("session service error")
+	}
+	s.recordAuthEvent(common.AuthEventSessionRevoke, userData.Username, userData.PlayerID, true, client,
+		fmt.Sprintf("revoked %d other sessions", len(revoked)))
+
+	return &common.MsgSessionResult{Success: true, Message: "Other sessions revoked", Revoked: len(revoked)}, nil
+}
+
+// ============ 辅助方法 ============
+
+// revokeUserSessions 撤销账号除 keepSessionID 以外的全部会话并通知 Gateway，返回被撤销的会话ID
+func (s *Service) revokeUserSessions(username, keepSessionID, reason string) ([]string, error) {
+	ctx := context.Background()
+	sessions, err := s.redis.ListUserSessions(ctx, username)
+	if err != nil {
+		log.Printf("Failed to list sessions for %s: %v", username, err)
+		return nil, err
+	}
+
+	revoked := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		if keepSessionID != "" && session.SessionID == keepSessionID {
+			continue
+		}
+		if err := s.redis.DeleteUserSession(ctx, username, session.SessionID); err != nil {
+			log.Printf("Failed to revoke session %s: %v", session.SessionID, err)
+			continue
+		}
+		revoked = append(revoked, session.SessionID)
+	}
+
+	if len(revoked) > 0 {
+		s.notifySessionsRevoked(revoked, reason)
+	}
+	return revoked, nil
+}
+
+// notifySessionsRevoked 通知 Gateway 关闭被撤销会话的连接
+func (s *Service) notifySessionsRevoked(sessionIDs []string, reason string) {
+	msg := common.MsgSessionRevoked{
+		SessionIDs: sessionIDs,
+		Reason:     reason,
+	}
+	if err := s.natsManager.Publish(common.GatewaySessionRevokedSubject, msg); err != nil {
+		log.Printf("Failed to notify gateway of revoked sessions: %v", err)
+	}
+}
+
+// currentSessionID 获取令牌绑定的会话ID
+func (s *Service) currentSessionID(token string) string {
+	claims, err := s.parseJWT(token)
+	if err != nil {
+		return ""
+	}
+	return sessionIDFromClaims(claims)
+}
+
+// sessionIDFromClaims 读取令牌中的 sid 声明
+func sessionIDFromClaims(claims jwt.MapClaims) string {
+	sessionID, _ := claims["sid"].(string)
+	return sessionID
+}
+
+// deviceName 会话展示用的设备名称：优先使用客户端上报的名称，否则取 User-Agent
+func deviceName(client *common.ClientInfo) string {
+	name := strings.TrimSpace(client.DeviceName)
+	if name == "" {
+		name = strings.TrimSpace(client.UserAgent)
+	}
+	if name == "" {
+		name = "Unknown device"
+	}
+	return truncate(name, common.SessionDeviceNameMaxLength)
+}
+
+// generateSessionID 生成会话ID
+func generateSessionID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "sess_" + hex.EncodeToString(bytes), nil
+}
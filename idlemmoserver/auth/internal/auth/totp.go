@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// TOTP 实现 (RFC 6238, HMAC-SHA1, 6位, 30秒步长)，兼容主流身份验证器 App
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateTOTPSecret 生成新的TOTP密钥（Base32编码）
+func generateTOTPSecret() (string, error) {
+	bytes := make([]byte, 20)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(bytes), nil
+}
+
+// totpProvisioningURI 生成 otpauth:// 绑定URI，客户端据此渲染二维码
+func totpProvisioningURI(username, secret string) string {
+	label := url.PathEscape(common.TOTPIssuer + ":" + username)
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", common.TOTPIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", common.TOTPDigits))
+	params.Set("period", fmt.Sprintf("%d", common.TOTPPeriod))
+
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+// totpStep 计算指定时间对应的时间步
+func totpStep(t time.Time) int64 {
+	return t.Unix() / common.TOTPPeriod
+}
+
+// totpCode 计算指定时间步的动态码
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < common.TOTPDigits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", common.TOTPDigits, value%mod), nil
+}
+
+// verifyTOTP 校验动态码，返回匹配的时间步
+// lastStep 为上次成功使用的时间步，小于等于它的动态码视为重放
+func verifyTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != common.TOTPDigits {
+		return 0, false
+	}
+
+	current := totpStep(now)
+	for offset := int64(-common.TOTPSkew); offset <= common.TOTPSkew; offset++ {
+		step := current + offset
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// generateRecoveryCodes 生成一次性恢复码，返回明文和用于存储的哈希
+func generateRecoveryCodes(count int) ([]string, []string, error) {
+	codes := make([]string, 0, count)
+	hashes := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		bytes := make([]byte, 5)
+		if _, err := rand.Read(bytes); err != nil {
+			return nil, nil, err
+		}
+		raw := hex.EncodeToString(bytes)
+		code := raw[:5] + "-" + raw[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode 计算恢复码哈希（忽略大小写和分隔符）
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// consumeRecoveryCode 校验恢复码，成功时返回移除该码后的哈希列表
+func consumeRecoveryCode(hashes []string, code string) ([]string, bool) {
+	target := hashRecoveryCode(code)
+	for i, hash := range hashes {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(target)) == 1 {
+			remaining := make([]string, 0, len(hashes)-1)
+			remaining = append(remaining, hashes[:i]...)
+			remaining = append(remaining, hashes[i+1:]...)
+			return remaining, true
+		}
+	}
+	return hashes, false
+}
@@ -0,0 +1,523 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+// ============ 双因素认证业务逻辑 ============
+
+// needsTwoFactor 判断登录是否需要第二步验证
+// 已开启TOTP、被GM设置为强制、或角色本身要求2FA的账号都需要
+func needsTwoFactor(userData *common.UserData, state *database.TwoFactorState) bool {
+	return state.Enabled || state.Required || common.TwoFactorRequiredRoles[userData.Role]
+}
+
+// beginTwoFactorChallenge 密码校验通过后发起第二步验证
+// 尚未绑定TOTP但被强制要求的账号只凭密码拿不到绑定信息，须再提交GM/管理员签发的绑定令牌
+// （首个管理员使用部署时配置的引导令牌），避免知道密码的人抢先绑定自己的设备
+func (s *Service) beginTwoFactorChallenge(userData *common.UserData, state *database.TwoFactorState) (*common.MsgAuthenticateUserResult, error) {
+	ctx := context.Background()
+
+	challenge, err := generateChallengeToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate challenge token: %w", err)
+	}
+
+	ttl := time.Duration(common.TwoFactorChallengeTTL) * time.Second
+	if err := s.redis.SetTwoFactorChallenge(ctx, challenge, userData.Username, ttl); err != nil {
+		return nil, fmt.Errorf("failed to store challenge: %w", err)
+	}
+
+	log.Printf("Auth: Two-factor challenge issued for user %s (enrollment required: %t)", userData.Username, !state.Enabled)
+	return twoFactorPrompt(challenge, state), nil
+}
+
+// twoFactorPrompt 登录第二步的提示：已绑定的账号提交动态码，尚未绑定的账号先提交绑定令牌
+func twoFactorPrompt(challenge string, state *database.TwoFactorState) *common.MsgAuthenticateUserResult {
+	if !state.Enabled {
+		return &common.MsgAuthenticateUserResult{
+			Success:            true,
+			Message:            "Two-factor enrollment required, submit the enrollment token issued by an administrator",
+			TwoFactorRequired:  true,
+			EnrollmentRequired: true,
+			ChallengeToken:     challenge,
+		}
+	}
+	return &common.MsgAuthenticateUserResult{
+		Success:           true,
+		Message:           "Two-factor authentication required",
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+	}
+}
+
+// verifyTwoFactorLogin 登录第二步：校验动态码或恢复码并签发正式Token
+// 尚未绑定的账号先凭绑定令牌换取绑定信息，再以首个动态码完成绑定
+func (s *Service) verifyTwoFactorLogin(challenge, code, enrollmentToken string, client *common.ClientInfo) (*common.MsgAuthenticateUserResult, error) {
+	ctx := context.Background()
+
+	username, err := s.redis.GetTwoFactorChallenge(ctx, challenge)
+	if err != nil {
+		return &common.MsgAuthenticateUserResult{
+			Success: false,
+			Message: "Invalid or expired challenge",
+		}, nil
+	}
+
+	userData, err := s.getUserData(username)
+	if err != nil {
+		return nil, fmt.Errorf("authentication service error")
+	}
+
+	// 失败次数按账号累计，重新输入密码换取新的挑战令牌也不能继续尝试
+	if locked, err := s.twoFactorLocked(ctx, username); err != nil {
+		log.Printf("Failed to load two-factor failures for %s: %v", username, err)
+		return nil, fmt.Errorf("authentication service error")
+	} else if locked {
+		s.redis.DeleteTwoFactorChallenge(ctx, challenge)
+		return twoFactorLockedResult(), nil
+	}
+
+	// 挑战令牌签发后账号可能已被封禁
+	if userData.Banned {
+		s.redis.DeleteTwoFactorChallenge(ctx, challenge)
+		s.recordAuthEvent(common.AuthEventLoginFailure, username, userData.PlayerID, false, client, "account banned")
+		return &common.MsgAuthenticateUserResult{
+			Success: false,
+			Message: "Account is banned",
+		}, nil
+	}
+
+	state, err := s.userRepo.GetTwoFactorState(ctx, username)
+	if err != nil {
+		log.Printf("Failed to load two-factor state for %s: %v", username, err)
+		return nil, fmt.Errorf("authentication service error")
+	}
+
+	if !state.Enabled {
+		if enrollmentToken != "" {
+			return s.beginLoginEnrollment(ctx, challenge, enrollmentToken, userData, client)
+		}
+		// 只有凭绑定令牌开始的绑定才能以动态码确认，密码泄露时他人无法抢先绑定
+		enrolling, err := s.redis.IsTwoFactorEnrolling(ctx, challenge)
+		if err != nil {
+			log.Printf("Failed to load enrollment state for %s: %v", username, err)
+			return nil, fmt.Errorf("authentication service error")
+		}
+		if !enrolling {
+			return &common.MsgAuthenticateUserResult{
+				Success: false,
+				Message: "Two-factor enrollment requires an enrollment token",
+			}, nil
+		}
+	}
+
+	if state.Secret == "" {
+		return &common.MsgAuthenticateUserResult{
+			Success: false,
+			Message: "Two-factor authentication is not set up",
+		}, nil
+	}
+
+	var recoveryCodes []string
+	if state.Enabled {
+		if !s.checkTwoFactorCode(ctx, username, state, code) {
+			s.recordAuthEvent(common.AuthEventLoginFailure, username, userData.PlayerID, false, client, "invalid 2fa code")
+			return s.failTwoFactorAttempt(ctx, challenge, username), nil
+		}
+	} else {
+		// 强制绑定流程：首个正确的动态码即确认绑定
+		step, ok := verifyTOTP(state.Secret, code, time.Now(), 0)
+		if !ok {
+			s.recordAuthEvent(common.AuthEventLoginFailure, username, userData.PlayerID, false, client, "invalid 2fa code")
+			return s.failTwoFactorAttempt(ctx, challenge, username), nil
+		}
+
+		codes, hashes, err := generateRecoveryCodes(common.RecoveryCodeCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+		}
+		if err := s.userRepo.EnableTwoFactor(ctx, username, step, hashes); err != nil {
+			return nil, err
+		}
+		recoveryCodes = codes
+		log.Printf("Auth: Two-factor authentication enrolled during login for user %s", username)
+		s.recordAuthEvent(common.AuthEventTwoFactorEnabled, username, userData.PlayerID, true, client, "enrolled during login")
+	}
+
+	s.redis.DeleteTwoFactorChallenge(ctx, challenge)
+	if err := s.redis.ResetTwoFactorFailures(ctx, username); err != nil {
+		log.Printf("Failed to reset two-factor failures for %s: %v", username, err)
+	}
+
+	result, err := s.issueLoginToken(userData, client)
+	if err != nil {
+		return nil, err
+	}
+	result.RecoveryCodes = recoveryCodes
+
+	log.Printf("Auth: Two-factor login successful for user %s", username)
+	s.completeLogin(userData, client, "2fa")
+	return result, nil
+}
+
+// beginLoginEnrollment 校验GM/管理员签发的绑定令牌或引导令牌，为挑战令牌生成待确认的TOTP密钥
+func (s *Service) beginLoginEnrollment(ctx context.Context, challenge, enrollmentToken string, userData *common.UserData, client *common.ClientInfo) (*common.MsgAuthenticateUserResult, error) {
+	owner, err := s.redis.ConsumeTwoFactorEnrollment(ctx, enrollmentToken)
+	issued := err == nil && owner == userData.Username
+	if !issued {
+		bootstrap, err := s.bootstrapEnrollment(ctx, enrollmentToken, userData)
+		if err != nil {
+			return nil, fmt.Errorf("authentication service error")
+		}
+		issued = bootstrap
+	}
+	if !issued {
+		s.recordAuthEvent(common.AuthEventLoginFailure, userData.Username, userData.PlayerID, false, client, "invalid 2fa enrollment token")
+		return s.failTwoFactorAttempt(ctx, challenge, userData.Username), nil
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	if err := s.userRepo.SetPendingTOTPSecret(ctx, userData.Username, secret); err != nil {
+		return nil, err
+	}
+	ttl := time.Duration(common.TwoFactorChallengeTTL) * time.Second
+	if err := s.redis.MarkTwoFactorEnrolling(ctx, challenge, ttl); err != nil {
+		return nil, fmt.Errorf("failed to store challenge: %w", err)
+	}
+
+	log.Printf("Auth: Two-factor enrollment started during login for user %s", userData.Username)
+	return &common.MsgAuthenticateUserResult{
+		Success:            true,
+		Message:            "Scan the provisioning URI and confirm with a code",
+		TwoFactorRequired:  true,
+		EnrollmentRequired: true,
+		ChallengeToken:     challenge,
+		TwoFactorSetup: &common.TwoFactorSetup{
+			Secret:          secret,
+			ProvisioningURI: totpProvisioningURI(userData.Username, secret),
+		},
+	}, nil
+}
+
+// bootstrapEnrollment 是否凭引导令牌为管理员开始首次绑定
+func (s *Service) bootstrapEnrollment(ctx context.Context, enrollmentToken string, userData *common.UserData) (bool, error) {
+	if s.bootstrapToken == "" || userData.Role != common.RoleAdmin {
+		return false, nil
+	}
+	enrolledAdmins, err := s.userRepo.CountTwoFactorEnabled(ctx, common.RoleAdmin)
+	if err != nil {
+		log.Printf("Failed to count enrolled admins: %v", err)
+		return false, err
+	}
+	if !bootstrapEnrollmentAllowed(s.bootstrapToken, enrollmentToken, userData.Role, enrolledAdmins) {
+		return false, nil
+	}
+	log.Printf("Auth: Bootstrap enrollment token accepted for admin %s", userData.Username)
+	return true, nil
+}
+
+// bootstrapEnrollmentAllowed 引导令牌只在还没有管理员开启2FA时对管理员账号有效
+func bootstrapEnrollmentAllowed(configured, submitted, role string, enrolledAdmins int64) bool {
+	if configured == "" || role != common.RoleAdmin || enrolledAdmins > 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(configured), []byte(submitted)) == 1
+}
+
+// bootstrapTokenFromEnv 读取部署时配置的引导令牌，过短的令牌容易被猜中，忽略
+func bootstrapTokenFromEnv() string {
+	token := os.Getenv(common.TwoFactorBootstrapTokenEnv)
+	if token != "" && len(token) < common.TwoFactorBootstrapTokenMinLength {
+		log.Printf("Ignoring %s: must be at least %d characters", common.TwoFactorBootstrapTokenEnv, common.TwoFactorBootstrapTokenMinLength)
+		return ""
+	}
+	return token
+}
+
+// setupTwoFactor 为已登录用户生成待确认的TOTP密钥
+func (s *Service) setupTwoFactor(token, _ string, _ *common.ClientInfo) (*common.MsgTwoFactorResult, error) {
+	ctx := context.Background()
+
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgTwoFactorResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	state, err := s.userRepo.GetTwoFactorState(ctx, userData.Username)
+	if err != nil {
+		return nil, fmt.Errorf("authentication service error")
+	}
+
+	if state.Enabled {
+		return &common.MsgTwoFactorResult{
+			Success: false,
+			Message: "Two-factor authentication is already enabled",
+		}, nil
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	if err := s.userRepo.SetPendingTOTPSecret(ctx, userData.Username, secret); err != nil {
+		return nil, err
+	}
+
+	return &common.MsgTwoFactorResult{
+		Success: true,
+		Message: "Scan the provisioning URI and confirm with a code",
+		Setup: &common.TwoFactorSetup{
+			Secret:          secret,
+			ProvisioningURI: totpProvisioningURI(userData.Username, secret),
+		},
+	}, nil
+}
+
+// enableTwoFactor 用首个动态码确认绑定，返回一次性恢复码
+func (s *Service) enableTwoFactor(token, code string, client *common.ClientInfo) (*common.MsgTwoFactorResult, error) {
+	ctx := context.Background()
+
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgTwoFactorResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	state, err := s.userRepo.GetTwoFactorState(ctx, userData.Username)
+	if err != nil {
+		return nil, fmt.Errorf("authentication service error")
+	}
+
+	if state.Enabled {
+		return &common.MsgTwoFactorResult{
+			Success: false,
+			Message: "Two-factor authentication is already enabled",
+		}, nil
+	}
+	if state.Secret == "" {
+		return &common.MsgTwoFactorResult{
+			Success: false,
+			Message: "Two-factor setup has not been started",
+		}, nil
+	}
+
+	step, ok := verifyTOTP(state.Secret, code, time.Now(), 0)
+	if !ok {
+		return &common.MsgTwoFactorResult{Success: false, Message: "Invalid verification code"}, nil
+	}
+
+	codes, hashes, err := generateRecoveryCodes(common.RecoveryCodeCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+	if err := s.userRepo.EnableTwoFactor(ctx, userData.Username, step, hashes); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Auth: Two-factor authentication enabled for user %s", userData.Username)
+	s.recordAuthEvent(common.AuthEventTwoFactorEnabled, userData.Username, userData.PlayerID, true, client, "")
+	return &common.MsgTwoFactorResult{
+		Success:       true,
+		Message:       "Two-factor authentication enabled",
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// disableTwoFactor 校验动态码或恢复码后关闭双因素认证
+func (s *Service) disableTwoFactor(token, code string, client *common.ClientInfo) (*common.MsgTwoFactorResult, error) {
+	ctx := context.Background()
+
+	userData, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgTwoFactorResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	state, err := s.userRepo.GetTwoFactorState(ctx, userData.Username)
+	if err != nil {
+		return nil, fmt.Errorf("authentication service error")
+	}
+
+	if !state.Enabled {
+		return &common.MsgTwoFactorResult{
+			Success: false,
+			Message: "Two-factor authentication is not enabled",
+		}, nil
+	}
+	if state.Required || common.TwoFactorRequiredRoles[userData.Role] {
+		return &common.MsgTwoFactorResult{
+			Success: false,
+			Message: "Two-factor authentication is required for this account",
+		}, nil
+	}
+
+	if !s.checkTwoFactorCode(ctx, userData.Username, state, code) {
+		return &common.MsgTwoFactorResult{Success: false, Message: "Invalid verification code"}, nil
+	}
+
+	if err := s.userRepo.DisableTwoFactor(ctx, userData.Username); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Auth: Two-factor authentication disabled for user %s", userData.Username)
+	s.recordAuthEvent(common.AuthEventTwoFactorDisabled, userData.Username, userData.PlayerID, true, client, "")
+	return &common.MsgTwoFactorResult{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	}, nil
+}
+
+// setTwoFactorRequirement GM/管理员强制某账号开启双因素认证
+// 账号尚未绑定时签发一次性绑定令牌，由签发人线下交给账号本人，登录时凭它完成绑定；
+// GM/管理员账号的绑定令牌只能由管理员签发
+func (s *Service) setTwoFactorRequirement(token, username string, required bool, _ *common.ClientInfo) (*common.MsgTwoFactorResult, error) {
+	caller, err := s.userFromToken(token)
+	if err != nil {
+		return &common.MsgTwoFactorResult{Success: false, Message: "Invalid token"}, nil
+	}
+
+	if caller.Role != common.RoleGM && caller.Role != common.RoleAdmin {
+		log.Printf("Auth: User %s (role %s) attempted to change 2FA requirement", caller.Username, caller.Role)
+		return &common.MsgTwoFactorResult{Success: false, Message: "Permission denied"}, nil
+	}
+
+	exists, err := s.checkUserExists(username)
+	if err != nil {
+		return nil, fmt.Errorf("authentication service error")
+	}
+	if !exists {
+		return &common.MsgTwoFactorResult{Success: false, Message: "User does not exist"}, nil
+	}
+
+	target, err := s.getUserData(username)
+	if err != nil {
+		return nil, fmt.Errorf("authentication service error")
+	}
+	ctx := context.Background()
+	state, err := s.userRepo.GetTwoFactorState(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("authentication service error")
+	}
+
+	if err := s.userRepo.SetRequireTwoFactor(ctx, username, required); err != nil {
+		return nil, err
+	}
+	log.Printf("Auth: %s set two-factor requirement for %s to %t", caller.Username, username, required)
+
+	result := &common.MsgTwoFactorResult{
+		Success: true,
+		Message: "Two-factor requirement updated",
+	}
+	if !required || state.Enabled {
+		return result, nil
+	}
+	if common.TwoFactorRequiredRoles[target.Role] && caller.Role != common.RoleAdmin {
+		result.Message = "Two-factor requirement updated, the enrollment token must be issued by an admin"
+		return result, nil
+	}
+
+	enrollmentToken, err := generateChallengeToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate enrollment token: %w", err)
+	}
+	ttl := time.Duration(common.TwoFactorEnrollmentTTL) * time.Second
+	if err := s.redis.SetTwoFactorEnrollment(ctx, enrollmentToken, username, ttl); err != nil {
+		return nil, fmt.Errorf("failed to store enrollment token: %w", err)
+	}
+
+	log.Printf("Auth: %s issued a two-factor enrollment token for %s", caller.Username, username)
+	result.EnrollmentToken = enrollmentToken
+	return result, nil
+}
+
+// ============ 辅助方法 ============
+
+// checkTwoFactorCode 校验已开启账号的动态码，动态码无效时尝试恢复码
+// 时间步与恢复码都以条件更新记录，并发提交同一动态码或恢复码时只有一个请求通过
+func (s *Service) checkTwoFactorCode(ctx context.Context, username string, state *database.TwoFactorState, code string) bool {
+	if step, ok := verifyTOTP(state.Secret, code, time.Now(), state.LastStep); ok {
+		if err := s.userRepo.UpdateTOTPLastStep(ctx, username, step); err != nil {
+			log.Printf("Failed to update TOTP step for %s: %v", username, err)
+			return false
+		}
+		return true
+	}
+
+	if remaining, ok := consumeRecoveryCode(state.RecoveryCodes, code); ok {
+		if err := s.userRepo.UpdateRecoveryCodes(ctx, username, state.RecoveryCodes, remaining); err != nil {
+			log.Printf("Failed to consume recovery code for %s: %v", username, err)
+			return false
+		}
+		log.Printf("Auth: Recovery code used for user %s (%d remaining)", username, len(remaining))
+		return true
+	}
+
+	return false
+}
+
+// failTwoFactorAttempt 记录一次失败：挑战令牌超过次数后作废，账号累计超过次数后锁定一段时间
+func (s *Service) failTwoFactorAttempt(ctx context.Context, challenge, username string) *common.MsgAuthenticateUserResult {
+	window := time.Duration(common.TwoFactorLockoutSeconds) * time.Second
+	failures, err := s.redis.IncrTwoFactorFailures(ctx, username, window)
+	if err != nil {
+		log.Printf("Failed to count two-factor failures for %s: %v", username, err)
+	}
+	if err == nil && failures >= common.TwoFactorAccountMaxFailures {
+		log.Printf("Auth: Two-factor verification locked for user %s after %d failures", username, failures)
+		s.redis.DeleteTwoFactorChallenge(ctx, challenge)
+		return twoFactorLockedResult()
+	}
+
+	ttl := time.Duration(common.TwoFactorChallengeTTL) * time.Second
+	attempts, err := s.redis.IncrTwoFactorAttempts(ctx, challenge, ttl)
+	if err == nil && attempts >= common.TwoFactorMaxAttempts {
+		s.redis.DeleteTwoFactorChallenge(ctx, challenge)
+		return &common.MsgAuthenticateUserResult{
+			Success: false,
+			Message: "Too many failed attempts, please log in again",
+		}
+	}
+
+	return &common.MsgAuthenticateUserResult{
+		Success: false,
+		Message: "Invalid verification code",
+	}
+}
+
+// twoFactorLocked 账号累计的失败次数是否已达到上限
+func (s *Service) twoFactorLocked(ctx context.Context, username string) (bool, error) {
+	failures, err := s.redis.GetTwoFactorFailures(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	return failures >= common.TwoFactorAccountMaxFailures, nil
+}
+
+// twoFactorLockedResult 账号因失败次数过多被暂时锁定
+func twoFactorLockedResult() *common.MsgAuthenticateUserResult {
+	return &common.MsgAuthenticateUserResult{
+		Success: false,
+		Message: "Too many failed attempts, please try again later",
+	}
+}
+
+// generateChallengeToken 生成登录第二步使用的一次性令牌
+func generateChallengeToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
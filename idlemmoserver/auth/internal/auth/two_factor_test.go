@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+func TestExistingAdminLogsInAfterDeploy(t *testing.T) {
+	bootstrap := strings.Repeat("b", common.TwoFactorBootstrapTokenMinLength)
+	t.Setenv(common.TwoFactorBootstrapTokenEnv, bootstrap)
+	configured := bootstrapTokenFromEnv()
+
+	// 部署前创建的管理员：角色强制2FA，但从未绑定
+	admin := &common.UserData{Username: "admin", Role: common.RoleAdmin}
+	state := &database.TwoFactorState{}
+	if !needsTwoFactor(admin, state) {
+		t.Fatalf("admin login does not require two-factor authentication")
+	}
+
+	// 只凭密码拿到的挑战不包含绑定信息
+	prompt := twoFactorPrompt("challenge", state)
+	if !prompt.EnrollmentRequired || prompt.TwoFactorSetup != nil {
+		t.Fatalf("prompt = %+v, want enrollment required without setup", prompt)
+	}
+
+	// 还没有管理员绑定时，引导令牌让首个管理员完成绑定
+	if !bootstrapEnrollmentAllowed(configured, bootstrap, admin.Role, 0) {
+		t.Errorf("bootstrap token rejected for the first admin")
+	}
+	if bootstrapEnrollmentAllowed(configured, bootstrap+"x", admin.Role, 0) {
+		t.Errorf("wrong bootstrap token accepted")
+	}
+	// 已有管理员绑定后改由管理员签发绑定令牌
+	if bootstrapEnrollmentAllowed(configured, bootstrap, admin.Role, 1) {
+		t.Errorf("bootstrap token accepted after an admin enrolled")
+	}
+	if bootstrapEnrollmentAllowed(configured, bootstrap, common.RoleGM, 0) {
+		t.Errorf("bootstrap token accepted for a GM")
+	}
+}
+
+func TestBootstrapTokenFromEnv(t *testing.T) {
+	t.Setenv(common.TwoFactorBootstrapTokenEnv, "")
+	if token := bootstrapTokenFromEnv(); token != "" || bootstrapEnrollmentAllowed(token, "", common.RoleAdmin, 0) {
+		t.Errorf("unset bootstrap token enables enrollment")
+	}
+
+	t.Setenv(common.TwoFactorBootstrapTokenEnv, "short")
+	if token := bootstrapTokenFromEnv(); token != "" {
+		t.Errorf("short bootstrap token %q accepted", token)
+	}
+}
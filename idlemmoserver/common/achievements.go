@@ -0,0 +1,71 @@
+package common
+
+import "fmt"
+
+// ============ 成就 ============
+// 成就定义来自配置表 achievements，由游戏事件驱动评估；进度计数与解锁记录保存在 game_progress 表，
+// 每个成就只解锁并发放一次奖励。新增成就后玩家下次上线（或热更新时在线玩家）会按已有进度追溯评估
+
+// 成就条件类型
+const (
+	AchievementConditionLevel         = "level"          // 角色等级达到 Target
+	AchievementConditionRealm         = "realm"          // 境界达到 Key
+	AchievementConditionItemGathered  = "item_gathered"  // 修炼序列累计获得物品 Key 共 Target 个
+	AchievementConditionLoginStreak   = "login_streak"   // 连续登录 Target 天（取历史最长连续天数）
+	AchievementConditionSequenceLevel = "sequence_level" // 修炼序列 Key 达到 Target 级
+)
+
+// AchievementCondition 成就达成条件
+type AchievementCondition struct {
+	Type   string `json:"type"`
+	Key    string `json:"key,omitempty"`
+	Target int64  `json:"target,omitempty"`
+}
+
+// AchievementDef 成就定义
+type AchievementDef struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Condition   AchievementCondition `json:"condition"`
+	Rewards     RewardBundle         `json:"rewards"`
+}
+
+// validateAchievement 校验成就条件与奖励的引用
+func (c *Content) validateAchievement(def AchievementDef) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s: %s", ContentTableAchievements, def.ID, fmt.Sprintf(format, args...)))
+	}
+
+	condition := def.Condition
+	switch condition.Type {
+	case AchievementConditionRealm:
+		if _, ok := GetRealm(condition.Key); !ok {
+			fail("unknown realm %q", condition.Key)
+		}
+	case AchievementConditionLevel, AchievementConditionLoginStreak:
+		if condition.Target <= 0 {
+			fail("target must be positive")
+		}
+	case AchievementConditionItemGathered:
+		if _, ok := c.Items[condition.Key]; !ok {
+			fail("unknown item %q", condition.Key)
+		}
+		if condition.Target <= 0 {
+			fail("target must be positive")
+		}
+	case AchievementConditionSequenceLevel:
+		if _, ok := c.Sequences[condition.Key]; !ok {
+			fail("unknown sequence %q", condition.Key)
+		}
+		if condition.Target <= 0 || condition.Target > SequenceMaxLevel {
+			fail("target must be in 1-%d", SequenceMaxLevel)
+		}
+	default:
+		fail("unknown condition type %q", condition.Type)
+	}
+
+	errs = append(errs, c.validateRewards(ContentTableAchievements, def.ID, def.Rewards)...)
+	return errs
+}
@@ -0,0 +1,193 @@
+package common
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// ============ 灵根资质 ============
+// 创建角色时按配置表随机生成灵根与基础属性，资质影响修炼速度、采集效率和掉落等公式
+
+// 五行灵根
+const (
+	ElementMetal = "metal" // 金
+	ElementWood  = "wood"  // 木
+	ElementWater = "water" // 水
+	ElementFire  = "fire"  // 火
+	ElementEarth = "earth" // 土
+)
+
+// Elements 五行灵根列表
+var Elements = []string{ElementMetal, ElementWood, ElementWater, ElementFire, ElementEarth}
+
+// SpiritRootGrade 灵根品级配置：灵根条数越少越纯粹，修炼越快
+type SpiritRootGrade struct {
+	Grade          string  `json:"grade"`
+	Name           string  `json:"name"`
+	RootCount      int     `json:"root_count"`      // 灵根条数
+	Weight         int     `json:"weight"`          // 随机权重
+	CultivationMul float64 `json:"cultivation_mul"` // 修炼速度倍率
+	PurityBonus    int     `json:"purity_bonus"`    // 纯度下限加成
+}
+
+// AttributeRange 基础属性的随机范围
+type AttributeRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// SpiritRootGrades 灵根品级配置表，按权重随机
+var SpiritRootGrades = []SpiritRootGrade{
+	{Grade: "heavenly", Name: "天灵根", RootCount: 1, Weight: 2, CultivationMul: 3.0, PurityBonus: 60},
+	{Grade: "earthly", Name: "地灵根", RootCount: 2, Weight: 8, CultivationMul: 2.0, PurityBonus: 40},
+	{Grade: "true", Name: "真灵根", RootCount: 3, Weight: 25, CultivationMul: 1.4, PurityBonus: 20},
+	{Grade: "mixed", Name: "杂灵根", RootCount: 4, Weight: 40, CultivationMul: 1.0, PurityBonus: 0},
+	{Grade: "pseudo", Name: "伪灵根", RootCount: 5, Weight: 25, CultivationMul: 0.7, PurityBonus: 0},
+}
+
+// BaseAttributeRanges 基础属性随机范围配置表
+var BaseAttributeRanges = map[string]AttributeRange{
+	"constitution":  {Min: 5, Max: 20}, // 根骨
+	"comprehension": {Min: 5, Max: 20}, // 悟性
+	"spirit_sense":  {Min: 5, Max: 20}, // 神识
+	"agility":       {Min: 5, Max: 20}, // 身法
+	"luck":          {Min: 1, Max: 10}, // 气运
+}
+
+// SpiritRoot 单条灵根
+type SpiritRoot struct {
+	Element string `json:"element"`
+	Purity  int    `json:"purity"` // 纯度 10-100
+}
+
+// BaseAttributes 角色基础属性
+type BaseAttributes struct {
+	Constitution  int `json:"constitution"`  // 根骨：影响气血与体修
+	Comprehension int `json:"comprehension"` // 悟性：影响修炼速度
+	SpiritSense   int `json:"spirit_sense"`  // 神识：影响炼丹炼器
+	Agility       int `json:"agility"`       // 身法：影响战斗先手与闪避
+	Luck          int `json:"luck"`          // 气运：影响掉落
+}
+
+// Aptitude 角色资质：灵根与基础属性
+type Aptitude struct {
+	Grade      string         `json:"grade"`
+	Roots      []SpiritRoot   `json:"roots"`
+	Attributes BaseAttributes `json:"attributes"`
+}
+
+// RollAptitude 按配置表随机生成资质
+func RollAptitude(rng *rand.Rand) *Aptitude {
+	grade := rollSpiritRootGrade(rng)
+
+	elements := append([]string(nil), Elements...)
+	rng.Shuffle(len(elements), func(i, j int) { elements[i], elements[j] = elements[j], elements[i] })
+
+	minPurity := SpiritRootPurityMin + grade.PurityBonus
+	if minPurity > SpiritRootPurityMax {
+		minPurity = SpiritRootPurityMax
+	}
+
+	roots := make([]SpiritRoot, 0, grade.RootCount)
+	for _, element := range elements[:grade.RootCount] {
+		roots = append(roots, SpiritRoot{
+			Element: element,
+			Purity:  minPurity + rng.Intn(SpiritRootPurityMax-minPurity+1),
+		})
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Purity > roots[j].Purity })
+
+	return &Aptitude{
+		Grade: grade.Grade,
+		Roots: roots,
+		Attributes: BaseAttributes{
+			Constitution:  rollAttribute(rng, "constitution"),
+			Comprehension: rollAttribute(rng, "comprehension"),
+			SpiritSense:   rollAttribute(rng, "spirit_sense"),
+			Agility:       rollAttribute(rng, "agility"),
+			Luck:          rollAttribute(rng, "luck"),
+		},
+	}
+}
+
+// GradeConfig 获取资质对应的灵根品级配置，未知品级按杂灵根处理
+func (a *Aptitude) GradeConfig() SpiritRootGrade {
+	for _, grade := range SpiritRootGrades {
+		if grade.Grade == a.Grade {
+			return grade
+		}
+	}
+	for _, grade := range SpiritRootGrades {
+		if grade.Grade == "mixed" {
+			return grade
+		}
+	}
+	return SpiritRootGrade{Grade: a.Grade, CultivationMul: 1.0}
+}
+
+// CultivationMultiplier 修炼速度倍率 = 品级倍率 × 平均纯度 × (1 + 悟性加成)
+func (a *Aptitude) CultivationMultiplier() float64 {
+	if a == nil {
+		return 1.0
+	}
+	return a.GradeConfig().CultivationMul * a.averagePurity() * (1 + float64(a.Attributes.Comprehension)/AttributeComprehensionScale)
+}
+
+// ElementAffinity 某一五行的亲和度（0 表示没有该灵根，1 表示满纯度），用于采集与功法加成
+func (a *Aptitude) ElementAffinity(element string) float64 {
+	if a == nil {
+		return 0
+	}
+	for _, root := range a.Roots {
+		if root.Element == element {
+			return float64(root.Purity) / SpiritRootPurityMax
+		}
+	}
+	return 0
+}
+
+// DropRateMultiplier 掉落概率倍率，每点气运 +1%
+func (a *Aptitude) DropRateMultiplier() float64 {
+	if a == nil {
+		return 1.0
+	}
+	return 1 + float64(a.Attributes.Luck)/100
+}
+
+// averagePurity 灵根平均纯度（0-1）
+func (a *Aptitude) averagePurity() float64 {
+	if len(a.Roots) == 0 {
+		return float64(SpiritRootPurityMin) / SpiritRootPurityMax
+	}
+	total := 0
+	for _, root := range a.Roots {
+		total += root.Purity
+	}
+	return float64(total) / float64(len(a.Roots)) / SpiritRootPurityMax
+}
+
+// rollSpiritRootGrade 按权重随机灵根品级
+func rollSpiritRootGrade(rng *rand.Rand) SpiritRootGrade {
+	total := 0
+	for _, grade := range SpiritRootGrades {
+		total += grade.Weight
+	}
+
+	roll := rng.Intn(total)
+	for _, grade := range SpiritRootGrades {
+		if roll < grade.Weight {
+			return grade
+		}
+		roll -= grade.Weight
+	}
+	return SpiritRootGrades[len(SpiritRootGrades)-1]
+}
+
+// rollAttribute 按配置范围随机基础属性
+func rollAttribute(rng *rand.Rand, name string) int {
+	r := BaseAttributeRanges[name]
+	if r.Max <= r.Min {
+		return r.Min
+	}
+	return r.Min + rng.Intn(r.Max-r.Min+1)
+}
@@ -16,6 +16,13 @@ const (
 	ClusterName = "idle-mmso-cluster"
 )
 
+// Gateway配置
+const (
+	// GatewayTrustedProxiesEnv 可信反向代理的IP或CIDR（逗号分隔），只采信这些代理转发的 X-Forwarded-For；
+	// 未设置时不信任任何代理，客户端IP取自TCP连接的对端地址
+	GatewayTrustedProxiesEnv = "GATEWAY_TRUSTED_PROXIES"
+)
+
 // WebSocket配置
 const (
 	WSPingInterval   = 30 // 秒
@@ -27,8 +34,179 @@ const (
 // 游戏配置
 const (
 	DefaultOfflineLimitHours = 24 // 小时
-	DefaultInventorySize     = 30
-	DefaultTickInterval      = 1 // 秒
+	DefaultInventorySize     = 30 // 背包格子数，放不下的物品丢弃并在 S_InventoryUpdate 中列出
+	DefaultTickInterval      = 1  // 秒
+	OfflineSimulationStep    = 60 // 离线结算的模拟步长（秒），增益按步长判断是否过期
+)
+
+// 游戏内容配置表（见 content.go）
+const (
+	ContentDir = "data" // 配置表目录，相对于 Game 服务的工作目录
+)
+
+// 玩家 Actor
+const (
+	PlayerActorPassivateMinutes = 30 // 无客户端消息超过该时长的玩家 Actor 保存后停止，再次访问时重新激活
+	PlayerActorMaxRestarts      = 10 // 每分钟内允许的最大重启次数，超过后停止该 Actor
+	PlayerActorRequestTimeout   = 5  // 向玩家 Actor 请求的超时（秒）
+	PlayerActorServiceTimeout   = 10 // 其他服务经由 Game 请求玩家 Actor（代扣、资质更新）的超时（秒），包含激活与存档确认
+)
+
+// 玩家进度（game_progress 表的 progress_type）
+const (
+	ProgressTypeAchievement = "achievement" // 成就解锁记录，键为成就ID
+	ProgressTypeStat        = "stat"        // 成就使用的累计计数，如 item_gathered:<物品ID>
+	ProgressTypeLogin       = "login"       // 登录天数与连续登录记录
+	ProgressTypeQuest       = "quest"       // 当前周期的任务状态，键为任务ID
+)
+
+// 每日/每周任务（配置表见 quests.go）
+const (
+	QuestMaxTracked = 5 // 同时追踪显示的任务数上限
+)
+
+// 炼丹/锻造（配置表见 crafting.go）
+const (
+	CraftingQueueSize            = 5   // 制作队列的最大任务数，只有队首任务在推进
+	CraftingMaxBatch             = 100 // 单个任务的最大制作次数
+	CraftingSkillMaxLevel        = 50
+	CraftingSkillExpBase         = 30   // 技能1级升2级所需经验
+	CraftingSkillExpGrowth       = 1.2  // 每级所需经验的增长倍率
+	CraftingSuccessBonusPerLevel = 0.02 // 超出配方所需等级的每级增加的成功率
+)
+
+// 商店（配置表见 shops.go）
+const (
+	ShopMaxPurchaseCount   = 99 // 单次购买的最大份数
+	ShopRecentPurchases    = 50 // 存档中保留的最近购买请求ID，用于识别重试的请求
+	ShopRequestIDMaxLength = 64
+	ShopHistoryLimit       = 100 // 查询购买记录的条数上限
+)
+
+// 代扣货币（见 game/internal/game/charges.go）
+const (
+	PlayerChargeRecent      = 50 // 存档中保留的最近代扣记录，用于识别重试与退还
+	PlayerChargeIDMaxLength = 64
+)
+
+// 玩家市场（见 market.go）
+const (
+	MarketCurrency             = "gold" // 挂单与出价使用的货币
+	MarketFeeRate              = 0.05   // 成交手续费比例
+	MarketMinFee               = 1
+	MarketMinBidIncrement      = 0.05 // 每次出价至少比当前出价高出的比例（至少 1）
+	MarketDefaultDurationHours = 24
+	MarketMaxDurationHours     = 72
+	MarketMaxListings          = 20  // 每个角色同时在售的挂单数上限
+	MarketMaxPendingOps        = 5   // 等待 Persist 确认的操作数上限
+	MarketSearchLimit          = 50  // 单次搜索返回的挂单数上限
+	MarketSettleInterval       = 60  // 到期挂单的结算间隔（秒）
+	MarketSettleBatch          = 100 // 每次结算的到期挂单数上限
+)
+
+// 宗门（见 sects.go）
+const (
+	SectCurrency             = "gold"         // 创建宗门、捐献和宝库使用的货币
+	SectContributionResource = "contribution" // 捐献获得的贡献货币
+	SectCreateCost           = 1000           // 创建宗门消耗的金币（不进入宝库）
+	SectNameMinLength        = 2
+	SectNameMaxLength        = 12
+	SectNoticeMaxLength      = 200
+	SectMaxApplications      = 5 // 每个玩家同时等待审核的申请数上限
+	SectContributionPerGold  = 1 // 每捐献 1 金币获得的贡献
+	SectExpPerGold           = 1 // 每捐献 1 金币增加的宗门经验
+	SectMaxPendingOps        = 5 // 等待 Persist 确认的操作数上限
+	SectListLimit            = 50
+)
+
+// 邮件
+const (
+	MailRetentionDays = 30 // 邮件保留天数，过期未领取的附件随邮件删除
+	MailListLimit     = 100
+)
+
+// 修炼序列（配置表见 sequences.go）
+const (
+	SequenceMaxLevel        = 99
+	SequenceLevelExpBase    = 50   // 序列1级升2级所需经验
+	SequenceLevelExpGrowth  = 1.15 // 每级所需经验的增长倍率
+	SequenceLevelSpeedBonus = 0.01 // 每级缩短的每轮耗时比例
+	SequenceMinInterval     = 1.0  // 每轮耗时下限（秒）
+)
+
+// 角色派生属性（境界与装备配置见 equipment.go）
+const (
+	StatsBaseAttack        = 5
+	StatsAttackPerLevel    = 2
+	StatsDefensePerLevel   = 1
+	StatsBaseHP            = 100
+	StatsHPPerLevel        = 10
+	StatsHPPerConstitution = 5 // 每点根骨增加的气血
+)
+
+// 多角色配置
+const (
+	DefaultCharacterSlots     = 3  // 每个账号默认角色栏位数
+	MaxCharacterSlots         = 8  // 单账号角色栏位上限
+	CharacterDeleteGraceHours = 72 // 删除角色的冷静期（小时），期间可恢复
+	CharacterNameMinLength    = 2
+	CharacterNameMaxLength    = 16
+)
+
+// 显示名称（角色名）：排行榜、聊天和公开资料只展示显示名称，不再暴露登录用户名
+const (
+	DisplayNamePlaceholderPrefix   = "Player_" // 注册时自动分配的占位名称前缀
+	DisplayNameRenameCooldownHours = 720       // 两次改名的最小间隔（小时）
+	DisplayNameRenameCost          = 500       // 改名消耗的金币，首次改名免费
+	DisplayNameRenameCurrency      = "gold"    // 改名费用使用的货币，经 Game 扣除
+	DisplayNameHistoryLimit        = 50        // 改名记录查询条数上限
+)
+
+// DisplayNameBlockedWords 显示名称中禁止出现的词（比较时忽略大小写和下划线）
+var DisplayNameBlockedWords = []string{
+	"admin", "administrator", "moderator", "system", "official", "support",
+	"fuck", "shit", "bitch", "cunt", "nigger", "faggot", "whore",
+	"管理员", "客服", "官方", "系统", "傻逼", "操你", "妈的", "去死",
+}
+
+// 灵根资质（配置表见 aptitude.go）
+const (
+	AptitudeMaxRerolls          = 3     // 可重新随机资质的次数，仅限尚未开始修炼的角色
+	SpiritRootPurityMin         = 10    // 单条灵根纯度下限
+	SpiritRootPurityMax         = 100   // 单条灵根纯度上限
+	AttributeComprehensionScale = 100.0 // 悟性对修炼速度的加成系数：每点悟性 +1%
+)
+
+// 数据导出与删除（被遗忘权）
+const (
+	DataRequestTypeExport  = "export"
+	DataRequestTypeErasure = "erasure"
+
+	DataRequestStatusPending    = "pending"
+	DataRequestStatusProcessing = "processing"
+	DataRequestStatusCompleted  = "completed"
+	DataRequestStatusCancelled  = "cancelled"
+	DataRequestStatusFailed     = "failed"
+
+	ErasureCoolingOffHours   = 168 // 删除账号的冷静期（小时），期间可撤销
+	DataExportRetentionHours = 72  // 导出档案保留时长（小时），过期后清除
+	DataRequestPollInterval  = 30  // Persist 扫描待处理请求的间隔（秒）
+	DataRequestBatchSize     = 10  // 每次扫描最多处理的请求数
+	ErasedUsernamePrefix     = "erased_"
+)
+
+// 密码哈希参数 (argon2id)，调整后旧参数的哈希会在用户下次登录时自动升级
+// Memory/Iterations/Parallelism 为默认值，可通过对应的环境变量覆盖
+const (
+	PasswordArgon2Memory      = 64 * 1024 // KiB
+	PasswordArgon2Iterations  = 3
+	PasswordArgon2Parallelism = 2
+	PasswordSaltLength        = 16
+	PasswordKeyLength         = 32
+
+	PasswordArgon2MemoryEnv      = "PASSWORD_ARGON2_MEMORY" // KiB
+	PasswordArgon2IterationsEnv  = "PASSWORD_ARGON2_ITERATIONS"
+	PasswordArgon2ParallelismEnv = "PASSWORD_ARGON2_PARALLELISM"
 )
 
 // 错误码
@@ -38,6 +216,8 @@ const (
 	ErrorCodeUserExists    = 1002
 	ErrorCodeUserNotFound  = 1003
 	ErrorCodeInvalidToken  = 1004
+	ErrorCode2FARequired   = 1005
+	ErrorCode2FAInvalid    = 1006
 	ErrorCodeInvalidData   = 2001
 	ErrorCodeInternalError = 5000
 )
@@ -51,16 +231,154 @@ const (
 	ClientMsgTypeStartSeq  = "C_StartSeq"
 	ClientMsgTypeStopSeq   = "C_StopSeq"
 
+	ClientMsgTypeInventoryUse     = "C_InventoryUse"     // 使用格子中的消耗品
+	ClientMsgTypeInventoryDiscard = "C_InventoryDiscard" // 丢弃格子中的物品
+	ClientMsgTypeInventorySort    = "C_InventorySort"    // 整理背包（排序并合并堆叠）
+
+	ClientMsgTypeEquip   = "C_Equip"   // 穿戴背包格子中的装备，部位已有装备时互换
+	ClientMsgTypeUnequip = "C_Unequip" // 卸下装备放回背包
+
+	ClientMsgTypeContentReload = "C_ContentReload" // 管理员热更新配置表
+	ClientMsgTypeAdminGrant    = "C_AdminGrant"    // 管理员向角色发放经验、货币或物品
+	ClientMsgTypeGameAction    = "C_GameAction"    // 游戏动作（任务接取、追踪、完成与领取等）
+
 	// 服务端消息类型
-	ServerMsgTypeRegisterOK      = "S_RegisterOK"
-	ServerMsgTypeLoginOK         = "S_LoginOK"
-	ServerMsgTypeError           = "S_Error"
-	ServerMsgTypePlayerData      = "S_PlayerData"
-	ServerMsgTypeSeqResult       = "S_SeqResult"
-	ServerMsgTypeInventoryUpdate = "S_InventoryUpdate"
-	ServerMsgTypeEquipmentUpdate = "S_EquipmentUpdate"
+	ServerMsgTypeRegisterOK       = "S_RegisterOK"
+	ServerMsgTypeLoginOK          = "S_LoginOK"
+	ServerMsgTypeError            = "S_Error"
+	ServerMsgTypePlayerData       = "S_PlayerData"
+	ServerMsgTypeSeqResult        = "S_SeqResult"
+	ServerMsgTypeOfflineReport    = "S_OfflineReport"
+	ServerMsgTypeInventoryUpdate  = "S_InventoryUpdate"
+	ServerMsgTypeEquipmentUpdate  = "S_EquipmentUpdate"
+	ServerMsgTypeLevelUp          = "S_LevelUp"
+	ServerMsgTypeAchievement      = "S_AchievementUnlocked"
+	ServerMsgTypeQuestList        = "S_QuestList"
+	ServerMsgTypeQuestUpdate      = "S_QuestUpdate"
+	ServerMsgTypeCraftingUpdate   = "S_CraftingUpdate"
+	ServerMsgTypeMailList         = "S_MailList"
+	ServerMsgTypeMailReceived     = "S_MailReceived"
+	ServerMsgTypeStaminaUpdate    = "S_StaminaUpdate"
+	ServerMsgTypeShopList         = "S_ShopList"
+	ServerMsgTypePurchaseResult   = "S_PurchaseResult"
+	ServerMsgTypePurchaseHistory  = "S_PurchaseHistory"
+	ServerMsgTypeMarketListings   = "S_MarketListings"
+	ServerMsgTypeMarketUpdate     = "S_MarketUpdate"
+	ServerMsgTypeSectInfo         = "S_SectInfo"
+	ServerMsgTypeSectList         = "S_SectList"
+	ServerMsgTypeSectApplications = "S_SectApplications"
+	ServerMsgTypeSectUpdate       = "S_SectUpdate"
+	ServerMsgTypeSectEvent        = "S_SectEvent"
+)
+
+// 账号角色
+const (
+	RolePlayer  = "player"
+	RoleSupport = "support"
+	RoleGM      = "gm"
+	RoleAdmin   = "admin"
+)
+
+// 登录会话（每台设备一条会话记录）
+const (
+	SessionTTLHours            = 24 // 会话有效期（小时），与令牌有效期一致，刷新令牌时顺延
+	SessionTouchInterval       = 60 // 最后活跃时间的最小刷新间隔（秒）
+	SessionDeviceNameMaxLength = 64
+)
+
+// 浏览器 Cookie 会话模式：令牌存放在 HttpOnly Cookie 中，不再暴露给页面脚本和 URL
+const (
+	AuthModeHeader    = "X-Auth-Mode" // 客户端声明认证模式的请求头
+	AuthModeCookie    = "cookie"
+	SessionCookieName = "idle_session"
+	CSRFCookieName    = "idle_csrf" // 双重提交校验用的 Cookie，页面脚本可读
+	CSRFHeaderName    = "X-CSRF-Token"
+	CSRFTokenLength   = 32
+)
+
+// API密钥（服务账号与自动化工具的只读访问）
+const (
+	APIKeyPrefix             = "ik_"
+	APIKeyScopeLeaderboard   = "leaderboard:read"
+	APIKeyScopeProfile       = "profile:read"
+	APIKeyDefaultRateLimit   = 60   // 每分钟请求数
+	APIKeyMaxRateLimit       = 6000 // 每分钟请求数上限
+	APIKeyRateLimitedMessage = "API key rate limit exceeded"
+	LeaderboardDefaultLimit  = 50
+	LeaderboardMaxLimit      = 100
+)
+
+// APIKeyScopes 可授予API密钥的权限范围
+var APIKeyScopes = map[string]bool{
+	APIKeyScopeLeaderboard: true,
+	APIKeyScopeProfile:     true,
+}
+
+// StaffRoles 可查询审计日志、封禁账号的角色
+var StaffRoles = map[string]bool{
+	RoleSupport: true,
+	RoleGM:      true,
+	RoleAdmin:   true,
+}
+
+// 认证审计事件类型
+const (
+	AuthEventRegister           = "register"
+	AuthEventLoginSuccess       = "login_success"
+	AuthEventLoginFailure       = "login_failure"
+	AuthEventTwoFactorChallenge = "2fa_challenge"
+	AuthEventTwoFactorEnabled   = "2fa_enabled"
+	AuthEventTwoFactorDisabled  = "2fa_disabled"
+	AuthEventTokenRefresh       = "token_refresh"
+	AuthEventPasswordChange     = "password_change"
+	AuthEventBan                = "ban"
+	AuthEventUnban              = "unban"
+	AuthEventCharacterCreate    = "character_create"
+	AuthEventCharacterDelete    = "character_delete"
+	AuthEventCharacterRestore   = "character_restore"
+	AuthEventCharacterRename    = "character_rename"
+	AuthEventAptitudeReroll     = "aptitude_reroll"
+	AuthEventDataExport         = "data_export"
+	AuthEventDataErasure        = "data_erasure"
+	AuthEventDataErasureCancel  = "data_erasure_cancel"
+	AuthEventSessionRevoke      = "session_revoke"
+	AuthEventAPIKeyCreate       = "apikey_create"
+	AuthEventAPIKeyRevoke       = "apikey_revoke"
+)
+
+// 审计日志查询配置
+const (
+	AuditDefaultPageSize = 50
+	AuditMaxPageSize     = 500
 )
 
+// 双因素认证配置
+const (
+	TOTPIssuer            = "IdleServer"
+	TOTPDigits            = 6
+	TOTPPeriod            = 30  // 秒
+	TOTPSkew              = 1   // 允许前后偏移的时间步数
+	TwoFactorChallengeTTL = 300 // 秒
+	TwoFactorMaxAttempts  = 5   // 单个挑战令牌允许的失败次数
+	RecoveryCodeCount     = 10
+
+	TwoFactorAccountMaxFailures = 10  // 账号在锁定窗口内跨挑战令牌累计允许的失败次数
+	TwoFactorLockoutSeconds     = 900 // 账号失败计数的窗口，达到上限后在最后一次失败后锁定这么久
+
+	TwoFactorEnrollmentTTL = 86400 // 秒，GM/管理员签发的强制绑定令牌有效期
+
+	// TwoFactorBootstrapTokenEnv 部署时由运维设置的引导绑定令牌：还没有任何管理员开启2FA时，
+	// 管理员账号可凭它在登录时完成首次绑定，之后的绑定令牌由已绑定的管理员签发
+	TwoFactorBootstrapTokenEnv       = "TWO_FACTOR_BOOTSTRAP_TOKEN"
+	TwoFactorBootstrapTokenMinLength = 32
+)
+
+// TwoFactorRequiredRoles 强制开启双因素认证的角色
+var TwoFactorRequiredRoles = map[string]bool{
+	RoleGM:    true,
+	RoleAdmin: true,
+}
+
 // 服务名称
 const (
 	ServiceNameLogin   = "login"
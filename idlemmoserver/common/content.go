@@ -0,0 +1,529 @@
+package common
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============ 游戏内容配置表 ============
+// 物品、序列、等级曲线、怪物、掉落表、成就、任务、重置时间和新角色初始数据从 ContentDir 下的版本化文件加载，
+// 加载时校验表间引用；热更新时整套表校验通过后才原子替换，失败则保留当前版本
+
+// 配置表文件名（不含扩展名），表文件支持 .json/.yaml/.yml，等级曲线另支持 .csv
+const (
+	ContentTableManifest     = "manifest"
+	ContentTableItems        = "items"
+	ContentTableSequences    = "sequences"
+	ContentTableLevelCurve   = "level_curve"
+	ContentTableMonsters     = "monsters"
+	ContentTableDropTables   = "drop_tables"
+	ContentTableDefaults     = "defaults"
+	ContentTableAchievements = "achievements"
+	ContentTableQuests       = "quests"
+	ContentTableSchedule     = "schedule"
+	ContentTableRecipes      = "recipes"
+	ContentTableStamina      = "stamina"
+	ContentTableShops        = "shops"
+	ContentTableSectLevels   = "sect_levels"
+)
+
+// ContentManifest 配置表版本信息
+type ContentManifest struct {
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// LevelCurveEntry 等级曲线：从 Level 升到下一级所需的角色经验
+type LevelCurveEntry struct {
+	Level     int   `json:"level"`
+	ExpToNext int64 `json:"exp_to_next"`
+}
+
+// MonsterDef 怪物定义
+type MonsterDef struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Level     int    `json:"level"`
+	Stats     Stats  `json:"stats"`
+	Exp       int64  `json:"exp"`        // 击败后获得的角色经验
+	DropTable string `json:"drop_table"` // 掉落表ID，为空表示不掉落
+}
+
+// DropTable 掉落表，掉落项与序列产出的格式相同
+type DropTable struct {
+	ID    string           `json:"id"`
+	Drops []SequenceReward `json:"drops"`
+}
+
+// ContentDefaults 新角色的初始游戏数据
+type ContentDefaults struct {
+	Level     int            `json:"level"`
+	Resources map[string]int `json:"resources"`
+}
+
+// Content 一个版本的完整游戏内容，加载后只读
+type Content struct {
+	Version      string
+	Items        map[string]ItemDef
+	Sequences    map[string]SequenceConfig
+	LevelCurve   map[int]int64
+	MaxLevel     int // 等级曲线覆盖的最高等级
+	Monsters     map[string]MonsterDef
+	DropTables   map[string]DropTable
+	Achievements map[string]AchievementDef
+	Quests       map[string]QuestDef
+	Recipes      map[string]RecipeDef
+	Stamina      map[string]StaminaDef
+	Shops        map[string]ShopDef
+	SectLevels   map[int]SectLevelDef
+	Schedule     ResetSchedule
+	Defaults     ContentDefaults
+}
+
+// ExpToNext 从 level 升到下一级所需经验，已到最高等级时返回 false
+func (c *Content) ExpToNext(level int) (int64, bool) {
+	exp, ok := c.LevelCurve[level]
+	return exp, ok
+}
+
+var (
+	currentContent atomic.Pointer[Content]
+	emptyContent   = &Content{
+		Items:        map[string]ItemDef{},
+		Sequences:    map[string]SequenceConfig{},
+		LevelCurve:   map[int]int64{},
+		Monsters:     map[string]MonsterDef{},
+		DropTables:   map[string]DropTable{},
+		Achievements: map[string]AchievementDef{},
+		Quests:       map[string]QuestDef{},
+		Recipes:      map[string]RecipeDef{},
+		Stamina:      map[string]StaminaDef{},
+		Shops:        map[string]ShopDef{},
+		SectLevels:   map[int]SectLevelDef{},
+	}
+)
+
+// CurrentContent 当前生效的游戏内容，尚未加载时返回空表
+func CurrentContent() *Content {
+	if content := currentContent.Load(); content != nil {
+		return content
+	}
+	return emptyContent
+}
+
+// SetContent 原子替换当前生效的游戏内容，返回被替换的版本
+func SetContent(content *Content) *Content {
+	return currentContent.Swap(content)
+}
+
+// LoadContent 从目录加载并校验整套配置表，任一表缺失、格式错误或引用无效时返回错误
+func LoadContent(dir string) (*Content, error) {
+	var manifest ContentManifest
+	if err := loadContentTable(dir, ContentTableManifest, &manifest); err != nil {
+		return nil, err
+	}
+
+	var items []ItemDef
+	var sequences []SequenceConfig
+	var monsters []MonsterDef
+	var dropTables []DropTable
+	var achievements []AchievementDef
+	var quests []QuestDef
+	var recipes []RecipeDef
+	var stamina []StaminaDef
+	var shops []ShopDef
+	var sectLevels []SectLevelDef
+	var schedule ResetSchedule
+	var defaults ContentDefaults
+	for name, out := range map[string]interface{}{
+		ContentTableItems:        &items,
+		ContentTableSequences:    &sequences,
+		ContentTableMonsters:     &monsters,
+		ContentTableDropTables:   &dropTables,
+		ContentTableDefaults:     &defaults,
+		ContentTableAchievements: &achievements,
+		ContentTableQuests:       &quests,
+		ContentTableSchedule:     &schedule,
+		ContentTableRecipes:      &recipes,
+		ContentTableStamina:      &stamina,
+		ContentTableShops:        &shops,
+		ContentTableSectLevels:   &sectLevels,
+	} {
+		if err := loadContentTable(dir, name, out); err != nil {
+			return nil, err
+		}
+	}
+
+	curve, err := loadLevelCurve(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	content := &Content{
+		Version:      manifest.Version,
+		Items:        make(map[string]ItemDef, len(items)),
+		Sequences:    make(map[string]SequenceConfig, len(sequences)),
+		LevelCurve:   make(map[int]int64, len(curve)),
+		Monsters:     make(map[string]MonsterDef, len(monsters)),
+		DropTables:   make(map[string]DropTable, len(dropTables)),
+		Achievements: make(map[string]AchievementDef, len(achievements)),
+		Quests:       make(map[string]QuestDef, len(quests)),
+		Recipes:      make(map[string]RecipeDef, len(recipes)),
+		Stamina:      make(map[string]StaminaDef, len(stamina)),
+		Shops:        make(map[string]ShopDef, len(shops)),
+		SectLevels:   make(map[int]SectLevelDef, len(sectLevels)),
+		Schedule:     schedule,
+		Defaults:     defaults,
+	}
+
+	var errs []error
+	if content.Version == "" {
+		errs = append(errs, fmt.Errorf("%s: missing version", ContentTableManifest))
+	}
+	for _, item := range items {
+		if _, ok := content.Items[item.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate id %q", ContentTableItems, item.ID))
+		}
+		content.Items[item.ID] = item
+	}
+	for _, sequence := range sequences {
+		if _, ok := content.Sequences[sequence.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate id %q", ContentTableSequences, sequence.ID))
+		}
+		content.Sequences[sequence.ID] = sequence
+	}
+	for _, entry := range curve {
+		if _, ok := content.LevelCurve[entry.Level]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate level %d", ContentTableLevelCurve, entry.Level))
+		}
+		content.LevelCurve[entry.Level] = entry.ExpToNext
+		content.MaxLevel = max(content.MaxLevel, entry.Level+1)
+	}
+	for _, monster := range monsters {
+		if _, ok := content.Monsters[monster.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate id %q", ContentTableMonsters, monster.ID))
+		}
+		content.Monsters[monster.ID] = monster
+	}
+	for _, table := range dropTables {
+		if _, ok := content.DropTables[table.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate id %q", ContentTableDropTables, table.ID))
+		}
+		content.DropTables[table.ID] = table
+	}
+	for _, achievement := range achievements {
+		if _, ok := content.Achievements[achievement.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate id %q", ContentTableAchievements, achievement.ID))
+		}
+		content.Achievements[achievement.ID] = achievement
+	}
+	for _, quest := range quests {
+		if _, ok := content.Quests[quest.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate id %q", ContentTableQuests, quest.ID))
+		}
+		content.Quests[quest.ID] = quest
+	}
+	for _, recipe := range recipes {
+		if _, ok := content.Recipes[recipe.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate id %q", ContentTableRecipes, recipe.ID))
+		}
+		content.Recipes[recipe.ID] = recipe
+	}
+	for _, def := range stamina {
+		if _, ok := content.Stamina[def.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate id %q", ContentTableStamina, def.ID))
+		}
+		content.Stamina[def.ID] = def
+	}
+	for _, shop := range shops {
+		if _, ok := content.Shops[shop.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate id %q", ContentTableShops, shop.ID))
+		}
+		content.Shops[shop.ID] = shop
+	}
+	for _, def := range sectLevels {
+		if _, ok := content.SectLevels[def.Level]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate level %d", ContentTableSectLevels, def.Level))
+		}
+		content.SectLevels[def.Level] = def
+	}
+	if err := content.Schedule.parse(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", ContentTableSchedule, err))
+	}
+
+	errs = append(errs, content.Validate()...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid content in %s: %w", dir, errors.Join(errs...))
+	}
+	return content, nil
+}
+
+// Validate 校验各表字段与表间引用，返回全部错误
+func (c *Content) Validate() []error {
+	var errs []error
+	fail := func(table, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", table, fmt.Sprintf(format, args...)))
+	}
+
+	// 等级曲线必须从1级开始连续
+	for level := 1; level < c.MaxLevel; level++ {
+		if exp, ok := c.LevelCurve[level]; !ok {
+			fail(ContentTableLevelCurve, "missing level %d", level)
+		} else if exp <= 0 {
+			fail(ContentTableLevelCurve, "level %d: exp_to_next must be positive", level)
+		}
+	}
+	if c.MaxLevel == 0 {
+		fail(ContentTableLevelCurve, "no levels defined")
+	}
+	for _, realm := range Realms {
+		if !c.hasLevel(realm.MinLevel) {
+			fail(ContentTableLevelCurve, "realm %s requires level %d beyond the curve", realm.ID, realm.MinLevel)
+		}
+	}
+
+	for id, item := range c.Items {
+		if id == "" {
+			fail(ContentTableItems, "item with empty id")
+		}
+		if _, ok := ItemTypeOrder[item.Type]; !ok {
+			fail(ContentTableItems, "%s: unknown type %q", id, item.Type)
+		}
+		if item.MaxStack < 1 {
+			fail(ContentTableItems, "%s: max_stack must be at least 1", id)
+		}
+		if (item.Type == ItemTypeEquipment) != (item.Equip != nil) {
+			fail(ContentTableItems, "%s: equipment items must have exactly an equip block", id)
+		}
+		if equip := item.Equip; equip != nil {
+			if item.MaxStack != 1 {
+				fail(ContentTableItems, "%s: equipment must not stack", id)
+			}
+			if !containsString(EquipSlots, equip.Slot) {
+				fail(ContentTableItems, "%s: unknown equip slot %q", id, equip.Slot)
+			}
+			if equip.MinRealm != "" {
+				if _, ok := GetRealm(equip.MinRealm); !ok {
+					fail(ContentTableItems, "%s: unknown realm %q", id, equip.MinRealm)
+				}
+			}
+			if equip.MinLevel > 0 && !c.hasLevel(equip.MinLevel) {
+				fail(ContentTableItems, "%s: min_level %d missing from level curve", id, equip.MinLevel)
+			}
+		}
+		if effect := item.Effect; effect != nil && effect.BuffDurationMinutes < 0 {
+			fail(ContentTableItems, "%s: negative buff duration", id)
+		}
+		if effect := item.Effect; effect != nil {
+			for resource, amount := range effect.Stamina {
+				if _, ok := c.Stamina[resource]; !ok {
+					fail(ContentTableItems, "%s: unknown stamina resource %q", id, resource)
+				}
+				if amount <= 0 {
+					fail(ContentTableItems, "%s: %s refill must be positive", id, resource)
+				}
+			}
+		}
+	}
+
+	for id, sequence := range c.Sequences {
+		if sequence.BaseInterval <= 0 {
+			fail(ContentTableSequences, "%s: base_interval must be positive", id)
+		}
+		if sequence.Element != "" && !containsString(Elements, sequence.Element) {
+			fail(ContentTableSequences, "%s: unknown element %q", id, sequence.Element)
+		}
+		errs = append(errs, c.validateDrops(ContentTableSequences, id, sequence.Rewards)...)
+	}
+
+	for id, monster := range c.Monsters {
+		if !c.hasLevel(monster.Level) {
+			fail(ContentTableMonsters, "%s: level %d missing from level curve", id, monster.Level)
+		}
+		if monster.DropTable != "" {
+			if _, ok := c.DropTables[monster.DropTable]; !ok {
+				fail(ContentTableMonsters, "%s: unknown drop table %q", id, monster.DropTable)
+			}
+		}
+	}
+
+	for id, table := range c.DropTables {
+		errs = append(errs, c.validateDrops(ContentTableDropTables, id, table.Drops)...)
+	}
+
+	for _, achievement := range c.Achievements {
+		errs = append(errs, c.validateAchievement(achievement)...)
+	}
+	for _, quest := range c.Quests {
+		errs = append(errs, c.validateQuest(quest)...)
+	}
+	for _, recipe := range c.Recipes {
+		errs = append(errs, c.validateRecipe(recipe)...)
+	}
+	for _, def := range c.Stamina {
+		errs = append(errs, c.validateStamina(def)...)
+	}
+	for _, shop := range c.Shops {
+		errs = append(errs, c.validateShop(shop)...)
+	}
+	errs = append(errs, c.validateSectLevels()...)
+
+	if !c.hasLevel(c.Defaults.Level) {
+		fail(ContentTableDefaults, "starting level %d missing from level curve", c.Defaults.Level)
+	}
+	for resource, amount := range c.Defaults.Resources {
+		if amount < 0 {
+			fail(ContentTableDefaults, "resource %s: negative amount", resource)
+		}
+	}
+	return errs
+}
+
+// validateDrops 校验掉落项引用的物品与概率、数量范围
+func (c *Content) validateDrops(table, owner string, drops []SequenceReward) []error {
+	var errs []error
+	for _, drop := range drops {
+		if _, ok := c.Items[drop.ItemID]; !ok {
+			errs = append(errs, fmt.Errorf("%s: %s: unknown item %q", table, owner, drop.ItemID))
+		}
+		if drop.Chance <= 0 || drop.Chance > 1 {
+			errs = append(errs, fmt.Errorf("%s: %s: %s chance must be in (0, 1]", table, owner, drop.ItemID))
+		}
+		if drop.Min < 1 || drop.Max < drop.Min {
+			errs = append(errs, fmt.Errorf("%s: %s: %s invalid amount range %d-%d", table, owner, drop.ItemID, drop.Min, drop.Max))
+		}
+	}
+	return errs
+}
+
+// validateRewards 校验奖励引用的货币与物品
+func (c *Content) validateRewards(table, owner string, rewards RewardBundle) []error {
+	var errs []error
+	if rewards.Exp < 0 {
+		errs = append(errs, fmt.Errorf("%s: %s: negative exp reward", table, owner))
+	}
+	for resource, amount := range rewards.Resources {
+		if _, ok := c.Defaults.Resources[resource]; !ok {
+			errs = append(errs, fmt.Errorf("%s: %s: unknown currency %q", table, owner, resource))
+		}
+		if amount <= 0 {
+			errs = append(errs, fmt.Errorf("%s: %s: %s reward must be positive", table, owner, resource))
+		}
+	}
+	for itemID, count := range rewards.Items {
+		if _, ok := c.Items[itemID]; !ok {
+			errs = append(errs, fmt.Errorf("%s: %s: unknown item %q", table, owner, itemID))
+		}
+		if count <= 0 {
+			errs = append(errs, fmt.Errorf("%s: %s: %s reward count must be positive", table, owner, itemID))
+		}
+	}
+	return errs
+}
+
+// hasLevel 等级是否在曲线覆盖范围内（最高等级没有升级经验，但同样有效）
+func (c *Content) hasLevel(level int) bool {
+	return level >= 1 && level <= c.MaxLevel
+}
+
+// loadContentTable 查找并解析一张配置表
+func loadContentTable(dir, name string, out interface{}) error {
+	path, err := findContentFile(dir, name, ".json", ".yaml", ".yml")
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	// YAML 先解析为通用结构再按 JSON 标签映射，两种格式共用同一套字段名
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if data, err = json.Marshal(raw); err != nil {
+			return fmt.Errorf("failed to convert %s: %w", path, err)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadLevelCurve 加载等级曲线，CSV 格式为 level,exp_to_next（首行可为表头）
+func loadLevelCurve(dir string) ([]LevelCurveEntry, error) {
+	path, err := findContentFile(dir, ContentTableLevelCurve, ".csv", ".json", ".yaml", ".yml")
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) != ".csv" {
+		var curve []LevelCurveEntry
+		err := loadContentTable(dir, ContentTableLevelCurve, &curve)
+		return curve, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	curve := make([]LevelCurveEntry, 0, len(records))
+	for i, record := range records {
+		if len(record) != 2 {
+			return nil, fmt.Errorf("%s line %d: expected 2 columns", path, i+1)
+		}
+		level, levelErr := strconv.Atoi(strings.TrimSpace(record[0]))
+		exp, expErr := strconv.ParseInt(strings.TrimSpace(record[1]), 10, 64)
+		if levelErr != nil || expErr != nil {
+			if i == 0 {
+				continue // 表头
+			}
+			return nil, fmt.Errorf("%s line %d: invalid number", path, i+1)
+		}
+		curve = append(curve, LevelCurveEntry{Level: level, ExpToNext: exp})
+	}
+	return curve, nil
+}
+
+// findContentFile 按扩展名顺序查找配置表文件
+func findContentFile(dir, name string, exts ...string) (string, error) {
+	for _, ext := range exts {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("content table %s not found in %s", name, dir)
+}
+
+// containsString 切片中是否包含指定字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"fmt"
+	"math"
+)
+
+// ============ 炼丹/锻造 ============
+// 配方来自配置表 recipes：消耗背包中的材料，经过 Duration 秒后按成功率产出成品，失败时产出副产物。
+// 每种生产技能独立升级，等级越高成功率越高；完成的成品放入背包，放不下的部分以邮件送达
+
+// 生产技能
+const (
+	CraftingSkillAlchemy = "alchemy" // 炼丹
+	CraftingSkillForging = "forging" // 锻造
+)
+
+// CraftingSkills 配方可以使用的生产技能
+var CraftingSkills = map[string]bool{
+	CraftingSkillAlchemy: true,
+	CraftingSkillForging: true,
+}
+
+// RecipeDef 配方定义，Inputs、Outputs、Byproducts 均为单次制作的物品ID -> 数量
+type RecipeDef struct {
+	ID            string           `json:"id"`
+	Name          string           `json:"name"`
+	Skill         string           `json:"skill"`
+	MinSkillLevel int              `json:"min_skill_level,omitempty"` // 所需技能等级
+	Inputs        map[string]int   `json:"inputs"`
+	Cost          map[string]int64 `json:"cost,omitempty"` // 每次制作消耗的货币（如体力）
+	Outputs       map[string]int   `json:"outputs"`
+	Byproducts    map[string]int   `json:"byproducts,omitempty"` // 失败时的产出，为空表示材料全部损失
+	Duration      float64          `json:"duration"`             // 单次制作耗时（秒）
+	SuccessRate   float64          `json:"success_rate"`         // 达到所需技能等级时的成功率
+	SkillExp      int64            `json:"skill_exp"`            // 每次制作获得的技能经验（失败减半）
+}
+
+// CraftingSkillExpToNext 生产技能从 level 升到下一级所需经验
+func CraftingSkillExpToNext(level int) int64 {
+	return int64(float64(CraftingSkillExpBase) * math.Pow(CraftingSkillExpGrowth, float64(level-1)))
+}
+
+// CraftingSuccessRate 按技能等级计算配方成功率，超出所需等级的每级增加 CraftingSuccessBonusPerLevel
+func CraftingSuccessRate(recipe RecipeDef, skillLevel int) float64 {
+	bonus := float64(max(skillLevel-max(recipe.MinSkillLevel, 1), 0)) * CraftingSuccessBonusPerLevel
+	return math.Min(recipe.SuccessRate+bonus, 1)
+}
+
+// validateRecipe 校验配方的技能、材料、产出与耗时
+func (c *Content) validateRecipe(def RecipeDef) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s: %s", ContentTableRecipes, def.ID, fmt.Sprintf(format, args...)))
+	}
+
+	if !CraftingSkills[def.Skill] {
+		fail("unknown skill %q", def.Skill)
+	}
+	if def.MinSkillLevel < 0 || def.MinSkillLevel > CraftingSkillMaxLevel {
+		fail("min_skill_level must be in [0, %d]", CraftingSkillMaxLevel)
+	}
+	if len(def.Inputs) == 0 {
+		fail("no inputs")
+	}
+	if len(def.Outputs) == 0 {
+		fail("no outputs")
+	}
+	for _, group := range []struct {
+		name  string
+		items map[string]int
+	}{{"input", def.Inputs}, {"output", def.Outputs}, {"byproduct", def.Byproducts}} {
+		for itemID, count := range group.items {
+			if _, ok := c.Items[itemID]; !ok {
+				fail("unknown %s item %q", group.name, itemID)
+			}
+			if count <= 0 {
+				fail("%s %s count must be positive", group.name, itemID)
+			}
+		}
+	}
+	for resource, amount := range def.Cost {
+		if _, ok := c.Defaults.Resources[resource]; !ok {
+			fail("unknown cost currency %q", resource)
+		}
+		if amount <= 0 {
+			fail("%s cost must be positive", resource)
+		}
+	}
+	if def.Duration <= 0 {
+		fail("duration must be positive")
+	}
+	if def.SuccessRate <= 0 || def.SuccessRate > 1 {
+		fail("success_rate must be in (0, 1]")
+	}
+	if def.SkillExp < 0 {
+		fail("negative skill_exp")
+	}
+	return errs
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrDisplayNameTaken 显示名称已被其他角色使用
+	ErrDisplayNameTaken = errors.New("display name is already taken")
+	// ErrRenameCooldown 距上次改名未满冷却时间
+	ErrRenameCooldown = errors.New("rename is on cooldown")
+	// ErrRenameNotCharged 非首次改名但调用方未收取费用
+	ErrRenameNotCharged = errors.New("rename fee was not charged")
+)
+
+// RenameCharacter 修改角色显示名称并记录改名历史
+// 首次改名免费且不受冷却限制，之后每次改名需间隔 cooldown；费用由调用方经 Game 扣除（在线玩家的货币
+// 只在其玩家 Actor 内修改），charged 为已扣除的金币，仅记入改名历史
+func (r *GORMCharacterRepository) RenameCharacter(ctx context.Context, username, playerID, newName string, charged int64, cooldown time.Duration) (*Player, error) {
+	var player Player
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("player_id = ? AND username = ? AND is_deleted = ?", playerID, username, false).
+			First(&player).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCharacterNotFound
+			}
+			return fmt.Errorf("failed to get character: %w", err)
+		}
+
+		if player.NameChangedAt != nil {
+			if time.Since(*player.NameChangedAt) < cooldown {
+				return ErrRenameCooldown
+			}
+			if charged <= 0 {
+				return ErrRenameNotCharged
+			}
+		}
+
+		taken, err := displayNameTaken(tx, newName, playerID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDisplayNameTaken
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"name":            newName,
+			"name_changed_at": now,
+		}
+		if err := tx.Model(&Player{}).Where("player_id = ?", playerID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to rename character: %w", err)
+		}
+
+		history := &NameHistory{
+			PlayerID:  playerID,
+			Username:  username,
+			OldName:   player.Name,
+			NewName:   newName,
+			Cost:      charged,
+			ChangedAt: now,
+		}
+		if err := tx.Create(history).Error; err != nil {
+			return fmt.Errorf("failed to record name history: %w", err)
+		}
+
+		player.Name = newName
+		player.NameChangedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Character %s of %s renamed to %s (cost %d)", playerID, username, newName, charged)
+	return &player, nil
+}
+
+// DisplayNameTaken 检查显示名称是否已被其他角色使用，用于收取改名费用前的预检查
+func (r *GORMCharacterRepository) DisplayNameTaken(ctx context.Context, name, excludePlayerID string) (bool, error) {
+	return displayNameTaken(r.db.WithContext(ctx), name, excludePlayerID)
+}
+
+// ListNameHistory 获取角色的改名记录，最近的在前
+func (r *GORMCharacterRepository) ListNameHistory(ctx context.Context, playerID string, limit int) ([]NameHistory, error) {
+	var history []NameHistory
+	err := r.db.WithContext(ctx).
+		Where("player_id = ?", playerID).
+		Order("changed_at DESC, id DESC").
+		Limit(limit).
+		Find(&history).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list name history: %w", err)
+	}
+	return history, nil
+}
+
+// GenerateDisplayName 生成注册时分配的占位显示名称，玩家可免费改名一次
+func GenerateDisplayName() (string, error) {
+	bytes := make([]byte, 4)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return common.DisplayNamePlaceholderPrefix + hex.EncodeToString(bytes), nil
+}
+
+// PrepareDisplayNames 在为 players.name 建立唯一索引前整理存量数据
+// 空名称、与登录用户名相同（会暴露账号）或与其他角色重复的名称改为占位名称
+func PrepareDisplayNames(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&Player{}) || migrator.HasIndex(&Player{}, "idx_players_name") {
+		return nil
+	}
+	if !migrator.HasColumn(&Player{}, "Name") {
+		if err := migrator.AddColumn(&Player{}, "Name"); err != nil {
+			return fmt.Errorf("failed to add name column: %w", err)
+		}
+	}
+
+	var players []Player
+	if err := db.Select("id", "player_id", "username", "name").Order("id ASC").Find(&players).Error; err != nil {
+		return fmt.Errorf("failed to load characters: %w", err)
+	}
+
+	taken := make(map[string]bool, len(players))
+	renamed := 0
+	for _, player := range players {
+		key := strings.ToLower(player.Name)
+		if player.Name != "" && !strings.EqualFold(player.Name, player.Username) && !taken[key] {
+			taken[key] = true
+			continue
+		}
+
+		name, err := GenerateDisplayName()
+		if err != nil {
+			return fmt.Errorf("failed to generate display name: %w", err)
+		}
+		for taken[strings.ToLower(name)] {
+			if name, err = GenerateDisplayName(); err != nil {
+				return fmt.Errorf("failed to generate display name: %w", err)
+			}
+		}
+		taken[strings.ToLower(name)] = true
+
+		if err := db.Model(&Player{}).Where("id = ?", player.ID).Update("name", name).Error; err != nil {
+			return fmt.Errorf("failed to assign display name: %w", err)
+		}
+		renamed++
+	}
+
+	if renamed > 0 {
+		log.Printf("Assigned placeholder display names to %d characters", renamed)
+	}
+	return nil
+}
+
+// displayNameTaken 检查显示名称是否已被其他角色使用（不区分大小写，已删除角色的名称同样保留）
+func displayNameTaken(tx *gorm.DB, name, excludePlayerID string) (bool, error) {
+	var count int64
+	query := tx.Model(&Player{}).Where("LOWER(name) = LOWER(?)", name)
+	if excludePlayerID != "" {
+		query = query.Where("player_id <> ?", excludePlayerID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check display name: %w", err)
+	}
+	return count > 0, nil
+}
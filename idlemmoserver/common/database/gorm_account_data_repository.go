@@ -0,0 +1,293 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+)
+
+// AccountExport 账号数据导出档案，汇总 MySQL 与 Redis 中关于该账号的全部数据
+// 密码哈希、TOTP密钥、恢复码等凭据不会导出
+type AccountExport struct {
+	ExportedAt   time.Time          `json:"exported_at"`
+	Account      *User              `json:"account"`
+	Characters   []CharacterExport  `json:"characters"`
+	GameProgress []GameProgress     `json:"game_progress"`
+	Mails        []Mail             `json:"mails"`
+	Purchases    []PurchaseHistory  `json:"purchases"`
+	Market       MarketExport       `json:"market"`
+	Sect         SectExport         `json:"sect"`
+	NameHistory  []NameHistory      `json:"name_history"`
+	AuthEvents   []AuthEvent        `json:"auth_events"`
+	DataRequests []DataRequest      `json:"data_requests"`
+	Cache        AccountCacheExport `json:"cache"`
+}
+
+// MarketExport 玩家市场数据：角色作为卖家的挂单、出价记录和作为买卖方的成交记录
+type MarketExport struct {
+	Listings []MarketListing `json:"listings"`
+	Bids     []MarketBid     `json:"bids"`
+	Trades   []MarketTrade   `json:"trades"`
+}
+
+// SectExport 宗门数据：角色的成员记录、入门申请和经手的宝库流水
+type SectExport struct {
+	Members      []SectMember      `json:"members"`
+	Applications []SectApplication `json:"applications"`
+	TreasuryLogs []SectTreasuryLog `json:"treasury_logs"`
+}
+
+// CharacterExport 角色数据，GameData 以 JSON 对象而非字符串导出
+type CharacterExport struct {
+	Player
+	GameData json.RawMessage `json:"game_data"`
+}
+
+// AccountCacheExport Redis 中的账号相关数据
+type AccountCacheExport struct {
+	OnlineCharacters []string                      `json:"online_characters"`
+	ActiveSessions   []common.SessionInfo          `json:"active_sessions"` // 登录设备会话，不包含令牌
+	Rankings         map[string]map[string]float64 `json:"rankings"`        // playerID -> 排行榜类型 -> 分数
+}
+
+// GORMAccountDataRepository 账号数据导出与删除仓库，负责跨表和 Redis 的整体操作
+type GORMAccountDataRepository struct {
+	db    *gorm.DB
+	redis *Redis
+}
+
+// NewGORMAccountDataRepository 创建账号数据仓库
+func NewGORMAccountDataRepository(db *gorm.DB, redis *Redis) *GORMAccountDataRepository {
+	return &GORMAccountDataRepository{
+		db:    db,
+		redis: redis,
+	}
+}
+
+// ExportAccountData 导出账号的全部数据（包含已删除的角色）
+func (r *GORMAccountDataRepository) ExportAccountData(ctx context.Context, username string) (*AccountExport, error) {
+	db := r.db.WithContext(ctx)
+
+	var user User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to load account %s: %w", username, err)
+	}
+
+	var players []Player
+	if err := db.Where("username = ?", username).Order("created_at ASC").Find(&players).Error; err != nil {
+		return nil, fmt.Errorf("failed to load characters: %w", err)
+	}
+	playerIDs := accountPlayerIDs(&user, players)
+
+	export := &AccountExport{
+		ExportedAt: time.Now(),
+		Account:    &user,
+		Characters: make([]CharacterExport, 0, len(players)),
+		Cache: AccountCacheExport{
+			OnlineCharacters: []string{},
+			ActiveSessions:   []common.SessionInfo{},
+			Rankings:         make(map[string]map[string]float64),
+		},
+	}
+
+	for _, player := range players {
+		gameData := json.RawMessage("{}")
+		if player.GameData != "" && json.Valid([]byte(player.GameData)) {
+			gameData = json.RawMessage(player.GameData)
+		}
+		export.Characters = append(export.Characters, CharacterExport{Player: player, GameData: gameData})
+	}
+
+	if err := db.Where("player_id IN ?", playerIDs).Order("id ASC").Find(&export.GameProgress).Error; err != nil {
+		return nil, fmt.Errorf("failed to load game progress: %w", err)
+	}
+	if err := db.Where("player_id IN ?", playerIDs).Order("id ASC").Find(&export.Mails).Error; err != nil {
+		return nil, fmt.Errorf("failed to load mails: %w", err)
+	}
+	if err := db.Where("player_id IN ?", playerIDs).Order("id ASC").Find(&export.Purchases).Error; err != nil {
+		return nil, fmt.Errorf("failed to load purchase history: %w", err)
+	}
+	if err := db.Where("seller_id IN ?", playerIDs).Order("id ASC").Find(&export.Market.Listings).Error; err != nil {
+		return nil, fmt.Errorf("failed to load market listings: %w", err)
+	}
+	if err := db.Where("bidder_id IN ?", playerIDs).Order("id ASC").Find(&export.Market.Bids).Error; err != nil {
+		return nil, fmt.Errorf("failed to load market bids: %w", err)
+	}
+	if err := db.Where("seller_id IN ? OR buyer_id IN ?", playerIDs, playerIDs).Order("id ASC").Find(&export.Market.Trades).Error; err != nil {
+		return nil, fmt.Errorf("failed to load market trades: %w", err)
+	}
+	if err := db.Where("player_id IN ?", playerIDs).Order("id ASC").Find(&export.Sect.Members).Error; err != nil {
+		return nil, fmt.Errorf("failed to load sect members: %w", err)
+	}
+	if err := db.Where("player_id IN ?", playerIDs).Order("id ASC").Find(&export.Sect.Applications).Error; err != nil {
+		return nil, fmt.Errorf("failed to load sect applications: %w", err)
+	}
+	if err := db.Where("player_id IN ?", playerIDs).Order("id ASC").Find(&export.Sect.TreasuryLogs).Error; err != nil {
+		return nil, fmt.Errorf("failed to load sect treasury logs: %w", err)
+	}
+	if err := db.Where("player_id IN ?", playerIDs).Order("id ASC").Find(&export.NameHistory).Error; err != nil {
+		return nil, fmt.Errorf("failed to load name history: %w", err)
+	}
+	if err := db.Where("username = ? OR player_id IN ?", username, playerIDs).Order("id ASC").Find(&export.AuthEvents).Error; err != nil {
+		return nil, fmt.Errorf("failed to load auth events: %w", err)
+	}
+	if err := db.Omit("archive").Where("username = ?", username).Order("id ASC").Find(&export.DataRequests).Error; err != nil {
+		return nil, fmt.Errorf("failed to load data requests: %w", err)
+	}
+
+	if r.redis != nil {
+		sessions, err := r.redis.ListUserSessions(ctx, username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sessions: %w", err)
+		}
+		export.Cache.ActiveSessions = append(export.Cache.ActiveSessions, sessions...)
+
+		for _, playerID := range playerIDs {
+			if online, err := r.redis.IsPlayerOnline(ctx, playerID); err == nil && online {
+				export.Cache.OnlineCharacters = append(export.Cache.OnlineCharacters, playerID)
+			}
+
+			rankings, err := r.redis.GetPlayerRankings(ctx, playerID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load rankings: %w", err)
+			}
+			if len(rankings) > 0 {
+				export.Cache.Rankings[playerID] = rankings
+			}
+		}
+	}
+
+	return export, nil
+}
+
+// EraseAccountData 删除账号数据：角色、进度和账号记录被删除，
+// 审计事件和请求记录保留但匿名化，Redis 中的缓存、会话、在线状态和排行榜条目一并清除
+// 返回用于替换用户名的匿名标识和被删除的会话ID，调用方需通知 Gateway 断开这些会话的连接
+func (r *GORMAccountDataRepository) EraseAccountData(ctx context.Context, username string) (string, []string, error) {
+	anonymized, err := anonymizedUsername()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate anonymized username: %w", err)
+	}
+
+	var playerIDs []string
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("username = ?", username).First(&user).Error; err != nil {
+			return fmt.Errorf("failed to load account %s: %w", username, err)
+		}
+
+		var players []Player
+		if err := tx.Select("player_id").Where("username = ?", username).Find(&players).Error; err != nil {
+			return fmt.Errorf("failed to load characters: %w", err)
+		}
+		playerIDs = accountPlayerIDs(&user, players)
+
+		if err := tx.Where("player_id IN ?", playerIDs).Delete(&GameProgress{}).Error; err != nil {
+			return fmt.Errorf("failed to delete game progress: %w", err)
+		}
+		if err := tx.Where("player_id IN ?", playerIDs).Delete(&Mail{}).Error; err != nil {
+			return fmt.Errorf("failed to delete mails: %w", err)
+		}
+		if err := tx.Where("player_id IN ?", playerIDs).Delete(&PurchaseHistory{}).Error; err != nil {
+			return fmt.Errorf("failed to delete purchase history: %w", err)
+		}
+		if err := eraseMarketData(tx, playerIDs, time.Now()); err != nil {
+			return err
+		}
+		if err := eraseSectData(tx, playerIDs); err != nil {
+			return err
+		}
+		if err := tx.Where("player_id IN ?", playerIDs).Delete(&NameHistory{}).Error; err != nil {
+			return fmt.Errorf("failed to delete name history: %w", err)
+		}
+		if err := tx.Where("username = ?", username).Delete(&Player{}).Error; err != nil {
+			return fmt.Errorf("failed to delete characters: %w", err)
+		}
+
+		if err := tx.Model(&AuthEvent{}).
+			Where("username = ? OR player_id IN ?", username, playerIDs).
+			Updates(map[string]interface{}{
+				"username":   anonymized,
+				"player_id":  "",
+				"ip":         "",
+				"user_agent": "",
+				"detail":     "",
+			}).Error; err != nil {
+			return fmt.Errorf("failed to anonymize auth events: %w", err)
+		}
+		if err := tx.Model(&AuthEvent{}).Where("actor = ?", username).Update("actor", anonymized).Error; err != nil {
+			return fmt.Errorf("failed to anonymize auth event actors: %w", err)
+		}
+
+		if err := tx.Model(&DataRequest{}).
+			Where("username = ?", username).
+			Updates(map[string]interface{}{
+				"username": anonymized,
+				"archive":  "",
+			}).Error; err != nil {
+			return fmt.Errorf("failed to anonymize data requests: %w", err)
+		}
+		if err := tx.Model(&DataRequest{}).Where("requested_by = ?", username).Update("requested_by", anonymized).Error; err != nil {
+			return fmt.Errorf("failed to anonymize data request actors: %w", err)
+		}
+
+		if err := tx.Where("username = ?", username).Delete(&User{}).Error; err != nil {
+			return fmt.Errorf("failed to delete account: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 数据库提交后再清理 Redis，失败只记录日志（缓存会自然过期，排行榜条目需人工复查）
+	var sessionIDs []string
+	if r.redis != nil {
+		for _, playerID := range playerIDs {
+			r.redis.DeletePlayerData(ctx, playerID)
+			r.redis.DeletePlayerData(ctx, fmt.Sprintf("user_by_player:%s", playerID))
+			r.redis.RemoveOnlinePlayer(ctx, playerID)
+		}
+		if sessionIDs, err = r.redis.DeleteUserSessions(ctx, username); err != nil {
+			log.Printf("Failed to delete sessions of erased account: %v", err)
+		}
+		if err := r.redis.RemoveFromRankings(ctx, playerIDs...); err != nil {
+			log.Printf("Failed to remove erased players from rankings: %v", err)
+		}
+		r.redis.DeletePlayerData(ctx, fmt.Sprintf("user:%s", username))
+		r.redis.GetClient().Del(ctx, fmt.Sprintf("user_exists:%s", username))
+	}
+
+	log.Printf("Account data erased: %s (%d characters)", anonymized, len(playerIDs))
+	return anonymized, sessionIDs, nil
+}
+
+// anonymizedUsername 生成删除账号后保留记录所用的匿名标识
+// 使用随机值而不是用户名哈希，避免通过字典反推出原用户名
+func anonymizedUsername() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return common.ErasedUsernamePrefix + hex.EncodeToString(bytes), nil
+}
+
+// accountPlayerIDs 汇总账号关联的全部 playerID（账号标识及所有角色）
+func accountPlayerIDs(user *User, players []Player) []string {
+	seen := map[string]bool{user.PlayerID: true}
+	playerIDs := []string{user.PlayerID}
+	for _, player := range players {
+		if !seen[player.PlayerID] {
+			seen[player.PlayerID] = true
+			playerIDs = append(playerIDs, player.PlayerID)
+		}
+	}
+	return playerIDs
+}
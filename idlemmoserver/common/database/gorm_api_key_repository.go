@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAPIKeyNotFound 密钥不存在
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// GORMAPIKeyRepository GORM API密钥仓库
+type GORMAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMAPIKeyRepository 创建GORM API密钥仓库
+func NewGORMAPIKeyRepository(db *gorm.DB) *GORMAPIKeyRepository {
+	return &GORMAPIKeyRepository{db: db}
+}
+
+// CreateKey 保存新签发的密钥（只包含哈希）
+func (r *GORMAPIKeyRepository) CreateKey(ctx context.Context, key *APIKey) error {
+	if err := r.db.WithContext(ctx).Create(key).Error; err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+// GetKey 根据公开标识获取密钥
+func (r *GORMAPIKeyRepository) GetKey(ctx context.Context, keyID string) (*APIKey, error) {
+	var key APIKey
+	err := r.db.WithContext(ctx).Where("key_id = ?", keyID).First(&key).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListKeys 获取全部密钥，最新签发的在前
+func (r *GORMAPIKeyRepository) ListKeys(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeKey 吊销密钥，已吊销的密钥返回 ErrAPIKeyNotFound
+func (r *GORMAPIKeyRepository) RevokeKey(ctx context.Context, keyID string) error {
+	result := r.db.WithContext(ctx).Model(&APIKey{}).
+		Where("key_id = ? AND revoked_at IS NULL", keyID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke api key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// MarkUsed 更新最后使用时间
+func (r *GORMAPIKeyRepository) MarkUsed(ctx context.Context, keyID string) error {
+	return r.db.WithContext(ctx).Model(&APIKey{}).
+		Where("key_id = ?", keyID).
+		Update("last_used_at", time.Now()).Error
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+)
+
+// AuthEventQuery 认证审计事件查询条件
+type AuthEventQuery struct {
+	Username  string
+	PlayerID  string
+	EventType string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	Offset    int
+}
+
+// DeviceHistory 账号的设备/IP使用记录
+type DeviceHistory struct {
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Logins    int64     `json:"logins"`
+	Failures  int64     `json:"failures"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// GORMAuthEventRepository 认证审计事件仓库
+type GORMAuthEventRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMAuthEventRepository 创建认证审计事件仓库
+func NewGORMAuthEventRepository(db *gorm.DB) *GORMAuthEventRepository {
+	return &GORMAuthEventRepository{db: db}
+}
+
+// RecordEvent 记录一条认证事件
+func (r *GORMAuthEventRepository) RecordEvent(ctx context.Context, event *AuthEvent) error {
+	if err := r.db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to record auth event: %w", err)
+	}
+	return nil
+}
+
+// ListEvents 按条件分页查询认证事件，按时间倒序
+func (r *GORMAuthEventRepository) ListEvents(ctx context.Context, query AuthEventQuery) ([]AuthEvent, int64, error) {
+	db := r.filter(r.db.WithContext(ctx).Model(&AuthEvent{}), query)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count auth events: %w", err)
+	}
+
+	var events []AuthEvent
+	err := db.Order("created_at DESC, id DESC").
+		Limit(normalizePageSize(query.Limit)).
+		Offset(query.Offset).
+		Find(&events).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list auth events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// GetDeviceHistory 汇总账号登录使用过的IP和设备
+func (r *GORMAuthEventRepository) GetDeviceHistory(ctx context.Context, query AuthEventQuery) ([]DeviceHistory, error) {
+	db := r.filter(r.db.WithContext(ctx).Model(&AuthEvent{}), query).
+		Where("event_type IN ?", []string{common.AuthEventLoginSuccess, common.AuthEventLoginFailure})
+
+	var history []DeviceHistory
+	err := db.Select(
+		"ip, user_agent, " +
+			"SUM(CASE WHEN success THEN 1 ELSE 0 END) AS logins, " +
+			"SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failures, " +
+			"MIN(created_at) AS first_seen, MAX(created_at) AS last_seen",
+	).
+		Group("ip, user_agent").
+		Order("last_seen DESC").
+		Limit(normalizePageSize(query.Limit)).
+		Scan(&history).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device history: %w", err)
+	}
+
+	return history, nil
+}
+
+// filter 应用查询条件
+func (r *GORMAuthEventRepository) filter(db *gorm.DB, query AuthEventQuery) *gorm.DB {
+	if query.Username != "" {
+		db = db.Where("username = ?", query.Username)
+	}
+	if query.PlayerID != "" {
+		db = db.Where("player_id = ?", query.PlayerID)
+	}
+	if query.EventType != "" {
+		db = db.Where("event_type = ?", query.EventType)
+	}
+	if !query.Since.IsZero() {
+		db = db.Where("created_at >= ?", query.Since)
+	}
+	if !query.Until.IsZero() {
+		db = db.Where("created_at < ?", query.Until)
+	}
+	return db
+}
+
+// normalizePageSize 规范分页大小
+func normalizePageSize(limit int) int {
+	if limit <= 0 {
+		return common.AuditDefaultPageSize
+	}
+	if limit > common.AuditMaxPageSize {
+		return common.AuditMaxPageSize
+	}
+	return limit
+}
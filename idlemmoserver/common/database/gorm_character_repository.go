@@ -0,0 +1,282 @@
+package database
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCharacterNotFound 角色不存在或不属于该账号
+	ErrCharacterNotFound = errors.New("character not found")
+	// ErrCharacterSlotsFull 角色栏位已满
+	ErrCharacterSlotsFull = errors.New("character slots are full")
+	// ErrNoRerollsLeft 资质重随次数已用完或角色已开始修炼
+	ErrNoRerollsLeft = errors.New("no aptitude rerolls left")
+)
+
+// GORMCharacterRepository GORM角色仓库
+// 角色即 players 表中的记录，通过 Username 关联到账号
+type GORMCharacterRepository struct {
+	db    *gorm.DB
+	redis *Redis
+}
+
+// NewGORMCharacterRepository 创建GORM角色仓库
+func NewGORMCharacterRepository(db *gorm.DB, redis *Redis) *GORMCharacterRepository {
+	return &GORMCharacterRepository{
+		db:    db,
+		redis: redis,
+	}
+}
+
+// ListCharacters 获取账号下的角色列表（包含处于删除冷静期的角色）
+func (r *GORMCharacterRepository) ListCharacters(ctx context.Context, username string) ([]Player, error) {
+	if err := r.finalizeExpiredDeletions(ctx, username); err != nil {
+		log.Printf("Failed to finalize character deletions for %s: %v", username, err)
+	}
+
+	var players []Player
+	err := r.db.WithContext(ctx).
+		Where("username = ? AND is_deleted = ?", username, false).
+		Order("created_at ASC").
+		Find(&players).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list characters: %w", err)
+	}
+
+	return players, nil
+}
+
+// CreateCharacter 在账号下创建新角色，冷静期内的角色同样占用栏位
+func (r *GORMCharacterRepository) CreateCharacter(ctx context.Context, username, name string, maxSlots int) (*Player, error) {
+	if err := r.finalizeExpiredDeletions(ctx, username); err != nil {
+		log.Printf("Failed to finalize character deletions for %s: %v", username, err)
+	}
+
+	playerID, err := generateCharacterID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate player ID: %w", err)
+	}
+
+	player := &Player{
+		PlayerID: playerID,
+		Username: username,
+		Name:     name,
+		GameData: "{}",
+	}
+	if err := player.SetAptitude(rollAptitude()); err != nil {
+		return nil, fmt.Errorf("failed to roll aptitude: %w", err)
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁定账号行，同一账号的并发创建在此串行，避免都通过栏位检查
+		var user User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("username = ?", username).
+			First(&user).Error; err != nil {
+			return fmt.Errorf("failed to lock account %s: %w", username, err)
+		}
+
+		var count int64
+		if err := tx.Model(&Player{}).
+			Where("username = ? AND is_deleted = ?", username, false).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count characters: %w", err)
+		}
+		if count >= int64(maxSlots) {
+			return ErrCharacterSlotsFull
+		}
+
+		taken, err := displayNameTaken(tx, name, "")
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDisplayNameTaken
+		}
+
+		if err := tx.Create(player).Error; err != nil {
+			return fmt.Errorf("failed to create character: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Character created: %s (PlayerID: %s) for account %s", name, playerID, username)
+	return player, nil
+}
+
+// GetCharacter 获取账号下的指定角色
+func (r *GORMCharacterRepository) GetCharacter(ctx context.Context, username, playerID string) (*Player, error) {
+	var player Player
+	err := r.db.WithContext(ctx).
+		Where("player_id = ? AND username = ? AND is_deleted = ?", playerID, username, false).
+		First(&player).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCharacterNotFound
+		}
+		return nil, fmt.Errorf("failed to get character: %w", err)
+	}
+
+	// 冷静期已过但尚未被清理的角色视为已删除
+	if player.DeleteScheduledAt != nil && !player.DeleteScheduledAt.After(time.Now()) {
+		return nil, ErrCharacterNotFound
+	}
+
+	return &player, nil
+}
+
+// ScheduleDeletion 将角色标记为待删除，deleteAt 之后删除生效
+func (r *GORMCharacterRepository) ScheduleDeletion(ctx context.Context, username, playerID string, deleteAt time.Time) error {
+	return r.updateCharacter(ctx, username, playerID, map[string]interface{}{
+		"delete_scheduled_at": deleteAt,
+	})
+}
+
+// CancelDeletion 撤销角色的删除
+func (r *GORMCharacterRepository) CancelDeletion(ctx context.Context, username, playerID string) error {
+	return r.updateCharacter(ctx, username, playerID, map[string]interface{}{
+		"delete_scheduled_at": nil,
+	})
+}
+
+// MarkSelected 记录角色最近一次被选择的时间，登录时默认进入该角色
+func (r *GORMCharacterRepository) MarkSelected(ctx context.Context, username, playerID string) error {
+	return r.updateCharacter(ctx, username, playerID, map[string]interface{}{
+		"last_selected_at": time.Now(),
+	})
+}
+
+// RerollAptitude 重新随机角色资质，次数有限且仅限尚未开始修炼的角色
+func (r *GORMCharacterRepository) RerollAptitude(ctx context.Context, username, playerID string) (*Player, error) {
+	var player Player
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("player_id = ? AND username = ? AND is_deleted = ?", playerID, username, false).
+			First(&player).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCharacterNotFound
+			}
+			return fmt.Errorf("failed to get character: %w", err)
+		}
+		if player.AptitudeRerollsLeft() <= 0 {
+			return ErrNoRerollsLeft
+		}
+
+		if err := player.SetAptitude(rollAptitude()); err != nil {
+			return fmt.Errorf("failed to roll aptitude: %w", err)
+		}
+		player.AptitudeRerolls++
+
+		return tx.Model(&Player{}).Where("player_id = ?", playerID).Updates(map[string]interface{}{
+			"aptitude":         player.Aptitude,
+			"aptitude_rerolls": player.AptitudeRerolls,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if r.redis != nil {
+		r.redis.DeletePlayerData(ctx, playerID)
+	}
+	return &player, nil
+}
+
+// updateCharacter 更新账号下指定角色的字段
+func (r *GORMCharacterRepository) updateCharacter(ctx context.Context, username, playerID string, updates map[string]interface{}) error {
+	result := r.db.WithContext(ctx).Model(&Player{}).
+		Where("player_id = ? AND username = ? AND is_deleted = ?", playerID, username, false).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update character: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrCharacterNotFound
+	}
+	return nil
+}
+
+// finalizeExpiredDeletions 冷静期结束的角色标记为已删除并清理缓存
+func (r *GORMCharacterRepository) finalizeExpiredDeletions(ctx context.Context, username string) error {
+	var expired []Player
+	err := r.db.WithContext(ctx).Select("player_id").
+		Where("username = ? AND is_deleted = ? AND delete_scheduled_at <= ?", username, false, time.Now()).
+		Find(&expired).Error
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	playerIDs := make([]string, 0, len(expired))
+	for _, player := range expired {
+		playerIDs = append(playerIDs, player.PlayerID)
+	}
+
+	if err := r.db.WithContext(ctx).Model(&Player{}).
+		Where("player_id IN ?", playerIDs).
+		Update("is_deleted", true).Error; err != nil {
+		return err
+	}
+
+	if r.redis != nil {
+		for _, playerID := range playerIDs {
+			r.redis.DeletePlayerData(ctx, playerID)
+		}
+	}
+
+	log.Printf("Characters deleted after grace period for %s: %v", username, playerIDs)
+	return nil
+}
+
+// BackfillAptitudes 为资质功能上线前创建的角色补充随机资质
+func BackfillAptitudes(db *gorm.DB) error {
+	var players []Player
+	if err := db.Select("id", "player_id").Where("aptitude IS NULL OR aptitude = ''").Find(&players).Error; err != nil {
+		return fmt.Errorf("failed to load characters without aptitude: %w", err)
+	}
+
+	for i := range players {
+		if err := players[i].SetAptitude(rollAptitude()); err != nil {
+			return fmt.Errorf("failed to roll aptitude: %w", err)
+		}
+		if err := db.Model(&Player{}).Where("id = ?", players[i].ID).Update("aptitude", players[i].Aptitude).Error; err != nil {
+			return fmt.Errorf("failed to assign aptitude: %w", err)
+		}
+	}
+
+	if len(players) > 0 {
+		log.Printf("Assigned aptitudes to %d existing characters", len(players))
+	}
+	return nil
+}
+
+// rollAptitude 按配置表随机生成角色资质
+func rollAptitude() *common.Aptitude {
+	return common.RollAptitude(rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// generateCharacterID 生成新的角色PlayerID
+func generateCharacterID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := crand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "player_" + hex.EncodeToString(bytes), nil
+}
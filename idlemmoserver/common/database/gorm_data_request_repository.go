@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDataRequestNotFound 请求不存在或不属于该账号
+	ErrDataRequestNotFound = errors.New("data request not found")
+	// ErrDataRequestNotCancellable 请求已开始处理或不是删除请求，不能撤销
+	ErrDataRequestNotCancellable = errors.New("data request cannot be cancelled")
+)
+
+// GORMDataRequestRepository GORM数据导出/删除请求仓库
+type GORMDataRequestRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMDataRequestRepository 创建GORM数据请求仓库
+func NewGORMDataRequestRepository(db *gorm.DB) *GORMDataRequestRepository {
+	return &GORMDataRequestRepository{db: db}
+}
+
+// CreateRequest 创建数据请求
+func (r *GORMDataRequestRepository) CreateRequest(ctx context.Context, request *DataRequest) error {
+	if request.Status == "" {
+		request.Status = common.DataRequestStatusPending
+	}
+	if request.ScheduledAt.IsZero() {
+		request.ScheduledAt = time.Now()
+	}
+
+	if err := r.db.WithContext(ctx).Create(request).Error; err != nil {
+		return fmt.Errorf("failed to create data request: %w", err)
+	}
+	return nil
+}
+
+// GetRequest 获取账号下的指定请求（包含导出档案）
+func (r *GORMDataRequestRepository) GetRequest(ctx context.Context, username, requestID string) (*DataRequest, error) {
+	var request DataRequest
+	err := r.db.WithContext(ctx).
+		Where("request_id = ? AND username = ?", requestID, username).
+		First(&request).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrDataRequestNotFound
+		}
+		return nil, fmt.Errorf("failed to get data request: %w", err)
+	}
+	return &request, nil
+}
+
+// ListRequests 获取账号的请求列表（不包含导出档案）
+func (r *GORMDataRequestRepository) ListRequests(ctx context.Context, username string) ([]DataRequest, error) {
+	var requests []DataRequest
+	err := r.db.WithContext(ctx).
+		Omit("archive").
+		Where("username = ?", username).
+		Order("created_at DESC").
+		Find(&requests).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data requests: %w", err)
+	}
+	return requests, nil
+}
+
+// FindActiveRequest 查找账号尚未完成的指定类型请求
+func (r *GORMDataRequestRepository) FindActiveRequest(ctx context.Context, username, requestType string) (*DataRequest, error) {
+	var request DataRequest
+	err := r.db.WithContext(ctx).
+		Omit("archive").
+		Where("username = ? AND type = ? AND status IN ?", username, requestType,
+			[]string{common.DataRequestStatusPending, common.DataRequestStatusProcessing}).
+		First(&request).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find active data request: %w", err)
+	}
+	return &request, nil
+}
+
+// CancelRequest 撤销冷静期内的删除请求
+func (r *GORMDataRequestRepository) CancelRequest(ctx context.Context, username, requestID string) error {
+	result := r.db.WithContext(ctx).Model(&DataRequest{}).
+		Where("request_id = ? AND username = ? AND type = ? AND status = ?",
+			requestID, username, common.DataRequestTypeErasure, common.DataRequestStatusPending).
+		Update("status", common.DataRequestStatusCancelled)
+	if result.Error != nil {
+		return fmt.Errorf("failed to cancel data request: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDataRequestNotCancellable
+	}
+	return nil
+}
+
+// ClaimDueRequests 领取到期的待处理请求并标记为处理中
+// 逐条以状态为条件更新，多个 Persist 实例同时扫描时每条请求只会被领取一次
+func (r *GORMDataRequestRepository) ClaimDueRequests(ctx context.Context, limit int) ([]DataRequest, error) {
+	var candidates []DataRequest
+	err := r.db.WithContext(ctx).
+		Omit("archive").
+		Where("status = ? AND scheduled_at <= ?", common.DataRequestStatusPending, time.Now()).
+		Order("scheduled_at ASC").
+		Limit(limit).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due data requests: %w", err)
+	}
+
+	claimed := make([]DataRequest, 0, len(candidates))
+	for _, request := range candidates {
+		result := r.db.WithContext(ctx).Model(&DataRequest{}).
+			Where("id = ? AND status = ?", request.ID, common.DataRequestStatusPending).
+			Update("status", common.DataRequestStatusProcessing)
+		if result.Error != nil {
+			return claimed, fmt.Errorf("failed to claim data request: %w", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			request.Status = common.DataRequestStatusProcessing
+			claimed = append(claimed, request)
+		}
+	}
+	return claimed, nil
+}
+
+// CompleteRequest 标记请求完成，导出请求同时保存档案
+func (r *GORMDataRequestRepository) CompleteRequest(ctx context.Context, id uint, archive string) error {
+	return r.db.WithContext(ctx).Model(&DataRequest{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       common.DataRequestStatusCompleted,
+			"completed_at": time.Now(),
+			"archive":      archive,
+			"error":        "",
+		}).Error
+}
+
+// FailRequest 标记请求失败
+func (r *GORMDataRequestRepository) FailRequest(ctx context.Context, id uint, reason string) error {
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+	return r.db.WithContext(ctx).Model(&DataRequest{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status": common.DataRequestStatusFailed,
+			"error":  reason,
+		}).Error
+}
+
+// PurgeExpiredArchives 清空完成时间早于 before 的导出档案
+func (r *GORMDataRequestRepository) PurgeExpiredArchives(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&DataRequest{}).
+		Where("type = ? AND status = ? AND completed_at < ? AND archive <> ''",
+			common.DataRequestTypeExport, common.DataRequestStatusCompleted, before).
+		Update("archive", "")
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge export archives: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+)
+
+// GORMMailRepository 玩家邮件仓库（mails 表）
+type GORMMailRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMMailRepository 创建玩家邮件仓库
+func NewGORMMailRepository(db *gorm.DB) *GORMMailRepository {
+	return &GORMMailRepository{db: db}
+}
+
+// SendMail 写入一封邮件，邮件ID已存在时视为重复投递并忽略
+func (r *GORMMailRepository) SendMail(ctx context.Context, mail common.Mail) error {
+	return createMail(r.db.WithContext(ctx), mail)
+}
+
+// createMail 写入一封邮件（可在事务内调用），邮件ID已存在时忽略
+func createMail(db *gorm.DB, mail common.Mail) error {
+	if mail.MailID == "" || mail.PlayerID == "" {
+		return fmt.Errorf("invalid mail %q for player %q", mail.MailID, mail.PlayerID)
+	}
+	attachments, err := json.Marshal(mail.Attachments)
+	if err != nil {
+		return fmt.Errorf("failed to encode attachments: %w", err)
+	}
+
+	row := Mail{
+		MailID:      mail.MailID,
+		PlayerID:    mail.PlayerID,
+		Sender:      mail.Sender,
+		Subject:     mail.Subject,
+		Body:        mail.Body,
+		Attachments: string(attachments),
+		ExpiresAt:   time.Unix(mail.ExpiresAt, 0),
+		CreatedAt:   time.Unix(mail.CreatedAt, 0),
+	}
+	result := db.Where(Mail{MailID: mail.MailID}).FirstOrCreate(&row)
+	if result.Error != nil {
+		return fmt.Errorf("failed to send mail: %w", result.Error)
+	}
+	return nil
+}
+
+// ListMail 玩家未过期的邮件，按时间从新到旧
+func (r *GORMMailRepository) ListMail(ctx context.Context, playerID string, limit int) ([]common.Mail, error) {
+	var rows []Mail
+	err := r.db.WithContext(ctx).
+		Where("player_id = ? AND expires_at > ?", playerID, time.Now()).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mail: %w", err)
+	}
+
+	mails := make([]common.Mail, 0, len(rows))
+	for _, row := range rows {
+		mail, err := row.toMail()
+		if err != nil {
+			return nil, err
+		}
+		mails = append(mails, mail)
+	}
+	return mails, nil
+}
+
+// ClaimMail 标记邮件附件已领取并返回邮件；已领取、已过期或不属于该玩家时返回错误
+func (r *GORMMailRepository) ClaimMail(ctx context.Context, playerID, mailID string) (*common.Mail, error) {
+	var claimed common.Mail
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// 条件更新保证同一封邮件只会被领取一次
+		result := tx.Model(&Mail{}).
+			Where("mail_id = ? AND player_id = ? AND claimed_at IS NULL AND expires_at > ?", mailID, playerID, now).
+			Update("claimed_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to claim mail: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("mail %s not found or already claimed", mailID)
+		}
+
+		var row Mail
+		if err := tx.Where("mail_id = ?", mailID).First(&row).Error; err != nil {
+			return fmt.Errorf("failed to load mail: %w", err)
+		}
+		mail, err := row.toMail()
+		if err != nil {
+			return err
+		}
+		claimed = mail
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claimed, nil
+}
+
+// DeleteExpiredMail 删除已过期的邮件，返回删除的数量
+func (r *GORMMailRepository) DeleteExpiredMail(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&Mail{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired mail: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// toMail 转换为服务间传递的邮件
+func (m Mail) toMail() (common.Mail, error) {
+	mail := common.Mail{
+		MailID:    m.MailID,
+		PlayerID:  m.PlayerID,
+		Sender:    m.Sender,
+		Subject:   m.Subject,
+		Body:      m.Body,
+		CreatedAt: m.CreatedAt.Unix(),
+		ExpiresAt: m.ExpiresAt.Unix(),
+	}
+	if m.ClaimedAt != nil {
+		mail.ClaimedAt = m.ClaimedAt.Unix()
+	}
+	if m.Attachments != "" {
+		if err := json.Unmarshal([]byte(m.Attachments), &mail.Attachments); err != nil {
+			return common.Mail{}, fmt.Errorf("invalid attachments in mail %s: %w", m.MailID, err)
+		}
+	}
+	return mail, nil
+}
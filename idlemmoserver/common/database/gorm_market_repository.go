@@ -0,0 +1,548 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 市场邮件的发件人
+const marketMailSender = "market"
+
+// 出价状态
+const (
+	bidStatusActive   = "active"
+	bidStatusOutbid   = "outbid"
+	bidStatusWon      = "won"
+	bidStatusRefunded = "refunded"
+)
+
+// MarketOutcome 市场操作的结果：操作后的挂单与本次投递的邮件（用于通知在线的收件人）
+type MarketOutcome struct {
+	Listing *common.MarketListing
+	Mails   []common.Mail
+}
+
+// GORMMarketRepository 玩家市场仓库（market_listings、market_bids、market_trades 表）
+// 每个操作在单个事务内锁定挂单、转移托管并写入邮件，重复提交同一操作ID时返回当前挂单而不重复执行
+type GORMMarketRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMMarketRepository 创建玩家市场仓库
+func NewGORMMarketRepository(db *gorm.DB) *GORMMarketRepository {
+	return &GORMMarketRepository{db: db}
+}
+
+// CreateListing 创建挂单，物品已由 Game 从卖家背包扣除
+func (r *GORMMarketRepository) CreateListing(ctx context.Context, listing common.MarketListing) (*MarketOutcome, error) {
+	if listing.ListingID == "" || listing.SellerID == "" || listing.ItemID == "" || listing.Count <= 0 {
+		return nil, fmt.Errorf("%w: invalid listing", common.ErrMarketRejected)
+	}
+	if listing.BuyoutPrice <= 0 && listing.StartPrice <= 0 {
+		return nil, fmt.Errorf("%w: listing needs a buyout or start price", common.ErrMarketRejected)
+	}
+
+	outcome := &MarketOutcome{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing MarketListing
+		err := tx.Where("listing_id = ?", listing.ListingID).First(&existing).Error
+		if err == nil {
+			if existing.SellerID != listing.SellerID {
+				return fmt.Errorf("%w: listing %s belongs to another player", common.ErrMarketRejected, listing.ListingID)
+			}
+			outcome.Listing = existing.toListing()
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load listing: %w", err)
+		}
+
+		var active int64
+		if err := tx.Model(&MarketListing{}).
+			Where("seller_id = ? AND status = ?", listing.SellerID, common.MarketStatusActive).
+			Count(&active).Error; err != nil {
+			return fmt.Errorf("failed to count listings: %w", err)
+		}
+		if active >= common.MarketMaxListings {
+			return fmt.Errorf("%w: too many active listings", common.ErrMarketRejected)
+		}
+
+		row := MarketListing{
+			ListingID:   listing.ListingID,
+			SellerID:    listing.SellerID,
+			ItemID:      listing.ItemID,
+			ItemType:    listing.ItemType,
+			Count:       listing.Count,
+			Currency:    listing.Currency,
+			BuyoutPrice: listing.BuyoutPrice,
+			StartPrice:  listing.StartPrice,
+			Status:      common.MarketStatusActive,
+			ExpiresAt:   time.Unix(listing.ExpiresAt, 0),
+		}
+		if err := tx.Create(&row).Error; err != nil {
+			return fmt.Errorf("failed to create listing: %w", err)
+		}
+		outcome.Listing = row.toListing()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outcome, nil
+}
+
+// BuyListing 一口价购买，price 为买家已托管的货币（必须等于一口价）；物品与卖家所得通过邮件送达，
+// 当前最高出价退还给出价者
+func (r *GORMMarketRepository) BuyListing(ctx context.Context, tradeID, buyerID, listingID string, price int64) (*MarketOutcome, error) {
+	if tradeID == "" || buyerID == "" {
+		return nil, fmt.Errorf("%w: invalid trade", common.ErrMarketRejected)
+	}
+
+	outcome := &MarketOutcome{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row, err := lockListing(tx, listingID)
+		if err != nil {
+			return err
+		}
+
+		var trade MarketTrade
+		err = tx.Where("trade_id = ?", tradeID).First(&trade).Error
+		if err == nil {
+			if trade.BuyerID != buyerID || trade.ListingID != listingID {
+				return fmt.Errorf("%w: trade %s does not match", common.ErrMarketRejected, tradeID)
+			}
+			outcome.Listing = row.toListing()
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load trade: %w", err)
+		}
+
+		now := time.Now()
+		switch {
+		case row.Status != common.MarketStatusActive || !now.Before(row.ExpiresAt):
+			return fmt.Errorf("%w: listing %s is no longer available", common.ErrMarketRejected, listingID)
+		case row.BuyoutPrice <= 0:
+			return fmt.Errorf("%w: listing %s is auction only", common.ErrMarketRejected, listingID)
+		case row.SellerID == buyerID:
+			return fmt.Errorf("%w: cannot buy your own listing", common.ErrMarketRejected)
+		case price != row.BuyoutPrice:
+			return fmt.Errorf("%w: price changed to %d", common.ErrMarketRejected, row.BuyoutPrice)
+		}
+
+		if row.BidderID != "" {
+			mail, err := refundBid(tx, row, bidStatusRefunded, now)
+			if err != nil {
+				return err
+			}
+			outcome.Mails = append(outcome.Mails, *mail)
+		}
+		mails, err := settleTrade(tx, row, tradeID, buyerID, row.BuyoutPrice, "buyout", now)
+		if err != nil {
+			return err
+		}
+		outcome.Mails = append(outcome.Mails, mails...)
+		outcome.Listing = row.toListing()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outcome, nil
+}
+
+// PlaceBid 在拍卖挂单上出价，amount 为出价者已托管的货币；被超出的上一个出价通过邮件退还
+func (r *GORMMarketRepository) PlaceBid(ctx context.Context, bidID, bidderID, listingID string, amount int64) (*MarketOutcome, error) {
+	if bidID == "" || bidderID == "" {
+		return nil, fmt.Errorf("%w: invalid bid", common.ErrMarketRejected)
+	}
+
+	outcome := &MarketOutcome{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row, err := lockListing(tx, listingID)
+		if err != nil {
+			return err
+		}
+
+		var bid MarketBid
+		err = tx.Where("bid_id = ?", bidID).First(&bid).Error
+		if err == nil {
+			if bid.BidderID != bidderID || bid.ListingID != listingID {
+				return fmt.Errorf("%w: bid %s does not match", common.ErrMarketRejected, bidID)
+			}
+			outcome.Listing = row.toListing()
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load bid: %w", err)
+		}
+
+		now := time.Now()
+		switch {
+		case row.Status != common.MarketStatusActive || !now.Before(row.ExpiresAt):
+			return fmt.Errorf("%w: listing %s is no longer available", common.ErrMarketRejected, listingID)
+		case row.StartPrice <= 0:
+			return fmt.Errorf("%w: listing %s does not accept bids", common.ErrMarketRejected, listingID)
+		case row.SellerID == bidderID:
+			return fmt.Errorf("%w: cannot bid on your own listing", common.ErrMarketRejected)
+		}
+		if minBid := common.MarketMinNextBid(*row.toListing()); amount < minBid {
+			return fmt.Errorf("%w: bid must be at least %d", common.ErrMarketRejected, minBid)
+		}
+
+		if row.BidderID != "" {
+			mail, err := refundBid(tx, row, bidStatusOutbid, now)
+			if err != nil {
+				return err
+			}
+			outcome.Mails = append(outcome.Mails, *mail)
+		}
+		bid = MarketBid{
+			BidID:     bidID,
+			ListingID: listingID,
+			BidderID:  bidderID,
+			Amount:    amount,
+			Status:    bidStatusActive,
+		}
+		if err := tx.Create(&bid).Error; err != nil {
+			return fmt.Errorf("failed to create bid: %w", err)
+		}
+		row.CurrentBid = amount
+		row.BidderID = bidderID
+		if err := tx.Model(row).Updates(map[string]interface{}{"current_bid": amount, "bidder_id": bidderID}).Error; err != nil {
+			return fmt.Errorf("failed to update listing: %w", err)
+		}
+		outcome.Listing = row.toListing()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outcome, nil
+}
+
+// CancelListing 卖家撤单，已有出价的挂单不能撤回；物品通过邮件退还
+func (r *GORMMarketRepository) CancelListing(ctx context.Context, sellerID, listingID string) (*MarketOutcome, error) {
+	outcome := &MarketOutcome{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row, err := lockListing(tx, listingID)
+		if err != nil {
+			return err
+		}
+		if row.SellerID != sellerID {
+			return fmt.Errorf("%w: listing %s belongs to another player", common.ErrMarketRejected, listingID)
+		}
+		if row.Status == common.MarketStatusCancelled {
+			outcome.Listing = row.toListing()
+			return nil
+		}
+		if row.Status != common.MarketStatusActive {
+			return fmt.Errorf("%w: listing %s is already %s", common.ErrMarketRejected, listingID, row.Status)
+		}
+		if row.BidderID != "" {
+			return fmt.Errorf("%w: listing %s has bids", common.ErrMarketRejected, listingID)
+		}
+
+		mail, err := closeListing(tx, row, common.MarketStatusCancelled, time.Now())
+		if err != nil {
+			return err
+		}
+		outcome.Mails = append(outcome.Mails, *mail)
+		outcome.Listing = row.toListing()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outcome, nil
+}
+
+// SettleExpired 结算到期的挂单：有出价时成交给最高出价者，否则物品退还卖家；返回投递的邮件
+func (r *GORMMarketRepository) SettleExpired(ctx context.Context, now time.Time, limit int) ([]common.Mail, error) {
+	var listingIDs []string
+	if err := r.db.WithContext(ctx).Model(&MarketListing{}).
+		Where("status = ? AND expires_at <= ?", common.MarketStatusActive, now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Pluck("listing_id", &listingIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to find expired listings: %w", err)
+	}
+
+	var mails []common.Mail
+	for _, listingID := range listingIDs {
+		err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			row, err := lockListing(tx, listingID)
+			if err != nil {
+				return err
+			}
+			// 加锁前可能已被购买或撤回
+			if row.Status != common.MarketStatusActive || row.ExpiresAt.After(now) {
+				return nil
+			}
+
+			if row.BidderID == "" {
+				mail, err := closeListing(tx, row, common.MarketStatusExpired, now)
+				if err != nil {
+					return err
+				}
+				mails = append(mails, *mail)
+				return nil
+			}
+
+			if err := tx.Model(&MarketBid{}).
+				Where("listing_id = ? AND status = ?", row.ListingID, bidStatusActive).
+				Update("status", bidStatusWon).Error; err != nil {
+				return fmt.Errorf("failed to update bid: %w", err)
+			}
+			settled, err := settleTrade(tx, row, "auction_"+row.ListingID, row.BidderID, row.CurrentBid, "auction", now)
+			if err != nil {
+				return err
+			}
+			mails = append(mails, settled...)
+			return nil
+		})
+		if err != nil {
+			return mails, err
+		}
+	}
+	return mails, nil
+}
+
+// Search 按条件搜索在售的挂单，返回当前页与符合条件的总数
+func (r *GORMMarketRepository) Search(ctx context.Context, query common.MarketQuery) ([]common.MarketListing, int64, error) {
+	db := r.db.WithContext(ctx).Model(&MarketListing{}).
+		Where("status = ? AND expires_at > ?", common.MarketStatusActive, time.Now())
+	if query.ItemID != "" {
+		db = db.Where("item_id = ?", query.ItemID)
+	}
+	if query.ItemType != "" {
+		db = db.Where("item_type = ?", query.ItemType)
+	}
+	if query.MinPrice > 0 {
+		db = db.Where("buyout_price >= ?", query.MinPrice)
+	}
+	if query.MaxPrice > 0 {
+		db = db.Where("buyout_price > 0 AND buyout_price <= ?", query.MaxPrice)
+	}
+	if query.AuctionOnly {
+		db = db.Where("start_price > 0")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count listings: %w", err)
+	}
+
+	order := "created_at DESC, id DESC"
+	switch query.Sort {
+	case common.MarketSortPriceAsc:
+		order = "buyout_price ASC, id ASC"
+	case common.MarketSortPriceDesc:
+		order = "buyout_price DESC, id DESC"
+	case common.MarketSortEndingSoon:
+		order = "expires_at ASC, id ASC"
+	}
+	limit := query.Limit
+	if limit <= 0 || limit > common.MarketSearchLimit {
+		limit = common.MarketSearchLimit
+	}
+
+	var rows []MarketListing
+	if err := db.Order(order).Offset(max(query.Offset, 0)).Limit(limit).Find(&rows).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to search listings: %w", err)
+	}
+	return toListings(rows), total, nil
+}
+
+// ListByPlayer 玩家在售的挂单以及玩家当前领先出价的挂单
+func (r *GORMMarketRepository) ListByPlayer(ctx context.Context, playerID string) ([]common.MarketListing, error) {
+	var rows []MarketListing
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND (seller_id = ? OR bidder_id = ?)", common.MarketStatusActive, playerID, playerID).
+		Order("expires_at ASC, id ASC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list player listings: %w", err)
+	}
+	return toListings(rows), nil
+}
+
+// eraseMarketData 删除玩家的市场数据（在删除角色或账号的事务内调用）：
+// 在售挂单上其他玩家的出价通过邮件退还，玩家在其他挂单上的领先出价随之作废
+func eraseMarketData(tx *gorm.DB, playerIDs []string, now time.Time) error {
+	var listings []MarketListing
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("seller_id IN ? AND status = ? AND bidder_id <> ''", playerIDs, common.MarketStatusActive).
+		Find(&listings).Error; err != nil {
+		return fmt.Errorf("failed to load market listings: %w", err)
+	}
+	for i := range listings {
+		if _, err := refundBid(tx, &listings[i], bidStatusRefunded, now); err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&MarketListing{}).
+		Where("bidder_id IN ? AND status = ?", playerIDs, common.MarketStatusActive).
+		Updates(map[string]interface{}{"current_bid": 0, "bidder_id": ""}).Error; err != nil {
+		return fmt.Errorf("failed to clear market bids: %w", err)
+	}
+
+	if err := tx.Where("seller_id IN ?", playerIDs).Delete(&MarketListing{}).Error; err != nil {
+		return fmt.Errorf("failed to delete market listings: %w", err)
+	}
+	if err := tx.Where("bidder_id IN ?", playerIDs).Delete(&MarketBid{}).Error; err != nil {
+		return fmt.Errorf("failed to delete market bids: %w", err)
+	}
+	if err := tx.Where("seller_id IN ? OR buyer_id IN ?", playerIDs, playerIDs).Delete(&MarketTrade{}).Error; err != nil {
+		return fmt.Errorf("failed to delete market trades: %w", err)
+	}
+	return nil
+}
+
+// lockListing 在事务内锁定挂单
+func lockListing(tx *gorm.DB, listingID string) (*MarketListing, error) {
+	var row MarketListing
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("listing_id = ?", listingID).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: listing %s not found", common.ErrMarketRejected, listingID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load listing: %w", err)
+	}
+	return &row, nil
+}
+
+// refundBid 将挂单当前最高出价退还给出价者并清空出价，status 为出价的新状态
+func refundBid(tx *gorm.DB, row *MarketListing, status string, now time.Time) (*common.Mail, error) {
+	var bid MarketBid
+	if err := tx.Where("listing_id = ? AND status = ?", row.ListingID, bidStatusActive).
+		Order("id DESC").First(&bid).Error; err != nil {
+		return nil, fmt.Errorf("failed to load bid on %s: %w", row.ListingID, err)
+	}
+	if err := tx.Model(&bid).Update("status", status).Error; err != nil {
+		return nil, fmt.Errorf("failed to update bid: %w", err)
+	}
+
+	mail := marketMail("mk_refund_"+bid.BidID, bid.BidderID, "出价退还", "你在市场上的出价已被超出或挂单已结束，出价退还",
+		common.RewardBundle{Resources: map[string]int64{row.Currency: bid.Amount}}, now)
+	if err := createMail(tx, mail); err != nil {
+		return nil, err
+	}
+
+	row.CurrentBid = 0
+	row.BidderID = ""
+	if err := tx.Model(row).Updates(map[string]interface{}{"current_bid": 0, "bidder_id": ""}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update listing: %w", err)
+	}
+	return &mail, nil
+}
+
+// settleTrade 成交：记录交易，物品邮寄给买家，扣除手续费后的所得邮寄给卖家
+func settleTrade(tx *gorm.DB, row *MarketListing, tradeID, buyerID string, price int64, kind string, now time.Time) ([]common.Mail, error) {
+	fee := common.MarketFee(price)
+	trade := MarketTrade{
+		TradeID:   tradeID,
+		ListingID: row.ListingID,
+		SellerID:  row.SellerID,
+		BuyerID:   buyerID,
+		ItemID:    row.ItemID,
+		Count:     row.Count,
+		Currency:  row.Currency,
+		Price:     price,
+		Fee:       fee,
+		Kind:      kind,
+	}
+	if err := tx.Create(&trade).Error; err != nil {
+		return nil, fmt.Errorf("failed to record trade: %w", err)
+	}
+
+	row.Status = common.MarketStatusSold
+	row.BuyerID = buyerID
+	row.ClosedAt = &now
+	if err := tx.Model(row).Updates(map[string]interface{}{
+		"status": row.Status, "buyer_id": buyerID, "closed_at": now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to close listing: %w", err)
+	}
+
+	mails := []common.Mail{
+		marketMail("mk_buy_"+tradeID, buyerID, "市场购得", "你在市场上购得的物品",
+			common.RewardBundle{Items: map[string]int{row.ItemID: row.Count}}, now),
+	}
+	if proceeds := price - fee; proceeds > 0 {
+		mails = append(mails, marketMail("mk_sale_"+tradeID, row.SellerID, "市场售出",
+			fmt.Sprintf("你的挂单以 %d 成交，扣除手续费 %d", price, fee),
+			common.RewardBundle{Resources: map[string]int64{row.Currency: proceeds}}, now))
+	}
+	for _, mail := range mails {
+		if err := createMail(tx, mail); err != nil {
+			return nil, err
+		}
+	}
+	return mails, nil
+}
+
+// closeListing 撤回或到期的挂单，物品邮寄退还卖家
+func closeListing(tx *gorm.DB, row *MarketListing, status string, now time.Time) (*common.Mail, error) {
+	row.Status = status
+	row.ClosedAt = &now
+	if err := tx.Model(row).Updates(map[string]interface{}{"status": status, "closed_at": now}).Error; err != nil {
+		return nil, fmt.Errorf("failed to close listing: %w", err)
+	}
+
+	mail := marketMail("mk_return_"+row.ListingID, row.SellerID, "挂单退回", "你的挂单已撤回或到期未售出，物品退还",
+		common.RewardBundle{Items: map[string]int{row.ItemID: row.Count}}, now)
+	if err := createMail(tx, mail); err != nil {
+		return nil, err
+	}
+	return &mail, nil
+}
+
+// marketMail 市场发出的邮件，邮件ID由操作确定，重复结算时不会重复投递
+func marketMail(mailID, playerID, subject, body string, attachments common.RewardBundle, now time.Time) common.Mail {
+	return common.Mail{
+		MailID:      mailID,
+		PlayerID:    playerID,
+		Sender:      marketMailSender,
+		Subject:     subject,
+		Body:        body,
+		Attachments: attachments,
+		CreatedAt:   now.Unix(),
+		ExpiresAt:   now.AddDate(0, 0, common.MailRetentionDays).Unix(),
+	}
+}
+
+// toListing 转换为服务间传递的挂单
+func (m MarketListing) toListing() *common.MarketListing {
+	return &common.MarketListing{
+		ListingID:   m.ListingID,
+		SellerID:    m.SellerID,
+		ItemID:      m.ItemID,
+		ItemType:    m.ItemType,
+		Count:       m.Count,
+		Currency:    m.Currency,
+		BuyoutPrice: m.BuyoutPrice,
+		StartPrice:  m.StartPrice,
+		CurrentBid:  m.CurrentBid,
+		BidderID:    m.BidderID,
+		BuyerID:     m.BuyerID,
+		Status:      m.Status,
+		ExpiresAt:   m.ExpiresAt.Unix(),
+		CreatedAt:   m.CreatedAt.Unix(),
+	}
+}
+
+// toListings 批量转换挂单
+func toListings(rows []MarketListing) []common.MarketListing {
+	listings := make([]common.MarketListing, 0, len(rows))
+	for _, row := range rows {
+		listings = append(listings, *row.toListing())
+	}
+	return listings
+}
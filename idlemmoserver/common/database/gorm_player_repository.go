@@ -28,19 +28,24 @@ func NewGORMPlayerRepository(db *gorm.DB, redis *Redis) *GORMPlayerRepository {
 
 // SavePlayerData 保存玩家数据
 func (r *GORMPlayerRepository) SavePlayerData(ctx context.Context, playerData *common.PlayerData) error {
+	now := time.Now()
+	playerData.LastSaveTime = now
+
 	// 序列化游戏数据
 	gameDataJSON, err := json.Marshal(playerData)
 	if err != nil {
 		return fmt.Errorf("failed to marshal player data: %w", err)
 	}
 
-	// 更新数据库
+	// 更新数据库，等级与经验同时写入 players 表的列，供排行榜、角色资料和资质重随判断使用
 	result := r.db.WithContext(ctx).Model(&Player{}).
 		Where("player_id = ?", playerData.PlayerID).
 		Updates(map[string]interface{}{
-			"last_save_time": time.Now(),
+			"level":          max(playerData.Level, 1),
+			"exp":            playerData.Exp,
+			"last_save_time": now,
 			"game_data":      string(gameDataJSON),
-			"updated_at":     time.Now(),
+			"updated_at":     now,
 		})
 
 	if result.Error != nil {
@@ -91,6 +96,9 @@ func (r *GORMPlayerRepository) LoadPlayerData(ctx context.Context, playerID stri
 			if exp, ok := extraData["exp"].(float64); ok {
 				playerData.Exp = int64(exp)
 			}
+			if gameData, ok := extraData["game_data"].(map[string]interface{}); ok {
+				playerData.GameData = gameData
+			}
 		}
 	}
 
@@ -107,7 +115,7 @@ func (r *GORMPlayerRepository) LoadPlayerData(ctx context.Context, playerID stri
 // PlayerExists 检查玩家是否存在
 func (r *GORMPlayerRepository) PlayerExists(ctx context.Context, playerID string) (bool, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&Player{}).Where("player_id = ?", playerID).Count(&count)
+	err := r.db.WithContext(ctx).Model(&Player{}).Where("player_id = ?", playerID).Count(&count).Error
 	if err != nil {
 		return false, fmt.Errorf("failed to check player existence: %w", err)
 	}
@@ -203,7 +211,7 @@ func (r *GORMPlayerRepository) GetPlayerStats(ctx context.Context, playerID stri
 	var player Player
 	err := r.db.WithContext(ctx).Select(
 		"level", "exp", "total_playtime", "login_count", "created_at", "last_save_time",
-	).Where("player_id = ?", playerID).First(&player)
+	).Where("player_id = ?", playerID).First(&player).Error
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get player stats: %w", err)
@@ -222,11 +230,12 @@ func (r *GORMPlayerRepository) GetPlayerStats(ctx context.Context, playerID stri
 	return stats, nil
 }
 
-// GetTopPlayersByLevel 获取等级排行榜
+// GetTopPlayersByLevel 获取等级排行榜（不包含已删除或处于删除冷静期的角色）
 func (r *GORMPlayerRepository) GetTopPlayersByLevel(ctx context.Context, limit int) ([]common.PlayerRanking, error) {
 	var players []Player
 	err := r.db.WithContext(ctx).
-		Select("player_id", "username", "level", "exp").
+		Select("player_id", "name", "level", "exp").
+		Where("is_deleted = ? AND delete_scheduled_at IS NULL", false).
 		Order("level DESC, exp DESC").
 		Limit(limit).
 		Find(&players).Error
@@ -240,7 +249,7 @@ func (r *GORMPlayerRepository) GetTopPlayersByLevel(ctx context.Context, limit i
 		rankings = append(rankings, common.PlayerRanking{
 			Rank:     i + 1,
 			PlayerID: player.PlayerID,
-			Username: player.Username,
+			Name:     player.Name,
 			Level:    player.Level,
 			Exp:      player.Exp,
 		})
@@ -249,6 +258,39 @@ func (r *GORMPlayerRepository) GetTopPlayersByLevel(ctx context.Context, limit i
 	return rankings, nil
 }
 
+// GetPlayerProfile 获取角色公开资料，名次按等级排行榜规则计算
+func (r *GORMPlayerRepository) GetPlayerProfile(ctx context.Context, playerID string) (*common.PlayerProfile, error) {
+	var player Player
+	err := r.db.WithContext(ctx).
+		Select("player_id", "name", "level", "exp", "created_at").
+		Where("player_id = ? AND is_deleted = ? AND delete_scheduled_at IS NULL", playerID, false).
+		First(&player).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("player %s not found", playerID)
+		}
+		return nil, fmt.Errorf("failed to get player profile: %w", err)
+	}
+
+	var ahead int64
+	err = r.db.WithContext(ctx).Model(&Player{}).
+		Where("is_deleted = ? AND delete_scheduled_at IS NULL", false).
+		Where("level > ? OR (level = ? AND exp > ?)", player.Level, player.Level, player.Exp).
+		Count(&ahead).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player rank: %w", err)
+	}
+
+	return &common.PlayerProfile{
+		PlayerID:  player.PlayerID,
+		Name:      player.Name,
+		Level:     player.Level,
+		Exp:       player.Exp,
+		Rank:      int(ahead) + 1,
+		CreatedAt: player.CreatedAt,
+	}, nil
+}
+
 // UpdatePlayerRanking 更新排行榜数据
 func (r *GORMPlayerRepository) UpdatePlayerRanking(ctx context.Context, rankingType string, playerID string, score float64) error {
 	if r.redis != nil {
@@ -289,6 +331,30 @@ func (r *GORMPlayerRepository) DeletePlayer(ctx context.Context, playerID string
 		return fmt.Errorf("failed to delete game progress: %w", err)
 	}
 
+	// 删除邮件
+	if err := tx.Where("player_id = ?", playerID).Delete(&Mail{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete mails: %w", err)
+	}
+
+	// 删除购买记录
+	if err := tx.Where("player_id = ?", playerID).Delete(&PurchaseHistory{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete purchase history: %w", err)
+	}
+
+	// 删除市场数据，在售挂单上其他玩家的出价退还
+	if err := eraseMarketData(tx, []string{playerID}, time.Now()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 删除宗门数据，担任宗主的宗门传位给其他成员
+	if err := eraseSectData(tx, []string{playerID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// 删除玩家记录
 	if err := tx.Where("player_id = ?", playerID).Delete(&Player{}).Error; err != nil {
 		tx.Rollback()
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GORMProgressRepository 玩家进度仓库（game_progress 表）
+type GORMProgressRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMProgressRepository 创建玩家进度仓库
+func NewGORMProgressRepository(db *gorm.DB) *GORMProgressRepository {
+	return &GORMProgressRepository{db: db}
+}
+
+// LoadProgress 加载玩家的全部进度记录
+func (r *GORMProgressRepository) LoadProgress(ctx context.Context, playerID string) ([]common.ProgressEntry, error) {
+	var rows []GameProgress
+	if err := r.db.WithContext(ctx).Where("player_id = ?", playerID).Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load progress: %w", err)
+	}
+
+	entries := make([]common.ProgressEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, common.ProgressEntry{
+			Type:  row.ProgressType,
+			Key:   row.ProgressKey,
+			Value: []byte(row.ProgressValue),
+		})
+	}
+	return entries, nil
+}
+
+// SaveProgress 在同一事务中写入（插入或覆盖）多条进度记录
+func (r *GORMProgressRepository) SaveProgress(ctx context.Context, playerID string, entries []common.ProgressEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	rows := make([]GameProgress, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type == "" || entry.Key == "" || len(entry.Value) == 0 {
+			return fmt.Errorf("invalid progress entry %s/%s", entry.Type, entry.Key)
+		}
+		rows = append(rows, GameProgress{
+			PlayerID:      playerID,
+			ProgressType:  entry.Type,
+			ProgressKey:   entry.Key,
+			ProgressValue: string(entry.Value),
+			UpdatedAt:     now,
+		})
+	}
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "player_id"}, {Name: "progress_type"}, {Name: "progress_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"progress_value", "updated_at"}),
+	}).Create(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPurchaseExists 同一玩家的同一请求ID已有购买记录
+var ErrPurchaseExists = errors.New("purchase already recorded")
+
+// GORMPurchaseRepository 商店购买记录仓库（purchase_history 表）
+type GORMPurchaseRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMPurchaseRepository 创建商店购买记录仓库
+func NewGORMPurchaseRepository(db *gorm.DB) *GORMPurchaseRepository {
+	return &GORMPurchaseRepository{db: db}
+}
+
+// RecordPurchase 写入购买记录，同一玩家的同一请求ID已存在时不覆盖并返回 ErrPurchaseExists
+func (r *GORMPurchaseRepository) RecordPurchase(ctx context.Context, record common.PurchaseRecord) error {
+	if record.PlayerID == "" || record.RequestID == "" {
+		return fmt.Errorf("invalid purchase %q for player %q", record.RequestID, record.PlayerID)
+	}
+	price, err := json.Marshal(record.Price)
+	if err != nil {
+		return fmt.Errorf("failed to encode price: %w", err)
+	}
+
+	row := PurchaseHistory{
+		PlayerID:    record.PlayerID,
+		RequestID:   record.RequestID,
+		ShopID:      record.ShopID,
+		OfferID:     record.OfferID,
+		ItemID:      record.ItemID,
+		Count:       record.Count,
+		Price:       string(price),
+		PurchasedAt: time.Unix(record.PurchasedAt, 0),
+	}
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record purchase: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s for player %s", ErrPurchaseExists, record.RequestID, record.PlayerID)
+	}
+	return nil
+}
+
+// FindPurchase 按请求ID查找玩家的购买记录，不存在时返回 nil
+func (r *GORMPurchaseRepository) FindPurchase(ctx context.Context, playerID, requestID string) (*common.PurchaseRecord, error) {
+	var rows []PurchaseHistory
+	err := r.db.WithContext(ctx).
+		Where("player_id = ? AND request_id = ?", playerID, requestID).
+		Limit(1).
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find purchase: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	record, err := purchaseRecord(rows[0])
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ListPurchases 玩家最近的购买记录，按时间从新到旧
+func (r *GORMPurchaseRepository) ListPurchases(ctx context.Context, playerID string, limit int) ([]common.PurchaseRecord, error) {
+	var rows []PurchaseHistory
+	err := r.db.WithContext(ctx).
+		Where("player_id = ?", playerID).
+		Order("purchased_at DESC, id DESC").
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list purchases: %w", err)
+	}
+
+	records := make([]common.PurchaseRecord, 0, len(rows))
+	for _, row := range rows {
+		record, err := purchaseRecord(row)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// purchaseRecord 将表记录转换为购买记录
+func purchaseRecord(row PurchaseHistory) (common.PurchaseRecord, error) {
+	record := common.PurchaseRecord{
+		RequestID:   row.RequestID,
+		PlayerID:    row.PlayerID,
+		ShopID:      row.ShopID,
+		OfferID:     row.OfferID,
+		ItemID:      row.ItemID,
+		Count:       row.Count,
+		PurchasedAt: row.PurchasedAt.Unix(),
+	}
+	if row.Price != "" {
+		if err := json.Unmarshal([]byte(row.Price), &record.Price); err != nil {
+			return record, fmt.Errorf("invalid price in purchase %s: %w", row.RequestID, err)
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,771 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/idle-server/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sectMailSender 宝库拨发邮件的发件来源
+const sectMailSender = "sect"
+
+// 宗门宝库流水类型
+const (
+	sectLogDonate   = "donate"
+	sectLogWithdraw = "withdraw"
+	sectLogUpgrade  = "upgrade"
+)
+
+// GORMSectRepository 宗门仓库（sects、sect_members、sect_applications、sect_treasury_logs 表）
+// 修改宗门的操作在事务内先锁定宗门行再读取成员，同一宗门的操作串行执行
+type GORMSectRepository struct {
+	db *gorm.DB
+}
+
+// NewGORMSectRepository 创建宗门仓库
+func NewGORMSectRepository(db *gorm.DB) *GORMSectRepository {
+	return &GORMSectRepository{db: db}
+}
+
+// CreateSect 创建宗门，创建者成为宗主；宗门ID已存在且属于该玩家时视为重复提交
+func (r *GORMSectRepository) CreateSect(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	if req.OpID == "" || req.Name == "" {
+		return nil, fmt.Errorf("%w: invalid sect", common.ErrSectRejected)
+	}
+
+	var result *common.SectResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing Sect
+		err := tx.Where("sect_id = ?", req.OpID).First(&existing).Error
+		if err == nil {
+			if existing.LeaderID != req.PlayerID {
+				return fmt.Errorf("%w: sect %s belongs to another player", common.ErrSectRejected, req.OpID)
+			}
+			result, err = sectResult(tx, &existing)
+			return err
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load sect: %w", err)
+		}
+
+		if member, err := findSectMember(tx, req.PlayerID); err != nil {
+			return err
+		} else if member != nil {
+			return fmt.Errorf("%w: already in a sect", common.ErrSectRejected)
+		}
+		var taken int64
+		if err := tx.Model(&Sect{}).Where("name = ?", req.Name).Count(&taken).Error; err != nil {
+			return fmt.Errorf("failed to check sect name: %w", err)
+		}
+		if taken > 0 {
+			return fmt.Errorf("%w: sect name %s is taken", common.ErrSectRejected, req.Name)
+		}
+
+		sect := Sect{SectID: req.OpID, Name: req.Name, LeaderID: req.PlayerID, Level: 1}
+		if err := tx.Create(&sect).Error; err != nil {
+			return fmt.Errorf("failed to create sect: %w", err)
+		}
+		if err := tx.Create(&SectMember{SectID: sect.SectID, PlayerID: req.PlayerID, Rank: common.SectRankLeader}).Error; err != nil {
+			return fmt.Errorf("failed to add sect leader: %w", err)
+		}
+		if err := tx.Where("player_id = ?", req.PlayerID).Delete(&SectApplication{}).Error; err != nil {
+			return fmt.Errorf("failed to clear applications: %w", err)
+		}
+		result, err = sectResult(tx, &sect)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Apply 申请加入宗门，已申请过时视为重复提交
+func (r *GORMSectRepository) Apply(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	var result *common.SectResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sect, err := lockSect(tx, req.SectID)
+		if err != nil {
+			return err
+		}
+		if member, err := findSectMember(tx, req.PlayerID); err != nil {
+			return err
+		} else if member != nil {
+			return fmt.Errorf("%w: already in a sect", common.ErrSectRejected)
+		}
+
+		var applications []SectApplication
+		if err := tx.Where("player_id = ?", req.PlayerID).Find(&applications).Error; err != nil {
+			return fmt.Errorf("failed to load applications: %w", err)
+		}
+		applied := false
+		for _, application := range applications {
+			applied = applied || application.SectID == sect.SectID
+		}
+		if !applied {
+			if len(applications) >= common.SectMaxApplications {
+				return fmt.Errorf("%w: too many pending applications", common.ErrSectRejected)
+			}
+			if err := tx.Create(&SectApplication{SectID: sect.SectID, PlayerID: req.PlayerID}).Error; err != nil {
+				return fmt.Errorf("failed to create application: %w", err)
+			}
+		}
+		result, err = sectResult(tx, sect)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Review 审核入门申请，通过时申请者成为弟子并撤回其其他申请
+func (r *GORMSectRepository) Review(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	var result *common.SectResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sect, _, err := lockMemberSect(tx, req.PlayerID, common.SectPermReview)
+		if err != nil {
+			return err
+		}
+		deleted := tx.Where("sect_id = ? AND player_id = ?", sect.SectID, req.TargetID).Delete(&SectApplication{})
+		if deleted.Error != nil {
+			return fmt.Errorf("failed to remove application: %w", deleted.Error)
+		}
+		if deleted.RowsAffected == 0 {
+			return fmt.Errorf("%w: no application from %s", common.ErrSectRejected, req.TargetID)
+		}
+
+		if req.Accept {
+			if err := checkSectLevel(sect, req.Level); err != nil {
+				return err
+			}
+			if member, err := findSectMember(tx, req.TargetID); err != nil {
+				return err
+			} else if member != nil {
+				return fmt.Errorf("%w: %s already joined a sect", common.ErrSectRejected, req.TargetID)
+			}
+			count, err := countSectMembers(tx, sect.SectID, "")
+			if err != nil {
+				return err
+			}
+			if count >= int64(req.Level.MaxMembers) {
+				return fmt.Errorf("%w: sect is full", common.ErrSectRejected)
+			}
+			if err := tx.Create(&SectMember{SectID: sect.SectID, PlayerID: req.TargetID, Rank: common.SectRankDisciple}).Error; err != nil {
+				return fmt.Errorf("failed to add member: %w", err)
+			}
+			if err := tx.Where("player_id = ?", req.TargetID).Delete(&SectApplication{}).Error; err != nil {
+				return fmt.Errorf("failed to clear applications: %w", err)
+			}
+		}
+		result, err = sectResult(tx, sect)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Leave 退出宗门；宗主需先传位，宗门只剩宗主一人时退出即解散
+func (r *GORMSectRepository) Leave(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	var result *common.SectResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sect, member, err := lockMemberSect(tx, req.PlayerID, "")
+		if err != nil {
+			return err
+		}
+		if member.Rank == common.SectRankLeader {
+			count, err := countSectMembers(tx, sect.SectID, "")
+			if err != nil {
+				return err
+			}
+			if count > 1 {
+				return fmt.Errorf("%w: transfer leadership before leaving", common.ErrSectRejected)
+			}
+			result, err = sectResult(tx, sect)
+			if err != nil {
+				return err
+			}
+			return deleteSect(tx, sect.SectID)
+		}
+
+		if err := tx.Delete(member).Error; err != nil {
+			return fmt.Errorf("failed to remove member: %w", err)
+		}
+		result, err = sectResult(tx, sect)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Kick 逐出职位低于自己的成员
+func (r *GORMSectRepository) Kick(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	return r.manage(ctx, req, common.SectPermKick, func(tx *gorm.DB, sect *Sect, actor, target *SectMember) error {
+		if !common.SectRankOutranks(actor.Rank, target.Rank) {
+			return fmt.Errorf("%w: cannot kick a member of equal or higher rank", common.ErrSectRejected)
+		}
+		if err := tx.Delete(target).Error; err != nil {
+			return fmt.Errorf("failed to remove member: %w", err)
+		}
+		return nil
+	})
+}
+
+// SetRank 任免长老（长老与弟子之间调整），长老人数受宗门等级限制
+func (r *GORMSectRepository) SetRank(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	if req.Rank != common.SectRankElder && req.Rank != common.SectRankDisciple {
+		return nil, fmt.Errorf("%w: invalid rank %q", common.ErrSectRejected, req.Rank)
+	}
+	return r.manage(ctx, req, common.SectPermSetRank, func(tx *gorm.DB, sect *Sect, actor, target *SectMember) error {
+		if target.Rank == common.SectRankLeader {
+			return fmt.Errorf("%w: use transfer to change the leader", common.ErrSectRejected)
+		}
+		if target.Rank == req.Rank {
+			return nil
+		}
+		if req.Rank == common.SectRankElder {
+			if err := checkSectLevel(sect, req.Level); err != nil {
+				return err
+			}
+			elders, err := countSectMembers(tx, sect.SectID, common.SectRankElder)
+			if err != nil {
+				return err
+			}
+			if elders >= int64(req.Level.MaxElders) {
+				return fmt.Errorf("%w: elder limit reached", common.ErrSectRejected)
+			}
+		}
+		if err := tx.Model(target).Update("rank", req.Rank).Error; err != nil {
+			return fmt.Errorf("failed to update rank: %w", err)
+		}
+		return nil
+	})
+}
+
+// Transfer 传位，原宗主接替新宗主原来的职位
+func (r *GORMSectRepository) Transfer(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	return r.manage(ctx, req, common.SectPermTransfer, func(tx *gorm.DB, sect *Sect, actor, target *SectMember) error {
+		if err := tx.Model(actor).Update("rank", target.Rank).Error; err != nil {
+			return fmt.Errorf("failed to update rank: %w", err)
+		}
+		if err := tx.Model(target).Update("rank", common.SectRankLeader).Error; err != nil {
+			return fmt.Errorf("failed to update rank: %w", err)
+		}
+		sect.LeaderID = target.PlayerID
+		if err := tx.Model(sect).Update("leader_id", target.PlayerID).Error; err != nil {
+			return fmt.Errorf("failed to update leader: %w", err)
+		}
+		return nil
+	})
+}
+
+// Disband 解散宗门，宝库中的金币随之清空；返回解散前的宗门与成员
+func (r *GORMSectRepository) Disband(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	var result *common.SectResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sect, _, err := lockMemberSect(tx, req.PlayerID, common.SectPermDisband)
+		if err != nil {
+			return err
+		}
+		result, err = sectResult(tx, sect)
+		if err != nil {
+			return err
+		}
+		return deleteSect(tx, sect.SectID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetNotice 修改宗门公告
+func (r *GORMSectRepository) SetNotice(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	var result *common.SectResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sect, _, err := lockMemberSect(tx, req.PlayerID, common.SectPermNotice)
+		if err != nil {
+			return err
+		}
+		sect.Notice = req.Notice
+		if err := tx.Model(sect).Update("notice", req.Notice).Error; err != nil {
+			return fmt.Errorf("failed to update notice: %w", err)
+		}
+		result, err = sectResult(tx, sect)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Donate 向宝库捐献金币（已由 Game 从玩家余额扣除），增加宗门经验与成员贡献；流水ID已存在时视为重复提交
+func (r *GORMSectRepository) Donate(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	if req.OpID == "" || req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: invalid donation", common.ErrSectRejected)
+	}
+
+	var result *common.SectResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先按流水ID去重，捐献后退出宗门的玩家重试时仍返回成功
+		if done, err := findTreasuryLog(tx, req.OpID, req.PlayerID); err != nil || done != nil {
+			if err == nil {
+				result, err = sectResultByID(tx, done.SectID)
+			}
+			return err
+		}
+
+		sect, member, err := lockMemberSect(tx, req.PlayerID, "")
+		if err != nil {
+			return err
+		}
+		sect.Treasury += req.Amount
+		sect.Exp += req.Amount * common.SectExpPerGold
+		if err := tx.Model(sect).Updates(map[string]interface{}{"treasury": sect.Treasury, "exp": sect.Exp}).Error; err != nil {
+			return fmt.Errorf("failed to update treasury: %w", err)
+		}
+		if err := tx.Model(member).Update("contribution", gorm.Expr("contribution + ?", req.Amount*common.SectContributionPerGold)).Error; err != nil {
+			return fmt.Errorf("failed to update contribution: %w", err)
+		}
+		if err := tx.Create(&SectTreasuryLog{
+			OpID: req.OpID, SectID: sect.SectID, PlayerID: req.PlayerID, Kind: sectLogDonate, Amount: req.Amount,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to record donation: %w", err)
+		}
+		result, err = sectResult(tx, sect)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Withdraw 从宝库向成员拨发金币，金币通过邮件送达；流水ID已存在时视为重复提交
+func (r *GORMSectRepository) Withdraw(ctx context.Context, req common.SectRequest) (*common.SectResult, *common.Mail, error) {
+	if req.OpID == "" || req.Amount <= 0 {
+		return nil, nil, fmt.Errorf("%w: invalid withdrawal", common.ErrSectRejected)
+	}
+
+	var result *common.SectResult
+	var mail *common.Mail
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if done, err := findTreasuryLog(tx, req.OpID, req.PlayerID); err != nil || done != nil {
+			if err == nil {
+				result, err = sectResultByID(tx, done.SectID)
+			}
+			return err
+		}
+
+		sect, _, err := lockMemberSect(tx, req.PlayerID, common.SectPermWithdraw)
+		if err != nil {
+			return err
+		}
+		target, err := findSectMember(tx, req.TargetID)
+		if err != nil {
+			return err
+		}
+		if target == nil || target.SectID != sect.SectID {
+			return fmt.Errorf("%w: %s is not a member", common.ErrSectRejected, req.TargetID)
+		}
+		if sect.Treasury < req.Amount {
+			return fmt.Errorf("%w: insufficient treasury", common.ErrSectRejected)
+		}
+
+		sect.Treasury -= req.Amount
+		if err := tx.Model(sect).Update("treasury", sect.Treasury).Error; err != nil {
+			return fmt.Errorf("failed to update treasury: %w", err)
+		}
+		if err := tx.Create(&SectTreasuryLog{
+			OpID: req.OpID, SectID: sect.SectID, PlayerID: req.PlayerID, TargetID: req.TargetID, Kind: sectLogWithdraw, Amount: -req.Amount,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to record withdrawal: %w", err)
+		}
+
+		now := time.Now()
+		sent := common.Mail{
+			MailID:      "sc_withdraw_" + req.OpID,
+			PlayerID:    req.TargetID,
+			Sender:      sectMailSender,
+			Subject:     "宗门拨发",
+			Body:        fmt.Sprintf("%s 宝库拨发的金币", sect.Name),
+			Attachments: common.RewardBundle{Resources: map[string]int64{common.SectCurrency: req.Amount}},
+			CreatedAt:   now.Unix(),
+			ExpiresAt:   now.AddDate(0, 0, common.MailRetentionDays).Unix(),
+		}
+		if err := createMail(tx, sent); err != nil {
+			return err
+		}
+		mail = &sent
+		result, err = sectResult(tx, sect)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, mail, nil
+}
+
+// Upgrade 宗门经验达到下一等级要求时消耗宝库金币升级
+func (r *GORMSectRepository) Upgrade(ctx context.Context, req common.SectRequest) (*common.SectResult, error) {
+	var result *common.SectResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sect, _, err := lockMemberSect(tx, req.PlayerID, common.SectPermUpgrade)
+		if err != nil {
+			return err
+		}
+		if err := checkSectLevel(sect, req.Level); err != nil {
+			return err
+		}
+		next := req.Next
+		switch {
+		case next == nil || next.Level != sect.Level+1:
+			return fmt.Errorf("%w: sect is at max level", common.ErrSectRejected)
+		case sect.Exp < next.Exp:
+			return fmt.Errorf("%w: requires %d sect exp", common.ErrSectRejected, next.Exp)
+		case sect.Treasury < next.Cost:
+			return fmt.Errorf("%w: requires %d gold in treasury", common.ErrSectRejected, next.Cost)
+		}
+
+		sect.Level = next.Level
+		sect.Treasury -= next.Cost
+		if err := tx.Model(sect).Updates(map[string]interface{}{"level": sect.Level, "treasury": sect.Treasury}).Error; err != nil {
+			return fmt.Errorf("failed to upgrade sect: %w", err)
+		}
+		if err := tx.Create(&SectTreasuryLog{
+			OpID: fmt.Sprintf("up_%s_%d", sect.SectID, sect.Level), SectID: sect.SectID, PlayerID: req.PlayerID, Kind: sectLogUpgrade, Amount: -next.Cost,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to record upgrade: %w", err)
+		}
+		result, err = sectResult(tx, sect)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetSect 宗门概况与成员
+func (r *GORMSectRepository) GetSect(ctx context.Context, sectID string) (*common.SectResult, error) {
+	result, err := sectResultByID(r.db.WithContext(ctx), sectID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetMembership 玩家所在的宗门与职位，未加入宗门时返回 nil
+func (r *GORMSectRepository) GetMembership(ctx context.Context, playerID string) (*common.SectMembership, error) {
+	db := r.db.WithContext(ctx)
+	member, err := findSectMember(db, playerID)
+	if err != nil || member == nil {
+		return nil, err
+	}
+	var sect Sect
+	if err := db.Where("sect_id = ?", member.SectID).First(&sect).Error; err != nil {
+		return nil, fmt.Errorf("failed to load sect: %w", err)
+	}
+	return &common.SectMembership{SectID: sect.SectID, SectName: sect.Name, Rank: member.Rank, Level: sect.Level}, nil
+}
+
+// ListSects 按名称搜索宗门，按等级与经验从高到低
+func (r *GORMSectRepository) ListSects(ctx context.Context, name string, limit int) ([]common.Sect, error) {
+	db := r.db.WithContext(ctx).Model(&Sect{})
+	if name != "" {
+		db = db.Where("name LIKE ?", "%"+name+"%")
+	}
+	var rows []Sect
+	if err := db.Order("level DESC, exp DESC, id ASC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list sects: %w", err)
+	}
+
+	sects := make([]common.Sect, 0, len(rows))
+	for _, row := range rows {
+		count, err := countSectMembers(r.db.WithContext(ctx), row.SectID, "")
+		if err != nil {
+			return nil, err
+		}
+		sect := row.toSect()
+		sect.MemberCount = int(count)
+		sects = append(sects, sect)
+	}
+	return sects, nil
+}
+
+// ListApplications 玩家所在宗门等待审核的申请，需要审核权限
+func (r *GORMSectRepository) ListApplications(ctx context.Context, playerID string) ([]common.SectApplication, error) {
+	db := r.db.WithContext(ctx)
+	member, err := findSectMember(db, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil || !common.SectRankHas(member.Rank, common.SectPermReview) {
+		return nil, fmt.Errorf("%w: no permission to review applications", common.ErrSectRejected)
+	}
+
+	var rows []struct {
+		SectApplication
+		Name string
+	}
+	err = db.Table("sect_applications").
+		Select("sect_applications.*, players.name").
+		Joins("LEFT JOIN players ON players.player_id = sect_applications.player_id").
+		Where("sect_applications.sect_id = ?", member.SectID).
+		Order("sect_applications.created_at ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applications: %w", err)
+	}
+
+	applications := make([]common.SectApplication, 0, len(rows))
+	for _, row := range rows {
+		applications = append(applications, common.SectApplication{
+			SectID: row.SectID, PlayerID: row.PlayerID, Name: row.Name, CreatedAt: row.CreatedAt.Unix(),
+		})
+	}
+	return applications, nil
+}
+
+// manage 宗门管理操作的公共流程：校验操作者权限与目标成员后执行 apply
+func (r *GORMSectRepository) manage(ctx context.Context, req common.SectRequest, permission string,
+	apply func(tx *gorm.DB, sect *Sect, actor, target *SectMember) error) (*common.SectResult, error) {
+	var result *common.SectResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sect, actor, err := lockMemberSect(tx, req.PlayerID, permission)
+		if err != nil {
+			return err
+		}
+		target, err := findSectMember(tx, req.TargetID)
+		if err != nil {
+			return err
+		}
+		if target == nil || target.SectID != sect.SectID || target.PlayerID == actor.PlayerID {
+			return fmt.Errorf("%w: %s is not another member of the sect", common.ErrSectRejected, req.TargetID)
+		}
+		if err := apply(tx, sect, actor, target); err != nil {
+			return err
+		}
+		result, err = sectResult(tx, sect)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// eraseSectData 删除玩家的宗门数据（在删除角色或账号的事务内调用）：
+// 宗主的宗门传给职位最高、入门最早的其他成员，没有其他成员时解散
+func eraseSectData(tx *gorm.DB, playerIDs []string) error {
+	var leaders []SectMember
+	if err := tx.Where("player_id IN ? AND `rank` = ?", playerIDs, common.SectRankLeader).Find(&leaders).Error; err != nil {
+		return fmt.Errorf("failed to load sect leaders: %w", err)
+	}
+	for _, leader := range leaders {
+		sect, err := lockSect(tx, leader.SectID)
+		if err != nil {
+			return err
+		}
+		var successor SectMember
+		err = tx.Where("sect_id = ? AND player_id NOT IN ?", sect.SectID, playerIDs).
+			Order(clause.OrderBy{Expression: clause.Expr{
+				SQL: "CASE `rank` WHEN ? THEN 0 ELSE 1 END, joined_at ASC, id ASC", Vars: []interface{}{common.SectRankElder},
+			}}).
+			First(&successor).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := deleteSect(tx, sect.SectID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find sect successor: %w", err)
+		}
+		if err := tx.Model(&successor).Update("rank", common.SectRankLeader).Error; err != nil {
+			return fmt.Errorf("failed to update rank: %w", err)
+		}
+		if err := tx.Model(sect).Update("leader_id", successor.PlayerID).Error; err != nil {
+			return fmt.Errorf("failed to update leader: %w", err)
+		}
+	}
+
+	if err := tx.Where("player_id IN ?", playerIDs).Delete(&SectMember{}).Error; err != nil {
+		return fmt.Errorf("failed to delete sect memberships: %w", err)
+	}
+	if err := tx.Where("player_id IN ?", playerIDs).Delete(&SectApplication{}).Error; err != nil {
+		return fmt.Errorf("failed to delete sect applications: %w", err)
+	}
+	if err := tx.Where("player_id IN ?", playerIDs).Delete(&SectTreasuryLog{}).Error; err != nil {
+		return fmt.Errorf("failed to delete sect treasury logs: %w", err)
+	}
+	return nil
+}
+
+// lockSect 在事务内锁定宗门
+func lockSect(tx *gorm.DB, sectID string) (*Sect, error) {
+	var sect Sect
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sect_id = ?", sectID).First(&sect).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: sect %s not found", common.ErrSectRejected, sectID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sect: %w", err)
+	}
+	return &sect, nil
+}
+
+// lockMemberSect 锁定玩家所在的宗门并重新读取成员记录，permission 非空时校验职位权限
+func lockMemberSect(tx *gorm.DB, playerID, permission string) (*Sect, *SectMember, error) {
+	member, err := findSectMember(tx, playerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if member == nil {
+		return nil, nil, fmt.Errorf("%w: not in a sect", common.ErrSectRejected)
+	}
+	sect, err := lockSect(tx, member.SectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 加锁前成员可能已被逐出或调整职位
+	member, err = findSectMember(tx, playerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if member == nil || member.SectID != sect.SectID {
+		return nil, nil, fmt.Errorf("%w: not in a sect", common.ErrSectRejected)
+	}
+	if permission != "" && !common.SectRankHas(member.Rank, permission) {
+		return nil, nil, fmt.Errorf("%w: %s cannot %s", common.ErrSectRejected, member.Rank, permission)
+	}
+	return sect, member, nil
+}
+
+// findSectMember 玩家的成员记录，未加入宗门时返回 nil
+func findSectMember(db *gorm.DB, playerID string) (*SectMember, error) {
+	var member SectMember
+	err := db.Where("player_id = ?", playerID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sect member: %w", err)
+	}
+	return &member, nil
+}
+
+// findTreasuryLog 按流水ID查找已记账的操作，属于其他玩家时拒绝
+func findTreasuryLog(tx *gorm.DB, opID, playerID string) (*SectTreasuryLog, error) {
+	var log SectTreasuryLog
+	err := tx.Where("op_id = ?", opID).First(&log).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load treasury log: %w", err)
+	}
+	if log.PlayerID != playerID {
+		return nil, fmt.Errorf("%w: operation %s belongs to another player", common.ErrSectRejected, opID)
+	}
+	return &log, nil
+}
+
+// checkSectLevel 校验 Game 所见的宗门等级与当前等级一致
+func checkSectLevel(sect *Sect, level *common.SectLevelDef) error {
+	if level == nil || level.Level != sect.Level {
+		return fmt.Errorf("%w: sect level changed, please retry", common.ErrSectRejected)
+	}
+	return nil
+}
+
+// countSectMembers 宗门成员数，rank 非空时只统计该职位
+func countSectMembers(db *gorm.DB, sectID, rank string) (int64, error) {
+	query := db.Model(&SectMember{}).Where("sect_id = ?", sectID)
+	if rank != "" {
+		query = query.Where("`rank` = ?", rank)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count sect members: %w", err)
+	}
+	return count, nil
+}
+
+// deleteSect 删除宗门及其成员和申请；宝库流水保留，解散前已提交的捐献重试时仍按流水ID返回成功
+func deleteSect(tx *gorm.DB, sectID string) error {
+	for _, model := range []interface{}{&SectMember{}, &SectApplication{}, &Sect{}} {
+		if err := tx.Where("sect_id = ?", sectID).Delete(model).Error; err != nil {
+			return fmt.Errorf("failed to delete sect %s: %w", sectID, err)
+		}
+	}
+	return nil
+}
+
+// sectResultByID 按宗门ID生成操作结果，宗门已解散时返回空结果
+func sectResultByID(db *gorm.DB, sectID string) (*common.SectResult, error) {
+	var sect Sect
+	err := db.Where("sect_id = ?", sectID).First(&sect).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &common.SectResult{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sect: %w", err)
+	}
+	return sectResult(db, &sect)
+}
+
+// sectResult 宗门概况与成员（含显示名称）
+func sectResult(db *gorm.DB, sect *Sect) (*common.SectResult, error) {
+	var rows []struct {
+		SectMember
+		Name string
+	}
+	err := db.Table("sect_members").
+		Select("sect_members.*, players.name").
+		Joins("LEFT JOIN players ON players.player_id = sect_members.player_id").
+		Where("sect_members.sect_id = ?", sect.SectID).
+		Order("sect_members.joined_at ASC, sect_members.id ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sect members: %w", err)
+	}
+
+	summary := sect.toSect()
+	summary.MemberCount = len(rows)
+	result := &common.SectResult{Sect: &summary, Members: make([]common.SectMember, 0, len(rows))}
+	for _, row := range rows {
+		result.Members = append(result.Members, common.SectMember{
+			PlayerID:     row.PlayerID,
+			Name:         row.Name,
+			Rank:         row.Rank,
+			Contribution: row.Contribution,
+			JoinedAt:     row.JoinedAt.Unix(),
+		})
+	}
+	return result, nil
+}
+
+// toSect 转换为服务间传递的宗门概况（不含成员数）
+func (s Sect) toSect() common.Sect {
+	return common.Sect{
+		SectID:    s.SectID,
+		Name:      s.Name,
+		LeaderID:  s.LeaderID,
+		Level:     s.Level,
+		Exp:       s.Exp,
+		Treasury:  s.Treasury,
+		Notice:    s.Notice,
+		CreatedAt: s.CreatedAt.Unix(),
+	}
+}
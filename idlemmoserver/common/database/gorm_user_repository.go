@@ -2,12 +2,13 @@ package database
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/idle-server/common"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -37,7 +38,7 @@ func (r *GORMUserRepository) CreateUser(ctx context.Context, username, password
 	}
 
 	// 生成密码哈希
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	passwordHash, err := HashPassword(password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -48,6 +49,12 @@ func (r *GORMUserRepository) CreateUser(ctx context.Context, username, password
 		return nil, fmt.Errorf("failed to generate player ID: %w", err)
 	}
 
+	// 首个角色使用占位显示名称，避免在排行榜等公开场景暴露登录用户名
+	displayName, err := GenerateDisplayName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate display name: %w", err)
+	}
+
 	// 开始事务
 	tx := r.db.Begin()
 	defer func() {
@@ -58,10 +65,12 @@ func (r *GORMUserRepository) CreateUser(ctx context.Context, username, password
 
 	// 创建用户记录
 	user := &User{
-		Username:     username,
-		PasswordHash: string(passwordHash),
-		PlayerID:     playerID,
-		IsActive:     true,
+		Username:       username,
+		PasswordHash:   passwordHash,
+		PlayerID:       playerID,
+		IsActive:       true,
+		Role:           common.RolePlayer,
+		CharacterSlots: common.DefaultCharacterSlots,
 	}
 
 	if err := tx.Create(user).Error; err != nil {
@@ -73,10 +82,15 @@ func (r *GORMUserRepository) CreateUser(ctx context.Context, username, password
 	player := &Player{
 		PlayerID:      playerID,
 		Username:      username,
+		Name:          displayName,
 		GameData:      "{}",
 		TotalPlaytime: 0,
 		LoginCount:    0,
 	}
+	if err := player.SetAptitude(rollAptitude()); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to roll aptitude: %w", err)
+	}
 
 	if err := tx.Create(player).Error; err != nil {
 		tx.Rollback()
@@ -91,8 +105,9 @@ func (r *GORMUserRepository) CreateUser(ctx context.Context, username, password
 	// 创建用户数据对象
 	userData := &common.UserData{
 		Username:  username,
-		Password:  string(passwordHash), // Include hashed password for authentication
+		Password:  passwordHash, // Include hashed password for authentication
 		PlayerID:  playerID,
+		Role:      common.RolePlayer,
 		CreatedAt: time.Now(),
 		LastLogin: time.Now(),
 	}
@@ -216,9 +231,18 @@ func (r *GORMUserRepository) AuthenticateUser(ctx context.Context, username, pas
 	}
 
 	// 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+	ok, needsRehash, err := VerifyPassword(user.PasswordHash, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !ok {
 		return nil, fmt.Errorf("invalid password")
 	}
+	if needsRehash {
+		if err := r.RehashPassword(ctx, username, user.PasswordHash, password); err != nil {
+			log.Printf("Failed to rehash password for %s: %v", username, err)
+		}
+	}
 
 	// 更新最后登录时间
 	if err := r.db.Model(&User{}).Where("username = ?", username).Update("last_login", time.Now()).Error; err != nil {
@@ -237,7 +261,84 @@ func (r *GORMUserRepository) AuthenticateUser(ctx context.Context, username, pas
 
 // UpdateLastLogin 更新最后登录时间
 func (r *GORMUserRepository) UpdateLastLogin(ctx context.Context, playerID string) error {
-	return r.db.WithContext(ctx).Model(&User{}).Where("player_id = ?", playerID).Update("last_login", time.Now()).Error
+	var user User
+	if err := r.db.WithContext(ctx).Select("username").Where("player_id = ?", playerID).First(&user).Error; err != nil {
+		return fmt.Errorf("failed to find user for playerID %s: %w", playerID, err)
+	}
+
+	if err := r.db.WithContext(ctx).Model(&User{}).Where("player_id = ?", playerID).Update("last_login", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to update last login: %w", err)
+	}
+
+	r.invalidateUserCache(ctx, user.Username)
+	if r.redis != nil {
+		r.redis.DeletePlayerData(ctx, fmt.Sprintf("user_by_player:%s", playerID))
+	}
+	return nil
+}
+
+// UpdatePassword 更新用户密码
+func (r *GORMUserRepository) UpdatePassword(ctx context.Context, username, password string) error {
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	result := r.db.WithContext(ctx).Model(&User{}).
+		Where("username = ? AND is_active = ?", username, true).
+		Update("password_hash", passwordHash)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update password: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user %s not found", username)
+	}
+
+	r.invalidateUserCache(ctx, username)
+	return nil
+}
+
+// RehashPassword 使用当前参数重新计算密码哈希
+// 以旧哈希为条件更新，期间密码已被修改时不会覆盖新密码
+func (r *GORMUserRepository) RehashPassword(ctx context.Context, username, oldHash, password string) error {
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	result := r.db.WithContext(ctx).Model(&User{}).
+		Where("username = ? AND password_hash = ?", username, oldHash).
+		Update("password_hash", passwordHash)
+	if result.Error != nil {
+		return fmt.Errorf("failed to rehash password: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	r.invalidateUserCache(ctx, username)
+	log.Printf("Password hash upgraded for user %s", username)
+	return nil
+}
+
+// SetBanned 封禁或解封用户
+func (r *GORMUserRepository) SetBanned(ctx context.Context, username string, banned bool, reason string) error {
+	if !banned {
+		reason = ""
+	}
+
+	result := r.db.WithContext(ctx).Model(&User{}).
+		Where("username = ? AND is_active = ?", username, true).
+		Updates(map[string]interface{}{
+			"is_banned":  banned,
+			"ban_reason": reason,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update ban status: %w", result.Error)
+	}
+
+	r.invalidateUserCache(ctx, username)
+	return nil
 }
 
 // DeleteUser 删除用户（软删除，设置为非活跃）
@@ -292,8 +393,169 @@ func (r *GORMUserRepository) GetUsersByLevel(ctx context.Context, minLevel, maxL
 	return users, nil
 }
 
+// ============ 双因素认证 ============
+
+// ErrTwoFactorStateChanged 条件更新时动态码时间步或恢复码已被并发的请求使用
+var ErrTwoFactorStateChanged = errors.New("two-factor state changed concurrently")
+
+// TwoFactorState 用户的双因素认证状态（包含密钥，仅供 Auth 服务内部使用）
+type TwoFactorState struct {
+	Secret        string
+	Enabled       bool
+	Required      bool
+	LastStep      int64
+	RecoveryCodes []string
+}
+
+// GetTwoFactorState 获取用户的双因素认证状态
+func (r *GORMUserRepository) GetTwoFactorState(ctx context.Context, username string) (*TwoFactorState, error) {
+	var user User
+	err := r.db.WithContext(ctx).
+		Select("totp_secret", "totp_enabled", "totp_last_step", "recovery_codes", "require_two_factor").
+		Where("username = ? AND is_active = ?", username, true).
+		First(&user).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("user %s not found", username)
+		}
+		return nil, fmt.Errorf("failed to get two-factor state: %w", err)
+	}
+
+	state := &TwoFactorState{
+		Secret:   user.TOTPSecret,
+		Enabled:  user.TOTPEnabled,
+		Required: user.RequireTwoFactor,
+		LastStep: user.TOTPLastStep,
+	}
+	if user.RecoveryCodes != "" {
+		if err := json.Unmarshal([]byte(user.RecoveryCodes), &state.RecoveryCodes); err != nil {
+			return nil, fmt.Errorf("failed to decode recovery codes: %w", err)
+		}
+	}
+
+	return state, nil
+}
+
+// SetPendingTOTPSecret 保存待确认的TOTP密钥（尚未开启）
+func (r *GORMUserRepository) SetPendingTOTPSecret(ctx context.Context, username, secret string) error {
+	return r.updateTwoFactor(ctx, username, map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+		"recovery_codes": "",
+	})
+}
+
+// EnableTwoFactor 确认绑定并开启双因素认证
+func (r *GORMUserRepository) EnableTwoFactor(ctx context.Context, username string, step int64, recoveryCodeHashes []string) error {
+	codes, err := json.Marshal(recoveryCodeHashes)
+	if err != nil {
+		return fmt.Errorf("failed to encode recovery codes: %w", err)
+	}
+
+	return r.updateTwoFactor(ctx, username, map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+		"recovery_codes": string(codes),
+	})
+}
+
+// DisableTwoFactor 关闭双因素认证并清除密钥
+func (r *GORMUserRepository) DisableTwoFactor(ctx context.Context, username string) error {
+	return r.updateTwoFactor(ctx, username, map[string]interface{}{
+		"totp_secret":    "",
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+		"recovery_codes": "",
+	})
+}
+
+// UpdateTOTPLastStep 记录最近一次使用的时间步，仅在新时间步大于已记录的时间步时更新，
+// 同一动态码被并发请求使用时只有一个成功，其余返回 ErrTwoFactorStateChanged
+func (r *GORMUserRepository) UpdateTOTPLastStep(ctx context.Context, username string, step int64) error {
+	return r.updateTwoFactorIf(ctx, username, "totp_last_step < ?", step, map[string]interface{}{
+		"totp_last_step": step,
+	})
+}
+
+// UpdateRecoveryCodes 将恢复码从 previous 更新为 remaining（使用后移除），
+// 恢复码已被并发的请求修改时返回 ErrTwoFactorStateChanged
+func (r *GORMUserRepository) UpdateRecoveryCodes(ctx context.Context, username string, previous, remaining []string) error {
+	before, err := json.Marshal(previous)
+	if err != nil {
+		return fmt.Errorf("failed to encode recovery codes: %w", err)
+	}
+	codes, err := json.Marshal(remaining)
+	if err != nil {
+		return fmt.Errorf("failed to encode recovery codes: %w", err)
+	}
+
+	return r.updateTwoFactorIf(ctx, username, "recovery_codes = ?", string(before), map[string]interface{}{
+		"recovery_codes": string(codes),
+	})
+}
+
+// CountTwoFactorEnabled 统计指定角色中已开启双因素认证的账号数
+func (r *GORMUserRepository) CountTwoFactorEnabled(ctx context.Context, role string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&User{}).
+		Where("role = ? AND totp_enabled = ? AND is_active = ?", role, true, true).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count two-factor accounts: %w", err)
+	}
+	return count, nil
+}
+
+// SetRequireTwoFactor 设置账号是否强制开启双因素认证
+func (r *GORMUserRepository) SetRequireTwoFactor(ctx context.Context, username string, required bool) error {
+	return r.updateTwoFactor(ctx, username, map[string]interface{}{
+		"require_two_factor": required,
+	})
+}
+
 // ============ 私有辅助方法 ============
 
+// updateTwoFactor 更新双因素认证相关字段并清除用户缓存
+func (r *GORMUserRepository) updateTwoFactor(ctx context.Context, username string, updates map[string]interface{}) error {
+	result := r.db.WithContext(ctx).Model(&User{}).
+		Where("username = ? AND is_active = ?", username, true).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update two-factor state: %w", result.Error)
+	}
+
+	r.invalidateUserCache(ctx, username)
+	return nil
+}
+
+// updateTwoFactorIf 满足条件时更新双因素认证字段，没有行被更新时返回 ErrTwoFactorStateChanged
+func (r *GORMUserRepository) updateTwoFactorIf(ctx context.Context, username, condition string, arg interface{}, updates map[string]interface{}) error {
+	result := r.db.WithContext(ctx).Model(&User{}).
+		Where("username = ? AND is_active = ?", username, true).
+		Where(condition, arg).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update two-factor state: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTwoFactorStateChanged
+	}
+
+	r.invalidateUserCache(ctx, username)
+	return nil
+}
+
+// invalidateUserCache 清除用户相关缓存
+func (r *GORMUserRepository) invalidateUserCache(ctx context.Context, username string) {
+	if r.redis == nil {
+		return
+	}
+
+	r.redis.DeletePlayerData(ctx, fmt.Sprintf("user:%s", username))
+	r.redis.GetClient().Del(ctx, fmt.Sprintf("user_exists:%s", username))
+}
+
 // generatePlayerID 生成新的PlayerID
 func (r *GORMUserRepository) generatePlayerID() (string, error) {
 	// 使用数据库的自增ID来生成唯一的PlayerID
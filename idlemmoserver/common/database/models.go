@@ -1,6 +1,8 @@
 package database
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/idle-server/common"
@@ -17,6 +19,21 @@ type User struct {
 	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 	LastLogin    *time.Time `json:"last_login"`
 	IsActive     bool       `gorm:"default:true" json:"is_active"`
+	Role         string     `gorm:"size:20;default:player" json:"role"`
+
+	// 双因素认证 - TOTPSecret 在 TOTPEnabled 为 false 时表示待确认的密钥
+	TOTPSecret       string `gorm:"size:64" json:"-"`
+	TOTPEnabled      bool   `gorm:"default:false" json:"totp_enabled"`
+	TOTPLastStep     int64  `gorm:"default:0" json:"-"` // 最近一次使用的时间步，防止动态码重放
+	RecoveryCodes    string `gorm:"type:text" json:"-"` // 恢复码的SHA-256哈希列表(JSON)
+	RequireTwoFactor bool   `gorm:"default:false" json:"require_two_factor"`
+
+	// 封禁状态
+	IsBanned  bool   `gorm:"default:false" json:"is_banned"`
+	BanReason string `gorm:"size:255" json:"ban_reason"`
+
+	// 多角色 - PlayerID 为注册时创建的首个角色，同时作为账号的稳定标识
+	CharacterSlots int `gorm:"default:3" json:"character_slots"`
 
 	// 移除外键约束 - 在应用层通过 PlayerID 关联
 }
@@ -36,6 +53,17 @@ type Player struct {
 	TotalPlaytime int64     `gorm:"default:0" json:"total_playtime"`
 	LoginCount    int       `gorm:"default:0" json:"login_count"`
 
+	// 多角色 - Username 为所属账号
+	Name              string     `gorm:"size:32;uniqueIndex:idx_players_name" json:"name"` // 显示名称，全服唯一（不区分大小写）
+	NameChangedAt     *time.Time `json:"name_changed_at"`
+	LastSelectedAt    *time.Time `json:"last_selected_at"`
+	DeleteScheduledAt *time.Time `gorm:"index" json:"delete_scheduled_at"` // 删除冷静期截止时间
+	IsDeleted         bool       `gorm:"default:false;index" json:"is_deleted"`
+
+	// 灵根资质 - 创建角色时随机生成
+	Aptitude        string `gorm:"type:json" json:"aptitude"`
+	AptitudeRerolls int    `gorm:"default:0" json:"aptitude_rerolls"` // 已重新随机的次数
+
 	// 移除外键约束 - 在应用层处理关联
 	GameProgress []GameProgress `gorm:"-" json:"game_progress,omitempty"`
 }
@@ -43,9 +71,9 @@ type Player struct {
 // GameProgress 游戏进度模型
 type GameProgress struct {
 	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	PlayerID      string    `gorm:"size:64;index;not null" json:"player_id"`
-	ProgressType  string    `gorm:"size:50;index" json:"progress_type"`
-	ProgressKey   string    `gorm:"size:100;not null" json:"progress_key"`
+	PlayerID      string    `gorm:"size:64;index;uniqueIndex:unique_progress,priority:1;not null" json:"player_id"`
+	ProgressType  string    `gorm:"size:50;index;uniqueIndex:unique_progress,priority:2" json:"progress_type"`
+	ProgressKey   string    `gorm:"size:100;uniqueIndex:unique_progress,priority:3;not null" json:"progress_key"`
 	ProgressValue string    `gorm:"type:json;not null" json:"progress_value"`
 	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
@@ -57,6 +85,182 @@ type GameProgress struct {
 	PlayerIDKey string `gorm:"-" json:"-"`
 }
 
+// AuthEvent 认证审计事件模型
+type AuthEvent struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username  string    `gorm:"size:50;index" json:"username"`
+	PlayerID  string    `gorm:"size:64;index" json:"player_id"`
+	EventType string    `gorm:"size:32;index" json:"event_type"`
+	Success   bool      `gorm:"default:true" json:"success"`
+	IP        string    `gorm:"size:64;index" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	Detail    string    `gorm:"size:255" json:"detail"`
+	Actor     string    `gorm:"size:50" json:"actor,omitempty"` // 操作者（封禁等由客服/GM发起的事件）
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// DataRequest 数据导出/删除请求模型
+type DataRequest struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	RequestID   string     `gorm:"size:64;uniqueIndex;not null" json:"request_id"`
+	Username    string     `gorm:"size:50;index;not null" json:"username"`
+	Type        string     `gorm:"size:16;index" json:"type"`
+	Status      string     `gorm:"size:16;index" json:"status"`
+	RequestedBy string     `gorm:"size:50" json:"requested_by"` // 发起人（本人或客服）
+	ScheduledAt time.Time  `gorm:"index" json:"scheduled_at"`   // 到期后由 Persist 处理
+	CompletedAt *time.Time `json:"completed_at"`
+	Archive     string     `gorm:"type:longtext" json:"-"` // 导出档案(JSON)，过期后清空
+	Error       string     `gorm:"size:255" json:"error"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// APIKey 服务账号/自动化工具使用的API密钥，只保存密钥的 SHA-256 哈希
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	KeyID      string     `gorm:"size:32;uniqueIndex;not null" json:"key_id"` // 公开标识，同时是密钥的前缀部分
+	Name       string     `gorm:"size:64;not null" json:"name"`
+	KeyHash    string     `gorm:"size:64;not null" json:"-"`
+	Scopes     string     `gorm:"size:255" json:"scopes"`       // 逗号分隔的权限范围
+	RateLimit  int        `gorm:"default:60" json:"rate_limit"` // 每分钟请求数
+	CreatedBy  string     `gorm:"size:50" json:"created_by"`    // 签发的管理员
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at"`      // 为空表示永不过期
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// NameHistory 显示名称修改记录
+type NameHistory struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	PlayerID  string    `gorm:"size:64;index;not null" json:"player_id"`
+	Username  string    `gorm:"size:50;index" json:"username"` // 所属账号，供客服追溯
+	OldName   string    `gorm:"size:32" json:"old_name"`
+	NewName   string    `gorm:"size:32;index" json:"new_name"`
+	Cost      int64     `gorm:"default:0" json:"cost"` // 消耗的金币
+	ChangedAt time.Time `gorm:"autoCreateTime;index" json:"changed_at"`
+}
+
+// Mail 玩家邮件，附件（物品与货币）由 Game 服务领取，领取后记录领取时间
+type Mail struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	MailID      string     `gorm:"size:64;uniqueIndex;not null" json:"mail_id"`
+	PlayerID    string     `gorm:"size:64;index;not null" json:"player_id"`
+	Sender      string     `gorm:"size:32" json:"sender"` // 发件来源，如 crafting、market
+	Subject     string     `gorm:"size:64" json:"subject"`
+	Body        string     `gorm:"size:512" json:"body"`
+	Attachments string     `gorm:"type:json" json:"attachments"` // common.MailAttachments
+	ExpiresAt   time.Time  `gorm:"index" json:"expires_at"`
+	ClaimedAt   *time.Time `json:"claimed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// PurchaseHistory 商店购买记录，(player_id, request_id) 唯一，Game 在扣费前据此识别重试的请求
+type PurchaseHistory struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	PlayerID    string    `gorm:"size:64;not null;uniqueIndex:unique_purchase,priority:1" json:"player_id"`
+	RequestID   string    `gorm:"size:64;not null;uniqueIndex:unique_purchase,priority:2" json:"request_id"`
+	ShopID      string    `gorm:"size:64;index" json:"shop_id"`
+	OfferID     string    `gorm:"size:64" json:"offer_id"`
+	ItemID      string    `gorm:"size:64" json:"item_id"`
+	Count       int       `json:"count"`
+	Price       string    `gorm:"type:json" json:"price"` // 货币 -> 总价
+	PurchasedAt time.Time `gorm:"index" json:"purchased_at"`
+}
+
+// MarketListing 市场挂单，挂单物品与最高出价的货币处于托管状态，直到成交、撤单或到期
+type MarketListing struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ListingID   string     `gorm:"size:64;uniqueIndex;not null" json:"listing_id"`
+	SellerID    string     `gorm:"size:64;index;not null" json:"seller_id"`
+	ItemID      string     `gorm:"size:64;index;not null" json:"item_id"`
+	ItemType    string     `gorm:"size:32;index" json:"item_type"`
+	Count       int        `gorm:"not null" json:"count"`
+	Currency    string     `gorm:"size:32;not null" json:"currency"`
+	BuyoutPrice int64      `gorm:"index" json:"buyout_price"`
+	StartPrice  int64      `json:"start_price"`
+	CurrentBid  int64      `json:"current_bid"`
+	BidderID    string     `gorm:"size:64;index" json:"bidder_id"`
+	BuyerID     string     `gorm:"size:64" json:"buyer_id"`
+	Status      string     `gorm:"size:16;index;not null" json:"status"` // active, sold, cancelled, expired
+	ExpiresAt   time.Time  `gorm:"index" json:"expires_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// MarketBid 拍卖出价，BidID 由 Game 生成，重复提交同一出价时返回原结果
+type MarketBid struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	BidID     string    `gorm:"size:64;uniqueIndex;not null" json:"bid_id"`
+	ListingID string    `gorm:"size:64;index;not null" json:"listing_id"`
+	BidderID  string    `gorm:"size:64;index;not null" json:"bidder_id"`
+	Amount    int64     `gorm:"not null" json:"amount"`
+	Status    string    `gorm:"size:16;not null" json:"status"` // active, outbid, won, refunded
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// MarketTrade 成交记录，TradeID 唯一（一口价为 Game 生成的交易ID，拍卖为 auction_<挂单ID>）
+type MarketTrade struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TradeID   string    `gorm:"size:64;uniqueIndex;not null" json:"trade_id"`
+	ListingID string    `gorm:"size:64;index;not null" json:"listing_id"`
+	SellerID  string    `gorm:"size:64;index" json:"seller_id"`
+	BuyerID   string    `gorm:"size:64;index" json:"buyer_id"`
+	ItemID    string    `gorm:"size:64" json:"item_id"`
+	Count     int       `json:"count"`
+	Currency  string    `gorm:"size:32" json:"currency"`
+	Price     int64     `json:"price"`
+	Fee       int64     `json:"fee"`
+	Kind      string    `gorm:"size:16" json:"kind"` // buyout, auction
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// Sect 宗门，SectID 由 Game 生成，名称唯一
+type Sect struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	SectID    string    `gorm:"size:64;uniqueIndex;not null" json:"sect_id"`
+	Name      string    `gorm:"size:64;uniqueIndex;not null" json:"name"`
+	LeaderID  string    `gorm:"size:64;index;not null" json:"leader_id"`
+	Level     int       `gorm:"default:1" json:"level"`
+	Exp       int64     `gorm:"default:0" json:"exp"`
+	Treasury  int64     `gorm:"default:0" json:"treasury"` // 宝库金币
+	Notice    string    `gorm:"size:512" json:"notice"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// SectMember 宗门成员，每个玩家最多加入一个宗门
+type SectMember struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	SectID       string    `gorm:"size:64;index;not null" json:"sect_id"`
+	PlayerID     string    `gorm:"size:64;uniqueIndex;not null" json:"player_id"`
+	Rank         string    `gorm:"size:16;not null" json:"rank"` // leader, elder, disciple
+	Contribution int64     `gorm:"default:0" json:"contribution"`
+	JoinedAt     time.Time `gorm:"autoCreateTime" json:"joined_at"`
+}
+
+// SectApplication 入门申请，(sect_id, player_id) 唯一，审核或申请者加入宗门后删除
+type SectApplication struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	SectID    string    `gorm:"size:64;uniqueIndex:unique_sect_application;not null" json:"sect_id"`
+	PlayerID  string    `gorm:"size:64;uniqueIndex:unique_sect_application;index;not null" json:"player_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// SectTreasuryLog 宗门宝库流水（捐献、拨发、升级），OpID 唯一，重复提交同一操作时不重复记账
+type SectTreasuryLog struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OpID      string    `gorm:"size:64;uniqueIndex;not null" json:"op_id"`
+	SectID    string    `gorm:"size:64;index;not null" json:"sect_id"`
+	PlayerID  string    `gorm:"size:64;index" json:"player_id"`
+	TargetID  string    `gorm:"size:64" json:"target_id"`
+	Kind      string    `gorm:"size:16;not null" json:"kind"` // donate, withdraw, upgrade
+	Amount    int64     `json:"amount"`                       // 宝库变动，支出为负
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
 // BeforeCreate GORM钩子 - 创建前
 func (gp *GameProgress) BeforeCreate(tx *gorm.DB) error {
 	gp.PlayerIDKey = gp.PlayerID + ":" + gp.ProgressType + ":" + gp.ProgressKey
@@ -76,6 +280,58 @@ func (GameProgress) TableName() string {
 	return "game_progress"
 }
 
+func (PurchaseHistory) TableName() string {
+	return "purchase_history"
+}
+
+func (MarketListing) TableName() string {
+	return "market_listings"
+}
+
+func (MarketBid) TableName() string {
+	return "market_bids"
+}
+
+func (MarketTrade) TableName() string {
+	return "market_trades"
+}
+
+func (Sect) TableName() string {
+	return "sects"
+}
+
+func (SectMember) TableName() string {
+	return "sect_members"
+}
+
+func (SectApplication) TableName() string {
+	return "sect_applications"
+}
+
+func (SectTreasuryLog) TableName() string {
+	return "sect_treasury_logs"
+}
+
+func (Mail) TableName() string {
+	return "mails"
+}
+
+func (AuthEvent) TableName() string {
+	return "auth_events"
+}
+
+func (DataRequest) TableName() string {
+	return "data_requests"
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+func (NameHistory) TableName() string {
+	return "name_history"
+}
+
 // ToUserData 转换为 UserData 结构体
 func (u *User) ToUserData() *common.UserData {
 	userData := &common.UserData{
@@ -83,6 +339,12 @@ func (u *User) ToUserData() *common.UserData {
 		Password:  u.PasswordHash, // Include password for authentication
 		PlayerID:  u.PlayerID,
 		CreatedAt: u.CreatedAt,
+
+		Role:             u.Role,
+		TwoFactorEnabled: u.TOTPEnabled,
+		RequireTwoFactor: u.RequireTwoFactor,
+		Banned:           u.IsBanned,
+		CharacterSlots:   u.CharacterSlots,
 	}
 
 	if u.LastLogin != nil {
@@ -99,11 +361,118 @@ func (p *Player) ToPlayerData() *common.PlayerData {
 		Username:     p.Username,
 		LastSaveTime: p.LastSaveTime,
 		CreatedAt:    p.CreatedAt,
+		Aptitude:     p.GetAptitude(),
 	}
 
 	return playerData
 }
 
+// ToCharacterInfo 转换为角色摘要
+func (p *Player) ToCharacterInfo() common.CharacterInfo {
+	return common.CharacterInfo{
+		PlayerID:          p.PlayerID,
+		Name:              p.Name,
+		Level:             p.Level,
+		CreatedAt:         p.CreatedAt,
+		LastSelectedAt:    p.LastSelectedAt,
+		DeleteScheduledAt: p.DeleteScheduledAt,
+		NameChangedAt:     p.NameChangedAt,
+		Aptitude:          p.GetAptitude(),
+		RerollsLeft:       p.AptitudeRerollsLeft(),
+	}
+}
+
+// GetAptitude 解析角色资质，尚未生成资质时返回 nil
+func (p *Player) GetAptitude() *common.Aptitude {
+	if p.Aptitude == "" {
+		return nil
+	}
+	var aptitude common.Aptitude
+	if err := json.Unmarshal([]byte(p.Aptitude), &aptitude); err != nil {
+		return nil
+	}
+	return &aptitude
+}
+
+// SetAptitude 保存角色资质
+func (p *Player) SetAptitude(aptitude *common.Aptitude) error {
+	data, err := json.Marshal(aptitude)
+	if err != nil {
+		return err
+	}
+	p.Aptitude = string(data)
+	return nil
+}
+
+// AptitudeRerollsLeft 剩余的资质重随次数，开始修炼后不能再重随
+func (p *Player) AptitudeRerollsLeft() int {
+	if p.Level > 1 || p.Exp > 0 {
+		return 0
+	}
+	left := common.AptitudeMaxRerolls - p.AptitudeRerolls
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// ToNameChange 转换为改名记录
+func (h *NameHistory) ToNameChange() common.NameChange {
+	return common.NameChange{
+		PlayerID:  h.PlayerID,
+		OldName:   h.OldName,
+		NewName:   h.NewName,
+		Cost:      h.Cost,
+		ChangedAt: h.ChangedAt,
+	}
+}
+
+// ToDataRequestInfo 转换为请求摘要
+func (d *DataRequest) ToDataRequestInfo() common.DataRequestInfo {
+	return common.DataRequestInfo{
+		RequestID:   d.RequestID,
+		Type:        d.Type,
+		Status:      d.Status,
+		CreatedAt:   d.CreatedAt,
+		ScheduledAt: d.ScheduledAt,
+		CompletedAt: d.CompletedAt,
+		Error:       d.Error,
+	}
+}
+
+// ScopeList 权限范围列表
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope 检查密钥是否具有指定权限范围
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ToAPIKeyInfo 转换为密钥摘要
+func (k *APIKey) ToAPIKeyInfo() common.APIKeyInfo {
+	return common.APIKeyInfo{
+		KeyID:      k.KeyID,
+		Name:       k.Name,
+		Scopes:     k.ScopeList(),
+		RateLimit:  k.RateLimit,
+		CreatedBy:  k.CreatedBy,
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
 // FromUserData 从 UserData 创建 Player
 func FromUserData(userData *common.UserData) *Player {
 	return &Player{
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/idle-server/common"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码哈希采用 PHC 字符串格式，算法和参数随哈希一起存储：
+//   $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+// 旧账号的 bcrypt 哈希 ($2a$/$2b$/$2y$) 仍可校验，并在登录成功后升级为 argon2id
+
+// PasswordParams argon2id 参数
+type PasswordParams struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// CurrentPasswordParams 新哈希使用的参数，启动时从环境变量读取，未设置时使用 common 中的默认值；
+// VerifyPassword 以它判断哈希是否需要升级
+var CurrentPasswordParams = passwordParamsFromEnv()
+
+// passwordParamsFromEnv 读取环境变量中的 argon2id 参数，无效的值忽略并使用默认值
+func passwordParamsFromEnv() PasswordParams {
+	params := PasswordParams{
+		Memory:      envUint32(common.PasswordArgon2MemoryEnv, common.PasswordArgon2Memory, math.MaxUint32),
+		Iterations:  envUint32(common.PasswordArgon2IterationsEnv, common.PasswordArgon2Iterations, math.MaxUint32),
+		Parallelism: uint8(envUint32(common.PasswordArgon2ParallelismEnv, common.PasswordArgon2Parallelism, math.MaxUint8)),
+		SaltLength:  common.PasswordSaltLength,
+		KeyLength:   common.PasswordKeyLength,
+	}
+	// argon2 要求内存至少为每个并行通道 8 KiB
+	if params.Memory < 8*uint32(params.Parallelism) {
+		log.Printf("Argon2 memory %d KiB is too small for parallelism %d, using defaults", params.Memory, params.Parallelism)
+		params.Memory = common.PasswordArgon2Memory
+		params.Parallelism = common.PasswordArgon2Parallelism
+	}
+	return params
+}
+
+// envUint32 读取 1..limit 范围内的整数环境变量，未设置或无效时返回默认值
+func envUint32(key string, fallback, limit uint32) uint32 {
+	raw, ok := os.LookupEnv(key)
+	if !ok || raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 32)
+	if err != nil || value == 0 || value > uint64(limit) {
+		log.Printf("Invalid %s=%q, using default %d", key, raw, fallback)
+		return fallback
+	}
+	return uint32(value)
+}
+
+// HashPassword 使用当前参数生成 argon2id 密码哈希
+func HashPassword(password string) (string, error) {
+	params := CurrentPasswordParams
+
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword 校验密码，needsRehash 表示哈希使用的是旧算法或旧参数，应在登录成功后重新计算
+func VerifyPassword(encoded, password string) (ok bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2idHash(encoded)
+		if err != nil {
+			return false, false, err
+		}
+
+		candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false, nil
+		}
+
+		current := CurrentPasswordParams
+		stale := params.Memory != current.Memory ||
+			params.Iterations != current.Iterations ||
+			params.Parallelism != current.Parallelism ||
+			uint32(len(salt)) != current.SaltLength ||
+			uint32(len(key)) != current.KeyLength
+		return true, stale, nil
+
+	case strings.HasPrefix(encoded, "$2"):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+			if err == bcrypt.ErrMismatchedHashAndPassword {
+				return false, false, nil
+			}
+			return false, false, fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+		return true, true, nil
+
+	default:
+		return false, false, fmt.Errorf("unknown password hash format")
+	}
+}
+
+// decodeArgon2idHash 解析 PHC 格式的 argon2id 哈希
+func decodeArgon2idHash(encoded string) (PasswordParams, []byte, []byte, error) {
+	var params PasswordParams
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: m=%d,t=%d,p=%d", params.Memory, params.Iterations, params.Parallelism)
+	}
+
+	// 空的盐或哈希会让任意密码都校验通过，必须拒绝
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if len(salt) == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: empty")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+	if len(key) != common.PasswordKeyLength {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash: key length %d, want %d", len(key), common.PasswordKeyLength)
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
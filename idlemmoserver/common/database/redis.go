@@ -3,11 +3,14 @@ package database
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/idle-server/common"
 )
 
 // RedisConfig Redis配置
@@ -28,6 +31,9 @@ func DefaultRedisConfig() *RedisConfig {
 	}
 }
 
+// ErrSessionNotFound 会话不存在、已过期或已被撤销
+var ErrSessionNotFound = errors.New("session not found")
+
 // Redis Redis连接管理
 type Redis struct {
 	client *redis.Client
@@ -107,22 +113,202 @@ func (r *Redis) IsHealthy() bool {
 
 // ============ 缓存操作方法 ============
 
-// SetUserSession 设置用户会话缓存
-func (r *Redis) SetUserSession(ctx context.Context, playerID, token string, expiration time.Duration) error {
-	key := fmt.Sprintf("session:%s", playerID)
-	return r.client.Set(ctx, key, token, expiration).Err()
+// SetUserSession 保存设备会话记录，并加入账号的会话索引 (sessions:<username>)
+func (r *Redis) SetUserSession(ctx context.Context, session *common.SessionInfo, expiration time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	indexKey := fmt.Sprintf("sessions:%s", session.Username)
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("session:%s", session.SessionID), data, expiration)
+	pipe.SAdd(ctx, indexKey, session.SessionID)
+	pipe.Expire(ctx, indexKey, expiration)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// TouchUserSession 更新会话的最后活跃信息，保持剩余有效期不变
+func (r *Redis) TouchUserSession(ctx context.Context, session *common.SessionInfo) error {
+	key := fmt.Sprintf("session:%s", session.SessionID)
+	ttl, err := r.client.TTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		return ErrSessionNotFound
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+// GetUserSession 获取设备会话记录，会话不存在或已过期时返回 ErrSessionNotFound
+func (r *Redis) GetUserSession(ctx context.Context, sessionID string) (*common.SessionInfo, error) {
+	data, err := r.client.Get(ctx, fmt.Sprintf("session:%s", sessionID)).Result()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session common.SessionInfo
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	return &session, nil
+}
+
+// ListUserSessions 获取账号的全部有效会话，顺带清理索引中已过期的会话ID
+func (r *Redis) ListUserSessions(ctx context.Context, username string) ([]common.SessionInfo, error) {
+	indexKey := fmt.Sprintf("sessions:%s", username)
+	sessionIDs, err := r.client.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]common.SessionInfo, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		session, err := r.GetUserSession(ctx, sessionID)
+		if err == ErrSessionNotFound {
+			r.client.SRem(ctx, indexKey, sessionID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, nil
+}
+
+// DeleteUserSession 删除设备会话记录
+func (r *Redis) DeleteUserSession(ctx context.Context, username, sessionID string) error {
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, fmt.Sprintf("session:%s", sessionID))
+	pipe.SRem(ctx, fmt.Sprintf("sessions:%s", username), sessionID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteUserSessions 删除账号的全部会话，返回被删除的会话ID
+func (r *Redis) DeleteUserSessions(ctx context.Context, username string) ([]string, error) {
+	indexKey := fmt.Sprintf("sessions:%s", username)
+	sessionIDs, err := r.client.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(sessionIDs)+1)
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, fmt.Sprintf("session:%s", sessionID))
+	}
+	keys = append(keys, indexKey)
+
+	if err := r.client.Del(ctx, keys...).Err(); err != nil {
+		return nil, err
+	}
+	return sessionIDs, nil
+}
+
+// IncrAPIKeyUsage 增加API密钥在当前分钟窗口内的请求数，返回窗口内的累计次数
+func (r *Redis) IncrAPIKeyUsage(ctx context.Context, keyID string, window time.Time) (int64, error) {
+	key := fmt.Sprintf("apikey_rate:%s:%d", keyID, window.Unix()/60)
+	count, err := r.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		r.client.Expire(ctx, key, 2*time.Minute)
+	}
+	return count, nil
+}
+
+// SetTwoFactorChallenge 保存登录第二步的挑战令牌
+func (r *Redis) SetTwoFactorChallenge(ctx context.Context, challenge, username string, expiration time.Duration) error {
+	key := fmt.Sprintf("2fa_challenge:%s", challenge)
+	return r.client.Set(ctx, key, username, expiration).Err()
 }
 
-// GetUserSession 获取用户会话缓存
-func (r *Redis) GetUserSession(ctx context.Context, playerID string) (string, error) {
-	key := fmt.Sprintf("session:%s", playerID)
+// GetTwoFactorChallenge 获取挑战令牌对应的用户名
+func (r *Redis) GetTwoFactorChallenge(ctx context.Context, challenge string) (string, error) {
+	key := fmt.Sprintf("2fa_challenge:%s", challenge)
 	return r.client.Get(ctx, key).Result()
 }
 
-// DeleteUserSession 删除用户会话缓存
-func (r *Redis) DeleteUserSession(ctx context.Context, playerID string) error {
-	key := fmt.Sprintf("session:%s", playerID)
-	return r.client.Del(ctx, key).Err()
+// IncrTwoFactorAttempts 增加挑战令牌的验证失败次数
+func (r *Redis) IncrTwoFactorAttempts(ctx context.Context, challenge string, expiration time.Duration) (int64, error) {
+	key := fmt.Sprintf("2fa_attempts:%s", challenge)
+	count, err := r.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	r.client.Expire(ctx, key, expiration)
+	return count, nil
+}
+
+// DeleteTwoFactorChallenge 删除挑战令牌
+func (r *Redis) DeleteTwoFactorChallenge(ctx context.Context, challenge string) error {
+	return r.client.Del(ctx,
+		fmt.Sprintf("2fa_challenge:%s", challenge),
+		fmt.Sprintf("2fa_attempts:%s", challenge),
+		fmt.Sprintf("2fa_enrolling:%s", challenge),
+	).Err()
+}
+
+// IncrTwoFactorFailures 增加账号跨挑战令牌累计的验证失败次数，窗口从最后一次失败起算
+func (r *Redis) IncrTwoFactorFailures(ctx context.Context, username string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf("2fa_failures:%s", username)
+	count, err := r.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	r.client.Expire(ctx, key, window)
+	return count, nil
+}
+
+// GetTwoFactorFailures 获取账号累计的验证失败次数
+func (r *Redis) GetTwoFactorFailures(ctx context.Context, username string) (int64, error) {
+	count, err := r.client.Get(ctx, fmt.Sprintf("2fa_failures:%s", username)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return count, err
+}
+
+// ResetTwoFactorFailures 验证成功后清除账号的失败次数
+func (r *Redis) ResetTwoFactorFailures(ctx context.Context, username string) error {
+	return r.client.Del(ctx, fmt.Sprintf("2fa_failures:%s", username)).Err()
+}
+
+// SetTwoFactorEnrollment 保存GM/管理员签发的强制绑定令牌
+func (r *Redis) SetTwoFactorEnrollment(ctx context.Context, enrollmentToken, username string, expiration time.Duration) error {
+	key := fmt.Sprintf("2fa_enrollment:%s", enrollmentToken)
+	return r.client.Set(ctx, key, username, expiration).Err()
+}
+
+// ConsumeTwoFactorEnrollment 取出并删除强制绑定令牌，返回签发对象的用户名
+func (r *Redis) ConsumeTwoFactorEnrollment(ctx context.Context, enrollmentToken string) (string, error) {
+	key := fmt.Sprintf("2fa_enrollment:%s", enrollmentToken)
+	return r.client.GetDel(ctx, key).Result()
+}
+
+// MarkTwoFactorEnrolling 标记挑战令牌已凭绑定令牌开始绑定
+func (r *Redis) MarkTwoFactorEnrolling(ctx context.Context, challenge string, expiration time.Duration) error {
+	key := fmt.Sprintf("2fa_enrolling:%s", challenge)
+	return r.client.Set(ctx, key, "1", expiration).Err()
+}
+
+// IsTwoFactorEnrolling 挑战令牌是否已凭绑定令牌开始绑定
+func (r *Redis) IsTwoFactorEnrolling(ctx context.Context, challenge string) (bool, error) {
+	key := fmt.Sprintf("2fa_enrolling:%s", challenge)
+	count, err := r.client.Exists(ctx, key).Result()
+	return count > 0, err
 }
 
 // SetPlayerData 设置玩家数据缓存
@@ -196,3 +382,58 @@ func (r *Redis) GetPlayerRank(ctx context.Context, rankingType string, playerID
 	key := fmt.Sprintf("ranking:%s", rankingType)
 	return r.client.ZRevRank(ctx, key, playerID).Result()
 }
+
+// GetPlayerRankings 获取玩家在所有排行榜中的分数
+func (r *Redis) GetPlayerRankings(ctx context.Context, playerID string) (map[string]float64, error) {
+	keys, err := r.rankingKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rankings := make(map[string]float64)
+	for _, key := range keys {
+		score, err := r.client.ZScore(ctx, key, playerID).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rankings[strings.TrimPrefix(key, "ranking:")] = score
+	}
+	return rankings, nil
+}
+
+// RemoveFromRankings 从所有排行榜中移除玩家
+func (r *Redis) RemoveFromRankings(ctx context.Context, playerIDs ...string) error {
+	if len(playerIDs) == 0 {
+		return nil
+	}
+
+	keys, err := r.rankingKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	members := make([]interface{}, 0, len(playerIDs))
+	for _, playerID := range playerIDs {
+		members = append(members, playerID)
+	}
+
+	for _, key := range keys {
+		if err := r.client.ZRem(ctx, key, members...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rankingKeys 扫描所有排行榜键
+func (r *Redis) rankingKeys(ctx context.Context) ([]string, error) {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, "ranking:*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
@@ -0,0 +1,95 @@
+package common
+
+// ============ 境界与装备 ============
+// 境界由角色等级决定；装备按部位穿戴，穿戴要求等级或境界，属性与资质一起汇总为角色的派生属性
+
+// Realm 修炼境界
+type Realm struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Order    int    `json:"order"`     // 境界高低，越大越高
+	MinLevel int    `json:"min_level"` // 达到该境界所需的角色等级
+}
+
+// Realms 境界配置表，按 Order 升序
+var Realms = []Realm{
+	{ID: "qi_refining", Name: "练气", Order: 1, MinLevel: 1},
+	{ID: "foundation", Name: "筑基", Order: 2, MinLevel: 10},
+	{ID: "golden_core", Name: "金丹", Order: 3, MinLevel: 20},
+	{ID: "nascent_soul", Name: "元婴", Order: 4, MinLevel: 30},
+	{ID: "spirit_severing", Name: "化神", Order: 5, MinLevel: 40},
+}
+
+// RealmForLevel 角色等级对应的境界
+func RealmForLevel(level int) Realm {
+	realm := Realms[0]
+	for _, r := range Realms {
+		if level >= r.MinLevel {
+			realm = r
+		}
+	}
+	return realm
+}
+
+// GetRealm 获取境界配置
+func GetRealm(realmID string) (Realm, bool) {
+	for _, realm := range Realms {
+		if realm.ID == realmID {
+			return realm, true
+		}
+	}
+	return Realm{}, false
+}
+
+// 装备部位
+const (
+	EquipSlotWeapon   = "weapon"   // 法器
+	EquipSlotRobe     = "robe"     // 法袍
+	EquipSlotTalisman = "talisman" // 护身符
+	EquipSlotRing     = "ring"     // 戒指
+)
+
+// EquipSlots 装备部位列表
+var EquipSlots = []string{EquipSlotWeapon, EquipSlotRobe, EquipSlotTalisman, EquipSlotRing}
+
+// Stats 角色派生属性，装备属性以同样的结构累加
+type Stats struct {
+	Attack    int     `json:"attack"`
+	Defense   int     `json:"defense"`
+	HP        int     `json:"hp"`
+	Speed     int     `json:"speed"`
+	ExpBonus  float64 `json:"exp_bonus,omitempty"`  // 修炼经验加成（0.1 表示 +10%）
+	DropBonus float64 `json:"drop_bonus,omitempty"` // 掉落概率加成
+}
+
+// Add 累加属性
+func (s *Stats) Add(other Stats) {
+	s.Attack += other.Attack
+	s.Defense += other.Defense
+	s.HP += other.HP
+	s.Speed += other.Speed
+	s.ExpBonus += other.ExpBonus
+	s.DropBonus += other.DropBonus
+}
+
+// EquipDef 装备属性与穿戴要求
+type EquipDef struct {
+	Slot     string `json:"slot"`
+	MinLevel int    `json:"min_level,omitempty"` // 需要的角色等级
+	MinRealm string `json:"min_realm,omitempty"` // 需要的境界
+	Stats    Stats  `json:"stats"`
+}
+
+// BaseStats 由等级和资质计算的基础属性（未计装备）
+func BaseStats(level int, aptitude *Aptitude) Stats {
+	stats := Stats{
+		Attack:  StatsBaseAttack + level*StatsAttackPerLevel,
+		Defense: level * StatsDefensePerLevel,
+		HP:      StatsBaseHP + level*StatsHPPerLevel,
+	}
+	if aptitude != nil {
+		stats.HP += aptitude.Attributes.Constitution * StatsHPPerConstitution
+		stats.Speed += aptitude.Attributes.Agility
+	}
+	return stats
+}
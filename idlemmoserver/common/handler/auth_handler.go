@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
@@ -23,11 +24,11 @@ func NewAuthHandler(name, messageType string, natsManager *nats.Manager) *AuthHa
 // LoginHandler 登录处理器
 type LoginHandler struct {
 	*AuthHandler
-	authFunc func(username, password string) (*common.MsgAuthenticateUserResult, error)
+	authFunc func(username, password string, client *common.ClientInfo) (*common.MsgAuthenticateUserResult, error)
 }
 
 // NewLoginHandler 创建登录处理器
-func NewLoginHandler(natsManager *nats.Manager, authFunc func(string, string) (*common.MsgAuthenticateUserResult, error)) *LoginHandler {
+func NewLoginHandler(natsManager *nats.Manager, authFunc func(string, string, *common.ClientInfo) (*common.MsgAuthenticateUserResult, error)) *LoginHandler {
 	return &LoginHandler{
 		AuthHandler: NewAuthHandler("LoginHandler", "C_Login", natsManager),
 		authFunc:    authFunc,
@@ -55,13 +56,13 @@ func (h *LoginHandler) Handle(ctx *MessageContext, request interface{}) (*Respon
 	log.Printf("Processing login request for user: %s", username)
 
 	// 调用认证函数
-	result, err := h.authFunc(username, password)
+	result, err := h.authFunc(username, password, ClientInfoFromContext(ctx))
 	if err != nil {
 		return ErrorResponseWithID(ctx.RequestID, err), nil
 	}
 
 	if !result.Success {
-		return ErrorResponseWithID(ctx.RequestID, fmt.Errorf(result.Message)), nil
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
 	}
 
 	return SuccessResponseWithID(ctx.RequestID, result), nil
@@ -70,11 +71,11 @@ func (h *LoginHandler) Handle(ctx *MessageContext, request interface{}) (*Respon
 // RegisterHandler 注册处理器
 type RegisterHandler struct {
 	*AuthHandler
-	registerFunc func(username, password string) (*common.MsgRegisterUserResult, error)
+	registerFunc func(username, password string, client *common.ClientInfo) (*common.MsgRegisterUserResult, error)
 }
 
 // NewRegisterHandler 创建注册处理器
-func NewRegisterHandler(natsManager *nats.Manager, registerFunc func(string, string) (*common.MsgRegisterUserResult, error)) *RegisterHandler {
+func NewRegisterHandler(natsManager *nats.Manager, registerFunc func(string, string, *common.ClientInfo) (*common.MsgRegisterUserResult, error)) *RegisterHandler {
 	return &RegisterHandler{
 		AuthHandler:  NewAuthHandler("RegisterHandler", "C_Register", natsManager),
 		registerFunc: registerFunc,
@@ -100,13 +101,834 @@ func (h *RegisterHandler) Handle(ctx *MessageContext, request interface{}) (*Res
 
 	log.Printf("Processing registration request for user: %s", username)
 
-	result, err := h.registerFunc(username, password)
+	result, err := h.registerFunc(username, password, ClientInfoFromContext(ctx))
 	if err != nil {
 		return ErrorResponseWithID(ctx.RequestID, err), nil
 	}
 
 	if !result.Success {
-		return ErrorResponseWithID(ctx.RequestID, fmt.Errorf(result.Message)), nil
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// TwoFactorVerifyHandler 双因素认证登录第二步处理器
+type TwoFactorVerifyHandler struct {
+	*AuthHandler
+	verifyFunc func(challengeToken, code, enrollmentToken string, client *common.ClientInfo) (*common.MsgAuthenticateUserResult, error)
+}
+
+// NewTwoFactorVerifyHandler 创建双因素认证登录处理器
+func NewTwoFactorVerifyHandler(natsManager *nats.Manager, verifyFunc func(string, string, string, *common.ClientInfo) (*common.MsgAuthenticateUserResult, error)) *TwoFactorVerifyHandler {
+	return &TwoFactorVerifyHandler{
+		AuthHandler: NewAuthHandler("TwoFactorVerifyHandler", "C_TwoFactorVerify", natsManager),
+		verifyFunc:  verifyFunc,
+	}
+}
+
+// Handle 处理动态码/恢复码校验，或用强制绑定令牌换取绑定信息
+func (h *TwoFactorVerifyHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	challengeToken, ok := reqData["challenge_token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing challenge_token")
+	}
+
+	code, _ := reqData["code"].(string)
+	enrollmentToken, _ := reqData["enrollment_token"].(string)
+	if code == "" && enrollmentToken == "" {
+		return nil, fmt.Errorf("missing code")
+	}
+
+	result, err := h.verifyFunc(challengeToken, code, enrollmentToken, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// TwoFactorManageHandler 双因素认证绑定/确认/解绑处理器
+type TwoFactorManageHandler struct {
+	*AuthHandler
+	manageFunc func(token, code string, client *common.ClientInfo) (*common.MsgTwoFactorResult, error)
+}
+
+// NewTwoFactorSetupHandler 创建生成TOTP密钥处理器
+func NewTwoFactorSetupHandler(natsManager *nats.Manager, setupFunc func(string, string, *common.ClientInfo) (*common.MsgTwoFactorResult, error)) *TwoFactorManageHandler {
+	return &TwoFactorManageHandler{
+		AuthHandler: NewAuthHandler("TwoFactorSetupHandler", "C_TwoFactorSetup", natsManager),
+		manageFunc:  setupFunc,
+	}
+}
+
+// NewTwoFactorEnableHandler 创建确认绑定处理器
+func NewTwoFactorEnableHandler(natsManager *nats.Manager, enableFunc func(string, string, *common.ClientInfo) (*common.MsgTwoFactorResult, error)) *TwoFactorManageHandler {
+	return &TwoFactorManageHandler{
+		AuthHandler: NewAuthHandler("TwoFactorEnableHandler", "C_TwoFactorEnable", natsManager),
+		manageFunc:  enableFunc,
+	}
+}
+
+// NewTwoFactorDisableHandler 创建解除绑定处理器
+func NewTwoFactorDisableHandler(natsManager *nats.Manager, disableFunc func(string, string, *common.ClientInfo) (*common.MsgTwoFactorResult, error)) *TwoFactorManageHandler {
+	return &TwoFactorManageHandler{
+		AuthHandler: NewAuthHandler("TwoFactorDisableHandler", "C_TwoFactorDisable", natsManager),
+		manageFunc:  disableFunc,
+	}
+}
+
+// Handle 处理双因素认证管理请求，code 在生成密钥时可为空
+func (h *TwoFactorManageHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	code, _ := reqData["code"].(string)
+
+	result, err := h.manageFunc(token, code, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// TwoFactorRequireHandler GM/管理员设置强制双因素认证处理器
+type TwoFactorRequireHandler struct {
+	*AuthHandler
+	requireFunc func(token, username string, required bool, client *common.ClientInfo) (*common.MsgTwoFactorResult, error)
+}
+
+// NewTwoFactorRequireHandler 创建强制双因素认证处理器
+func NewTwoFactorRequireHandler(natsManager *nats.Manager, requireFunc func(string, string, bool, *common.ClientInfo) (*common.MsgTwoFactorResult, error)) *TwoFactorRequireHandler {
+	return &TwoFactorRequireHandler{
+		AuthHandler: NewAuthHandler("TwoFactorRequireHandler", "C_TwoFactorRequire", natsManager),
+		requireFunc: requireFunc,
+	}
+}
+
+// Handle 处理强制双因素认证设置
+func (h *TwoFactorRequireHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	username, ok := reqData["username"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing username")
+	}
+
+	required, ok := reqData["required"].(bool)
+	if !ok {
+		return nil, fmt.Errorf("missing required")
+	}
+
+	log.Printf("Processing two-factor requirement change for user: %s (required=%t)", username, required)
+
+	result, err := h.requireFunc(token, username, required, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// TokenRefreshHandler 刷新令牌处理器
+type TokenRefreshHandler struct {
+	*AuthHandler
+	refreshFunc func(token string, client *common.ClientInfo) (*common.MsgAccountResult, error)
+}
+
+// NewTokenRefreshHandler 创建刷新令牌处理器
+func NewTokenRefreshHandler(natsManager *nats.Manager, refreshFunc func(string, *common.ClientInfo) (*common.MsgAccountResult, error)) *TokenRefreshHandler {
+	return &TokenRefreshHandler{
+		AuthHandler: NewAuthHandler("TokenRefreshHandler", "C_RefreshToken", natsManager),
+		refreshFunc: refreshFunc,
+	}
+}
+
+// Handle 处理刷新令牌
+func (h *TokenRefreshHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	result, err := h.refreshFunc(token, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// ChangePasswordHandler 修改密码处理器
+type ChangePasswordHandler struct {
+	*AuthHandler
+	changeFunc func(token, oldPassword, newPassword string, client *common.ClientInfo) (*common.MsgAccountResult, error)
+}
+
+// NewChangePasswordHandler 创建修改密码处理器
+func NewChangePasswordHandler(natsManager *nats.Manager, changeFunc func(string, string, string, *common.ClientInfo) (*common.MsgAccountResult, error)) *ChangePasswordHandler {
+	return &ChangePasswordHandler{
+		AuthHandler: NewAuthHandler("ChangePasswordHandler", "C_ChangePassword", natsManager),
+		changeFunc:  changeFunc,
+	}
+}
+
+// Handle 处理修改密码
+func (h *ChangePasswordHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	oldPassword, ok := reqData["old_password"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing old_password")
+	}
+
+	newPassword, ok := reqData["new_password"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing new_password")
+	}
+
+	result, err := h.changeFunc(token, oldPassword, newPassword, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// BanUserHandler 封禁/解封账号处理器
+type BanUserHandler struct {
+	*AuthHandler
+	banFunc func(token, username string, banned bool, reason string, client *common.ClientInfo) (*common.MsgAccountResult, error)
+}
+
+// NewBanUserHandler 创建封禁处理器
+func NewBanUserHandler(natsManager *nats.Manager, banFunc func(string, string, bool, string, *common.ClientInfo) (*common.MsgAccountResult, error)) *BanUserHandler {
+	return &BanUserHandler{
+		AuthHandler: NewAuthHandler("BanUserHandler", "C_BanUser", natsManager),
+		banFunc:     banFunc,
+	}
+}
+
+// Handle 处理封禁/解封
+func (h *BanUserHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	username, ok := reqData["username"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing username")
+	}
+
+	banned, ok := reqData["banned"].(bool)
+	if !ok {
+		return nil, fmt.Errorf("missing banned")
+	}
+
+	reason, _ := reqData["reason"].(string)
+
+	log.Printf("Processing ban request for user: %s (banned=%t)", username, banned)
+
+	result, err := h.banFunc(token, username, banned, reason, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// AuditQueryHandler 认证审计查询处理器（客服使用）
+type AuditQueryHandler struct {
+	*AuthHandler
+	queryFunc func(token string, filters map[string]interface{}) (interface{}, error)
+}
+
+// NewAuditEventsHandler 创建认证事件查询处理器
+func NewAuditEventsHandler(natsManager *nats.Manager, queryFunc func(string, map[string]interface{}) (interface{}, error)) *AuditQueryHandler {
+	return &AuditQueryHandler{
+		AuthHandler: NewAuthHandler("AuditEventsHandler", "C_AuditEvents", natsManager),
+		queryFunc:   queryFunc,
+	}
+}
+
+// NewAuditDevicesHandler 创建设备/IP历史查询处理器
+func NewAuditDevicesHandler(natsManager *nats.Manager, queryFunc func(string, map[string]interface{}) (interface{}, error)) *AuditQueryHandler {
+	return &AuditQueryHandler{
+		AuthHandler: NewAuthHandler("AuditDevicesHandler", "C_AuditDevices", natsManager),
+		queryFunc:   queryFunc,
+	}
+}
+
+// Handle 处理审计查询，filters 支持 username、player_id、event_type、since、until、limit、offset
+func (h *AuditQueryHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	filters := make(map[string]interface{})
+	if f, ok := reqData["filters"].(map[string]interface{}); ok {
+		filters = f
+	}
+
+	result, err := h.queryFunc(token, filters)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// ValidateTokenHandler 令牌校验处理器（Gateway 绑定 WebSocket 连接时使用）
+type ValidateTokenHandler struct {
+	*AuthHandler
+	validateFunc func(token string) (*common.MsgVerifyTokenResult, error)
+}
+
+// NewValidateTokenHandler 创建令牌校验处理器
+func NewValidateTokenHandler(natsManager *nats.Manager, validateFunc func(string) (*common.MsgVerifyTokenResult, error)) *ValidateTokenHandler {
+	return &ValidateTokenHandler{
+		AuthHandler:  NewAuthHandler("ValidateTokenHandler", "C_ValidateToken", natsManager),
+		validateFunc: validateFunc,
+	}
+}
+
+// Handle 处理令牌校验
+func (h *ValidateTokenHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	result, err := h.validateFunc(token)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Error)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// CharacterHandler 角色管理处理器（列表、创建、删除、恢复、选择）
+type CharacterHandler struct {
+	*AuthHandler
+	argKey        string // 请求中携带的参数名，列表请求为空
+	characterFunc func(token, arg string, client *common.ClientInfo) (*common.MsgCharacterResult, error)
+}
+
+// NewCharacterListHandler 创建角色列表处理器
+func NewCharacterListHandler(natsManager *nats.Manager, listFunc func(string, string, *common.ClientInfo) (*common.MsgCharacterResult, error)) *CharacterHandler {
+	return &CharacterHandler{
+		AuthHandler:   NewAuthHandler("CharacterListHandler", "C_CharacterList", natsManager),
+		characterFunc: listFunc,
+	}
+}
+
+// NewCharacterCreateHandler 创建新建角色处理器
+func NewCharacterCreateHandler(natsManager *nats.Manager, createFunc func(string, string, *common.ClientInfo) (*common.MsgCharacterResult, error)) *CharacterHandler {
+	return &CharacterHandler{
+		AuthHandler:   NewAuthHandler("CharacterCreateHandler", "C_CharacterCreate", natsManager),
+		argKey:        "name",
+		characterFunc: createFunc,
+	}
+}
+
+// NewCharacterDeleteHandler 创建删除角色处理器
+func NewCharacterDeleteHandler(natsManager *nats.Manager, deleteFunc func(string, string, *common.ClientInfo) (*common.MsgCharacterResult, error)) *CharacterHandler {
+	return &CharacterHandler{
+		AuthHandler:   NewAuthHandler("CharacterDeleteHandler", "C_CharacterDelete", natsManager),
+		argKey:        "player_id",
+		characterFunc: deleteFunc,
+	}
+}
+
+// NewCharacterRestoreHandler 创建恢复角色处理器
+func NewCharacterRestoreHandler(natsManager *nats.Manager, restoreFunc func(string, string, *common.ClientInfo) (*common.MsgCharacterResult, error)) *CharacterHandler {
+	return &CharacterHandler{
+		AuthHandler:   NewAuthHandler("CharacterRestoreHandler", "C_CharacterRestore", natsManager),
+		argKey:        "player_id",
+		characterFunc: restoreFunc,
+	}
+}
+
+// NewCharacterSelectHandler 创建选择角色处理器
+func NewCharacterSelectHandler(natsManager *nats.Manager, selectFunc func(string, string, *common.ClientInfo) (*common.MsgCharacterResult, error)) *CharacterHandler {
+	return &CharacterHandler{
+		AuthHandler:   NewAuthHandler("CharacterSelectHandler", "C_CharacterSelect", natsManager),
+		argKey:        "player_id",
+		characterFunc: selectFunc,
+	}
+}
+
+// Handle 处理角色管理请求
+func (h *CharacterHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	var arg string
+	if h.argKey != "" {
+		arg, ok = reqData[h.argKey].(string)
+		if !ok {
+			return nil, fmt.Errorf("missing %s", h.argKey)
+		}
+	}
+
+	result, err := h.characterFunc(token, arg, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// NewCharacterNamesHandler 创建改名记录查询处理器
+func NewCharacterNamesHandler(natsManager *nats.Manager, namesFunc func(string, string, *common.ClientInfo) (*common.MsgCharacterResult, error)) *CharacterHandler {
+	return &CharacterHandler{
+		AuthHandler:   NewAuthHandler("CharacterNamesHandler", "C_CharacterNames", natsManager),
+		argKey:        "player_id",
+		characterFunc: namesFunc,
+	}
+}
+
+// NewCharacterRerollHandler 创建资质重随处理器
+func NewCharacterRerollHandler(natsManager *nats.Manager, rerollFunc func(string, string, *common.ClientInfo) (*common.MsgCharacterResult, error)) *CharacterHandler {
+	return &CharacterHandler{
+		AuthHandler:   NewAuthHandler("CharacterRerollHandler", "C_CharacterReroll", natsManager),
+		argKey:        "player_id",
+		characterFunc: rerollFunc,
+	}
+}
+
+// CharacterRenameHandler 角色改名处理器
+type CharacterRenameHandler struct {
+	*AuthHandler
+	renameFunc func(token, playerID, name string, client *common.ClientInfo) (*common.MsgCharacterResult, error)
+}
+
+// NewCharacterRenameHandler 创建角色改名处理器
+func NewCharacterRenameHandler(natsManager *nats.Manager, renameFunc func(string, string, string, *common.ClientInfo) (*common.MsgCharacterResult, error)) *CharacterRenameHandler {
+	return &CharacterRenameHandler{
+		AuthHandler: NewAuthHandler("CharacterRenameHandler", "C_CharacterRename", natsManager),
+		renameFunc:  renameFunc,
+	}
+}
+
+// Handle 处理角色改名请求
+func (h *CharacterRenameHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	name, ok := reqData["name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing name")
+	}
+
+	result, err := h.renameFunc(token, playerID, name, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// SessionHandler 登录会话管理处理器（列表、撤销指定会话、撤销其他会话）
+type SessionHandler struct {
+	*AuthHandler
+	argKey      string // 请求中携带的参数名，列表请求为空
+	sessionFunc func(token, arg string, client *common.ClientInfo) (*common.MsgSessionResult, error)
+}
+
+// NewSessionListHandler 创建会话列表处理器
+func NewSessionListHandler(natsManager *nats.Manager, listFunc func(string, string, *common.ClientInfo) (*common.MsgSessionResult, error)) *SessionHandler {
+	return &SessionHandler{
+		AuthHandler: NewAuthHandler("SessionListHandler", "C_SessionList", natsManager),
+		sessionFunc: listFunc,
+	}
+}
+
+// NewSessionRevokeHandler 创建撤销会话处理器
+func NewSessionRevokeHandler(natsManager *nats.Manager, revokeFunc func(string, string, *common.ClientInfo) (*common.MsgSessionResult, error)) *SessionHandler {
+	return &SessionHandler{
+		AuthHandler: NewAuthHandler("SessionRevokeHandler", "C_SessionRevoke", natsManager),
+		argKey:      "session_id",
+		sessionFunc: revokeFunc,
+	}
+}
+
+// NewSessionRevokeOthersHandler 创建撤销其他会话处理器
+func NewSessionRevokeOthersHandler(natsManager *nats.Manager, revokeFunc func(string, string, *common.ClientInfo) (*common.MsgSessionResult, error)) *SessionHandler {
+	return &SessionHandler{
+		AuthHandler: NewAuthHandler("SessionRevokeOthersHandler", "C_SessionRevokeOthers", natsManager),
+		sessionFunc: revokeFunc,
+	}
+}
+
+// Handle 处理会话管理请求
+func (h *SessionHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	var arg string
+	if h.argKey != "" {
+		arg, ok = reqData[h.argKey].(string)
+		if !ok {
+			return nil, fmt.Errorf("missing %s", h.argKey)
+		}
+	}
+
+	result, err := h.sessionFunc(token, arg, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// APIKeyCreateHandler 签发API密钥处理器（管理员使用）
+type APIKeyCreateHandler struct {
+	*AuthHandler
+	createFunc func(token, name string, scopes []string, rateLimit, expiresInHours int, client *common.ClientInfo) (*common.MsgAPIKeyResult, error)
+}
+
+// NewAPIKeyCreateHandler 创建签发API密钥处理器
+func NewAPIKeyCreateHandler(natsManager *nats.Manager, createFunc func(string, string, []string, int, int, *common.ClientInfo) (*common.MsgAPIKeyResult, error)) *APIKeyCreateHandler {
+	return &APIKeyCreateHandler{
+		AuthHandler: NewAuthHandler("APIKeyCreateHandler", "C_APIKeyCreate", natsManager),
+		createFunc:  createFunc,
+	}
+}
+
+// Handle 处理签发API密钥
+func (h *APIKeyCreateHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	name, ok := reqData["name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing name")
+	}
+
+	rawScopes, ok := reqData["scopes"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("missing scopes")
+	}
+	scopes := make([]string, 0, len(rawScopes))
+	for _, raw := range rawScopes {
+		scope, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid scopes")
+		}
+		scopes = append(scopes, scope)
+	}
+
+	// 可选参数，缺省时使用默认限流且永不过期
+	rateLimit, _ := reqData["rate_limit"].(float64)
+	expiresInHours, _ := reqData["expires_in_hours"].(float64)
+
+	result, err := h.createFunc(token, name, scopes, int(rateLimit), int(expiresInHours), ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// APIKeyHandler API密钥管理处理器（列表、吊销）
+type APIKeyHandler struct {
+	*AuthHandler
+	argKey     string // 请求中携带的参数名，列表请求为空
+	apiKeyFunc func(token, arg string, client *common.ClientInfo) (*common.MsgAPIKeyResult, error)
+}
+
+// NewAPIKeyListHandler 创建API密钥列表处理器
+func NewAPIKeyListHandler(natsManager *nats.Manager, listFunc func(string, string, *common.ClientInfo) (*common.MsgAPIKeyResult, error)) *APIKeyHandler {
+	return &APIKeyHandler{
+		AuthHandler: NewAuthHandler("APIKeyListHandler", "C_APIKeyList", natsManager),
+		apiKeyFunc:  listFunc,
+	}
+}
+
+// NewAPIKeyRevokeHandler 创建吊销API密钥处理器
+func NewAPIKeyRevokeHandler(natsManager *nats.Manager, revokeFunc func(string, string, *common.ClientInfo) (*common.MsgAPIKeyResult, error)) *APIKeyHandler {
+	return &APIKeyHandler{
+		AuthHandler: NewAuthHandler("APIKeyRevokeHandler", "C_APIKeyRevoke", natsManager),
+		argKey:      "key_id",
+		apiKeyFunc:  revokeFunc,
+	}
+}
+
+// Handle 处理API密钥管理请求
+func (h *APIKeyHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	var arg string
+	if h.argKey != "" {
+		arg, ok = reqData[h.argKey].(string)
+		if !ok {
+			return nil, fmt.Errorf("missing %s", h.argKey)
+		}
+	}
+
+	result, err := h.apiKeyFunc(token, arg, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// APIKeyValidateHandler API密钥校验处理器（Gateway 处理只读请求时使用）
+type APIKeyValidateHandler struct {
+	*AuthHandler
+	validateFunc func(key, scope string) (*common.MsgAPIKeyResult, error)
+}
+
+// NewAPIKeyValidateHandler 创建API密钥校验处理器
+func NewAPIKeyValidateHandler(natsManager *nats.Manager, validateFunc func(string, string) (*common.MsgAPIKeyResult, error)) *APIKeyValidateHandler {
+	return &APIKeyValidateHandler{
+		AuthHandler:  NewAuthHandler("APIKeyValidateHandler", "C_APIKeyValidate", natsManager),
+		validateFunc: validateFunc,
+	}
+}
+
+// Handle 处理API密钥校验
+func (h *APIKeyValidateHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	key, ok := reqData["key"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing key")
+	}
+
+	scope, ok := reqData["scope"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing scope")
+	}
+
+	result, err := h.validateFunc(key, scope)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// DataRequestHandler 数据导出/删除请求处理器
+type DataRequestHandler struct {
+	*AuthHandler
+	argKey      string // 请求中携带的参数名
+	argOptional bool
+	requestFunc func(token, arg string, client *common.ClientInfo) (*common.MsgDataRequestResult, error)
+}
+
+// NewDataExportHandler 创建申请导出数据处理器
+func NewDataExportHandler(natsManager *nats.Manager, exportFunc func(string, string, *common.ClientInfo) (*common.MsgDataRequestResult, error)) *DataRequestHandler {
+	return &DataRequestHandler{
+		AuthHandler: NewAuthHandler("DataExportHandler", "C_DataExport", natsManager),
+		requestFunc: exportFunc,
+	}
+}
+
+// NewDataErasureHandler 创建申请删除账号处理器，需要再次提交密码确认
+func NewDataErasureHandler(natsManager *nats.Manager, erasureFunc func(string, string, *common.ClientInfo) (*common.MsgDataRequestResult, error)) *DataRequestHandler {
+	return &DataRequestHandler{
+		AuthHandler: NewAuthHandler("DataErasureHandler", "C_DataErasure", natsManager),
+		argKey:      "password",
+		requestFunc: erasureFunc,
+	}
+}
+
+// NewDataErasureCancelHandler 创建撤销删除处理器
+func NewDataErasureCancelHandler(natsManager *nats.Manager, cancelFunc func(string, string, *common.ClientInfo) (*common.MsgDataRequestResult, error)) *DataRequestHandler {
+	return &DataRequestHandler{
+		AuthHandler: NewAuthHandler("DataErasureCancelHandler", "C_DataErasureCancel", natsManager),
+		argKey:      "request_id",
+		requestFunc: cancelFunc,
+	}
+}
+
+// NewDataRequestStatusHandler 创建请求状态查询处理器，不带 request_id 时返回请求列表
+func NewDataRequestStatusHandler(natsManager *nats.Manager, statusFunc func(string, string, *common.ClientInfo) (*common.MsgDataRequestResult, error)) *DataRequestHandler {
+	return &DataRequestHandler{
+		AuthHandler: NewAuthHandler("DataRequestStatusHandler", "C_DataRequestStatus", natsManager),
+		argKey:      "request_id",
+		argOptional: true,
+		requestFunc: statusFunc,
+	}
+}
+
+// Handle 处理数据导出/删除请求
+func (h *DataRequestHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	token, ok := reqData["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	var arg string
+	if h.argKey != "" {
+		arg, ok = reqData[h.argKey].(string)
+		if !ok && !h.argOptional {
+			return nil, fmt.Errorf("missing %s", h.argKey)
+		}
+	}
+
+	result, err := h.requestFunc(token, arg, ClientInfoFromContext(ctx))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	if !result.Success {
+		return ErrorResponseWithID(ctx.RequestID, errors.New(result.Message)), nil
 	}
 
 	return SuccessResponseWithID(ctx.RequestID, result), nil
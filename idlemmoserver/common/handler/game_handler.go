@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 
+	"github.com/idle-server/common"
 	"github.com/idle-server/common/nats"
 )
 
@@ -183,3 +185,274 @@ func (h *GameActionHandler) Handle(ctx *MessageContext, request interface{}) (*R
 		"result":    result,
 	}), nil
 }
+
+// SequenceHandler 修炼序列处理器（开始/停止）
+type SequenceHandler struct {
+	*GameHandler
+	sequenceFunc func(playerID, sequenceID string) (interface{}, error)
+}
+
+// NewStartSequenceHandler 创建开始序列处理器
+func NewStartSequenceHandler(natsManager *nats.Manager, startFunc func(string, string) (interface{}, error)) *SequenceHandler {
+	return &SequenceHandler{
+		GameHandler:  NewGameHandler("StartSequenceHandler", common.ClientMsgTypeStartSeq, natsManager),
+		sequenceFunc: startFunc,
+	}
+}
+
+// NewStopSequenceHandler 创建停止序列处理器
+func NewStopSequenceHandler(natsManager *nats.Manager, stopFunc func(string, string) (interface{}, error)) *SequenceHandler {
+	return &SequenceHandler{
+		GameHandler:  NewGameHandler("StopSequenceHandler", common.ClientMsgTypeStopSeq, natsManager),
+		sequenceFunc: stopFunc,
+	}
+}
+
+// Handle 处理开始/停止修炼序列
+func (h *SequenceHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+	sequenceID, _ := reqData["sequence_id"].(string)
+
+	log.Printf("Processing %s for player: %s", ctx.MessageType, playerID)
+
+	result, err := h.sequenceFunc(playerID, sequenceID)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// InventoryHandler 背包操作处理器（使用、丢弃、整理）
+type InventoryHandler struct {
+	*GameHandler
+	inventoryFunc func(playerID, action string, slot, count int) (interface{}, error)
+}
+
+// NewInventoryHandler 创建背包操作处理器，action 为客户端消息类型
+func NewInventoryHandler(natsManager *nats.Manager, msgType string, inventoryFunc func(string, string, int, int) (interface{}, error)) *InventoryHandler {
+	return &InventoryHandler{
+		GameHandler:   NewGameHandler("InventoryHandler:"+msgType, msgType, natsManager),
+		inventoryFunc: inventoryFunc,
+	}
+}
+
+// Handle 处理背包操作
+func (h *InventoryHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+	slot, _ := reqData["slot"].(float64)
+	count, _ := reqData["count"].(float64)
+
+	log.Printf("Processing %s for player: %s", ctx.MessageType, playerID)
+
+	result, err := h.inventoryFunc(playerID, ctx.MessageType, int(slot), int(count))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// EquipmentHandler 装备处理器（穿戴、卸下）
+type EquipmentHandler struct {
+	*GameHandler
+	equipFunc func(playerID, action string, slot int, equipSlot string) (interface{}, error)
+}
+
+// NewEquipmentHandler 创建装备处理器，action 为客户端消息类型
+func NewEquipmentHandler(natsManager *nats.Manager, msgType string, equipFunc func(string, string, int, string) (interface{}, error)) *EquipmentHandler {
+	return &EquipmentHandler{
+		GameHandler: NewGameHandler("EquipmentHandler:"+msgType, msgType, natsManager),
+		equipFunc:   equipFunc,
+	}
+}
+
+// Handle 处理穿戴/卸下装备
+func (h *EquipmentHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+	slot, _ := reqData["slot"].(float64)
+	equipSlot, _ := reqData["equip_slot"].(string)
+
+	log.Printf("Processing %s for player: %s", ctx.MessageType, playerID)
+
+	result, err := h.equipFunc(playerID, ctx.MessageType, int(slot), equipSlot)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// ContentReloadHandler 配置表热更新处理器（管理员权限由 Gateway 校验）
+type ContentReloadHandler struct {
+	*GameHandler
+	reloadFunc func() (*common.MsgContentReloadResult, error)
+}
+
+// NewContentReloadHandler 创建配置表热更新处理器
+func NewContentReloadHandler(natsManager *nats.Manager, reloadFunc func() (*common.MsgContentReloadResult, error)) *ContentReloadHandler {
+	return &ContentReloadHandler{
+		GameHandler: NewGameHandler("ContentReloadHandler", common.ClientMsgTypeContentReload, natsManager),
+		reloadFunc:  reloadFunc,
+	}
+}
+
+// Handle 处理配置表热更新
+func (h *ContentReloadHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	log.Printf("Processing content reload request")
+
+	result, err := h.reloadFunc()
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// AdminGrantHandler 管理员发放处理器（管理员权限由 Gateway 校验）
+type AdminGrantHandler struct {
+	*GameHandler
+	grantFunc func(playerID string, params map[string]interface{}) (interface{}, error)
+}
+
+// NewAdminGrantHandler 创建管理员发放处理器
+func NewAdminGrantHandler(natsManager *nats.Manager, grantFunc func(string, map[string]interface{}) (interface{}, error)) *AdminGrantHandler {
+	return &AdminGrantHandler{
+		GameHandler: NewGameHandler("AdminGrantHandler", common.ClientMsgTypeAdminGrant, natsManager),
+		grantFunc:   grantFunc,
+	}
+}
+
+// Handle 处理管理员发放
+func (h *AdminGrantHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	log.Printf("Processing admin grant for player: %s", playerID)
+
+	result, err := h.grantFunc(playerID, reqData)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// PlayerChargeHandler 代扣货币处理器（由 Auth 等内部服务调用）
+type PlayerChargeHandler struct {
+	*GameHandler
+	chargeFunc func(charge common.PlayerCharge) (*common.MsgPlayerChargeResult, error)
+}
+
+// NewPlayerChargeHandler 创建代扣货币处理器
+func NewPlayerChargeHandler(natsManager *nats.Manager, chargeFunc func(common.PlayerCharge) (*common.MsgPlayerChargeResult, error)) *PlayerChargeHandler {
+	return &PlayerChargeHandler{
+		GameHandler: NewGameHandler("PlayerChargeHandler", "C_PlayerCharge", natsManager),
+		chargeFunc:  chargeFunc,
+	}
+}
+
+// Handle 处理代扣货币
+func (h *PlayerChargeHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	// charge 经 JSON 解码为通用结构，重新编码后解析为代扣请求
+	encoded, err := json.Marshal(reqData["charge"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid charge: %w", err)
+	}
+	var charge common.PlayerCharge
+	if err := json.Unmarshal(encoded, &charge); err != nil {
+		return nil, fmt.Errorf("invalid charge: %w", err)
+	}
+
+	log.Printf("Processing charge %s for player: %s", charge.ChargeID, charge.PlayerID)
+
+	result, err := h.chargeFunc(charge)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// PlayerAptitudeHandler 资质更新处理器（Auth 重随资质后调用）
+type PlayerAptitudeHandler struct {
+	*GameHandler
+	updateFunc func(playerID string, aptitude *common.Aptitude) error
+}
+
+// NewPlayerAptitudeHandler 创建资质更新处理器
+func NewPlayerAptitudeHandler(natsManager *nats.Manager, updateFunc func(string, *common.Aptitude) error) *PlayerAptitudeHandler {
+	return &PlayerAptitudeHandler{
+		GameHandler: NewGameHandler("PlayerAptitudeHandler", "C_PlayerAptitude", natsManager),
+		updateFunc:  updateFunc,
+	}
+}
+
+// Handle 处理资质更新
+func (h *PlayerAptitudeHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	// aptitude 经 JSON 解码为通用结构，重新编码后解析为资质
+	encoded, err := json.Marshal(reqData["aptitude"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid aptitude: %w", err)
+	}
+	var aptitude common.Aptitude
+	if err := json.Unmarshal(encoded, &aptitude); err != nil {
+		return nil, fmt.Errorf("invalid aptitude: %w", err)
+	}
+
+	log.Printf("Processing aptitude update for player: %s", playerID)
+
+	if err := h.updateFunc(playerID, &aptitude); err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"player_id": playerID,
+	}), nil
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/idle-server/common"
 	"github.com/idle-server/common/nats"
 	natsio "github.com/nats-io/nats.go"
 )
@@ -136,10 +137,6 @@ func (p *MessageProcessor) ProcessMessage(msg *natsio.Msg) error {
 		return p.errorHandler.HandleError(fmt.Errorf("invalid message format: %w", err), msg.Reply)
 	}
 
-	// 调试：记录收到的消息
-	log.Printf("MessageProcessor: Received message: %+v", request)
-	log.Printf("MessageProcessor: Raw message data: %s", string(msg.Data))
-
 	// 提取消息类型
 	messageType, ok := request["type"].(string)
 	if !ok {
@@ -147,6 +144,9 @@ func (p *MessageProcessor) ProcessMessage(msg *natsio.Msg) error {
 		return p.errorHandler.HandleError(fmt.Errorf("missing message type"), msg.Reply)
 	}
 
+	// 只记录消息类型，消息体中可能包含令牌和密码
+	log.Printf("MessageProcessor: Received %s on %s", messageType, msg.Subject)
+
 	// 创建消息上下文
 	ctx := &MessageContext{
 		MessageType: messageType,
@@ -203,6 +203,24 @@ func (p *MessageProcessor) extractMetadata(request map[string]interface{}) map[s
 	return make(map[string]interface{})
 }
 
+// ClientInfoFromContext 从消息 metadata 中提取客户端来源信息
+func ClientInfoFromContext(ctx *MessageContext) *common.ClientInfo {
+	client := &common.ClientInfo{}
+	if ctx == nil || ctx.Metadata == nil {
+		return client
+	}
+	if ip, ok := ctx.Metadata["ip"].(string); ok {
+		client.IP = ip
+	}
+	if userAgent, ok := ctx.Metadata["user_agent"].(string); ok {
+		client.UserAgent = userAgent
+	}
+	if deviceName, ok := ctx.Metadata["device_name"].(string); ok {
+		client.DeviceName = deviceName
+	}
+	return client
+}
+
 // getMapKeys 获取map的所有键
 func getMapKeys(m map[string]interface{}) []string {
 	keys := make([]string, 0, len(m))
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
+	"github.com/idle-server/common"
 	"github.com/idle-server/common/nats"
 )
 
@@ -183,7 +186,7 @@ func (h *LoadPlayerHandler) Handle(ctx *MessageContext, request interface{}) (*R
 	}), nil
 }
 
-// DeleteUserHandler 删除用户数据处理器
+// DeleteUserHandler 删除用户数据处理器，数据在冷静期结束后才会被删除
 type DeleteUserHandler struct {
 	*PersistHandler
 	deleteFunc func(userID string) error
@@ -217,6 +220,507 @@ func (h *DeleteUserHandler) Handle(ctx *MessageContext, request interface{}) (*R
 
 	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
 		"user_id": userID,
-		"status":  "deleted",
+		"status":  "erasure_scheduled",
 	}), nil
 }
+
+// ProcessDataRequestsHandler 立即处理到期的数据导出/删除请求
+type ProcessDataRequestsHandler struct {
+	*PersistHandler
+	processFunc func() error
+}
+
+// NewProcessDataRequestsHandler 创建数据请求处理触发器
+func NewProcessDataRequestsHandler(natsManager *nats.Manager, processFunc func() error) *ProcessDataRequestsHandler {
+	return &ProcessDataRequestsHandler{
+		PersistHandler: NewPersistHandler("ProcessDataRequestsHandler", "C_ProcessDataRequests", natsManager),
+		processFunc:    processFunc,
+	}
+}
+
+// Handle 处理数据请求触发消息
+func (h *ProcessDataRequestsHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	if err := h.processFunc(); err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"status": "processing",
+	}), nil
+}
+
+// LeaderboardHandler 等级排行榜查询处理器
+type LeaderboardHandler struct {
+	*PersistHandler
+	leaderboardFunc func(limit int) ([]common.PlayerRanking, error)
+}
+
+// NewLeaderboardHandler 创建排行榜查询处理器
+func NewLeaderboardHandler(natsManager *nats.Manager, leaderboardFunc func(int) ([]common.PlayerRanking, error)) *LeaderboardHandler {
+	return &LeaderboardHandler{
+		PersistHandler:  NewPersistHandler("LeaderboardHandler", "C_Leaderboard", natsManager),
+		leaderboardFunc: leaderboardFunc,
+	}
+}
+
+// Handle 处理排行榜查询
+func (h *LeaderboardHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	limit, _ := reqData["limit"].(float64)
+
+	rankings, err := h.leaderboardFunc(int(limit))
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"rankings": rankings,
+	}), nil
+}
+
+// PlayerProfileHandler 角色公开资料查询处理器
+type PlayerProfileHandler struct {
+	*PersistHandler
+	profileFunc func(playerID string) (*common.PlayerProfile, error)
+}
+
+// NewPlayerProfileHandler 创建角色资料查询处理器
+func NewPlayerProfileHandler(natsManager *nats.Manager, profileFunc func(string) (*common.PlayerProfile, error)) *PlayerProfileHandler {
+	return &PlayerProfileHandler{
+		PersistHandler: NewPersistHandler("PlayerProfileHandler", "C_PlayerProfile", natsManager),
+		profileFunc:    profileFunc,
+	}
+}
+
+// Handle 处理角色资料查询
+func (h *PlayerProfileHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	profile, err := h.profileFunc(playerID)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, profile), nil
+}
+
+// LoadProgressHandler 加载玩家进度处理器
+type LoadProgressHandler struct {
+	*PersistHandler
+	loadFunc func(playerID string) ([]common.ProgressEntry, error)
+}
+
+// NewLoadProgressHandler 创建加载玩家进度处理器
+func NewLoadProgressHandler(natsManager *nats.Manager, loadFunc func(string) ([]common.ProgressEntry, error)) *LoadProgressHandler {
+	return &LoadProgressHandler{
+		PersistHandler: NewPersistHandler("LoadProgressHandler", "C_LoadProgress", natsManager),
+		loadFunc:       loadFunc,
+	}
+}
+
+// Handle 处理加载玩家进度
+func (h *LoadProgressHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	entries, err := h.loadFunc(playerID)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"player_id": playerID,
+		"entries":   entries,
+	}), nil
+}
+
+// SaveProgressHandler 保存玩家进度处理器
+type SaveProgressHandler struct {
+	*PersistHandler
+	saveFunc func(playerID string, entries []common.ProgressEntry) error
+}
+
+// NewSaveProgressHandler 创建保存玩家进度处理器
+func NewSaveProgressHandler(natsManager *nats.Manager, saveFunc func(string, []common.ProgressEntry) error) *SaveProgressHandler {
+	return &SaveProgressHandler{
+		PersistHandler: NewPersistHandler("SaveProgressHandler", "C_SaveProgress", natsManager),
+		saveFunc:       saveFunc,
+	}
+}
+
+// Handle 处理保存玩家进度
+func (h *SaveProgressHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	// entries 经 JSON 解码为通用结构，重新编码后解析为进度记录
+	encoded, err := json.Marshal(reqData["entries"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid entries: %w", err)
+	}
+	var entries []common.ProgressEntry
+	if err := json.Unmarshal(encoded, &entries); err != nil {
+		return nil, fmt.Errorf("invalid entries: %w", err)
+	}
+
+	if err := h.saveFunc(playerID, entries); err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"player_id": playerID,
+		"saved":     len(entries),
+	}), nil
+}
+
+// SendMailHandler 投递邮件处理器
+type SendMailHandler struct {
+	*PersistHandler
+	sendFunc func(mail common.Mail) error
+}
+
+// NewSendMailHandler 创建投递邮件处理器
+func NewSendMailHandler(natsManager *nats.Manager, sendFunc func(common.Mail) error) *SendMailHandler {
+	return &SendMailHandler{
+		PersistHandler: NewPersistHandler("SendMailHandler", "C_SendMail", natsManager),
+		sendFunc:       sendFunc,
+	}
+}
+
+// Handle 处理投递邮件
+func (h *SendMailHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	// mail 经 JSON 解码为通用结构，重新编码后解析为邮件
+	encoded, err := json.Marshal(reqData["mail"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail: %w", err)
+	}
+	var mail common.Mail
+	if err := json.Unmarshal(encoded, &mail); err != nil {
+		return nil, fmt.Errorf("invalid mail: %w", err)
+	}
+
+	if err := h.sendFunc(mail); err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"mail_id": mail.MailID,
+	}), nil
+}
+
+// ListMailHandler 查询邮件处理器
+type ListMailHandler struct {
+	*PersistHandler
+	listFunc func(playerID string) ([]common.Mail, error)
+}
+
+// NewListMailHandler 创建查询邮件处理器
+func NewListMailHandler(natsManager *nats.Manager, listFunc func(string) ([]common.Mail, error)) *ListMailHandler {
+	return &ListMailHandler{
+		PersistHandler: NewPersistHandler("ListMailHandler", "C_ListMail", natsManager),
+		listFunc:       listFunc,
+	}
+}
+
+// Handle 处理查询邮件
+func (h *ListMailHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	mails, err := h.listFunc(playerID)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"player_id": playerID,
+		"mails":     mails,
+	}), nil
+}
+
+// ClaimMailHandler 领取邮件附件处理器
+type ClaimMailHandler struct {
+	*PersistHandler
+	claimFunc func(playerID, mailID string) (*common.Mail, error)
+}
+
+// NewClaimMailHandler 创建领取邮件附件处理器
+func NewClaimMailHandler(natsManager *nats.Manager, claimFunc func(string, string) (*common.Mail, error)) *ClaimMailHandler {
+	return &ClaimMailHandler{
+		PersistHandler: NewPersistHandler("ClaimMailHandler", "C_ClaimMail", natsManager),
+		claimFunc:      claimFunc,
+	}
+}
+
+// Handle 处理领取邮件附件
+func (h *ClaimMailHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+	mailID, ok := reqData["mail_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing mail_id")
+	}
+
+	mail, err := h.claimFunc(playerID, mailID)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"mail": mail,
+	}), nil
+}
+
+// RecordPurchaseHandler 写入购买记录处理器
+type RecordPurchaseHandler struct {
+	*PersistHandler
+	recordFunc func(record common.PurchaseRecord) error
+}
+
+// NewRecordPurchaseHandler 创建写入购买记录处理器
+func NewRecordPurchaseHandler(natsManager *nats.Manager, recordFunc func(common.PurchaseRecord) error) *RecordPurchaseHandler {
+	return &RecordPurchaseHandler{
+		PersistHandler: NewPersistHandler("RecordPurchaseHandler", "C_RecordPurchase", natsManager),
+		recordFunc:     recordFunc,
+	}
+}
+
+// Handle 处理写入购买记录
+func (h *RecordPurchaseHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	// purchase 经 JSON 解码为通用结构，重新编码后解析为购买记录
+	encoded, err := json.Marshal(reqData["purchase"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid purchase: %w", err)
+	}
+	var record common.PurchaseRecord
+	if err := json.Unmarshal(encoded, &record); err != nil {
+		return nil, fmt.Errorf("invalid purchase: %w", err)
+	}
+
+	if err := h.recordFunc(record); err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"request_id": record.RequestID,
+	}), nil
+}
+
+// PurchaseHistoryHandler 查询购买记录处理器
+type PurchaseHistoryHandler struct {
+	*PersistHandler
+	listFunc func(playerID string) ([]common.PurchaseRecord, error)
+}
+
+// NewPurchaseHistoryHandler 创建查询购买记录处理器
+func NewPurchaseHistoryHandler(natsManager *nats.Manager, listFunc func(string) ([]common.PurchaseRecord, error)) *PurchaseHistoryHandler {
+	return &PurchaseHistoryHandler{
+		PersistHandler: NewPersistHandler("PurchaseHistoryHandler", "C_PurchaseHistory", natsManager),
+		listFunc:       listFunc,
+	}
+}
+
+// Handle 处理查询购买记录
+func (h *PurchaseHistoryHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	purchases, err := h.listFunc(playerID)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"player_id": playerID,
+		"purchases": purchases,
+	}), nil
+}
+
+// FindPurchaseHandler 按请求ID查找购买记录处理器
+type FindPurchaseHandler struct {
+	*PersistHandler
+	findFunc func(playerID, requestID string) (*common.PurchaseRecord, error)
+}
+
+// NewFindPurchaseHandler 创建按请求ID查找购买记录处理器
+func NewFindPurchaseHandler(natsManager *nats.Manager, findFunc func(string, string) (*common.PurchaseRecord, error)) *FindPurchaseHandler {
+	return &FindPurchaseHandler{
+		PersistHandler: NewPersistHandler("FindPurchaseHandler", "C_FindPurchase", natsManager),
+		findFunc:       findFunc,
+	}
+}
+
+// Handle 处理按请求ID查找购买记录，记录不存在时 purchase 为空
+func (h *FindPurchaseHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	playerID, ok := reqData["player_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing player_id")
+	}
+	requestID, ok := reqData["request_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing request_id")
+	}
+
+	purchase, err := h.findFunc(playerID, requestID)
+	if err != nil {
+		return ErrorResponseWithID(ctx.RequestID, err), nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, map[string]interface{}{
+		"player_id": playerID,
+		"purchase":  purchase,
+	}), nil
+}
+
+// MarketHandler 玩家市场操作处理器，各操作共用同一请求格式（common.MarketRequest），按消息类型区分
+type MarketHandler struct {
+	*PersistHandler
+	marketFunc func(req common.MarketRequest) (interface{}, error)
+}
+
+// NewMarketHandler 创建玩家市场操作处理器
+func NewMarketHandler(natsManager *nats.Manager, messageType string, marketFunc func(common.MarketRequest) (interface{}, error)) *MarketHandler {
+	return &MarketHandler{
+		PersistHandler: NewPersistHandler("MarketHandler."+messageType, messageType, natsManager),
+		marketFunc:     marketFunc,
+	}
+}
+
+// Handle 处理市场操作；操作被拒绝时在 Data 中标记 rejected，Game 据此退还托管的物品或货币
+func (h *MarketHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	// market 经 JSON 解码为通用结构，重新编码后解析为市场请求
+	encoded, err := json.Marshal(reqData["market"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid market request: %w", err)
+	}
+	var req common.MarketRequest
+	if err := json.Unmarshal(encoded, &req); err != nil {
+		return nil, fmt.Errorf("invalid market request: %w", err)
+	}
+	if req.PlayerID == "" {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	result, err := h.marketFunc(req)
+	if err != nil {
+		response := ErrorResponseWithID(ctx.RequestID, err)
+		if errors.Is(err, common.ErrMarketRejected) {
+			response.Data = map[string]interface{}{"rejected": true}
+		}
+		return response, nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
+
+// SectHandler 宗门操作处理器，各操作共用同一请求格式（common.SectRequest），按消息类型区分
+type SectHandler struct {
+	*PersistHandler
+	sectFunc func(req common.SectRequest) (interface{}, error)
+}
+
+// NewSectHandler 创建宗门操作处理器
+func NewSectHandler(natsManager *nats.Manager, messageType string, sectFunc func(common.SectRequest) (interface{}, error)) *SectHandler {
+	return &SectHandler{
+		PersistHandler: NewPersistHandler("SectHandler."+messageType, messageType, natsManager),
+		sectFunc:       sectFunc,
+	}
+}
+
+// Handle 处理宗门操作；操作被拒绝时在 Data 中标记 rejected，Game 据此退还托管的货币
+func (h *SectHandler) Handle(ctx *MessageContext, request interface{}) (*Response, error) {
+	reqData, ok := request.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	encoded, err := json.Marshal(reqData["sect"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid sect request: %w", err)
+	}
+	var req common.SectRequest
+	if err := json.Unmarshal(encoded, &req); err != nil {
+		return nil, fmt.Errorf("invalid sect request: %w", err)
+	}
+	if req.PlayerID == "" {
+		return nil, fmt.Errorf("missing player_id")
+	}
+
+	result, err := h.sectFunc(req)
+	if err != nil {
+		response := ErrorResponseWithID(ctx.RequestID, err)
+		if errors.Is(err, common.ErrSectRejected) {
+			response.Data = map[string]interface{}{"rejected": true}
+		}
+		return response, nil
+	}
+
+	return SuccessResponseWithID(ctx.RequestID, result), nil
+}
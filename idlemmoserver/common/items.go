@@ -0,0 +1,52 @@
+package common
+
+// ============ 物品定义 ============
+// 背包中的物品按定义表校验堆叠上限与使用效果，定义表由 ContentDir/items 加载
+
+// 物品类型，整理背包时按此顺序排列
+const (
+	ItemTypeEquipment  = "equipment"  // 装备，不可堆叠
+	ItemTypeConsumable = "consumable" // 消耗品
+	ItemTypeMaterial   = "material"   // 材料
+	ItemTypeCurrency   = "currency"   // 货币类物品（如灵石）
+)
+
+// ItemTypeOrder 整理背包时的类型排序
+var ItemTypeOrder = map[string]int{
+	ItemTypeEquipment:  0,
+	ItemTypeConsumable: 1,
+	ItemTypeMaterial:   2,
+	ItemTypeCurrency:   3,
+}
+
+// ItemEffect 消耗品的使用效果
+type ItemEffect struct {
+	Exp                 int64            `json:"exp,omitempty"`                   // 立即获得的角色经验
+	BuffExpMultiplier   float64          `json:"buff_exp_multiplier,omitempty"`   // 增益：经验倍率
+	BuffDropMultiplier  float64          `json:"buff_drop_multiplier,omitempty"`  // 增益：掉落概率倍率
+	BuffDurationMinutes int              `json:"buff_duration_minutes,omitempty"` // 增益持续时间，重复使用时顺延
+	Stamina             map[string]int64 `json:"stamina,omitempty"`               // 补充体力等回复资源，可超出自然回复上限
+}
+
+// ItemDef 物品定义
+type ItemDef struct {
+	ID       string      `json:"id"`
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	MaxStack int         `json:"max_stack"`        // 单格堆叠上限
+	Effect   *ItemEffect `json:"effect,omitempty"` // 非空表示可使用
+	Equip    *EquipDef   `json:"equip,omitempty"`  // 非空表示可穿戴
+}
+
+// GetItemDef 从当前生效的配置表获取物品定义（配置表见 content.go）
+func GetItemDef(itemID string) (ItemDef, bool) {
+	def, ok := CurrentContent().Items[itemID]
+	return def, ok
+}
+
+// InventorySlot 背包格子，Count 为 0 表示该格已清空
+type InventorySlot struct {
+	Slot   int    `json:"slot"`
+	ItemID string `json:"item_id,omitempty"`
+	Count  int    `json:"count"`
+}
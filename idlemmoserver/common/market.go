@@ -0,0 +1,86 @@
+package common
+
+import (
+	"errors"
+	"math"
+)
+
+// ============ 玩家市场 ============
+// 挂单的物品从卖家背包转入托管（market_listings 表），买家一口价购买或在拍卖挂单上出价，
+// 出价的货币同样托管；成交、被超出的出价、撤单和到期退回的物品与货币都通过邮件送达。
+// Game 为每个操作生成唯一的操作ID并在存档中记录待确认的操作，Persist 在单个事务内完成
+// 校验、转移与邮件投递，同一操作ID重复提交时返回原结果，保证每笔交易只执行一次
+
+// ErrMarketRejected 市场操作被拒绝（挂单不存在、已成交、出价不足等），调用方应退还托管的物品或货币
+var ErrMarketRejected = errors.New("market request rejected")
+
+// 挂单状态
+const (
+	MarketStatusActive    = "active"
+	MarketStatusSold      = "sold"
+	MarketStatusCancelled = "cancelled"
+	MarketStatusExpired   = "expired"
+)
+
+// 市场搜索排序
+const (
+	MarketSortPriceAsc   = "price_asc"
+	MarketSortPriceDesc  = "price_desc"
+	MarketSortEndingSoon = "ending_soon"
+	MarketSortNewest     = "newest"
+)
+
+// MarketListing 市场挂单，BuyoutPrice 为 0 表示不能一口价购买，StartPrice 为 0 表示不接受出价
+type MarketListing struct {
+	ListingID   string `json:"listing_id"`
+	SellerID    string `json:"seller_id"`
+	ItemID      string `json:"item_id"`
+	ItemType    string `json:"item_type"`
+	Count       int    `json:"count"`
+	Currency    string `json:"currency"`
+	BuyoutPrice int64  `json:"buyout_price,omitempty"`
+	StartPrice  int64  `json:"start_price,omitempty"`
+	CurrentBid  int64  `json:"current_bid,omitempty"`
+	BidderID    string `json:"bidder_id,omitempty"`
+	BuyerID     string `json:"buyer_id,omitempty"`
+	Status      string `json:"status"`
+	ExpiresAt   int64  `json:"expires_at"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// MarketQuery 市场搜索条件，零值表示不限
+type MarketQuery struct {
+	ItemID      string `json:"item_id,omitempty"`
+	ItemType    string `json:"item_type,omitempty"`
+	MinPrice    int64  `json:"min_price,omitempty"` // 按一口价筛选
+	MaxPrice    int64  `json:"max_price,omitempty"`
+	AuctionOnly bool   `json:"auction_only,omitempty"`
+	Sort        string `json:"sort,omitempty"`
+	Offset      int    `json:"offset,omitempty"`
+	Limit       int    `json:"limit,omitempty"`
+}
+
+// MarketRequest Game 向 Persist 发起的市场操作
+type MarketRequest struct {
+	OpID      string         `json:"op_id,omitempty"` // 挂单ID、交易ID或出价ID，重复提交同一操作时返回原结果
+	PlayerID  string         `json:"player_id"`
+	ListingID string         `json:"listing_id,omitempty"`
+	Amount    int64          `json:"amount,omitempty"` // 一口价购买时为买家看到的价格，出价时为出价金额
+	Listing   *MarketListing `json:"listing,omitempty"`
+	Query     *MarketQuery   `json:"query,omitempty"`
+}
+
+// MarketFee 成交手续费（从卖家所得中扣除并回收），不超过成交价
+func MarketFee(price int64) int64 {
+	fee := max(int64(math.Ceil(float64(price)*MarketFeeRate)), MarketMinFee)
+	return min(fee, price)
+}
+
+// MarketMinNextBid 挂单可接受的最低出价
+func MarketMinNextBid(listing MarketListing) int64 {
+	if listing.CurrentBid == 0 {
+		return listing.StartPrice
+	}
+	increment := max(int64(math.Ceil(float64(listing.CurrentBid)*MarketMinBidIncrement)), 1)
+	return listing.CurrentBid + increment
+}
@@ -1,6 +1,8 @@
 package common
 
 import (
+	"encoding/json"
+
 	"github.com/asynkron/protoactor-go/actor"
 	"github.com/gorilla/websocket"
 )
@@ -38,11 +40,15 @@ type MsgVerifyToken struct {
 	Token string
 }
 
-// MsgVerifyTokenResult 验证Token结果
+// MsgVerifyTokenResult 验证Token结果，PlayerID 为令牌绑定的角色
 type MsgVerifyTokenResult struct {
-	Success  bool
-	PlayerID string
-	Error    string
+	Success   bool   `json:"success"`
+	PlayerID  string `json:"playerId"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID string `json:"sessionId,omitempty"`
+	Name      string `json:"name,omitempty"` // 角色显示名称，聊天等公开场景使用
+	Error     string `json:"error,omitempty"`
 }
 
 // ============ 玩家状态相关消息 ============
@@ -99,11 +105,116 @@ type MsgAuthenticateUser struct {
 }
 
 // MsgAuthenticateUserResult 认证用户结果
+// 开启双因素认证的账号在密码校验通过后只返回 ChallengeToken，
+// 需要再调用 auth.2fa.verify 提交动态码才能拿到正式 Token；
+// 被强制要求但尚未绑定的账号（EnrollmentRequired）需先提交GM/管理员签发的绑定令牌换取绑定信息
 type MsgAuthenticateUserResult struct {
-	Success  bool   `json:"success"`
-	Message  string `json:"message"`
-	PlayerID string `json:"playerId"`
-	Token    string `json:"token"`
+	Success            bool            `json:"success"`
+	Message            string          `json:"message"`
+	PlayerID           string          `json:"playerId"`
+	Token              string          `json:"token"`
+	TwoFactorRequired  bool            `json:"twoFactorRequired,omitempty"`
+	EnrollmentRequired bool            `json:"enrollmentRequired,omitempty"`
+	ChallengeToken     string          `json:"challengeToken,omitempty"`
+	TwoFactorSetup     *TwoFactorSetup `json:"twoFactorSetup,omitempty"`
+	RecoveryCodes      []string        `json:"recoveryCodes,omitempty"`
+	Characters         []CharacterInfo `json:"characters,omitempty"`
+}
+
+// TwoFactorSetup TOTP绑定信息，客户端用 ProvisioningURI 生成二维码
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+// MsgTwoFactorResult 双因素认证管理操作结果
+type MsgTwoFactorResult struct {
+	Success         bool            `json:"success"`
+	Message         string          `json:"message"`
+	Setup           *TwoFactorSetup `json:"setup,omitempty"`
+	RecoveryCodes   []string        `json:"recoveryCodes,omitempty"`
+	EnrollmentToken string          `json:"enrollmentToken,omitempty"` // 强制开启时签发，由GM/管理员线下交给账号本人
+}
+
+// MsgAccountResult 账号管理操作结果（刷新令牌、修改密码、封禁等）
+type MsgAccountResult struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Token   string `json:"token,omitempty"`
+}
+
+// MsgContentReloadResult 配置表热更新结果
+type MsgContentReloadResult struct {
+	Success         bool   `json:"success"`
+	Message         string `json:"message"`
+	Version         string `json:"version,omitempty"`          // 当前生效的版本
+	PreviousVersion string `json:"previous_version,omitempty"` // 被替换的版本
+}
+
+// MsgAdminGrantResult 管理员发放结果
+type MsgAdminGrantResult struct {
+	Success  bool           `json:"success"`
+	Message  string         `json:"message"`
+	Level    int            `json:"level"`
+	Exp      int64          `json:"exp"`
+	Balance  int64          `json:"balance,omitempty"`  // 变动后的货币余额
+	Overflow map[string]int `json:"overflow,omitempty"` // 背包放不下而丢弃的物品
+}
+
+// MsgPlayerChargeResult 代扣结果，Insufficient 为 true 表示货币不足，Duplicate 为 true 表示该请求已处理过
+type MsgPlayerChargeResult struct {
+	Success      bool   `json:"success"`
+	Message      string `json:"message"`
+	Balance      int64  `json:"balance"`
+	Insufficient bool   `json:"insufficient,omitempty"`
+	Duplicate    bool   `json:"duplicate,omitempty"`
+}
+
+// MsgCharacterResult 角色管理操作结果（列表、创建、删除、恢复、选择）
+// 选择角色成功时 Token 为绑定到该角色的新令牌
+type MsgCharacterResult struct {
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Characters []CharacterInfo `json:"characters"`
+	Character  *CharacterInfo  `json:"character,omitempty"`
+	MaxSlots   int             `json:"maxSlots"`
+	Token      string          `json:"token,omitempty"`
+	Names      []NameChange    `json:"names,omitempty"` // 改名记录，仅查询改名记录时返回
+}
+
+// MsgDataRequestResult 数据导出/删除请求操作结果
+// 查询已完成的导出请求时 Archive 为完整的 JSON 档案
+type MsgDataRequestResult struct {
+	Success  bool              `json:"success"`
+	Message  string            `json:"message"`
+	Request  *DataRequestInfo  `json:"request,omitempty"`
+	Requests []DataRequestInfo `json:"requests,omitempty"`
+	Archive  json.RawMessage   `json:"archive,omitempty"`
+}
+
+// MsgSessionResult 登录会话管理操作结果
+type MsgSessionResult struct {
+	Success  bool          `json:"success"`
+	Message  string        `json:"message"`
+	Sessions []SessionInfo `json:"sessions,omitempty"`
+	Revoked  int           `json:"revoked,omitempty"` // 本次撤销的会话数
+}
+
+// MsgSessionRevoked 会话撤销通知，Gateway 关闭这些会话的 WebSocket 连接
+type MsgSessionRevoked struct {
+	SessionIDs []string `json:"session_ids"`
+	Reason     string   `json:"reason"`
+}
+
+// MsgAPIKeyResult API密钥操作结果，Key 为明文密钥，只在签发时返回一次
+// 校验密钥时 Remaining 为当前限流窗口内的剩余请求数
+type MsgAPIKeyResult struct {
+	Success   bool         `json:"success"`
+	Message   string       `json:"message"`
+	Key       string       `json:"key,omitempty"`
+	APIKey    *APIKeyInfo  `json:"api_key,omitempty"`
+	APIKeys   []APIKeyInfo `json:"api_keys,omitempty"`
+	Remaining int          `json:"remaining,omitempty"`
 }
 
 // MsgSaveUser 保存用户数据
@@ -174,6 +285,38 @@ type CLogin struct {
 	Token string `json:"token"`
 }
 
+// CStartSeq 开始修炼序列，已有进行中的序列时切换为新序列
+type CStartSeq struct {
+	Type       string `json:"type"`
+	SequenceID string `json:"sequence_id"`
+}
+
+// CStopSeq 停止当前修炼序列
+type CStopSeq struct {
+	Type string `json:"type"`
+}
+
+// CInventoryAction 背包操作（使用、丢弃、整理），整理时忽略格子和数量
+type CInventoryAction struct {
+	Type  string `json:"type"`
+	Slot  int    `json:"slot"`
+	Count int    `json:"count"` // 为 0 时按 1 个（丢弃时按整格）处理
+}
+
+// CEquipAction 穿戴（按背包格子）或卸下（按装备部位）装备
+type CEquipAction struct {
+	Type      string `json:"type"`
+	Slot      int    `json:"slot"`       // C_Equip：背包格子
+	EquipSlot string `json:"equip_slot"` // C_Unequip：装备部位
+}
+
+// CGameAction 游戏动作，参数由 Game 服务按动作校验
+type CGameAction struct {
+	Type   string                 `json:"type"`
+	Action string                 `json:"action"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
 // ============ 服务端消息类型 ============
 
 // 服务端消息基类
@@ -205,6 +348,287 @@ type S_Pong struct {
 	Type string `json:"type"`
 }
 
+// S_SeqResult 修炼序列状态与本次结算产出
+type S_SeqResult struct {
+	Type        string         `json:"type"`
+	SequenceID  string         `json:"sequence_id"`
+	Running     bool           `json:"running"`
+	Level       int            `json:"level"`        // 序列等级
+	Exp         int64          `json:"exp"`          // 当前等级的序列经验
+	ExpToNext   int64          `json:"exp_to_next"`  // 升级所需序列经验
+	Interval    float64        `json:"interval"`     // 每轮耗时（秒）
+	Rounds      int            `json:"rounds"`       // 本次结算完成的轮数
+	ExpGained   int64          `json:"exp_gained"`   // 本次获得的角色经验
+	PlayerExp   int64          `json:"player_exp"`   // 角色当前等级的经验
+	PlayerLevel int            `json:"player_level"` // 角色等级
+	Items       map[string]int `json:"items,omitempty"`
+	LeveledUp   bool           `json:"leveled_up,omitempty"`
+}
+
+// S_OfflineReport 离线收益报告，上线结算完成后推送
+type S_OfflineReport struct {
+	Type           string         `json:"type"`
+	OfflineSeconds int64          `json:"offline_seconds"` // 实际离线时长
+	SettledSeconds int64          `json:"settled_seconds"` // 参与结算的时长，不超过离线收益上限
+	SequenceID     string         `json:"sequence_id"`
+	Rounds         int            `json:"rounds"`
+	ExpGained      int64          `json:"exp_gained"`
+	PlayerExp      int64          `json:"player_exp"`
+	PlayerLevel    int            `json:"player_level"`
+	Items          map[string]int `json:"items,omitempty"`
+	LevelsGained   int            `json:"levels_gained"`      // 序列提升的等级数
+	Level          int            `json:"level"`              // 结算后的序列等级
+	Overflow       map[string]int `json:"overflow,omitempty"` // 背包已满而丢弃的物品
+}
+
+// S_InventoryUpdate 背包增量更新，Full 为 true 时 Slots 为完整背包
+type S_InventoryUpdate struct {
+	Type     string          `json:"type"`
+	Reason   string          `json:"reason"` // 变化来源：sequence、offline、use、discard、sort、grant
+	Capacity int             `json:"capacity"`
+	Full     bool            `json:"full,omitempty"`
+	Slots    []InventorySlot `json:"slots"`
+	Overflow map[string]int  `json:"overflow,omitempty"` // 背包已满而丢弃的物品
+}
+
+// S_EquipmentUpdate 装备变化后的完整装备与重新计算的派生属性
+type S_EquipmentUpdate struct {
+	Type      string            `json:"type"`
+	Equipment map[string]string `json:"equipment"` // 部位 -> 物品ID
+	Realm     string            `json:"realm"`
+	Stats     Stats             `json:"stats"`
+}
+
+// S_LevelUp 角色升级，由经验累积到等级曲线要求时服务端推送
+type S_LevelUp struct {
+	Type          string `json:"type"`
+	PreviousLevel int    `json:"previous_level"`
+	Level         int    `json:"level"`
+	Exp           int64  `json:"exp"`         // 当前等级的经验
+	ExpToNext     int64  `json:"exp_to_next"` // 已到最高等级时为 0
+	Realm         string `json:"realm"`
+	RealmChanged  bool   `json:"realm_changed,omitempty"`
+	Source        string `json:"source"` // 经验来源
+}
+
+// S_AchievementUnlocked 成就解锁，奖励已发放
+type S_AchievementUnlocked struct {
+	Type          string       `json:"type"`
+	AchievementID string       `json:"achievement_id"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description,omitempty"`
+	Rewards       RewardBundle `json:"rewards"`
+	UnlockedAt    int64        `json:"unlocked_at"`
+}
+
+// QuestStatus 任务在当前周期的状态
+type QuestStatus struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Period      string       `json:"period"`
+	Status      string       `json:"status"`
+	Progress    int64        `json:"progress"`
+	Target      int64        `json:"target"`
+	Tracked     bool         `json:"tracked,omitempty"`
+	MinLevel    int          `json:"min_level,omitempty"`
+	Rewards     RewardBundle `json:"rewards"`
+	ResetsAt    int64        `json:"resets_at"` // 本周期结束（下次重置）的时间
+}
+
+// S_QuestList 当前周期的全部任务
+type S_QuestList struct {
+	Type          string        `json:"type"`
+	Quests        []QuestStatus `json:"quests"`
+	DailyResetAt  int64         `json:"daily_reset_at"`
+	WeeklyResetAt int64         `json:"weekly_reset_at"`
+}
+
+// S_QuestUpdate 单个任务状态变化（接取、进度、完成、领取）
+type S_QuestUpdate struct {
+	Type  string      `json:"type"`
+	Quest QuestStatus `json:"quest"`
+}
+
+// CraftingJobStatus 制作队列中的一个任务
+type CraftingJobStatus struct {
+	JobID      int64   `json:"job_id"`
+	RecipeID   string  `json:"recipe_id"`
+	Count      int     `json:"count"`       // 制作次数
+	Done       int     `json:"done"`        // 已完成次数
+	Progress   float64 `json:"progress"`    // 当前这次已进行的秒数
+	Duration   float64 `json:"duration"`    // 单次耗时（秒）
+	FinishesAt int64   `json:"finishes_at"` // 预计全部完成的时间，排队中的任务按前序任务顺延
+}
+
+// CraftingSkillStatus 生产技能等级
+type CraftingSkillStatus struct {
+	Level     int   `json:"level"`
+	Exp       int64 `json:"exp"`
+	ExpToNext int64 `json:"exp_to_next"` // 已到最高等级时为 0
+}
+
+// S_CraftingUpdate 制作队列与技能的完整状态，Results 为本次推送前完成的制作
+type S_CraftingUpdate struct {
+	Type    string                         `json:"type"`
+	Reason  string                         `json:"reason"` // start、cancel、progress、offline、status
+	Queue   []CraftingJobStatus            `json:"queue"`
+	Skills  map[string]CraftingSkillStatus `json:"skills"`
+	Results *CraftingResults               `json:"results,omitempty"`
+}
+
+// CraftingResults 一段时间内完成的制作汇总
+type CraftingResults struct {
+	Succeeded    map[string]int   `json:"succeeded,omitempty"`     // 配方ID -> 成功次数
+	Failed       map[string]int   `json:"failed,omitempty"`        // 配方ID -> 失败次数
+	Items        map[string]int   `json:"items,omitempty"`         // 获得的成品与副产物
+	Refunded     map[string]int   `json:"refunded,omitempty"`      // 取消或配方下架时退还的材料
+	RefundedCost map[string]int64 `json:"refunded_cost,omitempty"` // 取消或配方下架时退还的货币
+	Mailed       bool             `json:"mailed,omitempty"`        // 背包放不下的物品已通过邮件送达
+	SkillUps     map[string]int   `json:"skill_ups,omitempty"`     // 技能 -> 新等级
+}
+
+// StaminaStatus 回复资源的当前值与回复计时，客户端据此显示倒计时
+type StaminaStatus struct {
+	Current      int64 `json:"current"`
+	Max          int64 `json:"max"` // 自然回复上限
+	Cap          int64 `json:"cap"` // 持有上限
+	RegenAmount  int64 `json:"regen_amount"`
+	RegenSeconds int64 `json:"regen_seconds"`
+	NextRegenAt  int64 `json:"next_regen_at,omitempty"` // 下次回复的时间，已达上限时为 0
+	FullAt       int64 `json:"full_at,omitempty"`       // 回复到 Max 的时间，已达上限时为 0
+}
+
+// S_StaminaUpdate 回复资源变化（消耗、补充）后的状态
+type S_StaminaUpdate struct {
+	Type    string                   `json:"type"`
+	Stamina map[string]StaminaStatus `json:"stamina"`
+}
+
+// ShopOfferStatus 上架商品及玩家本周期的购买次数
+type ShopOfferStatus struct {
+	OfferID   string           `json:"offer_id"`
+	ItemID    string           `json:"item_id"`
+	Count     int              `json:"count"`
+	Price     map[string]int64 `json:"price"`
+	Limit     int              `json:"limit,omitempty"`
+	Purchased int              `json:"purchased,omitempty"` // 本周期已购买次数
+	MinLevel  int              `json:"min_level,omitempty"`
+}
+
+// ShopStatus 商店当前周期的商品
+type ShopStatus struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Offers   []ShopOfferStatus `json:"offers"`
+	ResetsAt int64             `json:"resets_at"` // 下次轮换或限购重置的时间
+}
+
+// S_ShopList 商店列表
+type S_ShopList struct {
+	Type  string       `json:"type"`
+	Shops []ShopStatus `json:"shops"`
+}
+
+// S_PurchaseResult 购买结果，Duplicate 表示同一请求ID已处理过，本次未重复扣费
+type S_PurchaseResult struct {
+	Type      string           `json:"type"`
+	Purchase  PurchaseRecord   `json:"purchase"`
+	Balances  map[string]int64 `json:"balances"`
+	Overflow  map[string]int   `json:"overflow,omitempty"`
+	Duplicate bool             `json:"duplicate,omitempty"`
+}
+
+// S_PurchaseHistory 最近的购买记录，按时间从新到旧
+type S_PurchaseHistory struct {
+	Type      string           `json:"type"`
+	Purchases []PurchaseRecord `json:"purchases"`
+}
+
+// S_MarketListings 市场搜索结果或自己的挂单
+type S_MarketListings struct {
+	Type     string          `json:"type"`
+	Listings []MarketListing `json:"listings"`
+	Total    int64           `json:"total"`
+}
+
+// S_MarketUpdate 市场操作的结果，Status 为 completed、pending（等待确认，稍后重试）或 rejected（托管已退还）
+type S_MarketUpdate struct {
+	Type    string         `json:"type"`
+	Action  string         `json:"action"`
+	OpID    string         `json:"op_id,omitempty"`
+	Status  string         `json:"status"`
+	Listing *MarketListing `json:"listing,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// S_SectInfo 宗门概况、成员与自己的职位
+type S_SectInfo struct {
+	Type       string          `json:"type"`
+	Sect       *Sect           `json:"sect,omitempty"`
+	Members    []SectMember    `json:"members,omitempty"`
+	Membership *SectMembership `json:"membership,omitempty"`
+}
+
+// S_SectList 宗门列表
+type S_SectList struct {
+	Type  string `json:"type"`
+	Sects []Sect `json:"sects"`
+}
+
+// S_SectApplications 等待审核的入门申请
+type S_SectApplications struct {
+	Type         string            `json:"type"`
+	Applications []SectApplication `json:"applications"`
+}
+
+// S_SectUpdate 宗门操作的结果，Status 为 completed、pending（等待确认，稍后重试）或 rejected（托管已退还）
+type S_SectUpdate struct {
+	Type       string          `json:"type"`
+	Action     string          `json:"action"`
+	OpID       string          `json:"op_id,omitempty"`
+	Status     string          `json:"status"`
+	Sect       *Sect           `json:"sect,omitempty"`
+	Membership *SectMembership `json:"membership,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// S_SectEvent 推送给在线宗门成员（及被审核、逐出的玩家）的宗门事件
+type S_SectEvent struct {
+	Type      string `json:"type"`
+	SectID    string `json:"sect_id"`
+	SectName  string `json:"sect_name"`
+	Event     string `json:"event"`
+	ActorID   string `json:"actor_id"`
+	TargetID  string `json:"target_id,omitempty"`
+	Rank      string `json:"rank,omitempty"`
+	Level     int    `json:"level"`
+	Amount    int64  `json:"amount,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// S_MailList 玩家未过期的邮件
+type S_MailList struct {
+	Type  string `json:"type"`
+	Mails []Mail `json:"mails"`
+}
+
+// S_MailReceived 收到新邮件
+type S_MailReceived struct {
+	Type string `json:"type"`
+	Mail Mail   `json:"mail"`
+}
+
+// AchievementStatus 成就进度，供状态查询
+type AchievementStatus struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Progress   int64  `json:"progress"`
+	Target     int64  `json:"target"`
+	UnlockedAt int64  `json:"unlocked_at,omitempty"`
+}
+
 // S_PlayerData 玩家数据
 type S_PlayerData struct {
 	Type     string      `json:"type"`
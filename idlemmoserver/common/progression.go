@@ -0,0 +1,82 @@
+package common
+
+// ============ 角色成长 ============
+// 角色经验与货币只能由服务端的游戏系统发放：经验按来源记录，累积到等级曲线（见 content.go）
+// 要求的数值时自动升级并触发升级事件；货币变动必须来自登记的来源，客户端无法直接设置
+
+// 经验来源
+const (
+	ExpSourceSequence    = "sequence"    // 修炼序列（含离线结算）
+	ExpSourceItem        = "item_use"    // 使用消耗品
+	ExpSourceAdmin       = "admin"       // 管理员补偿
+	ExpSourceAchievement = "achievement" // 成就奖励
+	ExpSourceQuest       = "quest"       // 任务奖励
+	ExpSourceMail        = "mail"        // 邮件附件
+)
+
+// ExpSources 允许发放角色经验的来源
+var ExpSources = map[string]bool{
+	ExpSourceSequence:    true,
+	ExpSourceItem:        true,
+	ExpSourceAdmin:       true,
+	ExpSourceAchievement: true,
+	ExpSourceQuest:       true,
+	ExpSourceMail:        true,
+}
+
+// 货币变动来源
+const (
+	CurrencySourceAdmin       = "admin"       // 管理员补偿或扣除
+	CurrencySourceAchievement = "achievement" // 成就奖励
+	CurrencySourceQuest       = "quest"       // 任务奖励
+	CurrencySourceMail        = "mail"        // 邮件附件
+	CurrencySourceItem        = "item_use"    // 使用消耗品（补充体力等）
+	CurrencySourceCrafting    = "crafting"    // 制作消耗与取消退还
+	CurrencySourceShop        = "shop"        // 商店购买
+	CurrencySourceMarket      = "market"      // 市场购买与出价托管，被拒绝时退还
+	CurrencySourceSect        = "sect"        // 创建宗门、捐献与捐献获得的贡献
+	CurrencySourceRename      = "rename"      // 修改显示名称的费用，改名失败时退还
+)
+
+// CurrencySources 允许变动货币的来源，货币种类以配置表 defaults.resources 为准
+var CurrencySources = map[string]bool{
+	CurrencySourceAdmin:       true,
+	CurrencySourceAchievement: true,
+	CurrencySourceQuest:       true,
+	CurrencySourceMail:        true,
+	CurrencySourceItem:        true,
+	CurrencySourceCrafting:    true,
+	CurrencySourceShop:        true,
+	CurrencySourceMarket:      true,
+	CurrencySourceSect:        true,
+	CurrencySourceRename:      true,
+}
+
+// 游戏事件类型，在玩家 Actor 内同步分发
+const (
+	GameEventLevelUp = "level_up" // 角色升级，Value 为新等级，Count 为提升的等级数
+	GameEventRealmUp = "realm_up" // 突破境界，Key 为新境界ID，Value 为境界序号
+
+	GameEventItemGathered    = "item_gathered"     // 修炼序列产出物品，Key 为物品ID，Count 为数量
+	GameEventSequenceLevelUp = "sequence_level_up" // 修炼序列升级，Key 为序列ID，Value 为新等级
+	GameEventLogin           = "login"             // 当天首次登录，Value 为连续登录天数
+	GameEventSequenceRounds  = "sequence_rounds"   // 完成修炼序列轮次，Key 为序列ID，Count 为轮数
+	GameEventItemCrafted     = "item_crafted"      // 制作成功获得成品，Key 为物品ID，Source 为配方ID，Count 为数量
+	GameEventItemUsed        = "item_used"         // 使用消耗品，Key 为物品ID，Count 为数量
+)
+
+// GameEvent 游戏事件
+type GameEvent struct {
+	Type   string `json:"type"`
+	Source string `json:"source,omitempty"` // 触发来源，如经验来源
+	Key    string `json:"key,omitempty"`
+	Value  int64  `json:"value,omitempty"` // 变化后的数值
+	Count  int64  `json:"count,omitempty"` // 本次变化量
+}
+
+// RewardBundle 配置表中的一组奖励（成就、任务等），经验与货币按对应来源发放，物品放入背包
+type RewardBundle struct {
+	Exp       int64            `json:"exp,omitempty"`
+	Resources map[string]int64 `json:"resources,omitempty"`
+	Items     map[string]int   `json:"items,omitempty"`
+}
@@ -0,0 +1,168 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // 内置时区数据，部署环境没有 zoneinfo 时也能解析重置时区
+)
+
+// ============ 每日/每周任务 ============
+// 任务定义来自配置表 quests，目标由游戏事件推进。任务状态按周期记录，周期以服务器日重置时间
+// （配置表 schedule，含时区）划分：记录所在周期与当前周期不同时视为已重置，无需定时批量清理
+
+// 任务周期
+const (
+	QuestPeriodDaily  = "daily"
+	QuestPeriodWeekly = "weekly"
+)
+
+// 任务目标类型，Key 为空时不限物品或序列
+const (
+	QuestObjectiveItemGathered   = "item_gathered"   // 修炼序列获得物品 Key 共 Target 个
+	QuestObjectiveSequenceRounds = "sequence_rounds" // 完成修炼序列 Key 共 Target 轮
+	QuestObjectiveItemUsed       = "item_used"       // 使用消耗品 Key 共 Target 个
+	QuestObjectiveItemCrafted    = "item_crafted"    // 制作成功获得物品 Key 共 Target 个
+)
+
+// 任务状态
+const (
+	QuestStatusAvailable = "available" // 本周期可接取
+	QuestStatusAccepted  = "accepted"  // 已接取，目标进行中
+	QuestStatusCompleted = "completed" // 目标已完成，待领取奖励
+	QuestStatusClaimed   = "claimed"   // 本周期奖励已领取
+)
+
+// QuestObjective 任务目标
+type QuestObjective struct {
+	Type   string `json:"type"`
+	Key    string `json:"key,omitempty"`
+	Target int64  `json:"target"`
+}
+
+// QuestDef 任务定义
+type QuestDef struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Period      string         `json:"period"`
+	MinLevel    int            `json:"min_level,omitempty"` // 接取所需的角色等级
+	Objective   QuestObjective `json:"objective"`
+	Rewards     RewardBundle   `json:"rewards"`
+}
+
+// ResetSchedule 服务器日重置时间：每天在 ResetTime 重置每日任务，每周在 WeeklyResetDay 的同一时间重置每周任务
+type ResetSchedule struct {
+	ResetTime      string `json:"reset_time"`       // HH:MM，按 Timezone 的本地时间
+	Timezone       string `json:"timezone"`         // IANA 时区，如 Asia/Shanghai，为空时使用 UTC
+	WeeklyResetDay string `json:"weekly_reset_day"` // 每周重置日，如 monday
+
+	location *time.Location
+	hour     int
+	minute   int
+	weekday  time.Weekday
+}
+
+// parse 解析重置时间与时区
+func (r *ResetSchedule) parse() error {
+	r.location = time.UTC
+	if r.Timezone != "" {
+		location, err := time.LoadLocation(r.Timezone)
+		if err != nil {
+			return fmt.Errorf("unknown timezone %q", r.Timezone)
+		}
+		r.location = location
+	}
+
+	if r.ResetTime != "" {
+		at, err := time.Parse("15:04", r.ResetTime)
+		if err != nil {
+			return fmt.Errorf("invalid reset_time %q, expected HH:MM", r.ResetTime)
+		}
+		r.hour, r.minute = at.Hour(), at.Minute()
+	}
+
+	r.weekday = time.Monday
+	if r.WeeklyResetDay != "" {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(day.String(), r.WeeklyResetDay) {
+				r.weekday, found = day, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid weekly_reset_day %q", r.WeeklyResetDay)
+		}
+	}
+	return nil
+}
+
+// DayStart now 所在服务器日的开始时间（最近一次每日重置）
+func (r *ResetSchedule) DayStart(now time.Time) time.Time {
+	location := r.location
+	if location == nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	start := time.Date(local.Year(), local.Month(), local.Day(), r.hour, r.minute, 0, 0, location)
+	if local.Before(start) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// WeekStart now 所在服务器周的开始时间（最近一次每周重置）
+func (r *ResetSchedule) WeekStart(now time.Time) time.Time {
+	start := r.DayStart(now)
+	days := (int(start.Weekday()) - int(r.weekday) + 7) % 7
+	return start.AddDate(0, 0, -days)
+}
+
+// Period 任务周期的开始与下次重置时间，周期以开始日期（服务器时区）作为键
+func (r *ResetSchedule) Period(period string, now time.Time) (string, time.Time) {
+	if period == QuestPeriodWeekly {
+		start := r.WeekStart(now)
+		return start.Format(time.DateOnly), start.AddDate(0, 0, 7)
+	}
+	start := r.DayStart(now)
+	return start.Format(time.DateOnly), start.AddDate(0, 0, 1)
+}
+
+// validateQuest 校验任务周期、目标与奖励的引用
+func (c *Content) validateQuest(def QuestDef) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s: %s", ContentTableQuests, def.ID, fmt.Sprintf(format, args...)))
+	}
+
+	if def.Period != QuestPeriodDaily && def.Period != QuestPeriodWeekly {
+		fail("unknown period %q", def.Period)
+	}
+	if def.MinLevel > 0 && !c.hasLevel(def.MinLevel) {
+		fail("min_level %d missing from level curve", def.MinLevel)
+	}
+
+	objective := def.Objective
+	switch objective.Type {
+	case QuestObjectiveItemGathered, QuestObjectiveItemUsed, QuestObjectiveItemCrafted:
+		if objective.Key != "" {
+			if _, ok := c.Items[objective.Key]; !ok {
+				fail("unknown item %q", objective.Key)
+			}
+		}
+	case QuestObjectiveSequenceRounds:
+		if objective.Key != "" {
+			if _, ok := c.Sequences[objective.Key]; !ok {
+				fail("unknown sequence %q", objective.Key)
+			}
+		}
+	default:
+		fail("unknown objective type %q", objective.Type)
+	}
+	if objective.Target <= 0 {
+		fail("target must be positive")
+	}
+
+	errs = append(errs, c.validateRewards(ContentTableQuests, def.ID, def.Rewards)...)
+	return errs
+}
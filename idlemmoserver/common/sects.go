@@ -0,0 +1,201 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ============ 宗门 ============
+// 宗门、成员、入门申请和宝库流水保存在 Persist 的 sects、sect_members、sect_applications、sect_treasury_logs 表中，
+// 每个操作在单个事务内校验职位权限并修改；配置表 sect_levels 定义各等级的人数上限、升级条件和全体成员共享的增益。
+// 成员向宝库捐献金币获得贡献（contribution 货币）并增加宗门经验，经验与宝库金币满足条件后由宗主或长老升级宗门
+
+// ErrSectRejected 宗门操作被拒绝（权限不足、人数已满、名称已被使用等），调用方应退还托管的货币
+var ErrSectRejected = errors.New("sect request rejected")
+
+// 宗门职位，按权限从高到低
+const (
+	SectRankLeader   = "leader"   // 宗主
+	SectRankElder    = "elder"    // 长老
+	SectRankDisciple = "disciple" // 弟子
+)
+
+// 宗门权限
+const (
+	SectPermReview   = "review"   // 审核入门申请
+	SectPermKick     = "kick"     // 逐出职位更低的成员
+	SectPermSetRank  = "set_rank" // 任免长老
+	SectPermTransfer = "transfer" // 传位
+	SectPermDisband  = "disband"  // 解散宗门
+	SectPermWithdraw = "withdraw" // 从宝库拨发金币
+	SectPermUpgrade  = "upgrade"  // 升级宗门
+	SectPermNotice   = "notice"   // 修改宗门公告
+)
+
+// sectRankOrder 职位高低，数值越大职位越高
+var sectRankOrder = map[string]int{
+	SectRankDisciple: 1,
+	SectRankElder:    2,
+	SectRankLeader:   3,
+}
+
+// sectRankPermissions 各职位拥有的权限
+var sectRankPermissions = map[string]map[string]bool{
+	SectRankLeader: {
+		SectPermReview: true, SectPermKick: true, SectPermSetRank: true, SectPermTransfer: true,
+		SectPermDisband: true, SectPermWithdraw: true, SectPermUpgrade: true, SectPermNotice: true,
+	},
+	SectRankElder: {
+		SectPermReview: true, SectPermKick: true, SectPermUpgrade: true, SectPermNotice: true,
+	},
+	SectRankDisciple: {},
+}
+
+// SectRankHas 职位是否拥有权限
+func SectRankHas(rank, permission string) bool {
+	return sectRankPermissions[rank][permission]
+}
+
+// SectRankOutranks 职位 rank 是否高于 other
+func SectRankOutranks(rank, other string) bool {
+	return sectRankOrder[rank] > sectRankOrder[other]
+}
+
+// 宗门事件
+const (
+	SectEventCreated           = "created"
+	SectEventApplied           = "applied"
+	SectEventRejected          = "application_rejected"
+	SectEventJoined            = "joined"
+	SectEventLeft              = "left"
+	SectEventKicked            = "kicked"
+	SectEventRankChanged       = "rank_changed"
+	SectEventLeaderTransferred = "leader_transferred"
+	SectEventDisbanded         = "disbanded"
+	SectEventDonated           = "donated"
+	SectEventWithdrawn         = "withdrawn"
+	SectEventLevelUp           = "level_up"
+	SectEventNotice            = "notice"
+)
+
+// SectLevelDef 宗门等级定义，Exp 与 Cost 为从上一级升到该等级的条件（1 级为 0）
+type SectLevelDef struct {
+	Level          int     `json:"level"`
+	Exp            int64   `json:"exp"`             // 升到该等级所需的宗门累计经验
+	Cost           int64   `json:"cost"`            // 升到该等级消耗的宝库金币
+	MaxMembers     int     `json:"max_members"`     // 成员人数上限（含宗主）
+	MaxElders      int     `json:"max_elders"`      // 长老人数上限
+	ExpMultiplier  float64 `json:"exp_multiplier"`  // 成员修炼经验倍率，0 表示不影响
+	DropMultiplier float64 `json:"drop_multiplier"` // 成员修炼掉落概率倍率，0 表示不影响
+}
+
+// GetSectLevel 从当前生效的配置表获取宗门等级定义
+func GetSectLevel(level int) (SectLevelDef, bool) {
+	def, ok := CurrentContent().SectLevels[level]
+	return def, ok
+}
+
+// Sect 宗门概况
+type Sect struct {
+	SectID      string `json:"sect_id"`
+	Name        string `json:"name"`
+	LeaderID    string `json:"leader_id"`
+	Level       int    `json:"level"`
+	Exp         int64  `json:"exp"`
+	Treasury    int64  `json:"treasury"` // 宝库金币
+	Notice      string `json:"notice,omitempty"`
+	MemberCount int    `json:"member_count"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// SectMember 宗门成员，Contribution 为累计捐献获得的贡献
+type SectMember struct {
+	PlayerID     string `json:"player_id"`
+	Name         string `json:"name,omitempty"`
+	Rank         string `json:"rank"`
+	Contribution int64  `json:"contribution"`
+	JoinedAt     int64  `json:"joined_at"`
+}
+
+// SectApplication 入门申请
+type SectApplication struct {
+	SectID    string `json:"sect_id"`
+	PlayerID  string `json:"player_id"`
+	Name      string `json:"name,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// SectMembership 玩家所在的宗门与职位
+type SectMembership struct {
+	SectID   string `json:"sect_id"`
+	SectName string `json:"sect_name"`
+	Rank     string `json:"rank"`
+	Level    int    `json:"level"`
+}
+
+// SectRequest Game 向 Persist 发起的宗门操作，Level 为 Game 所见的宗门等级定义（人数上限等），
+// 与宗门当前等级不一致时操作被拒绝
+type SectRequest struct {
+	OpID     string        `json:"op_id,omitempty"` // 创建宗门时为宗门ID，捐献与拨发时为流水ID，重复提交时返回原结果
+	PlayerID string        `json:"player_id"`
+	SectID   string        `json:"sect_id,omitempty"`
+	TargetID string        `json:"target_id,omitempty"`
+	Name     string        `json:"name,omitempty"`
+	Notice   string        `json:"notice,omitempty"`
+	Rank     string        `json:"rank,omitempty"`
+	Accept   bool          `json:"accept,omitempty"`
+	Amount   int64         `json:"amount,omitempty"`
+	Level    *SectLevelDef `json:"level,omitempty"`
+	Next     *SectLevelDef `json:"next,omitempty"` // 升级时的下一等级
+}
+
+// SectResult 宗门操作的结果：操作后的宗门概况与成员（宗门已解散时 Sect 为空），用于推送宗门事件
+type SectResult struct {
+	Sect    *Sect        `json:"sect,omitempty"`
+	Members []SectMember `json:"members,omitempty"`
+}
+
+// validateSectLevels 校验宗门等级从 1 开始连续、升级条件递增
+func (c *Content) validateSectLevels() []error {
+	var errs []error
+	fail := func(level int, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: level %d: %s", ContentTableSectLevels, level, fmt.Sprintf(format, args...)))
+	}
+
+	if _, ok := c.SectLevels[1]; !ok {
+		errs = append(errs, fmt.Errorf("%s: missing level 1", ContentTableSectLevels))
+	}
+	levels := make([]int, 0, len(c.SectLevels))
+	for level := range c.SectLevels {
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+
+	var prev *SectLevelDef
+	for i, level := range levels {
+		def := c.SectLevels[level]
+		if level != i+1 {
+			fail(level, "levels must be consecutive from 1")
+		}
+		if def.MaxMembers < 1 || def.MaxElders < 0 || def.MaxElders >= def.MaxMembers {
+			fail(level, "invalid member limits %d/%d", def.MaxMembers, def.MaxElders)
+		}
+		if def.ExpMultiplier < 0 || def.DropMultiplier < 0 {
+			fail(level, "negative multiplier")
+		}
+		if level == 1 && (def.Exp != 0 || def.Cost != 0) {
+			fail(level, "level 1 must not require exp or cost")
+		}
+		if prev != nil {
+			if def.Exp <= prev.Exp || def.Cost < 0 {
+				fail(level, "exp must increase and cost must not be negative")
+			}
+			if def.MaxMembers < prev.MaxMembers {
+				fail(level, "max_members must not decrease")
+			}
+		}
+		prev = &def
+	}
+	return errs
+}
@@ -0,0 +1,58 @@
+package common
+
+import "math"
+
+// ============ 修炼序列 ============
+// 玩家同一时间只能进行一个序列（打坐、挖矿、采药等），每个 Tick 累积进度，
+// 每完成一轮产出经验和物品；序列有独立的等级，等级越高每轮耗时越短。序列配置由 ContentDir/sequences 加载
+
+// 序列ID
+const (
+	SequenceMeditation    = "meditation"     // 打坐修炼
+	SequenceMining        = "mining"         // 挖矿
+	SequenceHerbGathering = "herb_gathering" // 采药
+)
+
+// SequenceReward 序列每轮的物品产出
+type SequenceReward struct {
+	ItemID string  `json:"item_id"`
+	Chance float64 `json:"chance"` // 每轮掉落概率（0-1），受气运加成
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+}
+
+// SequenceConfig 序列配置
+type SequenceConfig struct {
+	ID           string           `json:"id"`
+	Name         string           `json:"name"`
+	Element      string           `json:"element,omitempty"` // 关联的五行灵根，为空表示按修炼速度倍率结算
+	BaseInterval float64          `json:"base_interval"`     // 1级时每轮耗时（秒）
+	BaseExp      int64            `json:"base_exp"`          // 每轮产出的角色经验
+	SequenceExp  int64            `json:"sequence_exp"`      // 每轮产出的序列经验
+	Rewards      []SequenceReward `json:"rewards"`
+}
+
+// GetSequenceConfig 从当前生效的配置表获取序列配置（配置表见 content.go）
+func GetSequenceConfig(sequenceID string) (SequenceConfig, bool) {
+	config, ok := CurrentContent().Sequences[sequenceID]
+	return config, ok
+}
+
+// Interval 指定序列等级下每轮耗时，每级缩短一定比例，不低于下限
+func (c SequenceConfig) Interval(level int) float64 {
+	interval := c.BaseInterval * math.Pow(1-SequenceLevelSpeedBonus, float64(level-1))
+	return math.Max(interval, SequenceMinInterval)
+}
+
+// ExpMultiplier 资质对本序列经验产出的倍率：打坐按修炼速度，采集类按对应五行亲和
+func (c SequenceConfig) ExpMultiplier(aptitude *Aptitude) float64 {
+	if c.Element == "" {
+		return aptitude.CultivationMultiplier()
+	}
+	return 1 + aptitude.ElementAffinity(c.Element)
+}
+
+// SequenceExpToNext 序列从 level 升到下一级所需经验
+func SequenceExpToNext(level int) int64 {
+	return int64(float64(SequenceLevelExpBase) * math.Pow(SequenceLevelExpGrowth, float64(level-1)))
+}
@@ -0,0 +1,132 @@
+package common
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+)
+
+// ============ 商店 ============
+// 商店定义来自配置表 shops。固定商店始终出售全部商品；轮换商店每个周期从商品中确定性地选出
+// RotationSize 件（同一周期内所有玩家相同）；限购商店的每件商品每个周期限购 Limit 次。
+// 任何商店的商品都可以设置 Limit，周期按 schedule 的服务器日重置时间划分
+
+// 商店类型
+const (
+	ShopTypeFixed    = "fixed"
+	ShopTypeRotating = "rotating"
+	ShopTypeLimited  = "limited"
+)
+
+// ShopOffer 商品：花费 Price 购买 Count 个 ItemID
+type ShopOffer struct {
+	ID       string           `json:"id"`
+	ItemID   string           `json:"item_id"`
+	Count    int              `json:"count"`
+	Price    map[string]int64 `json:"price"`
+	Limit    int              `json:"limit,omitempty"`     // 每周期限购次数，0 表示不限
+	MinLevel int              `json:"min_level,omitempty"` // 购买所需的角色等级
+}
+
+// ShopDef 商店定义
+type ShopDef struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	Type         string      `json:"type"`
+	Period       string      `json:"period,omitempty"`        // 轮换与限购的周期（daily/weekly），为空时按每日
+	RotationSize int         `json:"rotation_size,omitempty"` // 轮换商店每个周期上架的商品数
+	Offers       []ShopOffer `json:"offers"`
+}
+
+// ResetPeriod 商店的轮换与限购周期
+func (d ShopDef) ResetPeriod() string {
+	if d.Period == "" {
+		return QuestPeriodDaily
+	}
+	return d.Period
+}
+
+// Offer 按ID查找商品
+func (d ShopDef) Offer(offerID string) (ShopOffer, bool) {
+	for _, offer := range d.Offers {
+		if offer.ID == offerID {
+			return offer, true
+		}
+	}
+	return ShopOffer{}, false
+}
+
+// ActiveOffers 周期 period 内上架的商品，轮换商店按商店ID与周期确定性地选取，保持配置表中的顺序
+func (d ShopDef) ActiveOffers(period string) []ShopOffer {
+	if d.Type != ShopTypeRotating || d.RotationSize >= len(d.Offers) {
+		return d.Offers
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(d.ID + "/" + period))
+	picked := rand.New(rand.NewSource(int64(hash.Sum64()))).Perm(len(d.Offers))[:d.RotationSize]
+	sort.Ints(picked)
+
+	offers := make([]ShopOffer, 0, len(picked))
+	for _, index := range picked {
+		offers = append(offers, d.Offers[index])
+	}
+	return offers
+}
+
+// validateShop 校验商店类型、周期与商品
+func (c *Content) validateShop(def ShopDef) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s: %s", ContentTableShops, def.ID, fmt.Sprintf(format, args...)))
+	}
+
+	switch def.Type {
+	case ShopTypeFixed, ShopTypeLimited:
+	case ShopTypeRotating:
+		if def.RotationSize <= 0 {
+			fail("rotation_size must be positive")
+		}
+	default:
+		fail("unknown type %q", def.Type)
+	}
+	if def.Period != "" && def.Period != QuestPeriodDaily && def.Period != QuestPeriodWeekly {
+		fail("unknown period %q", def.Period)
+	}
+	if len(def.Offers) == 0 {
+		fail("no offers")
+	}
+
+	seen := make(map[string]bool, len(def.Offers))
+	for _, offer := range def.Offers {
+		if offer.ID == "" || seen[offer.ID] {
+			fail("missing or duplicate offer id %q", offer.ID)
+		}
+		seen[offer.ID] = true
+		if _, ok := c.Items[offer.ItemID]; !ok {
+			fail("%s: unknown item %q", offer.ID, offer.ItemID)
+		}
+		if offer.Count <= 0 {
+			fail("%s: count must be positive", offer.ID)
+		}
+		if len(offer.Price) == 0 {
+			fail("%s: no price", offer.ID)
+		}
+		for resource, amount := range offer.Price {
+			if _, ok := c.Defaults.Resources[resource]; !ok {
+				fail("%s: unknown currency %q", offer.ID, resource)
+			}
+			if amount <= 0 {
+				fail("%s: %s price must be positive", offer.ID, resource)
+			}
+		}
+		if offer.Limit < 0 || (def.Type == ShopTypeLimited && offer.Limit == 0) {
+			fail("%s: limited shop offers need a positive limit", offer.ID)
+		}
+		if offer.MinLevel > 0 && !c.hasLevel(offer.MinLevel) {
+			fail("%s: min_level %d missing from level curve", offer.ID, offer.MinLevel)
+		}
+	}
+	return errs
+}
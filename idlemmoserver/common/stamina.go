@@ -0,0 +1,50 @@
+package common
+
+import "fmt"
+
+// ============ 体力等随时间回复的资源 ============
+// 配置表 stamina 中的资源（必须是 defaults.resources 中的货币）随时间自然回复，不依赖 Tick：
+// 玩家数据只记录数值和回复计时起点，读取或变动时按经过的时间惰性结算。
+// 自然回复到 Max 为止；道具补充和奖励可以超出 Max，但持有量不超过 Cap，超出的部分丢弃
+
+// StaminaDef 随时间回复的资源定义
+type StaminaDef struct {
+	ID           string `json:"id"` // 资源ID，与 defaults.resources 的键一致
+	Name         string `json:"name"`
+	Max          int64  `json:"max"`           // 自然回复上限
+	Cap          int64  `json:"cap"`           // 持有上限，道具补充可超出 Max 但不超过 Cap
+	RegenAmount  int64  `json:"regen_amount"`  // 每次回复的数量
+	RegenSeconds int64  `json:"regen_seconds"` // 回复间隔（秒）
+}
+
+// GetStaminaDef 从当前生效的配置表获取可回复资源定义
+func GetStaminaDef(resource string) (StaminaDef, bool) {
+	def, ok := CurrentContent().Stamina[resource]
+	return def, ok
+}
+
+// validateStamina 校验回复资源的上限与回复速度
+func (c *Content) validateStamina(def StaminaDef) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s: %s", ContentTableStamina, def.ID, fmt.Sprintf(format, args...)))
+	}
+
+	initial, ok := c.Defaults.Resources[def.ID]
+	if !ok {
+		fail("not a resource in %s", ContentTableDefaults)
+	}
+	if def.Max <= 0 {
+		fail("max must be positive")
+	}
+	if def.Cap < def.Max {
+		fail("cap must not be below max")
+	}
+	if int64(initial) > def.Cap {
+		fail("starting amount %d exceeds cap %d", initial, def.Cap)
+	}
+	if def.RegenAmount <= 0 || def.RegenSeconds <= 0 {
+		fail("regen_amount and regen_seconds must be positive")
+	}
+	return errs
+}
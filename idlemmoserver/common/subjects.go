@@ -8,6 +8,48 @@ const (
 	AuthGetUserSubject       = "auth.get_user"
 	AuthValidateTokenSubject = "auth.validate_token"
 
+	// ============ 双因素认证相关 ============
+	AuthTwoFactorVerifySubject  = "auth.2fa.verify"  // 登录第二步
+	AuthTwoFactorSetupSubject   = "auth.2fa.setup"   // 生成待绑定密钥
+	AuthTwoFactorEnableSubject  = "auth.2fa.enable"  // 确认绑定
+	AuthTwoFactorDisableSubject = "auth.2fa.disable" // 解除绑定
+	AuthTwoFactorRequireSubject = "auth.2fa.require" // GM/管理员设置强制2FA
+
+	// ============ 账号管理与审计相关 ============
+	AuthRefreshTokenSubject   = "auth.token.refresh"
+	AuthChangePasswordSubject = "auth.password.change"
+	AuthBanUserSubject        = "auth.ban"
+	AuthAuditEventsSubject    = "auth.audit.events"  // 客服查询账号认证事件
+	AuthAuditDevicesSubject   = "auth.audit.devices" // 客服查询账号设备/IP历史
+
+	// ============ 角色管理相关 ============
+	AuthCharacterListSubject    = "auth.character.list"
+	AuthCharacterCreateSubject  = "auth.character.create"
+	AuthCharacterDeleteSubject  = "auth.character.delete"  // 进入删除冷静期
+	AuthCharacterRestoreSubject = "auth.character.restore" // 冷静期内撤销删除
+	AuthCharacterSelectSubject  = "auth.character.select"  // 换取绑定到角色的令牌
+	AuthCharacterRenameSubject  = "auth.character.rename"  // 修改显示名称，有冷却时间并消耗金币
+	AuthCharacterNamesSubject   = "auth.character.names"   // 查询改名记录
+	AuthCharacterRerollSubject  = "auth.character.reroll"  // 重新随机灵根资质（次数有限）
+
+	// ============ 登录会话管理相关 ============
+	AuthSessionListSubject         = "auth.session.list"
+	AuthSessionRevokeSubject       = "auth.session.revoke"        // 撤销指定设备的会话
+	AuthSessionRevokeOthersSubject = "auth.session.revoke_others" // 撤销当前会话以外的全部会话
+
+	// ============ API密钥相关 ============
+	AuthAPIKeyCreateSubject   = "auth.apikey.create" // 管理员签发密钥
+	AuthAPIKeyListSubject     = "auth.apikey.list"
+	AuthAPIKeyRevokeSubject   = "auth.apikey.revoke"
+	AuthAPIKeyValidateSubject = "auth.apikey.validate" // Gateway 校验密钥、权限范围与限流
+
+	// ============ 数据导出与删除相关 ============
+	AuthDataExportSubject        = "auth.data.export"         // 申请导出账号数据
+	AuthDataErasureSubject       = "auth.data.erasure"        // 申请删除账号（进入冷静期）
+	AuthDataErasureCancelSubject = "auth.data.erasure.cancel" // 冷静期内撤销删除
+	AuthDataRequestStatusSubject = "auth.data.status"         // 查询请求状态与导出档案
+	PersistDataRequestSubject    = "persist.data_request"     // 通知 Persist 立即处理到期请求
+
 	// ============ OAuth服务相关 ============
 	OAuthAuthURLSubject  = "oauth.auth_url"
 	OAuthCallbackSubject = "oauth.callback"
@@ -24,6 +66,13 @@ const (
 	GamePlayerUnregisterSubject = "game.player.unregister"
 	GameStateSubject            = "game.state"
 	GameActionSubject           = "game.action"
+	GameSequenceSubject         = "game.sequence"        // 开始/停止修炼序列
+	GameInventorySubject        = "game.inventory"       // 背包使用、丢弃与整理
+	GameEquipmentSubject        = "game.equipment"       // 穿戴与卸下装备
+	GameContentReloadSubject    = "game.content.reload"  // 管理员热更新配置表
+	GameAdminGrantSubject       = "game.admin.grant"     // 管理员发放经验、货币或物品
+	GamePlayerChargeSubject     = "game.player.charge"   // 其他服务经由玩家 Actor 扣除或退还货币（改名费用等）
+	GamePlayerAptitudeSubject   = "game.player.aptitude" // Auth 重随资质后更新玩家 Actor 持有的资质
 
 	// ============ 持久化服务相关 ============
 	PersistSaveSubject       = "persist.save"
@@ -37,9 +86,50 @@ const (
 	PersistSavePlayerSubject = "persist.save_player"
 	PersistLoadPlayerSubject = "persist.load_player"
 
+	// 玩家进度（game_progress 表）
+	PersistLoadProgressSubject = "persist.progress.load"
+	PersistSaveProgressSubject = "persist.progress.save"
+
+	// 玩家邮件（mails 表）
+	PersistSendMailSubject  = "persist.mail.send"
+	PersistListMailSubject  = "persist.mail.list"
+	PersistClaimMailSubject = "persist.mail.claim"
+
+	// 商店购买记录（purchase_history 表）
+	PersistRecordPurchaseSubject  = "persist.purchase.record"
+	PersistPurchaseHistorySubject = "persist.purchase.history"
+	PersistFindPurchaseSubject    = "persist.purchase.find"
+
+	// 玩家市场（market_listings、market_bids、market_trades 表）
+	PersistMarketListSubject   = "persist.market.list"
+	PersistMarketBuySubject    = "persist.market.buy"
+	PersistMarketBidSubject    = "persist.market.bid"
+	PersistMarketCancelSubject = "persist.market.cancel"
+	PersistMarketSearchSubject = "persist.market.search"
+	PersistMarketMineSubject   = "persist.market.mine"
+
+	// 宗门（sects、sect_members、sect_applications、sect_treasury_logs 表）
+	PersistSectCreateSubject       = "persist.sect.create"
+	PersistSectApplySubject        = "persist.sect.apply"
+	PersistSectReviewSubject       = "persist.sect.review"
+	PersistSectLeaveSubject        = "persist.sect.leave"
+	PersistSectManageSubject       = "persist.sect.manage" // 逐出、任免、传位、解散、公告
+	PersistSectDonateSubject       = "persist.sect.donate"
+	PersistSectWithdrawSubject     = "persist.sect.withdraw"
+	PersistSectUpgradeSubject      = "persist.sect.upgrade"
+	PersistSectInfoSubject         = "persist.sect.info"
+	PersistSectListSubject         = "persist.sect.list"
+	PersistSectApplicationsSubject = "persist.sect.applications"
+	PersistSectMembershipSubject   = "persist.sect.membership"
+
+	// ============ 公开只读数据相关 ============
+	PersistLeaderboardSubject   = "persist.leaderboard"
+	PersistPlayerProfileSubject = "persist.player_profile"
+
 	// ============ 网关服务相关 ============
-	GatewayBroadcastSubject = "gateway.broadcast"
-	GatewayClientMsgSubject = "gateway.client_msg"
+	GatewayBroadcastSubject      = "gateway.broadcast"
+	GatewayClientMsgSubject      = "gateway.client_msg"
+	GatewaySessionRevokedSubject = "gateway.session.revoked" // 会话被撤销，关闭对应的 WebSocket 连接
 
 	// ============ 系统广播相关 ============
 	SystemHeartbeatSubject = "system.heartbeat"
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -12,28 +13,103 @@ type PlayerData struct {
 	Exp          int64     `json:"exp"`
 	LastSaveTime time.Time `json:"last_save_time"`
 	CreatedAt    time.Time `json:"created_at"`
+	Aptitude     *Aptitude `json:"aptitude,omitempty"` // 灵根资质，参与修炼速度等公式
+
+	// GameData Game 服务维护的游戏状态（进行中的序列、序列等级、背包、增益等）
+	GameData map[string]interface{} `json:"game_data,omitempty"`
+}
+
+// ProgressEntry game_progress 表中的一条玩家进度（成就、计数等），Value 为 JSON
+type ProgressEntry struct {
+	Type  string          `json:"type"`
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Mail 玩家邮件，附件在领取时发放（Exp 与 Resources 按邮件来源记录）
+type Mail struct {
+	MailID      string       `json:"mail_id"`
+	PlayerID    string       `json:"player_id"`
+	Sender      string       `json:"sender"`
+	Subject     string       `json:"subject"`
+	Body        string       `json:"body,omitempty"`
+	Attachments RewardBundle `json:"attachments"`
+	CreatedAt   int64        `json:"created_at"`
+	ExpiresAt   int64        `json:"expires_at"`
+	ClaimedAt   int64        `json:"claimed_at,omitempty"`
+}
+
+// PurchaseRecord 一次商店购买，RequestID 为客户端生成的请求ID（同一玩家内唯一）
+type PurchaseRecord struct {
+	RequestID   string           `json:"request_id"`
+	PlayerID    string           `json:"player_id"`
+	ShopID      string           `json:"shop_id"`
+	OfferID     string           `json:"offer_id"`
+	ItemID      string           `json:"item_id"`
+	Count       int              `json:"count"` // 获得的物品数量
+	Price       map[string]int64 `json:"price"` // 支付的总价
+	PurchasedAt int64            `json:"purchased_at"`
+}
+
+// PlayerCharge 其他服务经由 Game 扣除角色货币的请求，ChargeID 由调用方生成（同一角色内唯一），
+// 重试的请求不会重复扣费；Refund 为 true 时退还 ChargeID 对应的扣费
+type PlayerCharge struct {
+	PlayerID string `json:"player_id"`
+	ChargeID string `json:"charge_id"`
+	Resource string `json:"resource"`
+	Amount   int64  `json:"amount"`
+	Source   string `json:"source"`
+	Refund   bool   `json:"refund,omitempty"`
+}
+
+// Buff 限时增益，在线 Tick 和离线结算时对序列产出生效
+type Buff struct {
+	ID             string    `json:"id"`
+	ExpMultiplier  float64   `json:"exp_multiplier,omitempty"`  // 经验倍率，0 表示不影响
+	DropMultiplier float64   `json:"drop_multiplier,omitempty"` // 掉落概率倍率，0 表示不影响
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// Active 增益在指定时间是否仍生效
+func (b Buff) Active(at time.Time) bool {
+	return at.Before(b.ExpiresAt)
 }
 
 // UserData 用户数据结构
 type UserData struct {
-	Username  string    `json:"username"`
-	Password  string    `json:"password"` // 存储哈希值
-	PlayerID  string    `json:"player_id"`
-	Level     int       `json:"level"`
-	Exp       int64     `json:"exp"`
-	CreatedAt time.Time `json:"created_at"`
-	LastLogin time.Time `json:"last_login"`
+	Username         string    `json:"username"`
+	Password         string    `json:"password"` // 存储哈希值
+	PlayerID         string    `json:"player_id"`
+	Role             string    `json:"role"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	RequireTwoFactor bool      `json:"require_two_factor"`
+	Banned           bool      `json:"banned"`
+	CharacterSlots   int       `json:"character_slots"`
+	Level            int       `json:"level"`
+	Exp              int64     `json:"exp"`
+	CreatedAt        time.Time `json:"created_at"`
+	LastLogin        time.Time `json:"last_login"`
 }
 
 // PlayerRanking 玩家排行榜结构
 type PlayerRanking struct {
 	Rank     int    `json:"rank"`
 	PlayerID string `json:"player_id"`
-	Username string `json:"username"`
+	Name     string `json:"name"` // 显示名称，不包含登录用户名
 	Level    int    `json:"level"`
 	Exp      int64  `json:"exp"`
 }
 
+// PlayerProfile 角色公开资料，不包含账号信息
+type PlayerProfile struct {
+	PlayerID  string    `json:"player_id"`
+	Name      string    `json:"name"`
+	Level     int       `json:"level"`
+	Exp       int64     `json:"exp"`
+	Rank      int       `json:"rank"` // 等级排行榜名次
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // UserRepository 用户仓库接口
 type UserRepository interface {
 	SaveUser(user *UserData) error
@@ -42,3 +118,69 @@ type UserRepository interface {
 	UpdateLastLogin(username string) error
 	UserExists(username string) bool
 }
+
+// CharacterInfo 账号下的角色摘要
+type CharacterInfo struct {
+	PlayerID          string     `json:"player_id"`
+	Name              string     `json:"name"`
+	Level             int        `json:"level"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastSelectedAt    *time.Time `json:"last_selected_at,omitempty"`
+	DeleteScheduledAt *time.Time `json:"delete_scheduled_at,omitempty"` // 非空表示处于删除冷静期
+	NameChangedAt     *time.Time `json:"name_changed_at,omitempty"`     // 最近一次改名时间，为空表示尚未改名（首次改名免费）
+	Aptitude          *Aptitude  `json:"aptitude,omitempty"`            // 灵根资质
+	RerollsLeft       int        `json:"rerolls_left"`                  // 剩余的资质重随次数
+}
+
+// NameChange 显示名称修改记录
+type NameChange struct {
+	PlayerID  string    `json:"player_id"`
+	OldName   string    `json:"old_name"`
+	NewName   string    `json:"new_name"`
+	Cost      int64     `json:"cost"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// DataRequestInfo 数据导出/删除请求摘要
+type DataRequestInfo struct {
+	RequestID   string     `json:"request_id"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ScheduledAt time.Time  `json:"scheduled_at"` // 删除请求在此时间之后执行
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// APIKeyInfo API密钥摘要，不包含密钥本身
+type APIKeyInfo struct {
+	KeyID      string     `json:"key_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	RateLimit  int        `json:"rate_limit"` // 每分钟请求数
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// SessionInfo 设备登录会话，令牌通过 sid 声明绑定到会话
+type SessionInfo struct {
+	SessionID  string    `json:"session_id"`
+	Username   string    `json:"username"`
+	PlayerID   string    `json:"player_id"` // 会话当前选择的角色
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current,omitempty"` // 是否为发起请求的会话，仅在列表中设置
+}
+
+// ClientInfo 客户端来源信息，由 Gateway 通过消息 metadata 传递给后端服务
+type ClientInfo struct {
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	DeviceName string `json:"device_name"` // 客户端自报的设备名称，可为空
+}
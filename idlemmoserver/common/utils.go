@@ -2,6 +2,8 @@ package common
 
 import (
 	"encoding/json"
+	"os"
+	"strings"
 )
 
 // Unmarshal 通用的反序列化函数
@@ -13,3 +15,14 @@ func Unmarshal(data []byte, v interface{}) error {
 func Marshal(v interface{}) ([]byte, error) {
 	return json.Marshal(v)
 }
+
+// EnvList 读取逗号分隔的环境变量，忽略空项；未设置时返回 nil
+func EnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
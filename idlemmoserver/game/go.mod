@@ -3,13 +3,13 @@ module game
 go 1.25.2
 
 require (
+	github.com/asynkron/protoactor-go v0.0.0-20251008162023-d5226bee08eb
 	github.com/idle-server/common v0.0.0-00010101000000-000000000000
 	github.com/nats-io/nats.go v1.46.1
 )
 
 require (
 	github.com/Workiva/go-datastructures v1.1.6 // indirect
-	github.com/asynkron/protoactor-go v0.0.0-20251008162023-d5226bee08eb // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/emirpasic/gods v1.18.1 // indirect
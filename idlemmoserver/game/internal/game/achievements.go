@@ -0,0 +1,169 @@
+package game
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 成就（仅在玩家 Actor 内调用） ============
+// 游戏事件触发后评估全部未解锁的成就，达成时先写入解锁记录再发放奖励，保证每个成就只发放一次。
+// 进度由当前状态（等级、序列等级）或 game_progress 中的累计计数得出，新增的成就可按已有进度追溯解锁
+
+// achievementRecord 成就解锁记录
+type achievementRecord struct {
+	UnlockedAt int64 `json:"unlocked_at"`
+}
+
+// loginRecord 登录记录，按服务器日（见配置表 schedule）计算连续登录
+type loginRecord struct {
+	LastDay    string `json:"last_day"`
+	Streak     int64  `json:"streak"`
+	BestStreak int64  `json:"best_streak"`
+	TotalDays  int64  `json:"total_days"`
+}
+
+// loginRecordKey 登录记录的键
+const loginRecordKey = "daily"
+
+// itemGatheredStat 物品累计获得数量的计数键
+func itemGatheredStat(itemID string) string {
+	return common.AchievementConditionItemGathered + ":" + itemID
+}
+
+// recordLogin 记录当天首次登录并更新连续登录天数
+func (s *Service) recordLogin(playerState *PlayerState, now time.Time) {
+	var record loginRecord
+	playerState.Progress.Get(common.ProgressTypeLogin, loginRecordKey, &record)
+
+	dayStart := common.CurrentContent().Schedule.DayStart(now)
+	today := dayStart.Format(time.DateOnly)
+	if record.LastDay == today {
+		return
+	}
+	if record.LastDay == dayStart.AddDate(0, 0, -1).Format(time.DateOnly) {
+		record.Streak++
+	} else {
+		record.Streak = 1
+	}
+	record.LastDay = today
+	record.BestStreak = max(record.BestStreak, record.Streak)
+	record.TotalDays++
+	if err := playerState.Progress.Set(common.ProgressTypeLogin, loginRecordKey, record); err != nil {
+		log.Printf("Failed to record login for %s: %v", playerState.PlayerID, err)
+		return
+	}
+
+	s.fireEvent(playerState, common.GameEvent{
+		Type:  common.GameEventLogin,
+		Value: record.Streak,
+	})
+}
+
+// onItemGathered 累计修炼序列产出的物品数量
+func (s *Service) onItemGathered(playerState *PlayerState, event common.GameEvent) {
+	playerState.Progress.Add(common.ProgressTypeStat, itemGatheredStat(event.Key), event.Count)
+}
+
+// onAchievementEvent 游戏事件可能推进成就进度，重新评估未解锁的成就
+func (s *Service) onAchievementEvent(playerState *PlayerState, _ common.GameEvent) {
+	s.evaluateAchievements(playerState, time.Now())
+}
+
+// evaluateAchievements 解锁所有已达成的成就并发放奖励，返回本次解锁的数量
+func (s *Service) evaluateAchievements(playerState *PlayerState, now time.Time) int {
+	achievements := common.CurrentContent().Achievements
+	ids := make([]string, 0, len(achievements))
+	for id := range achievements {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	unlocked := 0
+	for _, id := range ids {
+		if playerState.Progress.Has(common.ProgressTypeAchievement, id) {
+			continue
+		}
+		def := achievements[id]
+		if progress, target := achievementProgress(playerState, def); progress < target {
+			continue
+		}
+		if s.unlockAchievement(playerState, def, now) {
+			unlocked++
+		}
+	}
+	return unlocked
+}
+
+// unlockAchievement 写入解锁记录后发放奖励并推送通知
+func (s *Service) unlockAchievement(playerState *PlayerState, def common.AchievementDef, now time.Time) bool {
+	// 先记录解锁：发放奖励可能再次触发事件和评估，不会重复发放
+	if err := playerState.Progress.Set(common.ProgressTypeAchievement, def.ID, achievementRecord{UnlockedAt: now.Unix()}); err != nil {
+		log.Printf("Failed to unlock achievement %s for %s: %v", def.ID, playerState.PlayerID, err)
+		return false
+	}
+
+	log.Printf("Player %s unlocked achievement %s", playerState.PlayerID, def.ID)
+	s.grantRewards(playerState, def.Rewards, common.ExpSourceAchievement, common.CurrencySourceAchievement, "achievement")
+	s.pushToClient(playerState.PlayerID, &common.S_AchievementUnlocked{
+		Type:          common.ServerMsgTypeAchievement,
+		AchievementID: def.ID,
+		Name:          def.Name,
+		Description:   def.Description,
+		Rewards:       def.Rewards,
+		UnlockedAt:    now.Unix(),
+	})
+	return true
+}
+
+// achievementProgress 成就的当前进度与目标值
+func achievementProgress(playerState *PlayerState, def common.AchievementDef) (int64, int64) {
+	condition := def.Condition
+	switch condition.Type {
+	case common.AchievementConditionLevel:
+		return int64(playerState.Level), condition.Target
+	case common.AchievementConditionRealm:
+		realm, ok := common.GetRealm(condition.Key)
+		if !ok {
+			return 0, 1
+		}
+		return int64(common.RealmForLevel(playerState.Level).Order), int64(realm.Order)
+	case common.AchievementConditionItemGathered:
+		return playerState.Progress.Int(common.ProgressTypeStat, itemGatheredStat(condition.Key)), condition.Target
+	case common.AchievementConditionLoginStreak:
+		var record loginRecord
+		playerState.Progress.Get(common.ProgressTypeLogin, loginRecordKey, &record)
+		return record.BestStreak, condition.Target
+	case common.AchievementConditionSequenceLevel:
+		if progress, ok := playerState.Sequences[condition.Key]; ok {
+			return int64(progress.Level), condition.Target
+		}
+		return 0, condition.Target
+	}
+	return 0, 1
+}
+
+// achievementStatuses 全部成就的进度，供状态查询
+func achievementStatuses(playerState *PlayerState) []common.AchievementStatus {
+	achievements := common.CurrentContent().Achievements
+	statuses := make([]common.AchievementStatus, 0, len(achievements))
+	for id, def := range achievements {
+		progress, target := achievementProgress(playerState, def)
+		status := common.AchievementStatus{
+			ID:       id,
+			Name:     def.Name,
+			Progress: min(progress, target),
+			Target:   target,
+		}
+		var record achievementRecord
+		if playerState.Progress.Get(common.ProgressTypeAchievement, id, &record) {
+			status.Progress = target
+			status.UnlockedAt = record.UnlockedAt
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
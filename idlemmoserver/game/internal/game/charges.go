@@ -0,0 +1,134 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 代扣货币（仅在玩家 Actor 内调用） ============
+// Auth 等服务收取游戏货币（例如改名费用）时不直接修改存档，而是请求 Game 由玩家 Actor 经 changeCurrency 扣除，
+// 否则在线 Actor 的下一次保存会覆盖其修改。扣费在存档确认写入后才回复成功，调用方收到成功后再提交自己的操作，
+// 提交失败时按同一代扣ID请求退还。最近的代扣记录随存档保存：重试的请求直接返回原结果；
+// 退还先于扣费到达（调用方等待超时后退还）时记录为已退还，之后到达的扣费被拒绝
+
+// ChargeRecord 一次代扣，随存档保存
+type ChargeRecord struct {
+	ID       string `json:"id"`
+	Resource string `json:"resource,omitempty"`
+	Amount   int64  `json:"amount,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Refunded bool   `json:"refunded,omitempty"`
+	At       int64  `json:"at"`
+}
+
+// handlePlayerCharge 处理其他服务的代扣请求，玩家不在线时先激活
+func (s *Service) handlePlayerCharge(charge common.PlayerCharge) (*common.MsgPlayerChargeResult, error) {
+	result, err := s.askPlayer(charge.PlayerID, &msgCharge{charge: charge})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*common.MsgPlayerChargeResult), nil
+}
+
+// findCharge 查找存档中的代扣记录
+func findCharge(playerState *PlayerState, chargeID string) *ChargeRecord {
+	for i := range playerState.Charges {
+		if playerState.Charges[i].ID == chargeID {
+			return &playerState.Charges[i]
+		}
+	}
+	return nil
+}
+
+// recordCharge 记录代扣，只保留最近的 PlayerChargeRecent 条
+func recordCharge(playerState *PlayerState, record ChargeRecord) {
+	playerState.Charges = append(playerState.Charges, record)
+	if len(playerState.Charges) > common.PlayerChargeRecent {
+		playerState.Charges = playerState.Charges[len(playerState.Charges)-common.PlayerChargeRecent:]
+	}
+}
+
+// applyCharge 扣除货币并确认存档写入，同一代扣ID已处理过时返回原结果
+func (s *Service) applyCharge(playerState *PlayerState, charge common.PlayerCharge, now time.Time) (*common.MsgPlayerChargeResult, error) {
+	if charge.ChargeID == "" || len(charge.ChargeID) > common.PlayerChargeIDMaxLength {
+		return nil, fmt.Errorf("charge_id is required (at most %d characters)", common.PlayerChargeIDMaxLength)
+	}
+	if charge.Refund {
+		return s.refundCharge(playerState, charge.ChargeID, now), nil
+	}
+	if charge.Amount <= 0 {
+		return nil, fmt.Errorf("charge amount must be positive")
+	}
+
+	if record := findCharge(playerState, charge.ChargeID); record != nil {
+		if record.Refunded {
+			return nil, fmt.Errorf("charge %s was refunded", charge.ChargeID)
+		}
+		return &common.MsgPlayerChargeResult{
+			Success:   true,
+			Message:   "Charged",
+			Balance:   settleStamina(playerState, record.Resource, now),
+			Duplicate: true,
+		}, nil
+	}
+
+	if balance := settleStamina(playerState, charge.Resource, now); balance < charge.Amount {
+		return &common.MsgPlayerChargeResult{
+			Success:      false,
+			Message:      fmt.Sprintf("insufficient %s", charge.Resource),
+			Balance:      balance,
+			Insufficient: true,
+		}, nil
+	}
+	balance, err := s.changeCurrency(playerState, charge.Resource, -charge.Amount, charge.Source)
+	if err != nil {
+		return nil, err
+	}
+	recordCharge(playerState, ChargeRecord{
+		ID:       charge.ChargeID,
+		Resource: charge.Resource,
+		Amount:   charge.Amount,
+		Source:   charge.Source,
+		At:       now.Unix(),
+	})
+
+	// 调用方据此提交操作，存档未确认写入时撤销扣费；之后的保存会覆盖可能已写入的扣费
+	if err := s.savePlayerDataConfirmed(playerState); err != nil {
+		if _, revertErr := s.changeCurrency(playerState, charge.Resource, charge.Amount, charge.Source); revertErr != nil {
+			log.Printf("Failed to revert charge %s for %s: %v", charge.ChargeID, playerState.PlayerID, revertErr)
+		}
+		playerState.Charges = playerState.Charges[:len(playerState.Charges)-1]
+		return nil, fmt.Errorf("failed to save charge: %w", err)
+	}
+
+	return &common.MsgPlayerChargeResult{Success: true, Message: "Charged", Balance: balance}, nil
+}
+
+// refundCharge 退还代扣；没有对应的扣费时记录为已退还，之后到达的同一扣费不再执行
+func (s *Service) refundCharge(playerState *PlayerState, chargeID string, now time.Time) *common.MsgPlayerChargeResult {
+	record := findCharge(playerState, chargeID)
+	switch {
+	case record == nil:
+		recordCharge(playerState, ChargeRecord{ID: chargeID, Refunded: true, At: now.Unix()})
+	case record.Refunded:
+		return &common.MsgPlayerChargeResult{Success: true, Message: "Refunded", Duplicate: true}
+	default:
+		if _, err := s.changeCurrency(playerState, record.Resource, record.Amount, record.Source); err != nil {
+			log.Printf("Failed to refund charge %s for %s: %v", chargeID, playerState.PlayerID, err)
+			return &common.MsgPlayerChargeResult{Success: false, Message: err.Error()}
+		}
+		record.Refunded = true
+	}
+
+	if err := s.savePlayerData(playerState); err != nil {
+		log.Printf("Failed to save refund %s for %s: %v", chargeID, playerState.PlayerID, err)
+	}
+	result := &common.MsgPlayerChargeResult{Success: true, Message: "Refunded"}
+	if record != nil {
+		result.Balance = settleStamina(playerState, record.Resource, now)
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// renameCharge 改名费用的代扣请求
+func renameCharge(chargeID string, amount int64) common.PlayerCharge {
+	return common.PlayerCharge{PlayerID: "p1", ChargeID: chargeID, Resource: "gold", Amount: amount, Source: common.CurrencySourceRename}
+}
+
+func TestChargeSavedBeforeConfirming(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	saves := bus.acceptSaves()
+
+	result, err := s.applyCharge(state, renameCharge("rn_1", 30), time.Now())
+	if err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+	if !result.Success || result.Balance != 70 || state.Resources["gold"] != 70 {
+		t.Fatalf("result = %+v, gold = %d; want success with 70 left", result, state.Resources["gold"])
+	}
+	if len(*saves) != 1 || (*saves)[0]["resources"].(map[string]interface{})["gold"] != float64(70) {
+		t.Fatalf("saves = %v, want one confirmed save with the debit", *saves)
+	}
+
+	// 重试的请求不会重复扣费
+	result, err = s.applyCharge(state, renameCharge("rn_1", 30), time.Now())
+	if err != nil || !result.Duplicate || state.Resources["gold"] != 70 {
+		t.Errorf("retry = %+v, %v, gold = %d; want duplicate with 70 left", result, err, state.Resources["gold"])
+	}
+}
+
+func TestChargeRevertedWhenSaveUnconfirmed(t *testing.T) {
+	s, _, state := newMarketTestService(t)
+
+	if _, err := s.applyCharge(state, renameCharge("rn_1", 30), time.Now()); err == nil {
+		t.Fatalf("charge succeeded without a confirmed save")
+	}
+	if state.Resources["gold"] != 100 || findCharge(state, "rn_1") != nil {
+		t.Errorf("gold = %d, charges = %+v; want 100 and no record", state.Resources["gold"], state.Charges)
+	}
+}
+
+func TestChargeInsufficient(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	bus.acceptSaves()
+
+	result, err := s.applyCharge(state, renameCharge("rn_1", 500), time.Now())
+	if err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+	if result.Success || !result.Insufficient || state.Resources["gold"] != 100 {
+		t.Errorf("result = %+v, gold = %d; want insufficient with 100 left", result, state.Resources["gold"])
+	}
+}
+
+func TestRefundCharge(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	bus.acceptSaves()
+
+	if _, err := s.applyCharge(state, renameCharge("rn_1", 30), time.Now()); err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+	refund := common.PlayerCharge{PlayerID: "p1", ChargeID: "rn_1", Refund: true}
+	for i := 0; i < 2; i++ {
+		if result, err := s.applyCharge(state, refund, time.Now()); err != nil || !result.Success {
+			t.Fatalf("refund = %+v, %v", result, err)
+		}
+	}
+	if state.Resources["gold"] != 100 {
+		t.Errorf("gold = %d, want 100 after a repeated refund", state.Resources["gold"])
+	}
+}
+
+func TestRefundBeforeChargeRejectsLateCharge(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	bus.acceptSaves()
+
+	// 调用方等待超时后先退还，之后才到达的扣费不再执行
+	if _, err := s.applyCharge(state, common.PlayerCharge{PlayerID: "p1", ChargeID: "rn_1", Refund: true}, time.Now()); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if _, err := s.applyCharge(state, renameCharge("rn_1", 30), time.Now()); err == nil {
+		t.Errorf("late charge accepted after its refund")
+	}
+	if state.Resources["gold"] != 100 {
+		t.Errorf("gold = %d, want 100", state.Resources["gold"])
+	}
+}
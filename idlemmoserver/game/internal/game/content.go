@@ -0,0 +1,65 @@
+package game
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 配置表加载与热更新 ============
+// 配置表以整套为单位加载并校验，通过后原子替换；在线玩家的 Actor 不受影响，
+// 之后的结算直接读取新表。已不存在的序列会在下次推进时停止，背包中已删除的物品保留原样；
+// 热更新后在线玩家重新评估成就，使新增的成就按已有进度追溯解锁
+
+// loadContent 启动时加载配置表，失败时服务不启动
+func (s *Service) loadContent() error {
+	content, err := common.LoadContent(common.ContentDir)
+	if err != nil {
+		return err
+	}
+	common.SetContent(content)
+
+	log.Printf("Game content %s loaded: %d items, %d sequences, %d monsters, max level %d",
+		content.Version, len(content.Items), len(content.Sequences), len(content.Monsters), content.MaxLevel)
+	return nil
+}
+
+// reloadContent 热更新配置表，新表校验失败时保留当前版本
+func (s *Service) reloadContent() (*common.MsgContentReloadResult, error) {
+	s.contentMutex.Lock()
+	defer s.contentMutex.Unlock()
+
+	content, err := common.LoadContent(common.ContentDir)
+	if err != nil {
+		log.Printf("Game content reload rejected: %v", err)
+		return nil, fmt.Errorf("content reload rejected: %w", err)
+	}
+
+	previous := common.SetContent(content)
+	result := &common.MsgContentReloadResult{
+		Success: true,
+		Version: content.Version,
+	}
+	if previous != nil {
+		result.PreviousVersion = previous.Version
+		for itemID := range previous.Items {
+			if _, ok := content.Items[itemID]; !ok {
+				log.Printf("Game content reload: item %s was removed", itemID)
+			}
+		}
+		for sequenceID := range previous.Sequences {
+			if _, ok := content.Sequences[sequenceID]; !ok {
+				log.Printf("Game content reload: sequence %s was removed, running instances will stop", sequenceID)
+			}
+		}
+	}
+	result.Message = fmt.Sprintf("content %s is now active", content.Version)
+
+	for _, pid := range s.onlinePlayers() {
+		s.actorSystem.Root.Send(pid, &msgEvaluateAchievements{})
+	}
+
+	log.Printf("Game content reloaded: %s -> %s", result.PreviousVersion, content.Version)
+	return result, nil
+}
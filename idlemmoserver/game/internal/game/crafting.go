@@ -0,0 +1,389 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 炼丹/锻造（仅在玩家 Actor 内调用） ============
+// 开始制作时一次性从背包扣除整批材料并记录在任务中（与队列同存档保存，不会重复扣除或丢失），
+// 每完成一次消耗一份预留材料；取消时退还剩余次数的材料。队列只推进队首任务，在线时随 Tick 推进，
+// 离线期间在激活时按离线时长（上限同离线收益）结算。产出放入背包，放不下的部分通过邮件送达
+
+// 制作相关的游戏动作
+const (
+	craftActionStart  = "craft_start"
+	craftActionCancel = "craft_cancel"
+	craftActionStatus = "craft_status"
+)
+
+// CraftingState 制作队列与生产技能，随存档保存
+type CraftingState struct {
+	Skills    map[string]*CraftingSkill `json:"skills,omitempty"`
+	Queue     []*CraftingJob            `json:"queue,omitempty"`
+	NextJobID int64                     `json:"next_job_id,omitempty"`
+}
+
+// CraftingSkill 生产技能的等级进度
+type CraftingSkill struct {
+	Level int   `json:"level"`
+	Exp   int64 `json:"exp"` // 当前等级内的经验
+}
+
+// CraftingJob 队列中的一个制作任务
+type CraftingJob struct {
+	JobID    int64            `json:"job_id"`
+	RecipeID string           `json:"recipe_id"`
+	Count    int              `json:"count"`
+	Done     int              `json:"done"`
+	Progress float64          `json:"progress"`       // 当前这次已进行的秒数
+	Inputs   map[string]int   `json:"inputs"`         // 开始时配方的单次材料，剩余次数的材料已从背包预留
+	Cost     map[string]int64 `json:"cost,omitempty"` // 开始时配方的单次货币消耗，取消时退还剩余次数的部分
+}
+
+// newCraftingState 创建空的制作状态
+func newCraftingState() *CraftingState {
+	return &CraftingState{Skills: make(map[string]*CraftingSkill)}
+}
+
+// skill 生产技能的进度，未练习过的技能为1级
+func (c *CraftingState) skill(name string) *CraftingSkill {
+	if c.Skills == nil {
+		c.Skills = make(map[string]*CraftingSkill)
+	}
+	skill, ok := c.Skills[name]
+	if !ok {
+		skill = &CraftingSkill{Level: 1}
+		c.Skills[name] = skill
+	}
+	return skill
+}
+
+// reserved 任务剩余次数预留的材料
+func (j *CraftingJob) reserved() map[string]int {
+	remaining := j.Count - j.Done
+	items := make(map[string]int, len(j.Inputs))
+	for itemID, count := range j.Inputs {
+		items[itemID] = count * remaining
+	}
+	return items
+}
+
+// reservedCost 任务剩余次数已支付的货币
+func (j *CraftingJob) reservedCost() map[string]int64 {
+	remaining := int64(j.Count - j.Done)
+	cost := make(map[string]int64, len(j.Cost))
+	for resource, amount := range j.Cost {
+		cost[resource] = amount * remaining
+	}
+	return cost
+}
+
+// refund 将任务剩余次数的材料与货币计入退还
+func (j *CraftingJob) refund(results *common.CraftingResults) {
+	for itemID, count := range j.reserved() {
+		results.Refunded[itemID] += count
+	}
+	for resource, amount := range j.reservedCost() {
+		results.RefundedCost[resource] += amount
+	}
+}
+
+// newCraftingResults 创建空的制作结果汇总
+func newCraftingResults() *common.CraftingResults {
+	return &common.CraftingResults{
+		Succeeded:    make(map[string]int),
+		Failed:       make(map[string]int),
+		Items:        make(map[string]int),
+		Refunded:     make(map[string]int),
+		RefundedCost: make(map[string]int64),
+		SkillUps:     make(map[string]int),
+	}
+}
+
+// applyCraftingAction 执行制作动作，返回并推送完整的制作状态
+func (s *Service) applyCraftingAction(playerState *PlayerState, action string, params map[string]interface{}) (*common.S_CraftingUpdate, error) {
+	now := time.Now()
+	var update *common.S_CraftingUpdate
+	switch action {
+	case craftActionStatus:
+		update = craftingStatus(playerState, "status", nil, now)
+	case craftActionStart:
+		recipeID, _ := params["recipe_id"].(string)
+		count, _ := params["count"].(float64)
+		if err := s.startCrafting(playerState, recipeID, max(int(count), 1)); err != nil {
+			return nil, err
+		}
+		update = craftingStatus(playerState, "start", nil, now)
+	case craftActionCancel:
+		jobID, _ := params["job_id"].(float64)
+		results, err := s.cancelCrafting(playerState, int64(jobID))
+		if err != nil {
+			return nil, err
+		}
+		update = s.deliverCrafting(playerState, results, "cancel", now)
+	default:
+		return nil, fmt.Errorf("unknown crafting action: %s", action)
+	}
+
+	playerState.LastActive = now
+	s.pushToClient(playerState.PlayerID, update)
+	return update, nil
+}
+
+// startCrafting 校验配方、技能等级与材料，扣除整批材料后加入队列
+func (s *Service) startCrafting(playerState *PlayerState, recipeID string, count int) error {
+	recipe, ok := common.CurrentContent().Recipes[recipeID]
+	if !ok {
+		return fmt.Errorf("unknown recipe: %s", recipeID)
+	}
+	if count > common.CraftingMaxBatch {
+		return fmt.Errorf("at most %d crafts per job", common.CraftingMaxBatch)
+	}
+	crafting := playerState.Crafting
+	if len(crafting.Queue) >= common.CraftingQueueSize {
+		return fmt.Errorf("crafting queue is full")
+	}
+	if level := crafting.skill(recipe.Skill).Level; level < recipe.MinSkillLevel {
+		return fmt.Errorf("requires %s level %d", recipe.Skill, recipe.MinSkillLevel)
+	}
+
+	// 先确认全部材料与货币足够再扣除，保证要么整批预留要么不做修改
+	inventory := playerState.Inventory
+	for itemID, perCraft := range recipe.Inputs {
+		if inventory.Count(itemID) < perCraft*count {
+			return fmt.Errorf("not enough %s", itemID)
+		}
+	}
+	now := time.Now()
+	for resource, perCraft := range recipe.Cost {
+		if settleStamina(playerState, resource, now) < perCraft*int64(count) {
+			return fmt.Errorf("insufficient %s", resource)
+		}
+	}
+	cost := make(map[string]int64, len(recipe.Cost))
+	for resource, perCraft := range recipe.Cost {
+		if _, err := s.changeCurrency(playerState, resource, -perCraft*int64(count), common.CurrencySourceCrafting); err != nil {
+			return err
+		}
+		cost[resource] = perCraft
+	}
+	var changed []int
+	inputs := make(map[string]int, len(recipe.Inputs))
+	for itemID, perCraft := range recipe.Inputs {
+		slots, err := inventory.Remove(itemID, perCraft*count)
+		if err != nil {
+			return err
+		}
+		changed = append(changed, slots...)
+		inputs[itemID] = perCraft
+	}
+
+	crafting.NextJobID++
+	crafting.Queue = append(crafting.Queue, &CraftingJob{
+		JobID:    crafting.NextJobID,
+		RecipeID: recipeID,
+		Count:    count,
+		Inputs:   inputs,
+		Cost:     cost,
+	})
+	log.Printf("Player %s started crafting %s x%d", playerState.PlayerID, recipeID, count)
+	s.pushToClient(playerState.PlayerID, s.inventoryUpdate(playerState, "crafting", changed))
+	return nil
+}
+
+// cancelCrafting 取消任务并退还剩余次数的材料，当前这次的进度作废
+func (s *Service) cancelCrafting(playerState *PlayerState, jobID int64) (*common.CraftingResults, error) {
+	crafting := playerState.Crafting
+	for i, job := range crafting.Queue {
+		if job.JobID != jobID {
+			continue
+		}
+		crafting.Queue = append(crafting.Queue[:i], crafting.Queue[i+1:]...)
+		results := newCraftingResults()
+		job.refund(results)
+		log.Printf("Player %s cancelled crafting %s (%d/%d done)", playerState.PlayerID, job.RecipeID, job.Done, job.Count)
+		return results, nil
+	}
+	return nil, fmt.Errorf("crafting job not found: %d", jobID)
+}
+
+// advanceCrafting 推进队列 elapsed 秒，结算完成的制作；只修改状态，产出由 deliverCrafting 发放
+// 没有完成任何一次时返回 nil
+func (s *Service) advanceCrafting(playerState *PlayerState, elapsed float64) *common.CraftingResults {
+	crafting := playerState.Crafting
+	var results *common.CraftingResults
+	for elapsed > 0 && len(crafting.Queue) > 0 {
+		job := crafting.Queue[0]
+		if results == nil {
+			results = newCraftingResults()
+		}
+
+		recipe, ok := common.CurrentContent().Recipes[job.RecipeID]
+		if !ok {
+			// 配方已下架：退还剩余材料与货币
+			job.refund(results)
+			crafting.Queue = crafting.Queue[1:]
+			continue
+		}
+
+		need := recipe.Duration - job.Progress
+		if elapsed < need {
+			job.Progress += elapsed
+			break
+		}
+		elapsed -= need
+		job.Progress = 0
+		job.Done++
+
+		skill := crafting.skill(recipe.Skill)
+		exp := recipe.SkillExp
+		if playerState.rng.Float64() < common.CraftingSuccessRate(recipe, skill.Level) {
+			results.Succeeded[recipe.ID]++
+			for itemID, count := range recipe.Outputs {
+				results.Items[itemID] += count
+			}
+		} else {
+			results.Failed[recipe.ID]++
+			for itemID, count := range recipe.Byproducts {
+				results.Items[itemID] += count
+			}
+			exp /= 2
+		}
+		if levels := grantCraftingExp(skill, exp); levels > 0 {
+			results.SkillUps[recipe.Skill] = skill.Level
+		}
+
+		if job.Done >= job.Count {
+			crafting.Queue = crafting.Queue[1:]
+		}
+	}
+	if results != nil && len(results.Succeeded) == 0 && len(results.Failed) == 0 && len(results.Refunded) == 0 {
+		return nil
+	}
+	return results
+}
+
+// grantCraftingExp 累积技能经验并升级，返回提升的等级数
+func grantCraftingExp(skill *CraftingSkill, exp int64) int {
+	levels := 0
+	skill.Exp += exp
+	for skill.Level < common.CraftingSkillMaxLevel {
+		need := common.CraftingSkillExpToNext(skill.Level)
+		if skill.Exp < need {
+			break
+		}
+		skill.Exp -= need
+		skill.Level++
+		levels++
+	}
+	if skill.Level >= common.CraftingSkillMaxLevel {
+		skill.Exp = 0
+	}
+	return levels
+}
+
+// settleOfflineCrafting 按离线时长推进制作队列，时长上限与离线收益相同
+func (s *Service) settleOfflineCrafting(playerState *PlayerState, lastSave, now time.Time) *common.CraftingResults {
+	if len(playerState.Crafting.Queue) == 0 || lastSave.IsZero() || !now.After(lastSave) {
+		return nil
+	}
+	settled := now.Sub(lastSave)
+	if limit := common.DefaultOfflineLimitHours * time.Hour; settled > limit {
+		settled = limit
+	}
+	return s.advanceCrafting(playerState, settled.Seconds())
+}
+
+// deliverCrafting 将产出与退还的材料放入背包，放不下的部分通过邮件送达，返回完整的制作状态
+func (s *Service) deliverCrafting(playerState *PlayerState, results *common.CraftingResults, reason string, now time.Time) *common.S_CraftingUpdate {
+	items := make(map[string]int, len(results.Items)+len(results.Refunded))
+	for itemID, count := range results.Items {
+		items[itemID] += count
+	}
+	for itemID, count := range results.Refunded {
+		items[itemID] += count
+	}
+
+	for resource, amount := range results.RefundedCost {
+		if _, err := s.changeCurrency(playerState, resource, amount, common.CurrencySourceCrafting); err != nil {
+			log.Printf("Failed to refund crafting %s to %s: %v", resource, playerState.PlayerID, err)
+		}
+	}
+	if update := s.grantItems(playerState, items, "crafting"); update != nil {
+		s.pushToClient(playerState.PlayerID, update)
+		if len(update.Overflow) > 0 {
+			// 已下架的物品无法投递，随溢出一并丢弃
+			mailed := make(map[string]int, len(update.Overflow))
+			for itemID, count := range update.Overflow {
+				if _, ok := common.GetItemDef(itemID); ok {
+					mailed[itemID] = count
+				}
+			}
+			if len(mailed) > 0 {
+				_, err := s.sendMail(playerState.PlayerID, mailSenderCrafting, "制作产出", "背包已满，以下物品通过邮件送达",
+					common.RewardBundle{Items: mailed})
+				if err != nil {
+					log.Printf("Failed to mail crafting overflow to %s: %v (%v)", playerState.PlayerID, err, mailed)
+				} else {
+					results.Mailed = true
+				}
+			}
+		}
+	}
+
+	for recipeID, count := range results.Succeeded {
+		for itemID, perCraft := range common.CurrentContent().Recipes[recipeID].Outputs {
+			s.fireEvent(playerState, common.GameEvent{
+				Type:   common.GameEventItemCrafted,
+				Source: recipeID,
+				Key:    itemID,
+				Count:  int64(perCraft * count),
+			})
+		}
+	}
+	return craftingStatus(playerState, reason, results, now)
+}
+
+// craftingStatus 生成完整的制作状态，FinishesAt 按队列顺序累计
+func craftingStatus(playerState *PlayerState, reason string, results *common.CraftingResults, now time.Time) *common.S_CraftingUpdate {
+	crafting := playerState.Crafting
+	update := &common.S_CraftingUpdate{
+		Type:    common.ServerMsgTypeCraftingUpdate,
+		Reason:  reason,
+		Queue:   make([]common.CraftingJobStatus, 0, len(crafting.Queue)),
+		Skills:  make(map[string]common.CraftingSkillStatus, len(common.CraftingSkills)),
+		Results: results,
+	}
+
+	finishesAt := float64(now.Unix())
+	for _, job := range crafting.Queue {
+		var duration float64
+		if recipe, ok := common.CurrentContent().Recipes[job.RecipeID]; ok {
+			duration = recipe.Duration
+		}
+		finishesAt += duration*float64(job.Count-job.Done) - job.Progress
+		update.Queue = append(update.Queue, common.CraftingJobStatus{
+			JobID:      job.JobID,
+			RecipeID:   job.RecipeID,
+			Count:      job.Count,
+			Done:       job.Done,
+			Progress:   job.Progress,
+			Duration:   duration,
+			FinishesAt: int64(finishesAt),
+		})
+	}
+
+	for name := range common.CraftingSkills {
+		skill := crafting.skill(name)
+		status := common.CraftingSkillStatus{Level: skill.Level, Exp: skill.Exp}
+		if skill.Level < common.CraftingSkillMaxLevel {
+			status.ExpToNext = common.CraftingSkillExpToNext(skill.Level)
+		}
+		update.Skills[name] = status
+	}
+	return update
+}
@@ -0,0 +1,141 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 装备（仅在玩家 Actor 内调用） ============
+// 装备与背包之间一对一互换：穿戴时背包格子让给被换下的装备，卸下时需要一个空格，
+// 任一步失败时回滚，不会出现物品丢失或复制
+
+// applyEquipAction 执行客户端的穿戴/卸下操作，成功后推送背包与装备更新
+func (s *Service) applyEquipAction(playerState *PlayerState, action string, slot int, equipSlot string) (*common.S_EquipmentUpdate, error) {
+	playerState.LastActive = time.Now()
+
+	var changed []int
+	var err error
+	switch action {
+	case common.ClientMsgTypeEquip:
+		changed, err = s.equipItem(playerState, slot)
+	case common.ClientMsgTypeUnequip:
+		changed, err = s.unequipItem(playerState, equipSlot)
+	default:
+		return nil, fmt.Errorf("unknown equipment action: %s", action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	update := s.equipmentUpdate(playerState)
+	s.pushToClient(playerState.PlayerID, s.inventoryUpdate(playerState, "equip", changed))
+	s.pushToClient(playerState.PlayerID, update)
+	return update, nil
+}
+
+// equipItem 穿戴背包格子中的装备，部位已有装备时换下放回背包
+func (s *Service) equipItem(playerState *PlayerState, slot int) ([]int, error) {
+	inv := playerState.Inventory
+	if slot < 0 || slot >= len(inv.Slots) {
+		return nil, fmt.Errorf("invalid slot: %d", slot)
+	}
+	itemID := inv.Slots[slot].ItemID
+	def, ok := common.GetItemDef(itemID)
+	if !ok || def.Equip == nil {
+		return nil, fmt.Errorf("item cannot be equipped")
+	}
+	if err := s.checkEquipRequirement(playerState, def.Equip); err != nil {
+		return nil, err
+	}
+
+	if _, err := inv.RemoveAt(slot, 1); err != nil {
+		return nil, err
+	}
+	changed := []int{slot}
+
+	previous := playerState.Equipment[def.Equip.Slot]
+	if previous != "" && inv.Slots[slot].ItemID == "" {
+		// 换下的装备放回刚让出的格子
+		inv.Slots[slot].ItemID = previous
+		inv.Slots[slot].Count = 1
+	} else if previous != "" {
+		slots, rest, err := inv.Add(previous, 1)
+		if err != nil || rest > 0 {
+			// 背包放不下换下的装备：撤销本次穿戴
+			inv.Slots[slot].ItemID = itemID
+			inv.Slots[slot].Count++
+			return nil, fmt.Errorf("inventory is full")
+		}
+		changed = append(changed, slots...)
+	}
+	playerState.Equipment[def.Equip.Slot] = itemID
+
+	log.Printf("Player %s equipped %s on %s", playerState.PlayerID, itemID, def.Equip.Slot)
+	return changed, nil
+}
+
+// unequipItem 卸下部位上的装备放回背包
+func (s *Service) unequipItem(playerState *PlayerState, equipSlot string) ([]int, error) {
+	itemID := playerState.Equipment[equipSlot]
+	if itemID == "" {
+		return nil, fmt.Errorf("nothing equipped on %s", equipSlot)
+	}
+
+	changed, rest, err := playerState.Inventory.Add(itemID, 1)
+	if err != nil {
+		return nil, err
+	}
+	if rest > 0 {
+		return nil, fmt.Errorf("inventory is full")
+	}
+	delete(playerState.Equipment, equipSlot)
+
+	log.Printf("Player %s unequipped %s from %s", playerState.PlayerID, itemID, equipSlot)
+	return changed, nil
+}
+
+// checkEquipRequirement 检查角色等级与境界是否满足装备要求
+func (s *Service) checkEquipRequirement(playerState *PlayerState, equip *common.EquipDef) error {
+	level := playerState.Level
+	if level < equip.MinLevel {
+		return fmt.Errorf("requires level %d", equip.MinLevel)
+	}
+	if equip.MinRealm != "" {
+		required, ok := common.GetRealm(equip.MinRealm)
+		if !ok {
+			return fmt.Errorf("unknown realm: %s", equip.MinRealm)
+		}
+		if common.RealmForLevel(level).Order < required.Order {
+			return fmt.Errorf("requires realm %s", required.Name)
+		}
+	}
+	return nil
+}
+
+// playerStats 计算玩家的派生属性：等级与资质的基础属性加上所有装备属性
+func playerStats(playerState *PlayerState) common.Stats {
+	stats := common.BaseStats(playerState.Level, playerState.Aptitude)
+	for _, itemID := range playerState.Equipment {
+		if def, ok := common.GetItemDef(itemID); ok && def.Equip != nil {
+			stats.Add(def.Equip.Stats)
+		}
+	}
+	return stats
+}
+
+// equipmentUpdate 生成完整装备与派生属性
+func (s *Service) equipmentUpdate(playerState *PlayerState) *common.S_EquipmentUpdate {
+	equipment := make(map[string]string, len(playerState.Equipment))
+	for slot, itemID := range playerState.Equipment {
+		equipment[slot] = itemID
+	}
+	return &common.S_EquipmentUpdate{
+		Type:      common.ServerMsgTypeEquipmentUpdate,
+		Equipment: equipment,
+		Realm:     common.RealmForLevel(playerState.Level).ID,
+		Stats:     playerStats(playerState),
+	}
+}
@@ -0,0 +1,367 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 背包 ============
+// 固定格子数的背包，同种物品优先叠入已有格子，放不下的部分视为溢出（丢弃并通知客户端）
+
+// Inventory 玩家背包，Slots 长度等于 Capacity，ItemID 为空表示空格
+type Inventory struct {
+	Capacity int                    `json:"capacity"`
+	Slots    []common.InventorySlot `json:"slots"`
+}
+
+// NewInventory 创建空背包
+func NewInventory(capacity int) *Inventory {
+	inv := &Inventory{Capacity: capacity}
+	inv.normalize()
+	return inv
+}
+
+// normalize 补齐格子并修正格子序号（加载存档或扩容后调用）
+func (inv *Inventory) normalize() {
+	slots := make([]common.InventorySlot, inv.Capacity)
+	for i := range slots {
+		slots[i].Slot = i
+	}
+	for _, slot := range inv.Slots {
+		if slot.Slot >= 0 && slot.Slot < inv.Capacity && slot.Count > 0 && slot.ItemID != "" {
+			slots[slot.Slot] = slot
+		}
+	}
+	inv.Slots = slots
+}
+
+// Count 背包中某物品的总数
+func (inv *Inventory) Count(itemID string) int {
+	total := 0
+	for _, slot := range inv.Slots {
+		if slot.ItemID == itemID {
+			total += slot.Count
+		}
+	}
+	return total
+}
+
+// Add 放入物品，返回发生变化的格子和放不下的数量
+func (inv *Inventory) Add(itemID string, count int) ([]int, int, error) {
+	def, ok := common.GetItemDef(itemID)
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown item: %s", itemID)
+	}
+	if count <= 0 {
+		return nil, 0, nil
+	}
+
+	var changed []int
+	// 先叠入已有的同种物品格子
+	for i := range inv.Slots {
+		if count == 0 {
+			break
+		}
+		slot := &inv.Slots[i]
+		if slot.ItemID != itemID || slot.Count >= def.MaxStack {
+			continue
+		}
+		moved := min(count, def.MaxStack-slot.Count)
+		slot.Count += moved
+		count -= moved
+		changed = append(changed, i)
+	}
+	// 再占用空格
+	for i := range inv.Slots {
+		if count == 0 {
+			break
+		}
+		slot := &inv.Slots[i]
+		if slot.ItemID != "" {
+			continue
+		}
+		moved := min(count, def.MaxStack)
+		slot.ItemID = itemID
+		slot.Count = moved
+		count -= moved
+		changed = append(changed, i)
+	}
+	return changed, count, nil
+}
+
+// Remove 从背包中移除指定数量的物品（从靠后的格子开始），数量不足时不做修改
+func (inv *Inventory) Remove(itemID string, count int) ([]int, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("invalid count: %d", count)
+	}
+	if inv.Count(itemID) < count {
+		return nil, fmt.Errorf("not enough %s", itemID)
+	}
+
+	var changed []int
+	for i := len(inv.Slots) - 1; i >= 0 && count > 0; i-- {
+		slot := &inv.Slots[i]
+		if slot.ItemID != itemID {
+			continue
+		}
+		moved := min(count, slot.Count)
+		slot.Count -= moved
+		count -= moved
+		if slot.Count == 0 {
+			slot.ItemID = ""
+		}
+		changed = append(changed, i)
+	}
+	return changed, nil
+}
+
+// Fits 一批物品能否全部放入背包，不修改背包；已下架的物品不占格子
+func (inv *Inventory) Fits(items map[string]int) bool {
+	probe := &Inventory{Capacity: inv.Capacity, Slots: append([]common.InventorySlot(nil), inv.Slots...)}
+	for itemID, count := range items {
+		if _, rest, err := probe.Add(itemID, count); err == nil && rest > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// RemoveAt 从指定格子移除物品，返回被移除的物品ID
+func (inv *Inventory) RemoveAt(index, count int) (string, error) {
+	if index < 0 || index >= len(inv.Slots) {
+		return "", fmt.Errorf("invalid slot: %d", index)
+	}
+	slot := &inv.Slots[index]
+	if slot.ItemID == "" {
+		return "", fmt.Errorf("slot %d is empty", index)
+	}
+	if count <= 0 || count > slot.Count {
+		return "", fmt.Errorf("invalid count: %d", count)
+	}
+
+	itemID := slot.ItemID
+	slot.Count -= count
+	if slot.Count == 0 {
+		slot.ItemID = ""
+	}
+	return itemID, nil
+}
+
+// Sort 整理背包：按物品类型和ID排序，并把同种物品合并为尽量少的格子
+func (inv *Inventory) Sort() {
+	totals := make(map[string]int)
+	for _, slot := range inv.Slots {
+		if slot.ItemID != "" {
+			totals[slot.ItemID] += slot.Count
+		}
+	}
+
+	itemIDs := make([]string, 0, len(totals))
+	for itemID := range totals {
+		itemIDs = append(itemIDs, itemID)
+	}
+	sort.Slice(itemIDs, func(i, j int) bool {
+		ti, tj := itemTypeOrder(itemIDs[i]), itemTypeOrder(itemIDs[j])
+		if ti != tj {
+			return ti < tj
+		}
+		return itemIDs[i] < itemIDs[j]
+	})
+
+	inv.Slots = nil
+	inv.normalize()
+	next := 0
+	for _, itemID := range itemIDs {
+		maxStack := 1
+		if def, ok := common.GetItemDef(itemID); ok {
+			maxStack = def.MaxStack
+		}
+		for remaining := totals[itemID]; remaining > 0 && next < inv.Capacity; next++ {
+			moved := min(remaining, maxStack)
+			inv.Slots[next].ItemID = itemID
+			inv.Slots[next].Count = moved
+			remaining -= moved
+		}
+	}
+}
+
+// snapshot 背包中的非空格子
+func (inv *Inventory) snapshot() []common.InventorySlot {
+	slots := make([]common.InventorySlot, 0, len(inv.Slots))
+	for _, slot := range inv.Slots {
+		if slot.ItemID != "" {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// itemTypeOrder 物品类型在整理时的排序，未知物品排在最后
+func itemTypeOrder(itemID string) int {
+	if def, ok := common.GetItemDef(itemID); ok {
+		if order, ok := common.ItemTypeOrder[def.Type]; ok {
+			return order
+		}
+	}
+	return len(common.ItemTypeOrder)
+}
+
+// ============ 背包业务逻辑（仅在玩家 Actor 内调用） ============
+
+// inventoryUpdate 根据变化的格子生成增量更新
+func (s *Service) inventoryUpdate(playerState *PlayerState, reason string, changed []int) *common.S_InventoryUpdate {
+	inv := playerState.Inventory
+	update := &common.S_InventoryUpdate{
+		Type:     common.ServerMsgTypeInventoryUpdate,
+		Reason:   reason,
+		Capacity: inv.Capacity,
+		Slots:    make([]common.InventorySlot, 0, len(changed)),
+	}
+	seen := make(map[int]bool, len(changed))
+	for _, index := range changed {
+		if seen[index] {
+			continue
+		}
+		seen[index] = true
+		update.Slots = append(update.Slots, inv.Slots[index])
+	}
+	return update
+}
+
+// fullInventoryUpdate 生成完整背包同步
+func (s *Service) fullInventoryUpdate(playerState *PlayerState, reason string) *common.S_InventoryUpdate {
+	return &common.S_InventoryUpdate{
+		Type:     common.ServerMsgTypeInventoryUpdate,
+		Reason:   reason,
+		Capacity: playerState.Inventory.Capacity,
+		Full:     true,
+		Slots:    playerState.Inventory.snapshot(),
+	}
+}
+
+// grantItems 向背包发放一批物品，没有任何变化时返回 nil
+func (s *Service) grantItems(playerState *PlayerState, items map[string]int, reason string) *common.S_InventoryUpdate {
+	if len(items) == 0 {
+		return nil
+	}
+
+	// 按物品ID顺序放入，保证背包将满时的溢出结果确定
+	itemIDs := make([]string, 0, len(items))
+	for itemID := range items {
+		itemIDs = append(itemIDs, itemID)
+	}
+	sort.Strings(itemIDs)
+
+	var changed []int
+	overflow := make(map[string]int)
+	for _, itemID := range itemIDs {
+		slots, rest, err := playerState.Inventory.Add(itemID, items[itemID])
+		if err != nil {
+			overflow[itemID] += items[itemID]
+			continue
+		}
+		changed = append(changed, slots...)
+		if rest > 0 {
+			overflow[itemID] += rest
+		}
+	}
+
+	update := s.inventoryUpdate(playerState, reason, changed)
+	if len(overflow) > 0 {
+		update.Overflow = overflow
+	}
+	return update
+}
+
+// applyInventoryAction 执行客户端的背包操作
+func (s *Service) applyInventoryAction(playerState *PlayerState, action string, slot, count int) (*common.S_InventoryUpdate, error) {
+	playerState.LastActive = time.Now()
+
+	switch action {
+	case common.ClientMsgTypeInventoryUse:
+		return s.useItem(playerState, slot, max(count, 1))
+	case common.ClientMsgTypeInventoryDiscard:
+		if count <= 0 && slot >= 0 && slot < len(playerState.Inventory.Slots) {
+			count = playerState.Inventory.Slots[slot].Count
+		}
+		if _, err := playerState.Inventory.RemoveAt(slot, count); err != nil {
+			return nil, err
+		}
+		return s.inventoryUpdate(playerState, "discard", []int{slot}), nil
+	case common.ClientMsgTypeInventorySort:
+		playerState.Inventory.Sort()
+		return s.fullInventoryUpdate(playerState, "sort"), nil
+	default:
+		return nil, fmt.Errorf("unknown inventory action: %s", action)
+	}
+}
+
+// useItem 使用格子中的消耗品
+func (s *Service) useItem(playerState *PlayerState, slot, count int) (*common.S_InventoryUpdate, error) {
+	if slot < 0 || slot >= len(playerState.Inventory.Slots) {
+		return nil, fmt.Errorf("invalid slot: %d", slot)
+	}
+	itemID := playerState.Inventory.Slots[slot].ItemID
+	def, ok := common.GetItemDef(itemID)
+	if !ok || def.Effect == nil {
+		return nil, fmt.Errorf("item cannot be used")
+	}
+	// 只补充体力的物品在全部资源已达持有上限时不消耗
+	if effect := def.Effect; len(effect.Stamina) > 0 && effect.Exp == 0 && effect.BuffDurationMinutes == 0 {
+		full := true
+		for resource := range effect.Stamina {
+			if stamina, ok := common.GetStaminaDef(resource); ok && settleStamina(playerState, resource, time.Now()) < stamina.Cap {
+				full = false
+			}
+		}
+		if full {
+			return nil, fmt.Errorf("already at maximum")
+		}
+	}
+	if _, err := playerState.Inventory.RemoveAt(slot, count); err != nil {
+		return nil, err
+	}
+
+	effect := def.Effect
+	if effect.Exp > 0 {
+		if _, err := s.grantExp(playerState, effect.Exp*int64(count), common.ExpSourceItem); err != nil {
+			return nil, err
+		}
+	}
+	if effect.BuffDurationMinutes > 0 {
+		s.addBuff(playerState, common.Buff{
+			ID:             def.ID,
+			ExpMultiplier:  effect.BuffExpMultiplier,
+			DropMultiplier: effect.BuffDropMultiplier,
+		}, time.Duration(effect.BuffDurationMinutes*count)*time.Minute)
+	}
+	for resource, amount := range effect.Stamina {
+		if _, err := s.changeCurrency(playerState, resource, amount*int64(count), common.CurrencySourceItem); err != nil {
+			return nil, err
+		}
+	}
+
+	s.fireEvent(playerState, common.GameEvent{
+		Type:  common.GameEventItemUsed,
+		Key:   itemID,
+		Count: int64(count),
+	})
+	return s.inventoryUpdate(playerState, "use", []int{slot}), nil
+}
+
+// addBuff 添加增益，已有同种增益时顺延持续时间
+func (s *Service) addBuff(playerState *PlayerState, buff common.Buff, duration time.Duration) {
+	now := time.Now()
+	playerState.Buffs = activeBuffs(playerState.Buffs, now)
+	for i := range playerState.Buffs {
+		if playerState.Buffs[i].ID == buff.ID {
+			playerState.Buffs[i].ExpiresAt = playerState.Buffs[i].ExpiresAt.Add(duration)
+			return
+		}
+	}
+	buff.ExpiresAt = now.Add(duration)
+	playerState.Buffs = append(playerState.Buffs, buff)
+}
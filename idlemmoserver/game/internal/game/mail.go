@@ -0,0 +1,158 @@
+package game
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 邮件 ============
+// 背包放不下的制作产出等通过邮件送达，邮件保存在 mails 表中（Persist），附件在领取时发放；
+// 领取前先确认背包放得下，再由 Persist 以条件更新标记领取，同一封邮件的附件只发放一次
+
+// 邮件相关的游戏动作
+const (
+	mailActionList  = "mail_list"
+	mailActionClaim = "mail_claim"
+)
+
+// 邮件发件来源
+const (
+	mailSenderCrafting = "crafting"
+)
+
+// generateMailID 生成邮件ID
+func generateMailID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "ml_" + hex.EncodeToString(bytes), nil
+}
+
+// sendMail 向玩家投递一封邮件，写入成功后推送给在线的收件人
+func (s *Service) sendMail(playerID, sender, subject, body string, attachments common.RewardBundle) (*common.Mail, error) {
+	mailID, err := generateMailID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mail id: %w", err)
+	}
+	now := time.Now()
+	mail := common.Mail{
+		MailID:      mailID,
+		PlayerID:    playerID,
+		Sender:      sender,
+		Subject:     subject,
+		Body:        body,
+		Attachments: attachments,
+		CreatedAt:   now.Unix(),
+		ExpiresAt:   now.AddDate(0, 0, common.MailRetentionDays).Unix(),
+	}
+
+	req := map[string]interface{}{
+		"type": "C_SendMail",
+		"mail": mail,
+	}
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := s.bus.RequestWithReply(common.PersistSendMailSubject, req, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to send mail: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to send mail: %s", result.Error)
+	}
+
+	log.Printf("Mail %s (%s) sent to %s", mailID, sender, playerID)
+	s.pushToClient(playerID, &common.S_MailReceived{Type: common.ServerMsgTypeMailReceived, Mail: mail})
+	return &mail, nil
+}
+
+// listMail 从 Persist 查询玩家未过期的邮件
+func (s *Service) listMail(playerID string) ([]common.Mail, error) {
+	req := map[string]interface{}{
+		"type":      "C_ListMail",
+		"player_id": playerID,
+	}
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Mails []common.Mail `json:"mails"`
+		} `json:"data"`
+	}
+	if err := s.bus.RequestWithReply(common.PersistListMailSubject, req, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to list mail: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list mail: %s", result.Error)
+	}
+	return result.Data.Mails, nil
+}
+
+// applyMailAction 执行邮件动作，仅在玩家 Actor 内调用
+func (s *Service) applyMailAction(playerState *PlayerState, action string, params map[string]interface{}) (interface{}, error) {
+	mails, err := s.listMail(playerState.PlayerID)
+	if err != nil {
+		return nil, err
+	}
+	if action == mailActionList {
+		list := &common.S_MailList{Type: common.ServerMsgTypeMailList, Mails: mails}
+		s.pushToClient(playerState.PlayerID, list)
+		return list, nil
+	}
+
+	mailID, _ := params["mail_id"].(string)
+	var mail *common.Mail
+	for i := range mails {
+		if mails[i].MailID == mailID {
+			mail = &mails[i]
+			break
+		}
+	}
+	if mail == nil {
+		return nil, fmt.Errorf("mail not found: %s", mailID)
+	}
+	if mail.ClaimedAt > 0 {
+		return nil, fmt.Errorf("mail %s already claimed", mailID)
+	}
+	if !playerState.Inventory.Fits(mail.Attachments.Items) {
+		return nil, fmt.Errorf("inventory full")
+	}
+
+	claimed, err := s.claimMail(playerState.PlayerID, mailID)
+	if err != nil {
+		return nil, err
+	}
+	s.grantRewards(playerState, claimed.Attachments, common.ExpSourceMail, common.CurrencySourceMail, "mail")
+	playerState.LastActive = time.Now()
+	return claimed, nil
+}
+
+// claimMail 请求 Persist 标记邮件已领取，返回领取的邮件
+func (s *Service) claimMail(playerID, mailID string) (*common.Mail, error) {
+	req := map[string]interface{}{
+		"type":      "C_ClaimMail",
+		"player_id": playerID,
+		"mail_id":   mailID,
+	}
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Mail *common.Mail `json:"mail"`
+		} `json:"data"`
+	}
+	if err := s.bus.RequestWithReply(common.PersistClaimMailSubject, req, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to claim mail: %w", err)
+	}
+	if !result.Success || result.Data.Mail == nil {
+		return nil, fmt.Errorf("failed to claim mail: %s", result.Error)
+	}
+	log.Printf("Player %s claimed mail %s", playerID, mailID)
+	return result.Data.Mail, nil
+}
@@ -0,0 +1,418 @@
+package game
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 玩家市场（仅在玩家 Actor 内调用） ============
+// 挂单、一口价购买和出价都先在本地托管：挂单的物品从背包扣除，购买和出价的货币从余额扣除，
+// 并作为待确认的操作随存档保存；Persist 确认存档写入后，才以操作ID提交给 Persist。Persist 在单个事务内
+// 完成交易并以操作ID去重，因此超时等无法确定结果的操作保留在存档中，之后原样重试而不会重复执行；
+// 被拒绝的操作退还托管。存档未确认写入的操作不会提交，崩溃后重新加载的存档中既没有托管也没有操作。
+// 成交的物品、卖家所得、被超出的出价和到期退回的物品均由 Persist 写入邮件
+
+// 市场相关的游戏动作
+const (
+	marketActionList   = "market_list"
+	marketActionBuy    = "market_buy"
+	marketActionBid    = "market_bid"
+	marketActionCancel = "market_cancel"
+	marketActionSearch = "market_search"
+	marketActionMine   = "market_mine"
+)
+
+// 待确认操作的类型
+const (
+	marketOpList = "list"
+	marketOpBuy  = "buy"
+	marketOpBid  = "bid"
+)
+
+// 市场操作结果
+const (
+	marketStatusCompleted = "completed"
+	marketStatusPending   = "pending"
+	marketStatusRejected  = "rejected"
+)
+
+// mailSenderMarket 市场退还的发件来源
+const mailSenderMarket = "market"
+
+// MarketState 已托管但尚未被 Persist 确认的市场操作，随存档保存
+type MarketState struct {
+	Pending []*MarketOp `json:"pending,omitempty"`
+}
+
+// MarketOp 待确认的市场操作，ID 即挂单ID、交易ID或出价ID
+type MarketOp struct {
+	ID        string                `json:"id"`
+	Kind      string                `json:"kind"`
+	ListingID string                `json:"listing_id,omitempty"`
+	Amount    int64                 `json:"amount,omitempty"`  // 托管的货币（购买、出价）
+	Listing   *common.MarketListing `json:"listing,omitempty"` // 挂单内容，托管的物品即挂单物品
+	CreatedAt int64                 `json:"created_at"`
+
+	unsaved bool // 托管尚未确认写入存档，不能提交给 Persist
+}
+
+// newMarketState 创建空的市场状态
+func newMarketState() *MarketState {
+	return &MarketState{}
+}
+
+// generateMarketID 生成市场操作ID
+func generateMarketID(prefix string) (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(bytes), nil
+}
+
+// applyMarketAction 执行市场动作并推送结果
+func (s *Service) applyMarketAction(playerState *PlayerState, action string, params map[string]interface{}) (interface{}, error) {
+	// 先重试之前未确认的操作，挂单数量与余额以确认后的结果为准
+	s.retryMarketOps(playerState)
+
+	now := time.Now()
+	var result interface{}
+	var err error
+	switch action {
+	case marketActionList:
+		result, err = s.marketList(playerState, params, now)
+	case marketActionBuy, marketActionBid:
+		result, err = s.marketBuyOrBid(playerState, action, params, now)
+	case marketActionCancel:
+		result, err = s.marketCancel(playerState, params)
+	case marketActionSearch:
+		result, err = s.marketSearch(playerState.PlayerID, params)
+	case marketActionMine:
+		result, err = s.marketQuery(common.PersistMarketMineSubject, "C_MarketMine", common.MarketRequest{PlayerID: playerState.PlayerID})
+	default:
+		return nil, fmt.Errorf("unknown market action: %s", action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	playerState.LastActive = now
+	s.pushToClient(playerState.PlayerID, result)
+	return result, nil
+}
+
+// marketList 托管背包中的物品并挂单
+func (s *Service) marketList(playerState *PlayerState, params map[string]interface{}, now time.Time) (*common.S_MarketUpdate, error) {
+	itemID, _ := params["item_id"].(string)
+	count, _ := params["count"].(float64)
+	buyout, _ := params["buyout_price"].(float64)
+	start, _ := params["start_price"].(float64)
+	hours, _ := params["duration_hours"].(float64)
+
+	def, ok := common.GetItemDef(itemID)
+	if !ok {
+		return nil, fmt.Errorf("unknown item: %s", itemID)
+	}
+	if count < 1 || playerState.Inventory.Count(itemID) < int(count) {
+		return nil, fmt.Errorf("not enough %s", itemID)
+	}
+	if buyout < 0 || start < 0 || (buyout == 0 && start == 0) {
+		return nil, fmt.Errorf("a buyout or start price is required")
+	}
+	if buyout > 0 && start >= buyout {
+		return nil, fmt.Errorf("start price must be below the buyout price")
+	}
+	duration := common.MarketDefaultDurationHours
+	if hours > 0 {
+		duration = int(hours)
+	}
+	if duration > common.MarketMaxDurationHours {
+		return nil, fmt.Errorf("listings last at most %d hours", common.MarketMaxDurationHours)
+	}
+	if err := checkMarketPending(playerState); err != nil {
+		return nil, err
+	}
+
+	listingID, err := generateMarketID("ls_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate listing id: %w", err)
+	}
+	changed, err := playerState.Inventory.Remove(itemID, int(count))
+	if err != nil {
+		return nil, err
+	}
+	s.pushToClient(playerState.PlayerID, s.inventoryUpdate(playerState, "market", changed))
+
+	op := &MarketOp{
+		ID:   listingID,
+		Kind: marketOpList,
+		Listing: &common.MarketListing{
+			ListingID:   listingID,
+			SellerID:    playerState.PlayerID,
+			ItemID:      itemID,
+			ItemType:    def.Type,
+			Count:       int(count),
+			Currency:    common.MarketCurrency,
+			BuyoutPrice: int64(buyout),
+			StartPrice:  int64(start),
+			ExpiresAt:   now.Add(time.Duration(duration) * time.Hour).Unix(),
+		},
+		CreatedAt: now.Unix(),
+	}
+	return s.submitEscrowedOp(playerState, op), nil
+}
+
+// marketBuyOrBid 托管货币并一口价购买或出价，price/amount 为玩家看到并同意支付的金额
+func (s *Service) marketBuyOrBid(playerState *PlayerState, action string, params map[string]interface{}, now time.Time) (*common.S_MarketUpdate, error) {
+	listingID, _ := params["listing_id"].(string)
+	key, kind, prefix := "price", marketOpBuy, "tr_"
+	if action == marketActionBid {
+		key, kind, prefix = "amount", marketOpBid, "bd_"
+	}
+	amount, _ := params[key].(float64)
+
+	if listingID == "" {
+		return nil, fmt.Errorf("listing_id is required")
+	}
+	if amount < 1 {
+		return nil, fmt.Errorf("%s is required", key)
+	}
+	if settleStamina(playerState, common.MarketCurrency, now) < int64(amount) {
+		return nil, fmt.Errorf("insufficient %s", common.MarketCurrency)
+	}
+	if err := checkMarketPending(playerState); err != nil {
+		return nil, err
+	}
+
+	opID, err := generateMarketID(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate market op id: %w", err)
+	}
+	if _, err := s.changeCurrency(playerState, common.MarketCurrency, -int64(amount), common.CurrencySourceMarket); err != nil {
+		return nil, err
+	}
+
+	op := &MarketOp{ID: opID, Kind: kind, ListingID: listingID, Amount: int64(amount), CreatedAt: now.Unix()}
+	return s.submitEscrowedOp(playerState, op), nil
+}
+
+// checkMarketPending 待确认的操作过多时拒绝新的操作
+func checkMarketPending(playerState *PlayerState) error {
+	if len(playerState.Market.Pending) >= common.MarketMaxPendingOps {
+		return fmt.Errorf("too many market operations awaiting confirmation, try again later")
+	}
+	return nil
+}
+
+// submitEscrowedOp 记录已托管的操作，Persist 确认存档写入后提交；
+// 存档未确认时操作保持待确认，由 retryMarketOps 在存档确认写入后提交
+func (s *Service) submitEscrowedOp(playerState *PlayerState, op *MarketOp) *common.S_MarketUpdate {
+	op.unsaved = true
+	playerState.Market.Pending = append(playerState.Market.Pending, op)
+	if err := s.savePlayerDataConfirmed(playerState); err != nil {
+		log.Printf("Failed to save market escrow %s for %s: %v", op.ID, playerState.PlayerID, err)
+		return &common.S_MarketUpdate{
+			Type:   common.ServerMsgTypeMarketUpdate,
+			Action: "market_" + op.Kind,
+			OpID:   op.ID,
+			Status: marketStatusPending,
+			Error:  err.Error(),
+		}
+	}
+
+	update := s.resolveMarketOp(playerState, op)
+	if update.Status != marketStatusPending {
+		if err := s.savePlayerData(playerState); err != nil {
+			log.Printf("Failed to save market op %s for %s: %v", op.ID, playerState.PlayerID, err)
+		}
+	}
+	return update
+}
+
+// retryMarketOps 重新提交未确认的操作，推送已有结果的操作并保存
+func (s *Service) retryMarketOps(playerState *PlayerState) {
+	if len(playerState.Market.Pending) == 0 {
+		return
+	}
+	for _, op := range playerState.Market.Pending {
+		if !op.unsaved {
+			continue
+		}
+		if err := s.savePlayerDataConfirmed(playerState); err != nil {
+			log.Printf("Failed to save market escrow for %s, ops not submitted: %v", playerState.PlayerID, err)
+			return
+		}
+		break
+	}
+
+	resolved := false
+	for _, op := range append([]*MarketOp(nil), playerState.Market.Pending...) {
+		update := s.resolveMarketOp(playerState, op)
+		if update.Status == marketStatusPending {
+			continue
+		}
+		resolved = true
+		s.pushToClient(playerState.PlayerID, update)
+	}
+	if resolved {
+		if err := s.savePlayerData(playerState); err != nil {
+			log.Printf("Failed to save market ops for %s: %v", playerState.PlayerID, err)
+		}
+	}
+}
+
+// resolveMarketOp 提交操作：成功时移除，被拒绝时退还托管并移除，无法确定结果时保留等待重试
+func (s *Service) resolveMarketOp(playerState *PlayerState, op *MarketOp) *common.S_MarketUpdate {
+	update := &common.S_MarketUpdate{Type: common.ServerMsgTypeMarketUpdate, Action: "market_" + op.Kind, OpID: op.ID}
+
+	req := common.MarketRequest{OpID: op.ID, PlayerID: playerState.PlayerID, ListingID: op.ListingID, Amount: op.Amount, Listing: op.Listing}
+	subject, msgType := common.PersistMarketListSubject, "C_MarketList"
+	switch op.Kind {
+	case marketOpBuy:
+		subject, msgType = common.PersistMarketBuySubject, "C_MarketBuy"
+	case marketOpBid:
+		subject, msgType = common.PersistMarketBidSubject, "C_MarketBid"
+	}
+
+	listing, err := s.marketRequest(subject, msgType, req)
+	switch {
+	case err == nil:
+		update.Status = marketStatusCompleted
+		update.Listing = listing
+		log.Printf("Player %s market %s %s completed", playerState.PlayerID, op.Kind, op.ID)
+	case errors.Is(err, common.ErrMarketRejected):
+		update.Status = marketStatusRejected
+		update.Error = err.Error()
+		s.refundMarketOp(playerState, op)
+		log.Printf("Player %s market %s %s rejected: %v", playerState.PlayerID, op.Kind, op.ID, err)
+	default:
+		update.Status = marketStatusPending
+		update.Error = err.Error()
+		log.Printf("Player %s market %s %s pending: %v", playerState.PlayerID, op.Kind, op.ID, err)
+		return update
+	}
+
+	for i, pending := range playerState.Market.Pending {
+		if pending.ID == op.ID {
+			playerState.Market.Pending = append(playerState.Market.Pending[:i], playerState.Market.Pending[i+1:]...)
+			break
+		}
+	}
+	return update
+}
+
+// refundMarketOp 退还被拒绝的操作托管的物品或货币，背包放不下的物品通过邮件送达
+func (s *Service) refundMarketOp(playerState *PlayerState, op *MarketOp) {
+	if op.Kind != marketOpList {
+		if _, err := s.changeCurrency(playerState, common.MarketCurrency, op.Amount, common.CurrencySourceMarket); err != nil {
+			log.Printf("Failed to refund market op %s for %s: %v", op.ID, playerState.PlayerID, err)
+		}
+		return
+	}
+
+	update := s.grantItems(playerState, map[string]int{op.Listing.ItemID: op.Listing.Count}, "market")
+	s.pushToClient(playerState.PlayerID, update)
+	if len(update.Overflow) > 0 {
+		_, err := s.sendMail(playerState.PlayerID, mailSenderMarket, "挂单退回", "挂单未能创建，背包已满，物品通过邮件退还",
+			common.RewardBundle{Items: update.Overflow})
+		if err != nil {
+			log.Printf("Failed to mail market refund %s to %s: %v", op.ID, playerState.PlayerID, err)
+		}
+	}
+}
+
+// marketCancel 撤回自己的挂单，物品由 Persist 通过邮件退还
+func (s *Service) marketCancel(playerState *PlayerState, params map[string]interface{}) (*common.S_MarketUpdate, error) {
+	listingID, _ := params["listing_id"].(string)
+	if listingID == "" {
+		return nil, fmt.Errorf("listing_id is required")
+	}
+	listing, err := s.marketRequest(common.PersistMarketCancelSubject, "C_MarketCancel",
+		common.MarketRequest{PlayerID: playerState.PlayerID, ListingID: listingID})
+	if err != nil {
+		return nil, err
+	}
+	return &common.S_MarketUpdate{
+		Type:    common.ServerMsgTypeMarketUpdate,
+		Action:  marketActionCancel,
+		Status:  marketStatusCompleted,
+		Listing: listing,
+	}, nil
+}
+
+// marketSearch 按条件搜索在售的挂单
+func (s *Service) marketSearch(playerID string, params map[string]interface{}) (*common.S_MarketListings, error) {
+	query := &common.MarketQuery{}
+	query.ItemID, _ = params["item_id"].(string)
+	query.ItemType, _ = params["item_type"].(string)
+	query.Sort, _ = params["sort"].(string)
+	query.AuctionOnly, _ = params["auction_only"].(bool)
+	if v, ok := params["min_price"].(float64); ok {
+		query.MinPrice = int64(v)
+	}
+	if v, ok := params["max_price"].(float64); ok {
+		query.MaxPrice = int64(v)
+	}
+	if v, ok := params["offset"].(float64); ok {
+		query.Offset = int(v)
+	}
+	if v, ok := params["limit"].(float64); ok {
+		query.Limit = int(v)
+	}
+	return s.marketQuery(common.PersistMarketSearchSubject, "C_MarketSearch", common.MarketRequest{PlayerID: playerID, Query: query})
+}
+
+// marketQuery 查询挂单列表
+func (s *Service) marketQuery(subject, msgType string, req common.MarketRequest) (*common.S_MarketListings, error) {
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Listings []common.MarketListing `json:"listings"`
+			Total    int64                  `json:"total"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{"type": msgType, "market": req}
+	if err := s.bus.RequestWithReply(subject, payload, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to query market: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to query market: %s", result.Error)
+	}
+	return &common.S_MarketListings{
+		Type:     common.ServerMsgTypeMarketListings,
+		Listings: result.Data.Listings,
+		Total:    result.Data.Total,
+	}, nil
+}
+
+// marketRequest 提交市场操作，被拒绝时返回包装 common.ErrMarketRejected 的错误
+func (s *Service) marketRequest(subject, msgType string, req common.MarketRequest) (*common.MarketListing, error) {
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Listing  *common.MarketListing `json:"listing"`
+			Rejected bool                  `json:"rejected"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{"type": msgType, "market": req}
+	if err := s.bus.RequestWithReply(subject, payload, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("market request failed: %w", err)
+	}
+	if result.Data.Rejected {
+		reason := strings.TrimPrefix(result.Error, common.ErrMarketRejected.Error()+": ")
+		return nil, fmt.Errorf("%w: %s", common.ErrMarketRejected, reason)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("market request failed: %s", result.Error)
+	}
+	return result.Data.Listing, nil
+}
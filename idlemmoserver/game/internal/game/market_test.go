@@ -0,0 +1,211 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// fakeBus 内存中的消息收发：按主题返回预设回复，未设置回复的主题视为超时
+type fakeBus struct {
+	handlers  map[string]func(req map[string]interface{}) interface{}
+	requests  []map[string]interface{} // 按顺序记录的请求，含 subject 字段
+	published []string
+}
+
+func newFakeBus() *fakeBus {
+	return &fakeBus{handlers: make(map[string]func(map[string]interface{}) interface{})}
+}
+
+func (b *fakeBus) Publish(subject string, msg interface{}) error {
+	b.published = append(b.published, subject)
+	return nil
+}
+
+func (b *fakeBus) RequestWithReply(subject string, request interface{}, response interface{}, timeout time.Duration) error {
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	var req map[string]interface{}
+	if err := json.Unmarshal(encoded, &req); err != nil {
+		return err
+	}
+	req["subject"] = subject
+	b.requests = append(b.requests, req)
+
+	handler, ok := b.handlers[subject]
+	if !ok {
+		return errors.New("nats: timeout")
+	}
+	reply, err := json.Marshal(handler(req))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(reply, response)
+}
+
+// subjects 已发送请求的主题
+func (b *fakeBus) subjects() []string {
+	subjects := make([]string, 0, len(b.requests))
+	for _, req := range b.requests {
+		subjects = append(subjects, req["subject"].(string))
+	}
+	return subjects
+}
+
+// acceptSaves 确认所有存档写入，返回记录每次写入的存档文档的切片指针
+func (b *fakeBus) acceptSaves() *[]map[string]interface{} {
+	saves := &[]map[string]interface{}{}
+	b.handlers[common.PersistSavePlayerSubject] = func(req map[string]interface{}) interface{} {
+		data := req["data"].(map[string]interface{})
+		*saves = append(*saves, data["game_data"].(map[string]interface{}))
+		return map[string]interface{}{"success": true}
+	}
+	return saves
+}
+
+func newMarketTestService(t *testing.T) (*Service, *fakeBus, *PlayerState) {
+	t.Helper()
+	loadTestContent(t)
+	bus := newFakeBus()
+	state := newPlayerState("p1", time.Now())
+	state.Resources["gold"] = 100
+	return &Service{bus: bus}, bus, state
+}
+
+// savedPendingIDs 存档文档中的待确认市场操作ID
+func savedPendingIDs(doc map[string]interface{}) []string {
+	market, _ := doc["market"].(map[string]interface{})
+	pending, _ := market["pending"].([]interface{})
+	ids := make([]string, 0, len(pending))
+	for _, op := range pending {
+		ids = append(ids, op.(map[string]interface{})["id"].(string))
+	}
+	return ids
+}
+
+func TestMarketListSubmittedAfterEscrowSaved(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	if _, _, err := state.Inventory.Add("iron_ore", 10); err != nil {
+		t.Fatalf("add items: %v", err)
+	}
+	saves := bus.acceptSaves()
+	bus.handlers[common.PersistMarketListSubject] = func(req map[string]interface{}) interface{} {
+		// 提交时托管的物品与待确认操作必须已写入存档
+		if len(*saves) != 1 || len(savedPendingIDs((*saves)[0])) != 1 {
+			t.Errorf("market op submitted before escrow was saved, saves = %d", len(*saves))
+		}
+		return map[string]interface{}{"success": true, "data": map[string]interface{}{"listing": req["market"].(map[string]interface{})["listing"]}}
+	}
+
+	result, err := s.applyMarketAction(state, marketActionList, map[string]interface{}{
+		"item_id": "iron_ore", "count": float64(5), "buyout_price": float64(30),
+	})
+	if err != nil {
+		t.Fatalf("market_list: %v", err)
+	}
+	update := result.(*common.S_MarketUpdate)
+	if update.Status != marketStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", update.Status, update.Error)
+	}
+	if got := bus.subjects(); len(got) != 2 || got[0] != common.PersistSavePlayerSubject || got[1] != common.PersistMarketListSubject {
+		t.Errorf("requests = %v, want save then list", got)
+	}
+	if ids := savedPendingIDs((*saves)[0]); ids[0] != update.OpID {
+		t.Errorf("saved pending op = %v, want %s", ids, update.OpID)
+	}
+	if len(state.Market.Pending) != 0 || state.Inventory.Count("iron_ore") != 5 {
+		t.Errorf("pending = %d, iron_ore = %d; want 0, 5", len(state.Market.Pending), state.Inventory.Count("iron_ore"))
+	}
+}
+
+func TestMarketEscrowNotSubmittedUntilSaveConfirmed(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	bought := 0
+	bus.handlers[common.PersistMarketBuySubject] = func(req map[string]interface{}) interface{} {
+		bought++
+		return map[string]interface{}{"success": true, "data": map[string]interface{}{}}
+	}
+
+	// 存档未确认：扣除的金币保持托管，操作不提交
+	result, err := s.applyMarketAction(state, marketActionBuy, map[string]interface{}{"listing_id": "ls_1", "price": float64(40)})
+	if err != nil {
+		t.Fatalf("market_buy: %v", err)
+	}
+	if status := result.(*common.S_MarketUpdate).Status; status != marketStatusPending {
+		t.Fatalf("status = %s, want pending", status)
+	}
+	if bought != 0 || len(state.Market.Pending) != 1 || state.Resources["gold"] != 60 {
+		t.Fatalf("bought = %d, pending = %d, gold = %d; want 0, 1, 60", bought, len(state.Market.Pending), state.Resources["gold"])
+	}
+
+	// 仍未确认时重试不会提交
+	s.retryMarketOps(state)
+	if bought != 0 {
+		t.Fatalf("op submitted without a confirmed save")
+	}
+
+	saves := bus.acceptSaves()
+	s.retryMarketOps(state)
+	if bought != 1 || len(*saves) == 0 || len(state.Market.Pending) != 0 || state.Resources["gold"] != 60 {
+		t.Errorf("bought = %d, saves = %d, pending = %d, gold = %d; want 1, >0, 0, 60",
+			bought, len(*saves), len(state.Market.Pending), state.Resources["gold"])
+	}
+}
+
+func TestMarketTimedOutOpRetriedWithSameID(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	bus.acceptSaves()
+
+	result, err := s.applyMarketAction(state, marketActionBid, map[string]interface{}{"listing_id": "ls_1", "amount": float64(25)})
+	if err != nil {
+		t.Fatalf("market_bid: %v", err)
+	}
+	first := result.(*common.S_MarketUpdate)
+	if first.Status != marketStatusPending || len(state.Market.Pending) != 1 {
+		t.Fatalf("status = %s, pending = %d; want pending, 1", first.Status, len(state.Market.Pending))
+	}
+
+	var retried []string
+	bus.handlers[common.PersistMarketBidSubject] = func(req map[string]interface{}) interface{} {
+		retried = append(retried, req["market"].(map[string]interface{})["op_id"].(string))
+		return map[string]interface{}{"success": true, "data": map[string]interface{}{}}
+	}
+	s.retryMarketOps(state)
+	s.retryMarketOps(state)
+
+	if len(retried) != 1 || retried[0] != first.OpID {
+		t.Errorf("retried = %v, want [%s]", retried, first.OpID)
+	}
+	if len(state.Market.Pending) != 0 || state.Resources["gold"] != 75 {
+		t.Errorf("pending = %d, gold = %d; want 0, 75", len(state.Market.Pending), state.Resources["gold"])
+	}
+}
+
+func TestMarketRejectedOpRefunded(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	bus.acceptSaves()
+	bus.handlers[common.PersistMarketBidSubject] = func(req map[string]interface{}) interface{} {
+		return map[string]interface{}{
+			"success": false,
+			"error":   common.ErrMarketRejected.Error() + ": bid too low",
+			"data":    map[string]interface{}{"rejected": true},
+		}
+	}
+
+	result, err := s.applyMarketAction(state, marketActionBid, map[string]interface{}{"listing_id": "ls_1", "amount": float64(25)})
+	if err != nil {
+		t.Fatalf("market_bid: %v", err)
+	}
+	update := result.(*common.S_MarketUpdate)
+	if update.Status != marketStatusRejected || update.Error != common.ErrMarketRejected.Error()+": bid too low" {
+		t.Errorf("update = %+v, want rejected: bid too low", update)
+	}
+	if len(state.Market.Pending) != 0 || state.Resources["gold"] != 100 {
+		t.Errorf("pending = %d, gold = %d; want 0, 100", len(state.Market.Pending), state.Resources["gold"])
+	}
+}
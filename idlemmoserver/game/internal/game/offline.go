@@ -0,0 +1,107 @@
+package game
+
+import (
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 离线收益 ============
+// 玩家上线时按距上次保存的时长（不超过 DefaultOfflineLimitHours）模拟进行中的序列，
+// 以 OfflineSimulationStep 为步长推进，使增益在离线期间按实际过期时间失效
+
+// settleOffline 结算离线收益，没有进行中的序列或未完成任何一轮时返回 nil
+// 在玩家 Actor 启动前调用，此时状态尚未对外可见
+func (s *Service) settleOffline(playerState *PlayerState, lastSave, now time.Time) *common.S_OfflineReport {
+	if playerState.Sequence == nil || lastSave.IsZero() || !now.After(lastSave) {
+		return nil
+	}
+
+	offline := now.Sub(lastSave)
+	settled := offline
+	if limit := common.DefaultOfflineLimitHours * time.Hour; settled > limit {
+		settled = limit
+	}
+
+	sequenceID := playerState.Sequence.SequenceID
+	startLevel := s.sequenceProgress(playerState, sequenceID).Level
+	report := &common.S_OfflineReport{
+		Type:           common.ServerMsgTypeOfflineReport,
+		OfflineSeconds: int64(offline.Seconds()),
+		SettledSeconds: int64(settled.Seconds()),
+		SequenceID:     sequenceID,
+		Items:          make(map[string]int),
+	}
+
+	step := time.Duration(common.OfflineSimulationStep) * time.Second
+	for at := lastSave; at.Before(lastSave.Add(settled)); at = at.Add(step) {
+		elapsed := step
+		if remaining := lastSave.Add(settled).Sub(at); remaining < elapsed {
+			elapsed = remaining
+		}
+
+		result, update := s.advanceSequence(playerState, elapsed.Seconds(), at)
+		if playerState.Sequence == nil {
+			break
+		}
+		if result == nil {
+			continue
+		}
+		if update != nil {
+			for itemID, amount := range update.Overflow {
+				if report.Overflow == nil {
+					report.Overflow = make(map[string]int)
+				}
+				report.Overflow[itemID] += amount
+			}
+		}
+		report.Rounds += result.Rounds
+		report.ExpGained += result.ExpGained
+		for itemID, amount := range result.Items {
+			report.Items[itemID] += amount
+		}
+	}
+
+	playerState.Buffs = activeBuffs(playerState.Buffs, now)
+	if report.Rounds == 0 {
+		return nil
+	}
+
+	report.PlayerExp = playerState.Experience
+	report.PlayerLevel = playerState.Level
+	report.Level = s.sequenceProgress(playerState, sequenceID).Level
+	report.LevelsGained = report.Level - startLevel
+
+	log.Printf("Player %s settled %ds offline progress on %s: %d rounds, %d exp",
+		playerState.PlayerID, report.SettledSeconds, sequenceID, report.Rounds, report.ExpGained)
+	return report
+}
+
+// buffMultipliers 计算指定时间生效的增益对经验和掉落的总倍率
+func buffMultipliers(buffs []common.Buff, at time.Time) (float64, float64) {
+	expMultiplier, dropMultiplier := 1.0, 1.0
+	for _, buff := range buffs {
+		if !buff.Active(at) {
+			continue
+		}
+		if buff.ExpMultiplier > 0 {
+			expMultiplier *= buff.ExpMultiplier
+		}
+		if buff.DropMultiplier > 0 {
+			dropMultiplier *= buff.DropMultiplier
+		}
+	}
+	return expMultiplier, dropMultiplier
+}
+
+// activeBuffs 过滤出指定时间仍生效的增益
+func activeBuffs(buffs []common.Buff, at time.Time) []common.Buff {
+	var active []common.Buff
+	for _, buff := range buffs {
+		if buff.Active(at) {
+			active = append(active, buff)
+		}
+	}
+	return active
+}
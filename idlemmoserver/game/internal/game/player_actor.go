@@ -0,0 +1,489 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/asynkron/protoactor-go/actor"
+	"github.com/idle-server/common"
+)
+
+// errPlayerStopping 玩家 Actor 正在钝化保存，需等其停止后重新激活
+var errPlayerStopping = errors.New("player actor is stopping")
+
+// ============ Actor 消息 ============
+
+// msgSpawnPlayer GameManagerActor：为已加载的玩家状态创建 PlayerActor
+type msgSpawnPlayer struct {
+	state *PlayerState
+}
+
+// msgSpawnResult 创建结果，created 为 false 表示玩家已在线
+type msgSpawnResult struct {
+	pid     *actor.PID
+	created bool
+	err     error
+}
+
+// msgLogin 玩家连接，刷新活跃时间并记录登录；连接期间 Actor 不会因空闲被钝化。
+// 已决定钝化的 Actor 回复 errPlayerStopping，由调用方重新激活后再登录
+type msgLogin struct {
+	at time.Time
+}
+
+// msgEvaluateAchievements 配置表热更新后重新评估成就
+type msgEvaluateAchievements struct{}
+
+// msgDisconnect 玩家断开连接，保存后停止 Actor
+type msgDisconnect struct{}
+
+// msgGetState 获取游戏状态
+type msgGetState struct{}
+
+// msgGameAction 执行游戏动作
+type msgGameAction struct {
+	action string
+	params map[string]interface{}
+}
+
+// msgStartSequence 开始修炼序列
+type msgStartSequence struct {
+	sequenceID string
+}
+
+// msgStopSequence 停止修炼序列
+type msgStopSequence struct{}
+
+// msgInventoryAction 背包操作（使用、丢弃、整理）
+type msgInventoryAction struct {
+	action string
+	slot   int
+	count  int
+}
+
+// msgEquipAction 穿戴/卸下装备
+type msgEquipAction struct {
+	action    string
+	slot      int
+	equipSlot string
+}
+
+// msgAdminGrant 管理员发放
+type msgAdminGrant struct {
+	params map[string]interface{}
+}
+
+// msgSetAptitude Auth 重随资质后更新玩家持有的资质
+type msgSetAptitude struct {
+	aptitude *common.Aptitude
+}
+
+// msgCharge 其他服务请求的代扣或退还货币
+type msgCharge struct {
+	charge common.PlayerCharge
+}
+
+// msgSequenceTick 序列推进，不视为玩家活动
+type msgSequenceTick struct {
+	now     time.Time
+	elapsed float64
+}
+
+// NotInfluenceReceiveTimeout Tick 不重置空闲计时，玩家长时间无操作时仍会被钝化
+func (*msgSequenceTick) NotInfluenceReceiveTimeout() {}
+
+// msgSectEvent 其他成员的宗门操作，更新所在的宗门并推送给客户端，不视为玩家活动
+type msgSectEvent struct {
+	event *common.S_SectEvent
+}
+
+// NotInfluenceReceiveTimeout 宗门事件不重置空闲计时
+func (*msgSectEvent) NotInfluenceReceiveTimeout() {}
+
+// msgReply 玩家 Actor 对请求的统一回复
+type msgReply struct {
+	result interface{}
+	err    error
+}
+
+// ============ GameManagerActor ============
+
+// GameManagerActor 玩家 Actor 的父级，负责创建、监督并维护在线玩家的 PID 索引
+type GameManagerActor struct {
+	service *Service
+}
+
+// newGameManagerProps 创建 GameManagerActor 配置，子 Actor 崩溃时单独重启（状态保留）
+func newGameManagerProps(s *Service) *actor.Props {
+	supervisor := actor.NewOneForOneStrategy(common.PlayerActorMaxRestarts, time.Minute, actor.DefaultDecider)
+	return actor.PropsFromProducer(func() actor.Actor {
+		return &GameManagerActor{service: s}
+	}, actor.WithSupervisor(supervisor))
+}
+
+// Receive 处理消息
+func (m *GameManagerActor) Receive(ctx actor.Context) {
+	switch msg := ctx.Message().(type) {
+	case *msgSpawnPlayer:
+		m.spawnPlayer(ctx, msg.state)
+	case *actor.Terminated:
+		playerID := strings.TrimPrefix(msg.Who.Id, ctx.Self().Id+"/")
+		m.service.unregisterPlayer(playerID, msg.Who)
+		log.Printf("Player actor %s stopped", playerID)
+	}
+}
+
+// spawnPlayer 创建 PlayerActor，玩家已在线时返回现有的 PID；
+// 旧 Actor 仍在钝化保存时拒绝创建，此前加载的存档可能早于该次保存
+func (m *GameManagerActor) spawnPlayer(ctx actor.Context, state *PlayerState) {
+	if pid, ok := m.service.lookupPlayer(state.PlayerID); ok {
+		ctx.Respond(&msgSpawnResult{pid: pid})
+		return
+	}
+	if _, ok := m.service.stoppingPlayer(state.PlayerID); ok {
+		ctx.Respond(&msgSpawnResult{err: errPlayerStopping})
+		return
+	}
+
+	// 重启时复用同一份玩家状态，Actor 崩溃不会丢失进度
+	props := actor.PropsFromProducer(func() actor.Actor {
+		return &PlayerActor{service: m.service, state: state}
+	})
+	pid, err := ctx.SpawnNamed(props, state.PlayerID)
+	if err != nil {
+		ctx.Respond(&msgSpawnResult{err: fmt.Errorf("failed to spawn player actor: %w", err)})
+		return
+	}
+
+	m.service.registerPlayer(state.PlayerID, pid)
+	ctx.Respond(&msgSpawnResult{pid: pid, created: true})
+}
+
+// ============ PlayerActor ============
+
+// PlayerActor 单个在线玩家，串行处理该玩家的所有消息
+type PlayerActor struct {
+	service *Service
+	state   *PlayerState
+}
+
+// Receive 处理消息
+func (a *PlayerActor) Receive(ctx actor.Context) {
+	s := a.service
+	state := a.state
+
+	switch msg := ctx.Message().(type) {
+	case *actor.Started:
+		ctx.SetReceiveTimeout(common.PlayerActorPassivateMinutes * time.Minute)
+		// 追溯评估上次上线后新增的成就
+		s.evaluateAchievements(state, time.Now())
+		if results := state.pendingCrafting; results != nil {
+			state.pendingCrafting = nil
+			s.pushToClient(state.PlayerID, s.deliverCrafting(state, results, "offline", time.Now()))
+			if err := s.savePlayerData(state); err != nil {
+				log.Printf("Failed to save offline crafting for %s: %v", state.PlayerID, err)
+			}
+		}
+		// 重新提交上次下线前未确认的市场与宗门操作
+		s.retryMarketOps(state)
+		s.retrySectOps(state)
+		if report := state.pendingReport; report != nil {
+			state.pendingReport = nil
+			if err := s.savePlayerData(state); err != nil {
+				log.Printf("Failed to save offline progress for %s: %v", state.PlayerID, err)
+			}
+			s.pushToClient(state.PlayerID, report)
+			if len(report.Items) > 0 {
+				s.pushToClient(state.PlayerID, s.fullInventoryUpdate(state, "offline"))
+			}
+		}
+	case *actor.Restarting:
+		log.Printf("Player actor %s restarting", state.PlayerID)
+	case *actor.ReceiveTimeout:
+		// 连接中的玩家即使长时间无操作也保持在线（修炼仍在推进并推送），只在断开连接后停止
+		if state.connected {
+			ctx.SetReceiveTimeout(common.PlayerActorPassivateMinutes * time.Minute)
+			return
+		}
+		log.Printf("Player %s idle, passivating", state.PlayerID)
+		a.passivate(ctx)
+	case *actor.Stopping:
+		// 停服或重启次数超限时直接停止，同样先移出在线索引；
+		// 等待 Persist 确认写入，之后重新激活时加载的是本次保存的存档
+		s.markPlayerStopping(state.PlayerID, ctx.Self(), false)
+		if err := s.savePlayerDataConfirmed(state); err != nil {
+			log.Printf("Failed to save player data for %s: %v", state.PlayerID, err)
+		}
+	case *msgLogin:
+		if state.passivating {
+			ctx.Respond(&msgReply{err: errPlayerStopping})
+			return
+		}
+		state.connected = true
+		state.LastActive = msg.at
+		s.recordLogin(state, msg.at)
+		ctx.Respond(&msgReply{})
+	case *msgEvaluateAchievements:
+		s.evaluateAchievements(state, time.Now())
+	case *msgSectEvent:
+		s.handleSectEvent(state, msg.event)
+	case *msgDisconnect:
+		a.passivate(ctx)
+	case *msgSequenceTick:
+		if state.Sequence != nil {
+			result, update := s.advanceSequence(state, msg.elapsed, msg.now)
+			if result != nil {
+				s.pushToClient(state.PlayerID, result)
+			}
+			if update != nil {
+				s.pushToClient(state.PlayerID, update)
+			}
+		}
+		if results := s.advanceCrafting(state, msg.elapsed); results != nil {
+			s.pushToClient(state.PlayerID, s.deliverCrafting(state, results, "progress", msg.now))
+		}
+	case *msgGetState:
+		state.LastActive = time.Now()
+		ctx.Respond(&msgReply{result: s.getState(state)})
+	case *msgGameAction:
+		state.LastActive = time.Now()
+		result, err := s.applyGameAction(state, msg.action, msg.params)
+		ctx.Respond(&msgReply{result: result, err: err})
+	case *msgStartSequence:
+		result, err := s.startSequence(state, msg.sequenceID)
+		ctx.Respond(&msgReply{result: result, err: err})
+	case *msgStopSequence:
+		result, err := s.stopSequence(state)
+		ctx.Respond(&msgReply{result: result, err: err})
+	case *msgInventoryAction:
+		result, err := s.applyInventoryAction(state, msg.action, msg.slot, msg.count)
+		if err == nil {
+			s.pushToClient(state.PlayerID, result)
+		}
+		ctx.Respond(&msgReply{result: result, err: err})
+	case *msgAdminGrant:
+		result, err := s.applyAdminGrant(state, msg.params)
+		ctx.Respond(&msgReply{result: result, err: err})
+	case *msgSetAptitude:
+		// 确认写入后 Persist 的缓存也更新为新资质，之后的保存与重新激活不会恢复旧资质
+		state.Aptitude = msg.aptitude
+		ctx.Respond(&msgReply{err: s.savePlayerDataConfirmed(state)})
+	case *msgCharge:
+		result, err := s.applyCharge(state, msg.charge, time.Now())
+		ctx.Respond(&msgReply{result: result, err: err})
+	case *msgEquipAction:
+		result, err := s.applyEquipAction(state, msg.action, msg.slot, msg.equipSlot)
+		ctx.Respond(&msgReply{result: result, err: err})
+	}
+}
+
+// ============ 玩家激活与索引 ============
+
+// passivate 钝化玩家 Actor：先移出在线索引，再以 PoisonPill 停止，
+// 已投递的消息（包括并发的登录）排在 PoisonPill 之前，仍会得到处理或回复
+func (a *PlayerActor) passivate(ctx actor.Context) {
+	if a.state.passivating {
+		return
+	}
+	a.state.passivating = true
+	a.state.connected = false
+	a.service.markPlayerStopping(a.state.PlayerID, ctx.Self(), true)
+}
+
+// activatePlayer 获取在线玩家的 PID，不在线时从 Persist 加载数据、结算离线收益并创建 Actor；
+// 旧 Actor 仍在钝化保存时等待其停止，再加载本次保存的存档
+func (s *Service) activatePlayer(playerID string) (*actor.PID, error) {
+	deadline := time.After(common.PlayerActorRequestTimeout * time.Second)
+	for {
+		pid, err := s.loadAndSpawnPlayer(playerID)
+		if !errors.Is(err, errPlayerStopping) {
+			return pid, err
+		}
+		done, ok := s.stoppingPlayer(playerID)
+		if !ok {
+			continue
+		}
+		select {
+		case <-done:
+		case <-deadline:
+			return nil, fmt.Errorf("player %s is still being saved: %w", playerID, err)
+		}
+	}
+}
+
+// loadAndSpawnPlayer 单次激活：加载存档、结算离线收益并请求 GameManagerActor 创建 Actor
+func (s *Service) loadAndSpawnPlayer(playerID string) (*actor.PID, error) {
+	if pid, ok := s.lookupPlayer(playerID); ok {
+		return pid, nil
+	}
+	if _, ok := s.stoppingPlayer(playerID); ok {
+		return nil, errPlayerStopping
+	}
+
+	playerData, err := s.loadPlayerData(playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	state := newPlayerState(playerID, now)
+	state.rng = rand.New(rand.NewSource(now.UnixNano()))
+	if err := s.applyPlayerData(state, playerData); err != nil {
+		return nil, err
+	}
+	progress, err := s.loadProgress(playerID)
+	if err != nil {
+		return nil, err
+	}
+	state.Progress.load(progress)
+	// 宗门增益参与离线收益结算，加载失败时不影响上线
+	if state.Sect, err = s.loadSectMembership(playerID); err != nil {
+		log.Printf("Failed to load sect membership for %s: %v", playerID, err)
+	}
+	// 离线收益在 Actor 创建前结算；若并发激活时玩家已在线，本次结算结果直接丢弃
+	state.pendingReport = s.settleOffline(state, playerData.LastSaveTime, now)
+	state.pendingCrafting = s.settleOfflineCrafting(state, playerData.LastSaveTime, now)
+
+	result, err := s.actorSystem.Root.RequestFuture(s.manager, &msgSpawnPlayer{state: state}, common.PlayerActorRequestTimeout*time.Second).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to spawn player actor: %w", err)
+	}
+	spawned := result.(*msgSpawnResult)
+	if spawned.err != nil {
+		return nil, spawned.err
+	}
+	if spawned.created {
+		log.Printf("Player actor %s activated", playerID)
+	}
+	return spawned.pid, nil
+}
+
+// askPlayer 向玩家 Actor 发送请求并等待回复，玩家不在线时先激活；
+// 请求期间 Actor 开始钝化时重新激活后重试
+func (s *Service) askPlayer(playerID string, msg interface{}) (interface{}, error) {
+	for {
+		if _, err := s.activatePlayer(playerID); err != nil {
+			return nil, fmt.Errorf("player %s not available: %w", playerID, err)
+		}
+
+		future, ok := s.requestPlayer(playerID, msg)
+		if !ok {
+			continue
+		}
+		result, err := future.Result()
+		if err != nil {
+			return nil, fmt.Errorf("player %s did not respond: %w", playerID, err)
+		}
+		reply, ok := result.(*msgReply)
+		if !ok {
+			return nil, fmt.Errorf("unexpected reply from player %s", playerID)
+		}
+		if errors.Is(reply.err, errPlayerStopping) {
+			continue
+		}
+		return reply.result, reply.err
+	}
+}
+
+// requestPlayer 在索引读锁内向在线玩家发送请求，请求总是排在钝化的 PoisonPill 之前
+func (s *Service) requestPlayer(playerID string, msg interface{}) (actor.Future, bool) {
+	s.playersMutex.RLock()
+	defer s.playersMutex.RUnlock()
+	pid, ok := s.players[playerID]
+	if !ok {
+		return nil, false
+	}
+	return s.actorSystem.Root.RequestFuture(pid, msg, common.PlayerActorRequestTimeout*time.Second), true
+}
+
+// sendPlayer 在索引读锁内向在线玩家发送消息，玩家不在线时返回 false
+func (s *Service) sendPlayer(playerID string, msg interface{}) bool {
+	s.playersMutex.RLock()
+	defer s.playersMutex.RUnlock()
+	pid, ok := s.players[playerID]
+	if ok {
+		s.actorSystem.Root.Send(pid, msg)
+	}
+	return ok
+}
+
+// lookupPlayer 查找在线玩家的 PID
+func (s *Service) lookupPlayer(playerID string) (*actor.PID, bool) {
+	s.playersMutex.RLock()
+	defer s.playersMutex.RUnlock()
+	pid, ok := s.players[playerID]
+	return pid, ok
+}
+
+// onlinePlayers 所有在线玩家的 PID
+func (s *Service) onlinePlayers() []*actor.PID {
+	s.playersMutex.RLock()
+	defer s.playersMutex.RUnlock()
+	pids := make([]*actor.PID, 0, len(s.players))
+	for _, pid := range s.players {
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+// registerPlayer 记录在线玩家的 PID，仅由 GameManagerActor 调用
+func (s *Service) registerPlayer(playerID string, pid *actor.PID) {
+	s.playersMutex.Lock()
+	defer s.playersMutex.Unlock()
+	s.players[playerID] = pid
+}
+
+// markPlayerStopping 将玩家 Actor 移出在线索引并登记为钝化中，poison 为 true 时在写锁内投递 PoisonPill，
+// 之后不会再有请求经由索引发往该 Actor
+func (s *Service) markPlayerStopping(playerID string, pid *actor.PID, poison bool) {
+	s.playersMutex.Lock()
+	defer s.playersMutex.Unlock()
+	if current, ok := s.players[playerID]; ok && current.Equal(pid) {
+		delete(s.players, playerID)
+		s.stopping[playerID] = make(chan struct{})
+	}
+	if poison {
+		s.actorSystem.Root.Poison(pid)
+	}
+}
+
+// stoppingPlayer 正在钝化的玩家，返回的通道在其 Actor 停止后关闭
+func (s *Service) stoppingPlayer(playerID string) (<-chan struct{}, bool) {
+	s.playersMutex.RLock()
+	defer s.playersMutex.RUnlock()
+	done, ok := s.stopping[playerID]
+	return done, ok
+}
+
+// unregisterPlayer 移除已停止的玩家 Actor 并唤醒等待其保存的激活，仅由 GameManagerActor 调用
+func (s *Service) unregisterPlayer(playerID string, pid *actor.PID) {
+	s.playersMutex.Lock()
+	defer s.playersMutex.Unlock()
+	if current, ok := s.players[playerID]; ok && current.Equal(pid) {
+		delete(s.players, playerID)
+	}
+	if done, ok := s.stopping[playerID]; ok {
+		close(done)
+		delete(s.stopping, playerID)
+	}
+}
+
+// stopAllPlayers 停止所有玩家 Actor 并等待其保存数据
+func (s *Service) stopAllPlayers() {
+	if s.actorSystem == nil {
+		return
+	}
+
+	for _, pid := range s.onlinePlayers() {
+		if err := s.actorSystem.Root.PoisonFuture(pid).Wait(); err != nil {
+			log.Printf("Failed to stop player actor %s: %v", pid.Id, err)
+		}
+	}
+	s.actorSystem.Root.Stop(s.manager)
+
+	log.Printf("All player data saved during shutdown")
+}
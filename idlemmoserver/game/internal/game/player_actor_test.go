@@ -0,0 +1,182 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asynkron/protoactor-go/actor"
+	"github.com/idle-server/common"
+)
+
+// persistBus 并发安全的 Persist 模拟：保存的存档在下次加载时返回，可令存档写入阻塞直到放行
+type persistBus struct {
+	mu     sync.Mutex
+	saved  map[string]interface{}
+	block  chan struct{} // 非空时下一次确认写入在此等待
+	saving chan struct{} // 确认写入开始阻塞时发出信号
+}
+
+func newPersistBus(playerID string) *persistBus {
+	return &persistBus{saved: map[string]interface{}{"player_id": playerID}, saving: make(chan struct{}, 1)}
+}
+
+func (b *persistBus) Publish(subject string, msg interface{}) error {
+	if subject == common.PersistSavePlayerSubject {
+		b.store(msg)
+	}
+	return nil
+}
+
+func (b *persistBus) RequestWithReply(subject string, request interface{}, response interface{}, timeout time.Duration) error {
+	var reply interface{}
+	switch subject {
+	case common.PersistLoadPlayerSubject:
+		b.mu.Lock()
+		reply = map[string]interface{}{"success": true, "data": map[string]interface{}{"data": b.saved}}
+		b.mu.Unlock()
+	case common.PersistLoadProgressSubject:
+		reply = map[string]interface{}{"success": true, "data": map[string]interface{}{"entries": []interface{}{}}}
+	case common.PersistSavePlayerSubject:
+		b.mu.Lock()
+		block := b.block
+		b.block = nil
+		b.mu.Unlock()
+		if block != nil {
+			b.saving <- struct{}{}
+			<-block
+		}
+		b.store(request)
+		reply = map[string]interface{}{"success": true}
+	default:
+		return errors.New("nats: timeout")
+	}
+
+	encoded, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, response)
+}
+
+// store 记录保存请求中的玩家数据
+func (b *persistBus) store(request interface{}) {
+	encoded, _ := json.Marshal(request)
+	var req map[string]interface{}
+	_ = json.Unmarshal(encoded, &req)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.saved = req["data"].(map[string]interface{})
+}
+
+// blockNextSave 令下一次确认写入阻塞，关闭返回的通道后放行
+func (b *persistBus) blockNextSave() chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.block = make(chan struct{})
+	return b.block
+}
+
+func newActorTestService(t *testing.T, bus messageBus) *Service {
+	t.Helper()
+	loadTestContent(t)
+	s := &Service{
+		bus:         bus,
+		players:     make(map[string]*actor.PID),
+		stopping:    make(map[string]chan struct{}),
+		actorSystem: actor.NewActorSystem(),
+	}
+	s.manager = s.actorSystem.Root.Spawn(newGameManagerProps(s))
+	t.Cleanup(s.stopAllPlayers)
+	return s
+}
+
+// playerGold 在线玩家当前的金币
+func playerGold(t *testing.T, s *Service, playerID string) int64 {
+	t.Helper()
+	result, err := s.handleGetState(playerID)
+	if err != nil {
+		t.Fatalf("get state: %v", err)
+	}
+	return result.(map[string]interface{})["resources"].(map[string]int64)["gold"]
+}
+
+func TestReconnectDuringPassivation(t *testing.T) {
+	bus := newPersistBus("p1")
+	s := newActorTestService(t, bus)
+
+	if err := s.handlePlayerConnect("p1"); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err := s.handleAdminGrant("p1", map[string]interface{}{"resource": "gold", "amount": float64(25)}); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	gold := playerGold(t, s, "p1")
+
+	// 断开后 Actor 在保存中阻塞，此时玩家重新连接
+	release := bus.blockNextSave()
+	if err := s.handlePlayerDisconnect("p1"); err != nil {
+		t.Fatalf("disconnect: %v", err)
+	}
+	<-bus.saving
+	reconnected := make(chan error, 1)
+	go func() { reconnected <- s.handlePlayerConnect("p1") }()
+
+	select {
+	case err := <-reconnected:
+		t.Fatalf("reconnected before the passivation save was confirmed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if err := <-reconnected; err != nil {
+		t.Fatalf("reconnect: %v", err)
+	}
+
+	// 重新激活的 Actor 加载的是钝化时保存的存档
+	if got := playerGold(t, s, "p1"); got != gold {
+		t.Errorf("gold after reconnect = %d, want %d", got, gold)
+	}
+
+	// 重新连接的玩家处于连接状态，空闲超时不会将其钝化
+	pid, ok := s.lookupPlayer("p1")
+	if !ok {
+		t.Fatalf("player not online after reconnect")
+	}
+	s.actorSystem.Root.Send(pid, &actor.ReceiveTimeout{})
+	if _, err := s.actorSystem.Root.RequestFuture(pid, &msgGetState{}, common.PlayerActorRequestTimeout*time.Second).Result(); err != nil {
+		t.Fatalf("get state: %v", err)
+	}
+	if current, ok := s.lookupPlayer("p1"); !ok || !current.Equal(pid) {
+		t.Errorf("reconnected player was passivated while connected")
+	}
+}
+
+func TestAptitudeUpdateReachesOnlineActor(t *testing.T) {
+	bus := newPersistBus("p1")
+	s := newActorTestService(t, bus)
+
+	if err := s.handlePlayerConnect("p1"); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	rerolled := &common.Aptitude{Grade: "heaven"}
+	if err := s.handlePlayerAptitude("p1", rerolled); err != nil {
+		t.Fatalf("update aptitude: %v", err)
+	}
+
+	state, err := s.handleGetState("p1")
+	if err != nil {
+		t.Fatalf("get state: %v", err)
+	}
+	if got := state.(map[string]interface{})["aptitude"].(*common.Aptitude); got.Grade != "heaven" {
+		t.Errorf("actor aptitude = %+v, want the rerolled aptitude", got)
+	}
+	// 更新后已确认保存，之后加载（包括 Persist 缓存）得到的是新资质
+	bus.mu.Lock()
+	saved, _ := bus.saved["aptitude"].(map[string]interface{})
+	bus.mu.Unlock()
+	if saved["grade"] != "heaven" {
+		t.Errorf("saved aptitude = %v, want the rerolled aptitude", saved)
+	}
+}
@@ -0,0 +1,146 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 玩家进度（game_progress 表） ============
+// 成就解锁记录、累计计数等按 类型/键 保存为独立的 JSON 记录，玩家激活时整体加载，
+// 保存玩家数据时只写回变化的记录；加载失败时不激活玩家，避免重复解锁和发放奖励
+
+// progressKey 进度记录的类型与键
+type progressKey struct {
+	Type string
+	Key  string
+}
+
+// ProgressStore 玩家的进度记录，仅由所属的 PlayerActor 访问
+type ProgressStore struct {
+	values map[progressKey]json.RawMessage
+	dirty  map[progressKey]bool
+}
+
+// newProgressStore 创建空的进度记录
+func newProgressStore() *ProgressStore {
+	return &ProgressStore{
+		values: make(map[progressKey]json.RawMessage),
+		dirty:  make(map[progressKey]bool),
+	}
+}
+
+// load 载入 Persist 返回的进度记录
+func (p *ProgressStore) load(entries []common.ProgressEntry) {
+	for _, entry := range entries {
+		p.values[progressKey{entry.Type, entry.Key}] = entry.Value
+	}
+}
+
+// Has 记录是否存在
+func (p *ProgressStore) Has(progressType, key string) bool {
+	_, ok := p.values[progressKey{progressType, key}]
+	return ok
+}
+
+// Get 解析记录到 out，记录不存在或格式错误时返回 false
+func (p *ProgressStore) Get(progressType, key string, out interface{}) bool {
+	raw, ok := p.values[progressKey{progressType, key}]
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(raw, out) == nil
+}
+
+// Set 写入记录并标记为待保存
+func (p *ProgressStore) Set(progressType, key string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode progress %s/%s: %w", progressType, key, err)
+	}
+	k := progressKey{progressType, key}
+	p.values[k] = encoded
+	p.dirty[k] = true
+	return nil
+}
+
+// Int 读取计数记录，不存在时为 0
+func (p *ProgressStore) Int(progressType, key string) int64 {
+	var value int64
+	p.Get(progressType, key, &value)
+	return value
+}
+
+// Add 累加计数记录，返回累加后的值
+func (p *ProgressStore) Add(progressType, key string, delta int64) int64 {
+	value := p.Int(progressType, key) + delta
+	if err := p.Set(progressType, key, value); err != nil {
+		log.Printf("Failed to update progress %s/%s: %v", progressType, key, err)
+	}
+	return value
+}
+
+// takeDirty 取出待保存的记录并清除标记
+func (p *ProgressStore) takeDirty() []common.ProgressEntry {
+	if len(p.dirty) == 0 {
+		return nil
+	}
+	entries := make([]common.ProgressEntry, 0, len(p.dirty))
+	for k := range p.dirty {
+		entries = append(entries, common.ProgressEntry{Type: k.Type, Key: k.Key, Value: p.values[k]})
+	}
+	p.dirty = make(map[progressKey]bool)
+	return entries
+}
+
+// markDirty 保存失败时重新标记记录，下次保存时重试
+func (p *ProgressStore) markDirty(entries []common.ProgressEntry) {
+	for _, entry := range entries {
+		p.dirty[progressKey{entry.Type, entry.Key}] = true
+	}
+}
+
+// loadProgress 从 Persist 加载玩家的全部进度记录
+func (s *Service) loadProgress(playerID string) ([]common.ProgressEntry, error) {
+	req := map[string]interface{}{
+		"type":      "C_LoadProgress",
+		"player_id": playerID,
+	}
+
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Entries []common.ProgressEntry `json:"entries"`
+		} `json:"data"`
+	}
+	if err := s.bus.RequestWithReply(common.PersistLoadProgressSubject, req, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to load progress: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to load progress: %s", result.Error)
+	}
+	return result.Data.Entries, nil
+}
+
+// saveProgress 保存变化的进度记录，仅在玩家 Actor 内调用
+func (s *Service) saveProgress(playerState *PlayerState) error {
+	entries := playerState.Progress.takeDirty()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	req := map[string]interface{}{
+		"type":      "C_SaveProgress",
+		"player_id": playerState.PlayerID,
+		"entries":   entries,
+	}
+	if err := s.bus.Publish(common.PersistSaveProgressSubject, req); err != nil {
+		playerState.Progress.markDirty(entries)
+		return fmt.Errorf("failed to publish progress: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 角色成长（仅在玩家 Actor 内调用） ============
+// experience 记录当前等级内的经验，累积到等级曲线要求时扣除并升级；达到曲线最高等级后不再累积
+
+// eventHandler 游戏事件处理函数，在触发事件的玩家 Actor 内同步执行
+type eventHandler func(playerState *PlayerState, event common.GameEvent)
+
+// registerEventHandlers 注册游戏事件处理函数
+func (s *Service) registerEventHandlers() {
+	s.eventHandlers = map[string][]eventHandler{
+		common.GameEventLevelUp:         {s.onLevelUp, s.onAchievementEvent},
+		common.GameEventRealmUp:         {s.onAchievementEvent},
+		common.GameEventItemGathered:    {s.onItemGathered, s.onAchievementEvent, s.onQuestEvent},
+		common.GameEventSequenceLevelUp: {s.onAchievementEvent},
+		common.GameEventLogin:           {s.onAchievementEvent},
+		common.GameEventSequenceRounds:  {s.onQuestEvent},
+		common.GameEventItemUsed:        {s.onQuestEvent},
+		common.GameEventItemCrafted:     {s.onQuestEvent},
+	}
+}
+
+// fireEvent 分发游戏事件
+func (s *Service) fireEvent(playerState *PlayerState, event common.GameEvent) {
+	for _, handle := range s.eventHandlers[event.Type] {
+		handle(playerState, event)
+	}
+}
+
+// grantExp 发放角色经验并处理升级，返回提升的等级数
+func (s *Service) grantExp(playerState *PlayerState, amount int64, source string) (int, error) {
+	if !common.ExpSources[source] {
+		return 0, fmt.Errorf("unknown exp source: %s", source)
+	}
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	content := common.CurrentContent()
+	level := playerState.Level
+	exp := playerState.Experience + amount
+
+	gained := 0
+	for {
+		expToNext, ok := content.ExpToNext(level)
+		if !ok {
+			// 最高等级：不再累积经验
+			exp = 0
+			break
+		}
+		if exp < expToNext {
+			break
+		}
+		exp -= expToNext
+		level++
+		gained++
+	}
+
+	previousLevel := playerState.Level
+	playerState.Level = level
+	playerState.Experience = exp
+
+	if gained > 0 {
+		log.Printf("Player %s leveled up %d -> %d (%s)", playerState.PlayerID, previousLevel, level, source)
+		s.fireEvent(playerState, common.GameEvent{
+			Type:   common.GameEventLevelUp,
+			Source: source,
+			Value:  int64(level),
+			Count:  int64(gained),
+		})
+		if realm := common.RealmForLevel(level); realm.ID != common.RealmForLevel(previousLevel).ID {
+			s.fireEvent(playerState, common.GameEvent{
+				Type:   common.GameEventRealmUp,
+				Source: source,
+				Key:    realm.ID,
+				Value:  int64(realm.Order),
+			})
+		}
+	}
+	return gained, nil
+}
+
+// onLevelUp 推送升级通知，并推送随等级变化的派生属性
+func (s *Service) onLevelUp(playerState *PlayerState, event common.GameEvent) {
+	level := int(event.Value)
+	previousLevel := level - int(event.Count)
+
+	expToNext, _ := common.CurrentContent().ExpToNext(level)
+	realm := common.RealmForLevel(level)
+	s.pushToClient(playerState.PlayerID, &common.S_LevelUp{
+		Type:          common.ServerMsgTypeLevelUp,
+		PreviousLevel: previousLevel,
+		Level:         level,
+		Exp:           playerState.Experience,
+		ExpToNext:     expToNext,
+		Realm:         realm.ID,
+		RealmChanged:  realm.ID != common.RealmForLevel(previousLevel).ID,
+		Source:        event.Source,
+	})
+	s.pushToClient(playerState.PlayerID, s.equipmentUpdate(playerState))
+}
+
+// changeCurrency 按登记的来源变动货币，余额不足时不做修改，返回变动后的余额
+// 体力等回复资源先结算自然回复，增加后超出持有上限的部分丢弃，变动后推送回复状态
+func (s *Service) changeCurrency(playerState *PlayerState, resource string, delta int64, source string) (int64, error) {
+	if !common.CurrencySources[source] {
+		return 0, fmt.Errorf("unknown currency source: %s", source)
+	}
+	if _, ok := common.CurrentContent().Defaults.Resources[resource]; !ok {
+		return 0, fmt.Errorf("unknown currency: %s", resource)
+	}
+
+	now := time.Now()
+	balance := settleStamina(playerState, resource, now) + delta
+	if balance < 0 {
+		return 0, fmt.Errorf("insufficient %s", resource)
+	}
+	stamina, isStamina := common.GetStaminaDef(resource)
+	if isStamina && delta > 0 && balance > stamina.Cap {
+		balance = max(stamina.Cap, playerState.Resources[resource])
+	}
+	playerState.Resources[resource] = balance
+
+	log.Printf("Player %s %s %+d (%s), balance %d", playerState.PlayerID, resource, delta, source, balance)
+	if isStamina {
+		// 从上限以下开始计时；消耗前已满时计时起点为当前时间
+		settleStamina(playerState, resource, now)
+		s.pushToClient(playerState.PlayerID, staminaUpdate(playerState, now))
+	}
+	return balance, nil
+}
+
+// grantRewards 发放配置表中的一组奖励，经验与货币按给定来源记录，物品放入背包并推送
+func (s *Service) grantRewards(playerState *PlayerState, rewards common.RewardBundle, expSource, currencySource, reason string) {
+	if rewards.Exp > 0 {
+		if _, err := s.grantExp(playerState, rewards.Exp, expSource); err != nil {
+			log.Printf("Failed to grant %s exp to %s: %v", reason, playerState.PlayerID, err)
+		}
+	}
+	for resource, amount := range rewards.Resources {
+		if _, err := s.changeCurrency(playerState, resource, amount, currencySource); err != nil {
+			log.Printf("Failed to grant %s %s to %s: %v", reason, resource, playerState.PlayerID, err)
+		}
+	}
+	if len(rewards.Items) > 0 {
+		s.pushToClient(playerState.PlayerID, s.grantItems(playerState, rewards.Items, reason))
+	}
+}
+
+// applyAdminGrant 执行管理员发放：经验、货币（可为负数表示扣除）和物品，任一项无效时不做任何修改
+func (s *Service) applyAdminGrant(playerState *PlayerState, params map[string]interface{}) (*common.MsgAdminGrantResult, error) {
+	exp, _ := params["exp"].(float64)
+	resource, _ := params["resource"].(string)
+	amount, _ := params["amount"].(float64)
+	itemID, _ := params["item_id"].(string)
+	count, _ := params["count"].(float64)
+
+	// 先校验全部参数，保证发放要么全部生效要么不生效
+	settleAllStamina(playerState, time.Now())
+	if exp < 0 {
+		return nil, fmt.Errorf("exp must not be negative")
+	}
+	if resource != "" {
+		if _, ok := common.CurrentContent().Defaults.Resources[resource]; !ok {
+			return nil, fmt.Errorf("unknown currency: %s", resource)
+		}
+		if playerState.Resources[resource]+int64(amount) < 0 {
+			return nil, fmt.Errorf("insufficient %s", resource)
+		}
+	}
+	if itemID != "" {
+		if _, ok := common.GetItemDef(itemID); !ok {
+			return nil, fmt.Errorf("unknown item: %s", itemID)
+		}
+		if count <= 0 {
+			return nil, fmt.Errorf("invalid count")
+		}
+	}
+	if exp == 0 && resource == "" && itemID == "" {
+		return nil, fmt.Errorf("nothing to grant")
+	}
+
+	playerState.LastActive = time.Now()
+	result := &common.MsgAdminGrantResult{Success: true, Message: "granted"}
+	if exp > 0 {
+		if _, err := s.grantExp(playerState, int64(exp), common.ExpSourceAdmin); err != nil {
+			return nil, err
+		}
+	}
+	if resource != "" {
+		balance, err := s.changeCurrency(playerState, resource, int64(amount), common.CurrencySourceAdmin)
+		if err != nil {
+			return nil, err
+		}
+		result.Balance = balance
+	}
+	if itemID != "" {
+		update := s.grantItems(playerState, map[string]int{itemID: int(count)}, "grant")
+		result.Overflow = update.Overflow
+		s.pushToClient(playerState.PlayerID, update)
+	}
+
+	result.Level = playerState.Level
+	result.Exp = playerState.Experience
+	return result, nil
+}
@@ -0,0 +1,209 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 每日/每周任务（仅在玩家 Actor 内调用） ============
+// 任务状态以 progress_type=quest 保存在 game_progress 表中，记录接取时所在的周期；
+// 记录的周期与当前周期不同时视为已重置（可重新接取），因此重置不需要定时任务
+
+// 任务相关的游戏动作
+const (
+	questActionList     = "quest_list"
+	questActionAccept   = "quest_accept"
+	questActionTrack    = "quest_track"
+	questActionComplete = "quest_complete"
+	questActionClaim    = "quest_claim"
+)
+
+// questRecord 玩家在某个周期内的任务状态
+type questRecord struct {
+	Period      string `json:"period"` // 周期开始日期（服务器时区）
+	Status      string `json:"status"`
+	Progress    int64  `json:"progress"`
+	Tracked     bool   `json:"tracked,omitempty"`
+	AcceptedAt  int64  `json:"accepted_at,omitempty"`
+	CompletedAt int64  `json:"completed_at,omitempty"`
+	ClaimedAt   int64  `json:"claimed_at,omitempty"`
+}
+
+// questEventObjectives 游戏事件对应的任务目标类型
+var questEventObjectives = map[string]string{
+	common.GameEventItemGathered:   common.QuestObjectiveItemGathered,
+	common.GameEventSequenceRounds: common.QuestObjectiveSequenceRounds,
+	common.GameEventItemUsed:       common.QuestObjectiveItemUsed,
+	common.GameEventItemCrafted:    common.QuestObjectiveItemCrafted,
+}
+
+// currentQuest 任务在当前周期的记录，上个周期的记录视为已重置
+func currentQuest(playerState *PlayerState, def common.QuestDef, now time.Time) (questRecord, time.Time) {
+	period, resetsAt := common.CurrentContent().Schedule.Period(def.Period, now)
+	var record questRecord
+	if !playerState.Progress.Get(common.ProgressTypeQuest, def.ID, &record) || record.Period != period {
+		record = questRecord{Period: period, Status: common.QuestStatusAvailable}
+	}
+	return record, resetsAt
+}
+
+// questStatus 生成任务状态
+func questStatus(def common.QuestDef, record questRecord, resetsAt time.Time) common.QuestStatus {
+	return common.QuestStatus{
+		ID:          def.ID,
+		Name:        def.Name,
+		Description: def.Description,
+		Period:      def.Period,
+		Status:      record.Status,
+		Progress:    record.Progress,
+		Target:      def.Objective.Target,
+		Tracked:     record.Tracked,
+		MinLevel:    def.MinLevel,
+		Rewards:     def.Rewards,
+		ResetsAt:    resetsAt.Unix(),
+	}
+}
+
+// questList 当前周期的全部任务，按周期和ID排序
+func questList(playerState *PlayerState, now time.Time) *common.S_QuestList {
+	content := common.CurrentContent()
+	list := &common.S_QuestList{
+		Type:   common.ServerMsgTypeQuestList,
+		Quests: make([]common.QuestStatus, 0, len(content.Quests)),
+	}
+	_, dailyReset := content.Schedule.Period(common.QuestPeriodDaily, now)
+	_, weeklyReset := content.Schedule.Period(common.QuestPeriodWeekly, now)
+	list.DailyResetAt = dailyReset.Unix()
+	list.WeeklyResetAt = weeklyReset.Unix()
+
+	for _, def := range content.Quests {
+		record, resetsAt := currentQuest(playerState, def, now)
+		list.Quests = append(list.Quests, questStatus(def, record, resetsAt))
+	}
+	sort.Slice(list.Quests, func(i, j int) bool {
+		a, b := list.Quests[i], list.Quests[j]
+		if a.Period != b.Period {
+			return a.Period == common.QuestPeriodDaily
+		}
+		return a.ID < b.ID
+	})
+	return list
+}
+
+// applyQuestAction 执行任务动作，返回变化后的任务状态（quest_list 返回全部任务）
+func (s *Service) applyQuestAction(playerState *PlayerState, action string, params map[string]interface{}) (interface{}, error) {
+	now := time.Now()
+	if action == questActionList {
+		list := questList(playerState, now)
+		s.pushToClient(playerState.PlayerID, list)
+		return list, nil
+	}
+
+	questID, _ := params["quest_id"].(string)
+	def, ok := common.CurrentContent().Quests[questID]
+	if !ok {
+		return nil, fmt.Errorf("unknown quest: %s", questID)
+	}
+	record, resetsAt := currentQuest(playerState, def, now)
+
+	switch action {
+	case questActionAccept:
+		if record.Status != common.QuestStatusAvailable {
+			return nil, fmt.Errorf("quest %s already accepted this period", questID)
+		}
+		if playerState.Level < def.MinLevel {
+			return nil, fmt.Errorf("requires level %d", def.MinLevel)
+		}
+		record.Status = common.QuestStatusAccepted
+		record.AcceptedAt = now.Unix()
+	case questActionTrack:
+		tracked, _ := params["tracked"].(bool)
+		if record.Status != common.QuestStatusAccepted && record.Status != common.QuestStatusCompleted {
+			return nil, fmt.Errorf("quest %s is not in progress", questID)
+		}
+		if tracked && !record.Tracked && trackedQuests(playerState, now) >= common.QuestMaxTracked {
+			return nil, fmt.Errorf("at most %d quests can be tracked", common.QuestMaxTracked)
+		}
+		record.Tracked = tracked
+	case questActionComplete:
+		if record.Status != common.QuestStatusAccepted {
+			return nil, fmt.Errorf("quest %s is not in progress", questID)
+		}
+		if record.Progress < def.Objective.Target {
+			return nil, fmt.Errorf("quest %s objective not met", questID)
+		}
+		record.Status = common.QuestStatusCompleted
+		record.CompletedAt = now.Unix()
+	case questActionClaim:
+		if record.Status != common.QuestStatusCompleted {
+			return nil, fmt.Errorf("quest %s is not completed", questID)
+		}
+		// 先记录领取再发放，奖励只发放一次
+		record.Status = common.QuestStatusClaimed
+		record.ClaimedAt = now.Unix()
+		record.Tracked = false
+	default:
+		return nil, fmt.Errorf("unknown quest action: %s", action)
+	}
+
+	playerState.LastActive = now
+	if err := playerState.Progress.Set(common.ProgressTypeQuest, questID, record); err != nil {
+		return nil, err
+	}
+	if action == questActionClaim {
+		log.Printf("Player %s claimed quest %s (%s)", playerState.PlayerID, questID, record.Period)
+		s.grantRewards(playerState, def.Rewards, common.ExpSourceQuest, common.CurrencySourceQuest, "quest")
+	}
+
+	update := &common.S_QuestUpdate{Type: common.ServerMsgTypeQuestUpdate, Quest: questStatus(def, record, resetsAt)}
+	s.pushToClient(playerState.PlayerID, update)
+	return update, nil
+}
+
+// trackedQuests 当前周期正在追踪的任务数
+func trackedQuests(playerState *PlayerState, now time.Time) int {
+	count := 0
+	for _, def := range common.CurrentContent().Quests {
+		if record, _ := currentQuest(playerState, def, now); record.Tracked {
+			count++
+		}
+	}
+	return count
+}
+
+// onQuestEvent 推进已接取任务的目标，达到目标时自动完成（奖励仍需领取）
+func (s *Service) onQuestEvent(playerState *PlayerState, event common.GameEvent) {
+	objective, ok := questEventObjectives[event.Type]
+	if !ok || event.Count <= 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, def := range common.CurrentContent().Quests {
+		if def.Objective.Type != objective || (def.Objective.Key != "" && def.Objective.Key != event.Key) {
+			continue
+		}
+		record, resetsAt := currentQuest(playerState, def, now)
+		if record.Status != common.QuestStatusAccepted {
+			continue
+		}
+
+		record.Progress = min(record.Progress+event.Count, def.Objective.Target)
+		if record.Progress >= def.Objective.Target {
+			record.Status = common.QuestStatusCompleted
+			record.CompletedAt = now.Unix()
+		}
+		if err := playerState.Progress.Set(common.ProgressTypeQuest, def.ID, record); err != nil {
+			log.Printf("Failed to update quest %s for %s: %v", def.ID, playerState.PlayerID, err)
+			continue
+		}
+		s.pushToClient(playerState.PlayerID, &common.S_QuestUpdate{
+			Type:  common.ServerMsgTypeQuestUpdate,
+			Quest: questStatus(def, record, resetsAt),
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 玩家存档 ============
+// 存档以带版本号的 PlayerSave 保存在 players.game_data 中。加载时按 saveMigrations 逐版本
+// 升级原始 JSON 文档，再解析为强类型结构；修改 PlayerSave 的结构时递增 currentSaveVersion
+// 并登记从上一版本升级的迁移函数（附测试），旧存档即可继续加载
+
+// currentSaveVersion 当前存档结构版本
+const currentSaveVersion = 7
+
+// PlayerSave 玩家存档（当前版本）
+type PlayerSave struct {
+	SchemaVersion  int                          `json:"schema_version"`
+	Level          int                          `json:"level"`
+	Experience     int64                        `json:"experience"` // 当前等级内的经验
+	Resources      map[string]int64             `json:"resources"`
+	Sequences      map[string]*SequenceProgress `json:"sequences,omitempty"`
+	ActiveSequence *SequenceState               `json:"active_sequence,omitempty"`
+	Buffs          []common.Buff                `json:"buffs,omitempty"`
+	Inventory      *Inventory                   `json:"inventory,omitempty"`
+	Equipment      map[string]string            `json:"equipment,omitempty"`
+	Crafting       *CraftingState               `json:"crafting,omitempty"`
+	StaminaRegen   map[string]int64             `json:"stamina_regen,omitempty"` // 回复资源 -> 回复计时起点（Unix 秒）
+	Shop           *ShopState                   `json:"shop,omitempty"`
+	Market         *MarketState                 `json:"market,omitempty"`
+	SectOps        *SectState                   `json:"sect_ops,omitempty"`
+	Charges        []ChargeRecord               `json:"charges,omitempty"`
+}
+
+// saveMigration 将存档文档从 From 版本升级到 From+1 版本
+type saveMigration struct {
+	From    int
+	Migrate func(doc map[string]interface{}) error
+}
+
+// saveMigrations 已登记的存档迁移，按 From 升序，必须覆盖 0 到 currentSaveVersion-1 的每个版本
+var saveMigrations = []saveMigration{
+	{From: 0, Migrate: migrateSaveV0},
+	{From: 1, Migrate: migrateSaveV1},
+	{From: 2, Migrate: migrateSaveV2},
+	{From: 3, Migrate: migrateSaveV3},
+	{From: 4, Migrate: migrateSaveV4},
+	{From: 5, Migrate: migrateSaveV5},
+	{From: 6, Migrate: migrateSaveV6},
+}
+
+// decodeSave 将加载的存档文档升级到当前版本并解析，空文档视为新角色
+func decodeSave(doc map[string]interface{}) (*PlayerSave, error) {
+	if len(doc) == 0 {
+		return nil, nil
+	}
+	if err := migrateSave(doc, saveMigrations, currentSaveVersion); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode save: %w", err)
+	}
+	var save PlayerSave
+	if err := json.Unmarshal(encoded, &save); err != nil {
+		return nil, fmt.Errorf("failed to decode save: %w", err)
+	}
+	return &save, nil
+}
+
+// migrateSave 按顺序执行迁移，把文档升级到 target 版本；版本高于 target 或缺少迁移时返回错误
+func migrateSave(doc map[string]interface{}, migrations []saveMigration, target int) error {
+	version := 0
+	if raw, ok := doc["schema_version"]; ok {
+		v, ok := raw.(float64)
+		if !ok || v != math.Trunc(v) || v < 0 {
+			return fmt.Errorf("invalid schema_version: %v", raw)
+		}
+		version = int(v)
+	}
+	if version > target {
+		return fmt.Errorf("save version %d is newer than supported version %d", version, target)
+	}
+
+	byVersion := make(map[int]saveMigration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.From] = migration
+	}
+
+	for ; version < target; version++ {
+		migration, ok := byVersion[version]
+		if !ok {
+			return fmt.Errorf("no save migration from version %d", version)
+		}
+		if err := migration.Migrate(doc); err != nil {
+			return fmt.Errorf("save migration from version %d failed: %w", version, err)
+		}
+		doc["schema_version"] = float64(version + 1)
+	}
+	return nil
+}
+
+// migrateSaveV0 无版本号的旧存档（游戏数据为任意键值）升级到版本 1：
+//   - resources 中的小数取整
+//   - 早期以物品计数字典保存的 inventory 按堆叠上限放入格子（放不下或已删除的物品丢弃）
+//   - 删除不再保存的 aptitude（以 players.aptitude 为准）、last_save、achievements
+func migrateSaveV0(doc map[string]interface{}) error {
+	if resources, ok := doc["resources"].(map[string]interface{}); ok {
+		for resource, value := range resources {
+			amount, ok := value.(float64)
+			if !ok {
+				return fmt.Errorf("resource %s is not a number", resource)
+			}
+			resources[resource] = math.Floor(amount)
+		}
+	}
+
+	for _, key := range []string{"level", "experience"} {
+		if value, ok := doc[key].(float64); ok {
+			doc[key] = math.Floor(value)
+		}
+	}
+
+	if raw, ok := doc["inventory"].(map[string]interface{}); ok {
+		if _, slotted := raw["slots"]; !slotted {
+			inventory := NewInventory(common.DefaultInventorySize)
+			itemIDs := make([]string, 0, len(raw))
+			for itemID := range raw {
+				itemIDs = append(itemIDs, itemID)
+			}
+			sort.Strings(itemIDs)
+			for _, itemID := range itemIDs {
+				count, _ := raw[itemID].(float64)
+				if _, rest, err := inventory.Add(itemID, int(count)); err != nil || rest > 0 {
+					log.Printf("Save migration: dropped %s from legacy inventory", itemID)
+				}
+			}
+			inventory.Sort()
+			doc["inventory"] = inventory
+		}
+	}
+
+	delete(doc, "aptitude")
+	delete(doc, "last_save")
+	delete(doc, "achievements")
+	return nil
+}
+
+// migrateSaveV1 版本 1 升级到版本 2：新增制作队列与生产技能（crafting），旧存档没有该字段，
+// 加载后为空队列、技能均为1级，文档无需转换
+func migrateSaveV1(doc map[string]interface{}) error {
+	return nil
+}
+
+// migrateSaveV2 版本 2 升级到版本 3：新增回复资源的计时起点（stamina_regen），旧存档没有该字段，
+// 体力数值保留，加载后从当前时间开始计时，文档无需转换
+func migrateSaveV2(doc map[string]interface{}) error {
+	return nil
+}
+
+// migrateSaveV3 版本 3 升级到版本 4：新增商店限购次数与最近的购买（shop），旧存档没有购买记录，
+// 加载后为空，文档无需转换
+func migrateSaveV3(doc map[string]interface{}) error {
+	return nil
+}
+
+// migrateSaveV4 版本 4 升级到版本 5：新增待确认的市场操作（market），旧存档没有托管中的操作，
+// 加载后为空，文档无需转换
+func migrateSaveV4(doc map[string]interface{}) error {
+	return nil
+}
+
+// migrateSaveV5 版本 5 升级到版本 6：新增待确认的宗门操作（sect_ops），旧存档没有托管中的操作，
+// 加载后为空，文档无需转换
+func migrateSaveV5(doc map[string]interface{}) error {
+	return nil
+}
+
+// migrateSaveV6 版本 6 升级到版本 7：新增其他服务最近的代扣（charges），旧存档没有代扣记录，
+// 加载后为空，文档无需转换
+func migrateSaveV6(doc map[string]interface{}) error {
+	return nil
+}
+
+// ============ 存档与玩家状态的转换 ============
+
+// newPlayerState 按配置表的初始数据创建玩家状态
+func newPlayerState(playerID string, now time.Time) *PlayerState {
+	defaults := common.CurrentContent().Defaults
+	resources := make(map[string]int64, len(defaults.Resources))
+	for resource, amount := range defaults.Resources {
+		resources[resource] = int64(amount)
+	}
+
+	return &PlayerState{
+		PlayerID:     playerID,
+		ConnectedAt:  now,
+		LastActive:   now,
+		Level:        max(defaults.Level, 1),
+		Resources:    resources,
+		Sequences:    make(map[string]*SequenceProgress),
+		Inventory:    NewInventory(common.DefaultInventorySize),
+		Equipment:    make(map[string]string),
+		Progress:     newProgressStore(),
+		Crafting:     newCraftingState(),
+		StaminaRegen: make(map[string]int64),
+		Shop:         newShopState(),
+		Market:       newMarketState(),
+		SectOps:      newSectState(),
+	}
+}
+
+// applySave 将存档恢复到玩家状态
+func applySave(playerState *PlayerState, save *PlayerSave) {
+	if save.Level > 0 {
+		playerState.Level = save.Level
+	}
+	playerState.Experience = save.Experience
+	for resource, amount := range save.Resources {
+		playerState.Resources[resource] = amount
+	}
+	if save.Sequences != nil {
+		playerState.Sequences = save.Sequences
+	}
+	if save.ActiveSequence != nil && save.ActiveSequence.SequenceID != "" {
+		playerState.Sequence = save.ActiveSequence
+	}
+	playerState.Buffs = save.Buffs
+	if save.Inventory != nil {
+		if save.Inventory.Capacity <= 0 {
+			save.Inventory.Capacity = common.DefaultInventorySize
+		}
+		save.Inventory.normalize()
+		playerState.Inventory = save.Inventory
+	}
+	if save.Equipment != nil {
+		playerState.Equipment = save.Equipment
+	}
+	if save.Crafting != nil {
+		playerState.Crafting = save.Crafting
+	}
+	if save.StaminaRegen != nil {
+		playerState.StaminaRegen = save.StaminaRegen
+	}
+	if save.Shop != nil {
+		playerState.Shop = save.Shop
+	}
+	if save.Market != nil {
+		playerState.Market = save.Market
+	}
+	if save.SectOps != nil {
+		playerState.SectOps = save.SectOps
+	}
+	playerState.Charges = save.Charges
+}
+
+// snapshotSave 生成当前版本的存档，只保留未过期的增益
+func snapshotSave(playerState *PlayerState, now time.Time) *PlayerSave {
+	return &PlayerSave{
+		SchemaVersion:  currentSaveVersion,
+		Level:          playerState.Level,
+		Experience:     playerState.Experience,
+		Resources:      playerState.Resources,
+		Sequences:      playerState.Sequences,
+		ActiveSequence: playerState.Sequence,
+		Buffs:          activeBuffs(playerState.Buffs, now),
+		Inventory:      playerState.Inventory,
+		Equipment:      playerState.Equipment,
+		Crafting:       playerState.Crafting,
+		StaminaRegen:   playerState.StaminaRegen,
+		Shop:           playerState.Shop,
+		Market:         playerState.Market,
+		SectOps:        playerState.SectOps,
+		Charges:        playerState.Charges,
+	}
+}
+
+// encodeSave 将存档转换为 PlayerData.GameData 使用的 JSON 对象
+func encodeSave(save *PlayerSave) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(save)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
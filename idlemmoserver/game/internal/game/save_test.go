@@ -0,0 +1,423 @@
+package game
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+var testNow = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func loadTestContent(t *testing.T) {
+	t.Helper()
+	content, err := common.LoadContent("../../data")
+	if err != nil {
+		t.Fatalf("load content: %v", err)
+	}
+	common.SetContent(content)
+}
+
+// parseSaveDoc 模拟从数据库读取的存档文档（数值均为 float64）
+func parseSaveDoc(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		t.Fatalf("parse save doc: %v", err)
+	}
+	return doc
+}
+
+func TestDecodeSaveEmpty(t *testing.T) {
+	save, err := decodeSave(nil)
+	if err != nil || save != nil {
+		t.Fatalf("decodeSave(nil) = %v, %v; want nil, nil", save, err)
+	}
+}
+
+func TestDecodeSaveLegacyCountInventory(t *testing.T) {
+	loadTestContent(t)
+	doc := parseSaveDoc(t, `{
+		"level": 5,
+		"experience": 120.7,
+		"resources": {"gold": 150.9, "gems": 3},
+		"inventory": {"iron_ore": 1200, "qi_pill": 2, "removed_item": 4},
+		"aptitude": {"spirit_roots": []},
+		"last_save": 1700000000,
+		"achievements": []
+	}`)
+
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+	if save.SchemaVersion != currentSaveVersion {
+		t.Errorf("SchemaVersion = %d, want %d", save.SchemaVersion, currentSaveVersion)
+	}
+	if save.Level != 5 || save.Experience != 120 {
+		t.Errorf("Level/Experience = %d/%d, want 5/120", save.Level, save.Experience)
+	}
+	if want := map[string]int64{"gold": 150, "gems": 3}; !reflect.DeepEqual(save.Resources, want) {
+		t.Errorf("Resources = %v, want %v", save.Resources, want)
+	}
+	if save.Inventory == nil {
+		t.Fatal("Inventory is nil")
+	}
+	if got := save.Inventory.Count("iron_ore"); got != 1200 {
+		t.Errorf("iron_ore count = %d, want 1200", got)
+	}
+	if got := save.Inventory.Count("qi_pill"); got != 2 {
+		t.Errorf("qi_pill count = %d, want 2", got)
+	}
+	if got := save.Inventory.Count("removed_item"); got != 0 {
+		t.Errorf("removed_item count = %d, want 0", got)
+	}
+	if save.Inventory.Capacity != common.DefaultInventorySize {
+		t.Errorf("Capacity = %d, want %d", save.Inventory.Capacity, common.DefaultInventorySize)
+	}
+	for _, key := range []string{"aptitude", "last_save", "achievements"} {
+		if _, ok := doc[key]; ok {
+			t.Errorf("legacy key %s was not removed", key)
+		}
+	}
+}
+
+func TestDecodeSaveUnversionedSlottedInventory(t *testing.T) {
+	loadTestContent(t)
+	doc := parseSaveDoc(t, `{
+		"level": 3,
+		"inventory": {"capacity": 40, "slots": [{"item_id": "spirit_herb", "count": 7}, null]},
+		"equipment": {"weapon": "iron_sword"}
+	}`)
+
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+	if save.Inventory == nil || save.Inventory.Capacity != 40 {
+		t.Fatalf("Inventory = %+v, want capacity 40", save.Inventory)
+	}
+	if got := save.Inventory.Count("spirit_herb"); got != 7 {
+		t.Errorf("spirit_herb count = %d, want 7", got)
+	}
+	if save.Equipment["weapon"] != "iron_sword" {
+		t.Errorf("Equipment = %v", save.Equipment)
+	}
+}
+
+func TestDecodeSaveInvalidResource(t *testing.T) {
+	doc := parseSaveDoc(t, `{"resources": {"gold": "lots"}}`)
+	if _, err := decodeSave(doc); err == nil {
+		t.Fatal("expected error for non-numeric resource")
+	}
+}
+
+func TestDecodeSaveCurrentVersionRoundTrip(t *testing.T) {
+	loadTestContent(t)
+	state := newPlayerState("p1", testNow)
+	state.Level = 12
+	state.Experience = 345
+	state.Resources["gold"] = 999
+	state.Sequences["meditation"] = &SequenceProgress{Level: 4, Exp: 10}
+	state.StaminaRegen["energy"] = testNow.Unix() - 90
+	state.Shop.recordPurchase(common.PurchaseRecord{RequestID: "req-1", ShopID: "general_store", OfferID: "qi_pill", Count: 2}, "2026-01-01", 2)
+	if _, _, err := state.Inventory.Add("qi_pill", 3); err != nil {
+		t.Fatalf("add item: %v", err)
+	}
+
+	doc, err := encodeSave(snapshotSave(state, testNow))
+	if err != nil {
+		t.Fatalf("encodeSave: %v", err)
+	}
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if restored.Level != 12 || restored.Experience != 345 || restored.Resources["gold"] != 999 {
+		t.Errorf("restored level/exp/gold = %d/%d/%d", restored.Level, restored.Experience, restored.Resources["gold"])
+	}
+	if restored.Resources["gems"] != state.Resources["gems"] {
+		t.Errorf("gems = %d, want %d", restored.Resources["gems"], state.Resources["gems"])
+	}
+	if progress := restored.Sequences["meditation"]; progress == nil || progress.Level != 4 || progress.Exp != 10 {
+		t.Errorf("meditation progress = %+v", progress)
+	}
+	if got := restored.Inventory.Count("qi_pill"); got != 3 {
+		t.Errorf("qi_pill count = %d, want 3", got)
+	}
+	if got := restored.StaminaRegen["energy"]; got != testNow.Unix()-90 {
+		t.Errorf("energy regen anchor = %d, want %d", got, testNow.Unix()-90)
+	}
+	if got := restored.Shop.purchased("general_store", "qi_pill", "2026-01-01"); got != 2 {
+		t.Errorf("qi_pill purchased = %d, want 2", got)
+	}
+	if len(restored.Shop.Recent) != 1 || restored.Shop.Recent[0].RequestID != "req-1" {
+		t.Errorf("recent purchases = %+v, want req-1", restored.Shop.Recent)
+	}
+}
+
+func TestDecodeSaveMarketPendingRoundTrip(t *testing.T) {
+	loadTestContent(t)
+	state := newPlayerState("p1", testNow)
+	state.Market.Pending = []*MarketOp{
+		{ID: "bd_1", Kind: marketOpBid, ListingID: "ls_9", Amount: 40, CreatedAt: testNow.Unix()},
+		{ID: "ls_2", Kind: marketOpList, Listing: &common.MarketListing{
+			ListingID: "ls_2", SellerID: "p1", ItemID: "iron_ore", Count: 5, Currency: common.MarketCurrency, BuyoutPrice: 30,
+		}, CreatedAt: testNow.Unix()},
+	}
+
+	doc, err := encodeSave(snapshotSave(state, testNow))
+	if err != nil {
+		t.Fatalf("encodeSave: %v", err)
+	}
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if !reflect.DeepEqual(restored.Market, state.Market) {
+		t.Errorf("Market = %+v, want %+v", restored.Market, state.Market)
+	}
+}
+
+func TestDecodeSaveV4WithoutMarket(t *testing.T) {
+	loadTestContent(t)
+	doc := parseSaveDoc(t, `{"schema_version": 4, "resources": {"gold": 50}}`)
+
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if restored.Market == nil || len(restored.Market.Pending) != 0 {
+		t.Fatalf("Market = %+v, want no pending ops", restored.Market)
+	}
+	if restored.Resources["gold"] != 50 {
+		t.Errorf("gold = %d, want 50", restored.Resources["gold"])
+	}
+}
+
+func TestDecodeSaveSectPendingRoundTrip(t *testing.T) {
+	loadTestContent(t)
+	state := newPlayerState("p1", testNow)
+	state.Sect = &common.SectMembership{SectID: "st_1", SectName: "青云门", Rank: common.SectRankLeader, Level: 2}
+	state.SectOps.Pending = []*SectOp{
+		{ID: "st_2", Kind: sectOpCreate, Name: "天机阁", Amount: common.SectCreateCost, CreatedAt: testNow.Unix()},
+		{ID: "sd_1", Kind: sectOpDonate, Amount: 300, CreatedAt: testNow.Unix()},
+	}
+
+	doc, err := encodeSave(snapshotSave(state, testNow))
+	if err != nil {
+		t.Fatalf("encodeSave: %v", err)
+	}
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if !reflect.DeepEqual(restored.SectOps, state.SectOps) {
+		t.Errorf("SectOps = %+v, want %+v", restored.SectOps, state.SectOps)
+	}
+	// 所在宗门以 Persist 为准，不写入存档
+	if restored.Sect != nil {
+		t.Errorf("Sect = %+v, want nil", restored.Sect)
+	}
+}
+
+func TestDecodeSaveV5WithoutSectOps(t *testing.T) {
+	loadTestContent(t)
+	doc := parseSaveDoc(t, `{"schema_version": 5, "resources": {"gold": 50}, "market": {"pending": [{"id": "bd_1", "kind": "bid", "amount": 10}]}}`)
+
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if restored.SectOps == nil || len(restored.SectOps.Pending) != 0 {
+		t.Fatalf("SectOps = %+v, want no pending ops", restored.SectOps)
+	}
+	if len(restored.Market.Pending) != 1 || restored.Market.Pending[0].ID != "bd_1" {
+		t.Errorf("Market = %+v, want bd_1 pending", restored.Market)
+	}
+}
+
+func TestDecodeSaveV6WithoutCharges(t *testing.T) {
+	loadTestContent(t)
+	doc := parseSaveDoc(t, `{"schema_version": 6, "resources": {"gold": 50}}`)
+
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if len(restored.Charges) != 0 || restored.Resources["gold"] != 50 {
+		t.Errorf("charges = %+v, gold = %d; want none and 50", restored.Charges, restored.Resources["gold"])
+	}
+}
+
+func TestDecodeSaveChargesRoundTrip(t *testing.T) {
+	loadTestContent(t)
+	state := newPlayerState("p1", testNow)
+	recordCharge(state, ChargeRecord{ID: "rn_1", Resource: "gold", Amount: 500, Source: common.CurrencySourceRename, At: testNow.Unix()})
+
+	doc, err := encodeSave(snapshotSave(state, testNow))
+	if err != nil {
+		t.Fatalf("encodeSave: %v", err)
+	}
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if !reflect.DeepEqual(restored.Charges, state.Charges) {
+		t.Errorf("Charges = %+v, want %+v", restored.Charges, state.Charges)
+	}
+}
+
+func TestDecodeSaveV2WithoutStaminaRegen(t *testing.T) {
+	loadTestContent(t)
+	doc := parseSaveDoc(t, `{"schema_version": 2, "resources": {"energy": 40}}`)
+
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if restored.Resources["energy"] != 40 {
+		t.Errorf("energy = %d, want 40", restored.Resources["energy"])
+	}
+	// 没有计时起点时从当前时间开始回复
+	if got := settleStamina(restored, "energy", testNow); got != 40 || restored.StaminaRegen["energy"] != testNow.Unix() {
+		t.Errorf("energy/anchor = %d/%d, want 40/%d", got, restored.StaminaRegen["energy"], testNow.Unix())
+	}
+}
+
+func TestDecodeSaveV1WithoutCrafting(t *testing.T) {
+	loadTestContent(t)
+	doc := parseSaveDoc(t, `{"schema_version": 1, "level": 8, "resources": {"gold": 10}}`)
+
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+	if save.SchemaVersion != currentSaveVersion || save.Crafting != nil {
+		t.Fatalf("SchemaVersion/Crafting = %d/%+v, want %d/nil", save.SchemaVersion, save.Crafting, currentSaveVersion)
+	}
+
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if restored.Crafting == nil || len(restored.Crafting.Queue) != 0 {
+		t.Fatalf("Crafting = %+v, want empty queue", restored.Crafting)
+	}
+	if level := restored.Crafting.skill(common.CraftingSkillAlchemy).Level; level != 1 {
+		t.Errorf("alchemy level = %d, want 1", level)
+	}
+}
+
+func TestDecodeSaveCraftingRoundTrip(t *testing.T) {
+	loadTestContent(t)
+	state := newPlayerState("p1", testNow)
+	state.Crafting.skill(common.CraftingSkillForging).Level = 3
+	state.Crafting.NextJobID = 1
+	state.Crafting.Queue = []*CraftingJob{{
+		JobID: 1, RecipeID: "forge_iron_sword", Count: 4, Done: 1, Progress: 12.5,
+		Inputs: map[string]int{"iron_ore": 20},
+	}}
+
+	doc, err := encodeSave(snapshotSave(state, testNow))
+	if err != nil {
+		t.Fatalf("encodeSave: %v", err)
+	}
+	save, err := decodeSave(doc)
+	if err != nil {
+		t.Fatalf("decodeSave: %v", err)
+	}
+
+	restored := newPlayerState("p1", testNow)
+	applySave(restored, save)
+	if !reflect.DeepEqual(restored.Crafting, state.Crafting) {
+		t.Errorf("Crafting = %+v, want %+v", restored.Crafting, state.Crafting)
+	}
+	if reserved := restored.Crafting.Queue[0].reserved(); reserved["iron_ore"] != 60 {
+		t.Errorf("reserved iron_ore = %d, want 60", reserved["iron_ore"])
+	}
+}
+
+func TestDecodeSaveNewerVersion(t *testing.T) {
+	doc := parseSaveDoc(t, `{"schema_version": 99}`)
+	_, err := decodeSave(doc)
+	if err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("err = %v, want newer version error", err)
+	}
+}
+
+func TestDecodeSaveInvalidVersion(t *testing.T) {
+	for _, raw := range []string{`{"schema_version": 1.5}`, `{"schema_version": -1}`, `{"schema_version": "1"}`} {
+		if _, err := decodeSave(parseSaveDoc(t, raw)); err == nil {
+			t.Errorf("decodeSave(%s) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestMigrateSaveChain(t *testing.T) {
+	var applied []int
+	step := func(from int) saveMigration {
+		return saveMigration{From: from, Migrate: func(doc map[string]interface{}) error {
+			applied = append(applied, from)
+			doc["steps"] = append(doc["steps"].([]int), from)
+			return nil
+		}}
+	}
+	// 乱序登记的迁移仍按版本依次执行
+	migrations := []saveMigration{step(2), step(0), step(1)}
+
+	doc := map[string]interface{}{"schema_version": float64(1), "steps": []int{}}
+	if err := migrateSave(doc, migrations, 3); err != nil {
+		t.Fatalf("migrateSave: %v", err)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+	if doc["schema_version"] != float64(3) {
+		t.Errorf("schema_version = %v, want 3", doc["schema_version"])
+	}
+}
+
+func TestMigrateSaveMissingMigration(t *testing.T) {
+	migrations := []saveMigration{{From: 0, Migrate: func(map[string]interface{}) error { return nil }}}
+	doc := map[string]interface{}{}
+	err := migrateSave(doc, migrations, 2)
+	if err == nil || !strings.Contains(err.Error(), "no save migration from version 1") {
+		t.Fatalf("err = %v, want missing migration error", err)
+	}
+}
+
+func TestSaveMigrationsCoverAllVersions(t *testing.T) {
+	covered := make(map[int]bool, len(saveMigrations))
+	for _, migration := range saveMigrations {
+		if covered[migration.From] {
+			t.Errorf("duplicate migration from version %d", migration.From)
+		}
+		covered[migration.From] = true
+	}
+	for version := 0; version < currentSaveVersion; version++ {
+		if !covered[version] {
+			t.Errorf("missing migration from version %d", version)
+		}
+	}
+}
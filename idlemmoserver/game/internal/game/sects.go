@@ -0,0 +1,628 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 宗门（仅在玩家 Actor 内调用） ============
+// 宗门数据以 Persist 为准，玩家所在的宗门与职位（PlayerState.Sect）在上线时加载、随操作结果和宗门事件更新，
+// 宗门等级的经验与掉落倍率在修炼（含离线收益）时生效。创建宗门与捐献消耗的金币与市场相同，
+// 先在本地托管并作为待确认的操作随存档保存，Persist 确认存档写入后再以宗门ID或流水ID提交，
+// 无法确定结果时之后原样重试；宝库拨发同样作为待确认操作以固定的流水ID重试，不会重复拨发。
+// 操作成功后向本服在线的相关成员 Actor 发送宗门事件，由其更新缓存的职位并推送给客户端
+
+// 宗门相关的游戏动作
+const (
+	sectActionCreate       = "sect_create"
+	sectActionList         = "sect_list"
+	sectActionInfo         = "sect_info"
+	sectActionApply        = "sect_apply"
+	sectActionApplications = "sect_applications"
+	sectActionReview       = "sect_review"
+	sectActionLeave        = "sect_leave"
+	sectActionKick         = "sect_kick"
+	sectActionSetRank      = "sect_set_rank"
+	sectActionTransfer     = "sect_transfer"
+	sectActionDisband      = "sect_disband"
+	sectActionDonate       = "sect_donate"
+	sectActionWithdraw     = "sect_withdraw"
+	sectActionUpgrade      = "sect_upgrade"
+	sectActionNotice       = "sect_notice"
+)
+
+// 待确认操作的类型
+const (
+	sectOpCreate   = "create"
+	sectOpDonate   = "donate"
+	sectOpWithdraw = "withdraw"
+)
+
+// SectState 尚未被 Persist 确认的宗门操作，随存档保存
+type SectState struct {
+	Pending []*SectOp `json:"pending,omitempty"`
+}
+
+// SectOp 待确认的宗门操作，创建宗门时 ID 即宗门ID，捐献与拨发时为流水ID
+type SectOp struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name,omitempty"`      // 宗门名称（创建）
+	TargetID  string `json:"target_id,omitempty"` // 拨发对象（拨发）
+	Amount    int64  `json:"amount"`              // 创建与捐献时为托管的金币，拨发时为宝库拨出的金币
+	CreatedAt int64  `json:"created_at"`
+
+	unsaved bool // 尚未确认写入存档，不能提交给 Persist
+}
+
+// newSectState 创建空的宗门状态
+func newSectState() *SectState {
+	return &SectState{}
+}
+
+// sectMultipliers 所在宗门等级对修炼经验和掉落的倍率
+func sectMultipliers(membership *common.SectMembership) (float64, float64) {
+	expMultiplier, dropMultiplier := 1.0, 1.0
+	if membership == nil {
+		return expMultiplier, dropMultiplier
+	}
+	def, ok := common.GetSectLevel(membership.Level)
+	if !ok {
+		return expMultiplier, dropMultiplier
+	}
+	if def.ExpMultiplier > 0 {
+		expMultiplier = def.ExpMultiplier
+	}
+	if def.DropMultiplier > 0 {
+		dropMultiplier = def.DropMultiplier
+	}
+	return expMultiplier, dropMultiplier
+}
+
+// applySectAction 执行宗门动作并推送结果
+func (s *Service) applySectAction(playerState *PlayerState, action string, params map[string]interface{}) (interface{}, error) {
+	// 先重试之前未确认的操作，所在宗门与余额以确认后的结果为准
+	s.retrySectOps(playerState)
+
+	now := time.Now()
+	var result interface{}
+	var err error
+	switch action {
+	case sectActionCreate:
+		result, err = s.sectCreate(playerState, params, now)
+	case sectActionDonate:
+		result, err = s.sectDonate(playerState, params, now)
+	case sectActionWithdraw:
+		result, err = s.sectWithdraw(playerState, params, now)
+	case sectActionList:
+		result, err = s.sectList(playerState.PlayerID, params)
+	case sectActionInfo:
+		result, err = s.sectInfo(playerState, params)
+	case sectActionApplications:
+		result, err = s.sectApplications(playerState.PlayerID)
+	default:
+		result, err = s.sectOperate(playerState, action, params, now)
+	}
+	if err != nil {
+		// 被拒绝通常是宗门已发生变化（被逐出、等级变化等），重新加载所在的宗门
+		if errors.Is(err, common.ErrSectRejected) {
+			s.refreshSectMembership(playerState)
+		}
+		return nil, err
+	}
+
+	playerState.LastActive = now
+	s.pushToClient(playerState.PlayerID, result)
+	return result, nil
+}
+
+// sectCreate 托管金币并创建宗门
+func (s *Service) sectCreate(playerState *PlayerState, params map[string]interface{}, now time.Time) (*common.S_SectUpdate, error) {
+	name, _ := params["name"].(string)
+	name = strings.TrimSpace(name)
+
+	if length := utf8.RuneCountInString(name); length < common.SectNameMinLength || length > common.SectNameMaxLength {
+		return nil, fmt.Errorf("sect name must be %d-%d characters", common.SectNameMinLength, common.SectNameMaxLength)
+	}
+	if playerState.Sect != nil {
+		return nil, fmt.Errorf("already in a sect")
+	}
+	for _, op := range playerState.SectOps.Pending {
+		if op.Kind == sectOpCreate {
+			return nil, fmt.Errorf("a sect is already being created")
+		}
+	}
+	if settleStamina(playerState, common.SectCurrency, now) < common.SectCreateCost {
+		return nil, fmt.Errorf("insufficient %s", common.SectCurrency)
+	}
+	if err := checkSectPending(playerState); err != nil {
+		return nil, err
+	}
+
+	sectID, err := generateMarketID("st_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate sect id: %w", err)
+	}
+	if _, err := s.changeCurrency(playerState, common.SectCurrency, -common.SectCreateCost, common.CurrencySourceSect); err != nil {
+		return nil, err
+	}
+
+	op := &SectOp{ID: sectID, Kind: sectOpCreate, Name: name, Amount: common.SectCreateCost, CreatedAt: now.Unix()}
+	return s.submitSectOp(playerState, op), nil
+}
+
+// sectDonate 托管金币并捐献给宝库
+func (s *Service) sectDonate(playerState *PlayerState, params map[string]interface{}, now time.Time) (*common.S_SectUpdate, error) {
+	amount, _ := params["amount"].(float64)
+
+	if playerState.Sect == nil {
+		return nil, fmt.Errorf("not in a sect")
+	}
+	if amount < 1 {
+		return nil, fmt.Errorf("amount is required")
+	}
+	if settleStamina(playerState, common.SectCurrency, now) < int64(amount) {
+		return nil, fmt.Errorf("insufficient %s", common.SectCurrency)
+	}
+	if err := checkSectPending(playerState); err != nil {
+		return nil, err
+	}
+
+	opID, err := generateMarketID("sd_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate sect op id: %w", err)
+	}
+	if _, err := s.changeCurrency(playerState, common.SectCurrency, -int64(amount), common.CurrencySourceSect); err != nil {
+		return nil, err
+	}
+
+	op := &SectOp{ID: opID, Kind: sectOpDonate, Amount: int64(amount), CreatedAt: now.Unix()}
+	return s.submitSectOp(playerState, op), nil
+}
+
+// sectWithdraw 从宝库向成员拨发金币，作为待确认操作以固定的流水ID提交，超时重试不会重复拨发
+func (s *Service) sectWithdraw(playerState *PlayerState, params map[string]interface{}, now time.Time) (*common.S_SectUpdate, error) {
+	targetID, _ := params["player_id"].(string)
+	amount, _ := params["amount"].(float64)
+
+	if playerState.Sect == nil {
+		return nil, fmt.Errorf("not in a sect")
+	}
+	if targetID == "" {
+		return nil, fmt.Errorf("player_id is required")
+	}
+	if amount < 1 {
+		return nil, fmt.Errorf("amount is required")
+	}
+	if err := checkSectPending(playerState); err != nil {
+		return nil, err
+	}
+
+	opID, err := generateMarketID("sw_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate sect op id: %w", err)
+	}
+	op := &SectOp{ID: opID, Kind: sectOpWithdraw, TargetID: targetID, Amount: int64(amount), CreatedAt: now.Unix()}
+	return s.submitSectOp(playerState, op), nil
+}
+
+// checkSectPending 待确认的操作过多时拒绝新的操作
+func checkSectPending(playerState *PlayerState) error {
+	if len(playerState.SectOps.Pending) >= common.SectMaxPendingOps {
+		return fmt.Errorf("too many sect operations awaiting confirmation, try again later")
+	}
+	return nil
+}
+
+// submitSectOp 记录待确认的操作，Persist 确认存档写入后提交；
+// 存档未确认时操作保持待确认，由 retrySectOps 在存档确认写入后提交
+func (s *Service) submitSectOp(playerState *PlayerState, op *SectOp) *common.S_SectUpdate {
+	op.unsaved = true
+	playerState.SectOps.Pending = append(playerState.SectOps.Pending, op)
+	if err := s.savePlayerDataConfirmed(playerState); err != nil {
+		log.Printf("Failed to save sect op %s for %s: %v", op.ID, playerState.PlayerID, err)
+		return &common.S_SectUpdate{
+			Type:   common.ServerMsgTypeSectUpdate,
+			Action: "sect_" + op.Kind,
+			OpID:   op.ID,
+			Status: marketStatusPending,
+			Error:  err.Error(),
+		}
+	}
+
+	update := s.resolveSectOp(playerState, op)
+	if update.Status != marketStatusPending {
+		if err := s.savePlayerData(playerState); err != nil {
+			log.Printf("Failed to save sect op %s for %s: %v", op.ID, playerState.PlayerID, err)
+		}
+	}
+	return update
+}
+
+// retrySectOps 重新提交未确认的操作，推送已有结果的操作并保存
+func (s *Service) retrySectOps(playerState *PlayerState) {
+	if len(playerState.SectOps.Pending) == 0 {
+		return
+	}
+	for _, op := range playerState.SectOps.Pending {
+		if !op.unsaved {
+			continue
+		}
+		if err := s.savePlayerDataConfirmed(playerState); err != nil {
+			log.Printf("Failed to save sect ops for %s, ops not submitted: %v", playerState.PlayerID, err)
+			return
+		}
+		break
+	}
+
+	resolved := false
+	for _, op := range append([]*SectOp(nil), playerState.SectOps.Pending...) {
+		update := s.resolveSectOp(playerState, op)
+		if update.Status == marketStatusPending {
+			continue
+		}
+		resolved = true
+		s.pushToClient(playerState.PlayerID, update)
+	}
+	if resolved {
+		if err := s.savePlayerData(playerState); err != nil {
+			log.Printf("Failed to save sect ops for %s: %v", playerState.PlayerID, err)
+		}
+	}
+}
+
+// resolveSectOp 提交操作：成功时移除（捐献发放贡献），被拒绝时退还托管的金币并移除，无法确定结果时保留等待重试
+func (s *Service) resolveSectOp(playerState *PlayerState, op *SectOp) *common.S_SectUpdate {
+	update := &common.S_SectUpdate{Type: common.ServerMsgTypeSectUpdate, Action: "sect_" + op.Kind, OpID: op.ID}
+
+	req := common.SectRequest{OpID: op.ID, PlayerID: playerState.PlayerID, TargetID: op.TargetID, Name: op.Name, Amount: op.Amount}
+	subject, msgType := common.PersistSectCreateSubject, "C_SectCreate"
+	switch op.Kind {
+	case sectOpDonate:
+		subject, msgType = common.PersistSectDonateSubject, "C_SectDonate"
+	case sectOpWithdraw:
+		subject, msgType = common.PersistSectWithdrawSubject, "C_SectWithdraw"
+	}
+
+	result, err := s.sectRequest(subject, msgType, req)
+	switch {
+	case err == nil:
+		update.Status = marketStatusCompleted
+		update.Sect = result.Sect
+		if membership := sectMembership(result, playerState.PlayerID); membership != nil || op.Kind == sectOpCreate {
+			playerState.Sect = membership
+		}
+		switch op.Kind {
+		case sectOpDonate:
+			contribution := op.Amount * common.SectContributionPerGold
+			if _, err := s.changeCurrency(playerState, common.SectContributionResource, contribution, common.CurrencySourceSect); err != nil {
+				log.Printf("Failed to grant sect contribution %s for %s: %v", op.ID, playerState.PlayerID, err)
+			}
+			s.publishSectEvent(playerState.PlayerID, result, &common.S_SectEvent{Event: common.SectEventDonated, Amount: op.Amount})
+		case sectOpWithdraw:
+			s.publishSectEvent(playerState.PlayerID, result, &common.S_SectEvent{
+				Event: common.SectEventWithdrawn, TargetID: op.TargetID, Amount: op.Amount,
+			})
+		}
+		log.Printf("Player %s sect %s %s completed", playerState.PlayerID, op.Kind, op.ID)
+	case errors.Is(err, common.ErrSectRejected):
+		update.Status = marketStatusRejected
+		update.Error = err.Error()
+		if op.Kind != sectOpWithdraw {
+			if _, err := s.changeCurrency(playerState, common.SectCurrency, op.Amount, common.CurrencySourceSect); err != nil {
+				log.Printf("Failed to refund sect op %s for %s: %v", op.ID, playerState.PlayerID, err)
+			}
+		}
+		log.Printf("Player %s sect %s %s rejected: %v", playerState.PlayerID, op.Kind, op.ID, err)
+	default:
+		update.Status = marketStatusPending
+		update.Error = err.Error()
+		log.Printf("Player %s sect %s %s pending: %v", playerState.PlayerID, op.Kind, op.ID, err)
+		return update
+	}
+	update.Membership = playerState.Sect
+
+	for i, pending := range playerState.SectOps.Pending {
+		if pending.ID == op.ID {
+			playerState.SectOps.Pending = append(playerState.SectOps.Pending[:i], playerState.SectOps.Pending[i+1:]...)
+			break
+		}
+	}
+	return update
+}
+
+// sectOperate 申请、审核、退出与宗门管理操作，成功后向相关成员发送宗门事件
+func (s *Service) sectOperate(playerState *PlayerState, action string, params map[string]interface{}, now time.Time) (*common.S_SectUpdate, error) {
+	targetID, _ := params["player_id"].(string)
+	req := common.SectRequest{PlayerID: playerState.PlayerID, TargetID: targetID}
+	event := &common.S_SectEvent{TargetID: targetID}
+	needsTarget := true
+
+	var subject, msgType string
+	switch action {
+	case sectActionApply:
+		req.SectID, _ = params["sect_id"].(string)
+		if req.SectID == "" {
+			return nil, fmt.Errorf("sect_id is required")
+		}
+		if playerState.Sect != nil {
+			return nil, fmt.Errorf("already in a sect")
+		}
+		subject, msgType, event.Event, needsTarget = common.PersistSectApplySubject, "C_SectApply", common.SectEventApplied, false
+	case sectActionReview:
+		req.Accept, _ = params["accept"].(bool)
+		req.Level = currentSectLevel(playerState)
+		subject, msgType, event.Event = common.PersistSectReviewSubject, "C_SectReview", common.SectEventRejected
+		if req.Accept {
+			event.Event = common.SectEventJoined
+		}
+	case sectActionLeave:
+		subject, msgType, event.Event, needsTarget = common.PersistSectLeaveSubject, "C_SectLeave", common.SectEventLeft, false
+	case sectActionKick:
+		subject, msgType, event.Event = common.PersistSectManageSubject, "C_SectKick", common.SectEventKicked
+	case sectActionSetRank:
+		req.Rank, _ = params["rank"].(string)
+		req.Level = currentSectLevel(playerState)
+		subject, msgType, event.Event, event.Rank = common.PersistSectManageSubject, "C_SectSetRank", common.SectEventRankChanged, req.Rank
+	case sectActionTransfer:
+		subject, msgType, event.Event, event.Rank = common.PersistSectManageSubject, "C_SectTransfer", common.SectEventLeaderTransferred, common.SectRankLeader
+	case sectActionDisband:
+		subject, msgType, event.Event, needsTarget = common.PersistSectManageSubject, "C_SectDisband", common.SectEventDisbanded, false
+	case sectActionNotice:
+		req.Notice, _ = params["notice"].(string)
+		req.Notice = strings.TrimSpace(req.Notice)
+		if utf8.RuneCountInString(req.Notice) > common.SectNoticeMaxLength {
+			return nil, fmt.Errorf("notice must be at most %d characters", common.SectNoticeMaxLength)
+		}
+		subject, msgType, event.Event, needsTarget = common.PersistSectManageSubject, "C_SectNotice", common.SectEventNotice, false
+	case sectActionUpgrade:
+		req.Level = currentSectLevel(playerState)
+		if req.Level != nil {
+			if next, ok := common.GetSectLevel(req.Level.Level + 1); ok {
+				req.Next = &next
+			}
+		}
+		subject, msgType, event.Event, needsTarget = common.PersistSectUpgradeSubject, "C_SectUpgrade", common.SectEventLevelUp, false
+	default:
+		return nil, fmt.Errorf("unknown sect action: %s", action)
+	}
+	if needsTarget && targetID == "" {
+		return nil, fmt.Errorf("player_id is required")
+	}
+	if action != sectActionApply && playerState.Sect == nil {
+		return nil, fmt.Errorf("not in a sect")
+	}
+
+	result, err := s.sectRequest(subject, msgType, req)
+	if err != nil {
+		return nil, err
+	}
+	if action == sectActionLeave || action == sectActionDisband {
+		playerState.Sect = nil
+	} else if action != sectActionApply {
+		playerState.Sect = sectMembership(result, playerState.PlayerID)
+	}
+	event.Timestamp = now.Unix()
+	s.publishSectEvent(playerState.PlayerID, result, event)
+
+	return &common.S_SectUpdate{
+		Type:       common.ServerMsgTypeSectUpdate,
+		Action:     action,
+		Status:     marketStatusCompleted,
+		Sect:       result.Sect,
+		Membership: playerState.Sect,
+	}, nil
+}
+
+// currentSectLevel 玩家所见的宗门等级定义，Persist 据此校验人数上限等
+func currentSectLevel(playerState *PlayerState) *common.SectLevelDef {
+	if playerState.Sect == nil {
+		return nil
+	}
+	def, ok := common.GetSectLevel(playerState.Sect.Level)
+	if !ok {
+		return nil
+	}
+	return &def
+}
+
+// sectMembership 从操作结果中找出玩家的成员记录，不在该宗门时返回 nil
+func sectMembership(result *common.SectResult, playerID string) *common.SectMembership {
+	if result == nil || result.Sect == nil {
+		return nil
+	}
+	for _, member := range result.Members {
+		if member.PlayerID == playerID {
+			return &common.SectMembership{
+				SectID:   result.Sect.SectID,
+				SectName: result.Sect.Name,
+				Rank:     member.Rank,
+				Level:    result.Sect.Level,
+			}
+		}
+	}
+	return nil
+}
+
+// publishSectEvent 向本服在线的相关玩家发送宗门事件（不含操作者本人）：
+// 入门申请只发给有审核权限的成员，审核与逐出额外发给目标玩家
+func (s *Service) publishSectEvent(actorID string, result *common.SectResult, event *common.S_SectEvent) {
+	if result == nil || result.Sect == nil {
+		return
+	}
+	event.Type = common.ServerMsgTypeSectEvent
+	event.SectID = result.Sect.SectID
+	event.SectName = result.Sect.Name
+	event.ActorID = actorID
+	event.Level = result.Sect.Level
+	if event.Timestamp == 0 {
+		event.Timestamp = time.Now().Unix()
+	}
+
+	recipients := make(map[string]bool, len(result.Members)+1)
+	for _, member := range result.Members {
+		if event.Event != common.SectEventApplied || common.SectRankHas(member.Rank, common.SectPermReview) {
+			recipients[member.PlayerID] = true
+		}
+	}
+	if event.TargetID != "" {
+		recipients[event.TargetID] = true
+	}
+	delete(recipients, actorID)
+
+	for playerID := range recipients {
+		s.sendPlayer(playerID, &msgSectEvent{event: event})
+	}
+}
+
+// handleSectEvent 收到宗门事件：更新缓存的所在宗门与职位并推送给客户端
+func (s *Service) handleSectEvent(playerState *PlayerState, event *common.S_SectEvent) {
+	self := event.TargetID == playerState.PlayerID
+	current := playerState.Sect != nil && playerState.Sect.SectID == event.SectID
+
+	switch {
+	case event.Event == common.SectEventJoined && self:
+		playerState.Sect = &common.SectMembership{
+			SectID:   event.SectID,
+			SectName: event.SectName,
+			Rank:     common.SectRankDisciple,
+			Level:    event.Level,
+		}
+	case event.Event == common.SectEventKicked && self && current, event.Event == common.SectEventDisbanded && current:
+		playerState.Sect = nil
+	case (event.Event == common.SectEventRankChanged || event.Event == common.SectEventLeaderTransferred) && self && current:
+		playerState.Sect.Rank = event.Rank
+	case event.Event == common.SectEventLevelUp && current:
+		playerState.Sect.Level = event.Level
+	}
+	s.pushToClient(playerState.PlayerID, event)
+}
+
+// refreshSectMembership 重新加载玩家所在的宗门，失败时保留缓存
+func (s *Service) refreshSectMembership(playerState *PlayerState) {
+	membership, err := s.loadSectMembership(playerState.PlayerID)
+	if err != nil {
+		log.Printf("Failed to refresh sect membership for %s: %v", playerState.PlayerID, err)
+		return
+	}
+	playerState.Sect = membership
+}
+
+// loadSectMembership 从 Persist 加载玩家所在的宗门与职位
+func (s *Service) loadSectMembership(playerID string) (*common.SectMembership, error) {
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Membership *common.SectMembership `json:"membership"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{"type": "C_SectMembership", "sect": common.SectRequest{PlayerID: playerID}}
+	if err := s.bus.RequestWithReply(common.PersistSectMembershipSubject, payload, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to load sect membership: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to load sect membership: %s", result.Error)
+	}
+	return result.Data.Membership, nil
+}
+
+// sectInfo 宗门概况与成员，未指定宗门时查询自己所在的宗门
+func (s *Service) sectInfo(playerState *PlayerState, params map[string]interface{}) (*common.S_SectInfo, error) {
+	sectID, _ := params["sect_id"].(string)
+	if sectID == "" && playerState.Sect == nil {
+		return nil, fmt.Errorf("not in a sect")
+	}
+
+	result, err := s.sectRequest(common.PersistSectInfoSubject, "C_SectInfo", common.SectRequest{PlayerID: playerState.PlayerID, SectID: sectID})
+	if err != nil {
+		return nil, err
+	}
+	if result.Sect == nil {
+		return nil, fmt.Errorf("sect not found")
+	}
+	if membership := sectMembership(result, playerState.PlayerID); membership != nil ||
+		(playerState.Sect != nil && playerState.Sect.SectID == result.Sect.SectID) {
+		playerState.Sect = membership
+	}
+	return &common.S_SectInfo{
+		Type:       common.ServerMsgTypeSectInfo,
+		Sect:       result.Sect,
+		Members:    result.Members,
+		Membership: playerState.Sect,
+	}, nil
+}
+
+// sectList 按名称搜索宗门
+func (s *Service) sectList(playerID string, params map[string]interface{}) (*common.S_SectList, error) {
+	name, _ := params["name"].(string)
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Sects []common.Sect `json:"sects"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{"type": "C_SectList", "sect": common.SectRequest{PlayerID: playerID, Name: name}}
+	if err := s.bus.RequestWithReply(common.PersistSectListSubject, payload, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to list sects: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list sects: %s", result.Error)
+	}
+	return &common.S_SectList{Type: common.ServerMsgTypeSectList, Sects: result.Data.Sects}, nil
+}
+
+// sectApplications 所在宗门等待审核的入门申请
+func (s *Service) sectApplications(playerID string) (*common.S_SectApplications, error) {
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Applications []common.SectApplication `json:"applications"`
+			Rejected     bool                     `json:"rejected"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{"type": "C_SectApplications", "sect": common.SectRequest{PlayerID: playerID}}
+	if err := s.bus.RequestWithReply(common.PersistSectApplicationsSubject, payload, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to list sect applications: %w", err)
+	}
+	if result.Data.Rejected {
+		reason := strings.TrimPrefix(result.Error, common.ErrSectRejected.Error()+": ")
+		return nil, fmt.Errorf("%w: %s", common.ErrSectRejected, reason)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list sect applications: %s", result.Error)
+	}
+	return &common.S_SectApplications{Type: common.ServerMsgTypeSectApplications, Applications: result.Data.Applications}, nil
+}
+
+// sectRequest 提交宗门操作，被拒绝时返回包装 common.ErrSectRejected 的错误
+func (s *Service) sectRequest(subject, msgType string, req common.SectRequest) (*common.SectResult, error) {
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			common.SectResult
+			Rejected bool `json:"rejected"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{"type": msgType, "sect": req}
+	if err := s.bus.RequestWithReply(subject, payload, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("sect request failed: %w", err)
+	}
+	if result.Data.Rejected {
+		reason := strings.TrimPrefix(result.Error, common.ErrSectRejected.Error()+": ")
+		return nil, fmt.Errorf("%w: %s", common.ErrSectRejected, reason)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("sect request failed: %s", result.Error)
+	}
+	return &result.Data.SectResult, nil
+}
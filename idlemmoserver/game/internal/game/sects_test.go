@@ -0,0 +1,111 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/idle-server/common"
+)
+
+func newSectTestService(t *testing.T) (*Service, *fakeBus, *PlayerState) {
+	t.Helper()
+	s, bus, state := newMarketTestService(t)
+	state.Resources["gold"] = 2000
+	state.Sect = &common.SectMembership{SectID: "st_1", SectName: "青云门", Rank: common.SectRankLeader, Level: 1}
+	return s, bus, state
+}
+
+// sectReply 宗门操作成功的回复，玩家为宗门的宗主
+func sectReply(playerID string) map[string]interface{} {
+	return map[string]interface{}{"success": true, "data": common.SectResult{
+		Sect:    &common.Sect{SectID: "st_1", Name: "青云门", LeaderID: playerID, Level: 1},
+		Members: []common.SectMember{{PlayerID: playerID, Rank: common.SectRankLeader}},
+	}}
+}
+
+func TestSectDonateSubmittedAfterEscrowSaved(t *testing.T) {
+	s, bus, state := newSectTestService(t)
+
+	result, err := s.applySectAction(state, sectActionDonate, map[string]interface{}{"amount": float64(300)})
+	if err != nil {
+		t.Fatalf("sect_donate: %v", err)
+	}
+	if status := result.(*common.S_SectUpdate).Status; status != marketStatusPending {
+		t.Fatalf("status = %s, want pending while the escrow is unsaved", status)
+	}
+	if len(bus.subjects()) != 1 || state.Resources["gold"] != 1700 {
+		t.Fatalf("requests = %v, gold = %d; want only the save attempt, 1700", bus.subjects(), state.Resources["gold"])
+	}
+
+	saves := bus.acceptSaves()
+	donated := 0
+	bus.handlers[common.PersistSectDonateSubject] = func(req map[string]interface{}) interface{} {
+		donated++
+		if len(*saves) == 0 {
+			t.Errorf("donation submitted before the escrow was saved")
+		}
+		return sectReply("p1")
+	}
+	s.retrySectOps(state)
+	s.retrySectOps(state)
+
+	if donated != 1 || len(state.SectOps.Pending) != 0 {
+		t.Errorf("donated = %d, pending = %d; want 1, 0", donated, len(state.SectOps.Pending))
+	}
+	if state.Resources["gold"] != 1700 || state.Resources[common.SectContributionResource] != 300 {
+		t.Errorf("gold = %d, contribution = %d; want 1700, 300", state.Resources["gold"], state.Resources[common.SectContributionResource])
+	}
+}
+
+func TestSectCreateRejectedRefunded(t *testing.T) {
+	s, bus, state := newSectTestService(t)
+	state.Sect = nil
+	bus.acceptSaves()
+	bus.handlers[common.PersistSectCreateSubject] = func(req map[string]interface{}) interface{} {
+		return map[string]interface{}{
+			"success": false,
+			"error":   common.ErrSectRejected.Error() + ": sect name 青云门 is taken",
+			"data":    map[string]interface{}{"rejected": true},
+		}
+	}
+
+	result, err := s.applySectAction(state, sectActionCreate, map[string]interface{}{"name": "青云门"})
+	if err != nil {
+		t.Fatalf("sect_create: %v", err)
+	}
+	if status := result.(*common.S_SectUpdate).Status; status != marketStatusRejected {
+		t.Errorf("status = %s, want rejected", status)
+	}
+	if state.Resources["gold"] != 2000 || len(state.SectOps.Pending) != 0 || state.Sect != nil {
+		t.Errorf("gold = %d, pending = %d, sect = %+v; want 2000, 0, nil", state.Resources["gold"], len(state.SectOps.Pending), state.Sect)
+	}
+}
+
+func TestSectWithdrawRetriedWithSameID(t *testing.T) {
+	s, bus, state := newSectTestService(t)
+	bus.acceptSaves()
+
+	result, err := s.applySectAction(state, sectActionWithdraw, map[string]interface{}{"player_id": "p2", "amount": float64(500)})
+	if err != nil {
+		t.Fatalf("sect_withdraw: %v", err)
+	}
+	first := result.(*common.S_SectUpdate)
+	if first.Status != marketStatusPending || len(state.SectOps.Pending) != 1 {
+		t.Fatalf("status = %s, pending = %d; want pending, 1", first.Status, len(state.SectOps.Pending))
+	}
+
+	var submitted []string
+	bus.handlers[common.PersistSectWithdrawSubject] = func(req map[string]interface{}) interface{} {
+		submitted = append(submitted, req["sect"].(map[string]interface{})["op_id"].(string))
+		return sectReply("p1")
+	}
+	s.retrySectOps(state)
+	s.retrySectOps(state)
+
+	if len(submitted) != 1 || submitted[0] != first.OpID {
+		t.Errorf("submitted = %v, want [%s]", submitted, first.OpID)
+	}
+	// 拨发不托管玩家的金币，完成与否都不改变余额
+	if len(state.SectOps.Pending) != 0 || state.Resources["gold"] != 2000 {
+		t.Errorf("pending = %d, gold = %d; want 0, 2000", len(state.SectOps.Pending), state.Resources["gold"])
+	}
+}
@@ -0,0 +1,237 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// SequenceState 玩家当前进行中的修炼序列
+type SequenceState struct {
+	SequenceID string    `json:"sequence_id"`
+	StartedAt  time.Time `json:"started_at"`
+	Progress   float64   `json:"progress"` // 当前轮已累积的时间（秒）
+}
+
+// SequenceProgress 单个序列的等级进度
+type SequenceProgress struct {
+	Level int   `json:"level"`
+	Exp   int64 `json:"exp"`
+}
+
+// startTickLoop 按 DefaultTickInterval 推进所有玩家的修炼序列
+func (s *Service) startTickLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastTick := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Sequence tick loop stopped")
+			return
+		case now := <-ticker.C:
+			s.tick(now, now.Sub(lastTick).Seconds())
+			lastTick = now
+		}
+	}
+}
+
+// tick 向所有在线玩家 Actor 投递一次序列推进，由各 Actor 结算并推送结果
+func (s *Service) tick(now time.Time, elapsed float64) {
+	msg := &msgSequenceTick{now: now, elapsed: elapsed}
+	for _, pid := range s.onlinePlayers() {
+		s.actorSystem.Root.Send(pid, msg)
+	}
+}
+
+// advanceSequence 为玩家的当前序列累积时间并结算完成的轮数，产出的物品放入背包
+// 没有完成任何一轮时返回 nil，没有物品产出时背包更新为 nil；now 用于判断增益是否生效，仅在玩家 Actor 内调用
+func (s *Service) advanceSequence(playerState *PlayerState, elapsed float64, now time.Time) (*common.S_SeqResult, *common.S_InventoryUpdate) {
+	sequence := playerState.Sequence
+	config, ok := common.GetSequenceConfig(sequence.SequenceID)
+	if !ok {
+		playerState.Sequence = nil
+		return nil, nil
+	}
+
+	progress := s.sequenceProgress(playerState, sequence.SequenceID)
+	startLevel := progress.Level
+	sequence.Progress += elapsed
+
+	result := &common.S_SeqResult{
+		Type:       common.ServerMsgTypeSeqResult,
+		SequenceID: sequence.SequenceID,
+		Running:    true,
+		Items:      make(map[string]int),
+	}
+
+	aptitude := playerState.Aptitude
+	stats := playerStats(playerState)
+	buffExp, buffDrop := buffMultipliers(playerState.Buffs, now)
+	sectExp, sectDrop := sectMultipliers(playerState.Sect)
+	expMultiplier := config.ExpMultiplier(aptitude) * buffExp * sectExp * (1 + stats.ExpBonus)
+	dropMultiplier := aptitude.DropRateMultiplier() * buffDrop * sectDrop * (1 + stats.DropBonus)
+
+	for sequence.Progress >= config.Interval(progress.Level) {
+		sequence.Progress -= config.Interval(progress.Level)
+		result.Rounds++
+
+		// 角色经验
+		result.ExpGained += int64(float64(config.BaseExp) * expMultiplier)
+
+		// 物品产出
+		for _, reward := range config.Rewards {
+			if playerState.rng.Float64() >= reward.Chance*dropMultiplier {
+				continue
+			}
+			amount := reward.Min
+			if reward.Max > reward.Min {
+				amount += playerState.rng.Intn(reward.Max - reward.Min + 1)
+			}
+			result.Items[reward.ItemID] += amount
+		}
+
+		// 序列经验与升级
+		if progress.Level >= common.SequenceMaxLevel {
+			continue
+		}
+		progress.Exp += config.SequenceExp
+		for progress.Level < common.SequenceMaxLevel && progress.Exp >= common.SequenceExpToNext(progress.Level) {
+			progress.Exp -= common.SequenceExpToNext(progress.Level)
+			progress.Level++
+			result.LeveledUp = true
+		}
+	}
+
+	if result.Rounds == 0 {
+		return nil, nil
+	}
+
+	// 角色升级由 grantExp 触发升级事件并推送 S_LevelUp
+	if _, err := s.grantExp(playerState, result.ExpGained, common.ExpSourceSequence); err != nil {
+		log.Printf("Failed to grant sequence exp to %s: %v", playerState.PlayerID, err)
+	}
+	result.PlayerExp = playerState.Experience
+	result.PlayerLevel = playerState.Level
+	update := s.grantItems(playerState, result.Items, "sequence")
+	s.fillSequenceStatus(result, config, progress)
+
+	s.fireEvent(playerState, common.GameEvent{
+		Type:  common.GameEventSequenceRounds,
+		Key:   sequence.SequenceID,
+		Count: int64(result.Rounds),
+	})
+	for itemID, amount := range result.Items {
+		s.fireEvent(playerState, common.GameEvent{
+			Type:   common.GameEventItemGathered,
+			Source: common.ExpSourceSequence,
+			Key:    itemID,
+			Count:  int64(amount),
+		})
+	}
+	if progress.Level > startLevel {
+		s.fireEvent(playerState, common.GameEvent{
+			Type:  common.GameEventSequenceLevelUp,
+			Key:   sequence.SequenceID,
+			Value: int64(progress.Level),
+			Count: int64(progress.Level - startLevel),
+		})
+	}
+	return result, update
+}
+
+// startSequence 开始修炼序列，已有进行中的序列时切换（未完成的一轮进度作废）
+func (s *Service) startSequence(playerState *PlayerState, sequenceID string) (*common.S_SeqResult, error) {
+	config, ok := common.GetSequenceConfig(sequenceID)
+	if !ok {
+		return nil, fmt.Errorf("unknown sequence: %s", sequenceID)
+	}
+
+	playerState.LastActive = time.Now()
+	playerState.Sequence = &SequenceState{
+		SequenceID: sequenceID,
+		StartedAt:  time.Now(),
+	}
+
+	result := &common.S_SeqResult{
+		Type:        common.ServerMsgTypeSeqResult,
+		SequenceID:  sequenceID,
+		Running:     true,
+		PlayerExp:   playerState.Experience,
+		PlayerLevel: playerState.Level,
+	}
+	s.fillSequenceStatus(result, config, s.sequenceProgress(playerState, sequenceID))
+
+	log.Printf("Player %s started sequence %s", playerState.PlayerID, sequenceID)
+	s.pushToClient(playerState.PlayerID, result)
+	return result, nil
+}
+
+// stopSequence 停止当前修炼序列（未完成的一轮进度作废）
+func (s *Service) stopSequence(playerState *PlayerState) (*common.S_SeqResult, error) {
+	if playerState.Sequence == nil {
+		return nil, fmt.Errorf("no sequence is running")
+	}
+
+	sequenceID := playerState.Sequence.SequenceID
+	playerState.LastActive = time.Now()
+	playerState.Sequence = nil
+
+	result := &common.S_SeqResult{
+		Type:        common.ServerMsgTypeSeqResult,
+		SequenceID:  sequenceID,
+		Running:     false,
+		PlayerExp:   playerState.Experience,
+		PlayerLevel: playerState.Level,
+	}
+	if config, ok := common.GetSequenceConfig(sequenceID); ok {
+		s.fillSequenceStatus(result, config, s.sequenceProgress(playerState, sequenceID))
+	}
+
+	log.Printf("Player %s stopped sequence %s", playerState.PlayerID, sequenceID)
+	s.pushToClient(playerState.PlayerID, result)
+	return result, nil
+}
+
+// sequenceProgress 获取玩家某个序列的等级进度，不存在时从1级开始
+func (s *Service) sequenceProgress(playerState *PlayerState, sequenceID string) *SequenceProgress {
+	if playerState.Sequences == nil {
+		playerState.Sequences = make(map[string]*SequenceProgress)
+	}
+	progress, ok := playerState.Sequences[sequenceID]
+	if !ok {
+		progress = &SequenceProgress{Level: 1}
+		playerState.Sequences[sequenceID] = progress
+	}
+	return progress
+}
+
+// fillSequenceStatus 填充序列的等级、经验和每轮耗时
+func (s *Service) fillSequenceStatus(result *common.S_SeqResult, config common.SequenceConfig, progress *SequenceProgress) {
+	result.Level = progress.Level
+	result.Exp = progress.Exp
+	result.Interval = config.Interval(progress.Level)
+	if progress.Level < common.SequenceMaxLevel {
+		result.ExpToNext = common.SequenceExpToNext(progress.Level)
+	}
+}
+
+// pushToClient 通过 Gateway 推送消息给玩家的 WebSocket 连接
+func (s *Service) pushToClient(playerID string, msg interface{}) {
+	data, err := common.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to marshal message for player %s: %v", playerID, err)
+		return
+	}
+
+	if err := s.bus.Publish(common.GatewayBroadcastSubject, common.MsgToClient{
+		PlayerID: playerID,
+		Data:     data,
+	}); err != nil {
+		log.Printf("Failed to push message to player %s: %v", playerID, err)
+	}
+}
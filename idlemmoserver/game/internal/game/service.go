@@ -2,11 +2,14 @@ package game
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"sync"
 	"time"
 
+	"github.com/asynkron/protoactor-go/actor"
 	"github.com/idle-server/common"
 	"github.com/idle-server/common/handler"
 	"github.com/idle-server/common/nats"
@@ -15,27 +18,66 @@ import (
 )
 
 // Service 统一的游戏服务
+// 每个在线玩家是 GameManagerActor 下的一个 PlayerActor，玩家状态只在其 Actor 内修改；
+// players 仅记录在线玩家的 PID，由 GameManagerActor 维护；钝化中的玩家移入 stopping，停止后关闭其通道
 type Service struct {
 	*service.BaseServiceImpl
 	natsManager  *nats.Manager
+	bus          messageBus // 与 Persist、Gateway 的消息收发，即 natsManager
 	processor    *handler.MessageProcessor
-	players      map[string]*PlayerState
+	actorSystem  *actor.ActorSystem
+	manager      *actor.PID
+	players      map[string]*actor.PID
+	stopping     map[string]chan struct{}
 	playersMutex sync.RWMutex
+	contentMutex sync.Mutex // 串行化配置表热更新
+	tickCancel   context.CancelFunc
+
+	eventHandlers map[string][]eventHandler // 游戏事件处理函数，启动时注册后只读
+}
+
+// messageBus 服务间消息收发，由 nats.Manager 实现，测试中替换为内存实现
+type messageBus interface {
+	Publish(subject string, msg interface{}) error
+	RequestWithReply(subject string, request interface{}, response interface{}, timeout time.Duration) error
 }
 
-// PlayerState 玩家状态
+// PlayerState 玩家状态，仅由所属的 PlayerActor 访问
 type PlayerState struct {
-	PlayerID    string
-	ConnectedAt time.Time
-	LastActive  time.Time
-	GameData    map[string]interface{} // 游戏数据
+	PlayerID     string
+	ConnectedAt  time.Time
+	LastActive   time.Time
+	Level        int                          // 角色等级
+	Experience   int64                        // 当前等级内的经验
+	Resources    map[string]int64             // 货币
+	Aptitude     *common.Aptitude             // 灵根资质，以 players.aptitude 为准，不随存档保存
+	Sequence     *SequenceState               // 进行中的修炼序列
+	Sequences    map[string]*SequenceProgress // 各序列的等级进度
+	Buffs        []common.Buff                // 限时增益
+	Inventory    *Inventory                   // 背包
+	Equipment    map[string]string            // 已穿戴装备：部位 -> 物品ID
+	Progress     *ProgressStore               // game_progress 表中的进度（成就、累计计数等）
+	Crafting     *CraftingState               // 制作队列与生产技能
+	StaminaRegen map[string]int64             // 回复资源的回复计时起点（Unix 秒），见 stamina.go
+	Shop         *ShopState                   // 商店限购次数与最近的购买
+	Market       *MarketState                 // 已托管、等待 Persist 确认的市场操作
+	SectOps      *SectState                   // 已托管、等待 Persist 确认的宗门操作
+	Charges      []ChargeRecord               // 其他服务最近的代扣，用于识别重试与退还
+	Sect         *common.SectMembership       // 所在的宗门与职位，以 Persist 为准，不随存档保存
+
+	rng             *rand.Rand              // 序列产出与制作成功率随机数
+	pendingReport   *common.S_OfflineReport // 激活时结算的离线收益，Actor 启动后保存并推送
+	pendingCrafting *common.CraftingResults // 激活时结算的离线制作，Actor 启动后发放并推送
+	connected       bool                    // 玩家的连接是否在线，连接期间不因空闲钝化
+	passivating     bool                    // 已移出在线索引，等待 PoisonPill 停止；Actor 重启后仍保留
 }
 
 // NewService 创建新的游戏服务
 func NewService() service.Service {
 	return &Service{
 		BaseServiceImpl: service.NewBaseService("Game"),
-		players:         make(map[string]*PlayerState),
+		players:         make(map[string]*actor.PID),
+		stopping:        make(map[string]chan struct{}),
 	}
 }
 
@@ -46,12 +88,25 @@ func (s *Service) Start(ctx context.Context) error {
 		return err
 	}
 
+	// 加载游戏内容配置表
+	if err := s.loadContent(); err != nil {
+		return fmt.Errorf("failed to load game content: %w", err)
+	}
+
 	// 初始化 NATS 管理器
 	var err error
 	s.natsManager, err = nats.NewManager(common.NATSURL)
 	if err != nil {
 		return fmt.Errorf("failed to initialize NATS manager: %w", err)
 	}
+	s.bus = s.natsManager
+
+	// 注册游戏事件处理函数
+	s.registerEventHandlers()
+
+	// 初始化 Actor 系统和玩家管理 Actor
+	s.actorSystem = actor.NewActorSystem()
+	s.manager = s.actorSystem.Root.Spawn(newGameManagerProps(s))
 
 	// 初始化消息处理器
 	s.processor = handler.NewMessageProcessor(s.natsManager)
@@ -66,6 +121,11 @@ func (s *Service) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to register NATS subscriptions: %w", err)
 	}
 
+	// 启动修炼序列 Tick
+	var tickCtx context.Context
+	tickCtx, s.tickCancel = context.WithCancel(ctx)
+	go s.startTickLoop(tickCtx, common.DefaultTickInterval*time.Second)
+
 	log.Printf("Game Service started successfully")
 	return nil
 }
@@ -77,8 +137,13 @@ func (s *Service) Stop(ctx context.Context) error {
 		return err
 	}
 
-	// 保存所有玩家数据
-	s.saveAllPlayerData()
+	// 停止修炼序列 Tick
+	if s.tickCancel != nil {
+		s.tickCancel()
+	}
+
+	// 停止所有玩家 Actor（停止前保存玩家数据）
+	s.stopAllPlayers()
 
 	// 关闭 NATS 管理器
 	if s.natsManager != nil {
@@ -107,6 +172,35 @@ func (s *Service) registerHandlers() error {
 	actionHandler := handler.NewGameActionHandler(s.natsManager, s.handleGameAction)
 	s.processor.RegisterHandler(actionHandler)
 
+	// 注册修炼序列处理器
+	s.processor.RegisterHandler(handler.NewStartSequenceHandler(s.natsManager, s.handleStartSequence))
+	s.processor.RegisterHandler(handler.NewStopSequenceHandler(s.natsManager, s.handleStopSequence))
+
+	// 注册背包处理器
+	for _, msgType := range []string{
+		common.ClientMsgTypeInventoryUse,
+		common.ClientMsgTypeInventoryDiscard,
+		common.ClientMsgTypeInventorySort,
+	} {
+		s.processor.RegisterHandler(handler.NewInventoryHandler(s.natsManager, msgType, s.handleInventoryAction))
+	}
+
+	// 注册配置表热更新处理器
+	s.processor.RegisterHandler(handler.NewContentReloadHandler(s.natsManager, s.reloadContent))
+
+	// 注册管理员发放处理器
+	s.processor.RegisterHandler(handler.NewAdminGrantHandler(s.natsManager, s.handleAdminGrant))
+
+	// 注册代扣货币处理器
+	s.processor.RegisterHandler(handler.NewPlayerChargeHandler(s.natsManager, s.handlePlayerCharge))
+
+	// 注册资质更新处理器
+	s.processor.RegisterHandler(handler.NewPlayerAptitudeHandler(s.natsManager, s.handlePlayerAptitude))
+
+	// 注册装备处理器
+	s.processor.RegisterHandler(handler.NewEquipmentHandler(s.natsManager, common.ClientMsgTypeEquip, s.handleEquipAction))
+	s.processor.RegisterHandler(handler.NewEquipmentHandler(s.natsManager, common.ClientMsgTypeUnequip, s.handleEquipAction))
+
 	return nil
 }
 
@@ -140,6 +234,55 @@ func (s *Service) registerNATSSubscriptions() error {
 		return fmt.Errorf("failed to subscribe to game action subject: %w", err)
 	}
 
+	// 使用统一的消息处理器订阅修炼序列主题
+	if _, err := s.natsManager.Subscribe(common.GameSequenceSubject, &natsMessageAdapter{
+		processor: s.processor,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to game sequence subject: %w", err)
+	}
+
+	// 使用统一的消息处理器订阅背包主题
+	if _, err := s.natsManager.Subscribe(common.GameInventorySubject, &natsMessageAdapter{
+		processor: s.processor,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to game inventory subject: %w", err)
+	}
+
+	// 使用统一的消息处理器订阅装备主题
+	if _, err := s.natsManager.Subscribe(common.GameEquipmentSubject, &natsMessageAdapter{
+		processor: s.processor,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to game equipment subject: %w", err)
+	}
+
+	// 使用统一的消息处理器订阅配置表热更新主题
+	if _, err := s.natsManager.Subscribe(common.GameContentReloadSubject, &natsMessageAdapter{
+		processor: s.processor,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to game content reload subject: %w", err)
+	}
+
+	// 使用统一的消息处理器订阅管理员发放主题
+	if _, err := s.natsManager.Subscribe(common.GameAdminGrantSubject, &natsMessageAdapter{
+		processor: s.processor,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to game admin grant subject: %w", err)
+	}
+
+	// 使用统一的消息处理器订阅代扣货币主题
+	if _, err := s.natsManager.Subscribe(common.GamePlayerChargeSubject, &natsMessageAdapter{
+		processor: s.processor,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to game player charge subject: %w", err)
+	}
+
+	// 使用统一的消息处理器订阅资质更新主题
+	if _, err := s.natsManager.Subscribe(common.GamePlayerAptitudeSubject, &natsMessageAdapter{
+		processor: s.processor,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to game player aptitude subject: %w", err)
+	}
+
 	log.Printf("Game NATS subscriptions registered successfully")
 	return nil
 }
@@ -156,100 +299,118 @@ func (a *natsMessageAdapter) Handle(msg *natsio.Msg) error {
 
 // 业务逻辑处理方法
 
-// handlePlayerConnect 处理玩家连接
+// handlePlayerConnect 处理玩家连接，激活玩家 Actor（已在线时只刷新活跃时间）
 func (s *Service) handlePlayerConnect(playerID string) error {
 	log.Printf("Game Service: Player %s connected", playerID)
 
-	s.playersMutex.Lock()
-	defer s.playersMutex.Unlock()
-
-	// 创建或更新玩家状态
-	playerState := &PlayerState{
-		PlayerID:    playerID,
-		ConnectedAt: time.Now(),
-		LastActive:  time.Now(),
-		GameData:    make(map[string]interface{}),
-	}
-
-	// 尝试加载玩家数据
-	if err := s.loadPlayerData(playerID); err != nil {
-		log.Printf("Failed to load player data for %s: %v", playerID, err)
-		// 为新玩家初始化默认数据
-		playerState.GameData = s.initDefaultGameData()
+	// 经由 askPlayer 确认登录送达：正在钝化的 Actor 拒绝登录，改由重新激活的 Actor 处理
+	if _, err := s.askPlayer(playerID, &msgLogin{at: time.Now()}); err != nil {
+		log.Printf("Failed to activate player %s: %v", playerID, err)
+		return err
 	}
 
-	s.players[playerID] = playerState
-
 	log.Printf("Player %s connected and initialized successfully", playerID)
 	return nil
 }
 
-// handlePlayerDisconnect 处理玩家断开连接
+// handlePlayerDisconnect 处理玩家断开连接，玩家 Actor 在停止前保存数据
 func (s *Service) handlePlayerDisconnect(playerID string) error {
 	log.Printf("Game Service: Player %s disconnected", playerID)
 
-	s.playersMutex.Lock()
-	defer s.playersMutex.Unlock()
-
-	if playerState, exists := s.players[playerID]; exists {
-		// 保存玩家数据
-		if err := s.savePlayerData(playerID, playerState.GameData); err != nil {
-			log.Printf("Failed to save player data for %s: %v", playerID, err)
-		}
-
-		// 从内存中移除玩家
-		delete(s.players, playerID)
-	}
+	s.sendPlayer(playerID, &msgDisconnect{})
 
-	log.Printf("Player %s disconnected and data saved", playerID)
+	log.Printf("Player %s disconnected", playerID)
 	return nil
 }
 
 // handleGetState 处理获取游戏状态
 func (s *Service) handleGetState(playerID string) (interface{}, error) {
-	s.playersMutex.RLock()
-	defer s.playersMutex.RUnlock()
+	return s.askPlayer(playerID, &msgGetState{})
+}
 
-	playerState, exists := s.players[playerID]
-	if !exists {
-		return nil, fmt.Errorf("player %s not found", playerID)
-	}
+// handleGameAction 处理游戏动作
+func (s *Service) handleGameAction(playerID, action string, params map[string]interface{}) (interface{}, error) {
+	return s.askPlayer(playerID, &msgGameAction{action: action, params: params})
+}
 
-	// 更新最后活跃时间
-	playerState.LastActive = time.Now()
+// handleStartSequence 处理开始修炼序列
+func (s *Service) handleStartSequence(playerID, sequenceID string) (interface{}, error) {
+	return s.askPlayer(playerID, &msgStartSequence{sequenceID: sequenceID})
+}
 
-	// 返回玩家游戏状态
-	return map[string]interface{}{
-		"player_id":    playerID,
-		"connected_at": playerState.ConnectedAt.Unix(),
-		"last_active":  playerState.LastActive.Unix(),
-		"game_data":    playerState.GameData,
-	}, nil
+// handleStopSequence 处理停止修炼序列
+func (s *Service) handleStopSequence(playerID, _ string) (interface{}, error) {
+	return s.askPlayer(playerID, &msgStopSequence{})
 }
 
-// handleGameAction 处理游戏动作
-func (s *Service) handleGameAction(playerID, action string, params map[string]interface{}) (interface{}, error) {
-	s.playersMutex.Lock()
-	defer s.playersMutex.Unlock()
+// handleInventoryAction 处理背包操作
+func (s *Service) handleInventoryAction(playerID, action string, slot, count int) (interface{}, error) {
+	return s.askPlayer(playerID, &msgInventoryAction{action: action, slot: slot, count: count})
+}
 
-	playerState, exists := s.players[playerID]
-	if !exists {
-		return nil, fmt.Errorf("player %s not found", playerID)
-	}
+// handleEquipAction 处理穿戴/卸下装备
+func (s *Service) handleEquipAction(playerID, action string, slot int, equipSlot string) (interface{}, error) {
+	return s.askPlayer(playerID, &msgEquipAction{action: action, slot: slot, equipSlot: equipSlot})
+}
 
-	// 更新最后活跃时间
-	playerState.LastActive = time.Now()
+// handlePlayerAptitude 处理资质更新：资质由 Auth 写入 players 表，玩家 Actor 持有的副本随之更新并保存；
+// 玩家不在线时先激活，避免并发激活加载到更新前的缓存
+func (s *Service) handlePlayerAptitude(playerID string, aptitude *common.Aptitude) error {
+	_, err := s.askPlayer(playerID, &msgSetAptitude{aptitude: aptitude})
+	return err
+}
 
-	log.Printf("Processing game action '%s' for player %s", action, playerID)
+// handleAdminGrant 处理管理员发放
+func (s *Service) handleAdminGrant(playerID string, params map[string]interface{}) (interface{}, error) {
+	return s.askPlayer(playerID, &msgAdminGrant{params: params})
+}
+
+// getState 在玩家 Actor 内生成游戏状态
+func (s *Service) getState(playerState *PlayerState) map[string]interface{} {
+	expToNext, _ := common.CurrentContent().ExpToNext(playerState.Level)
+	settleAllStamina(playerState, time.Now())
+	return map[string]interface{}{
+		"player_id":        playerState.PlayerID,
+		"connected_at":     playerState.ConnectedAt.Unix(),
+		"last_active":      playerState.LastActive.Unix(),
+		"level":            playerState.Level,
+		"experience":       playerState.Experience,
+		"exp_to_next":      expToNext,
+		"resources":        playerState.Resources,
+		"stamina":          staminaStatus(playerState),
+		"aptitude":         playerState.Aptitude,
+		"cultivation_rate": playerState.Aptitude.CultivationMultiplier(),
+		"inventory":        s.fullInventoryUpdate(playerState, "sync"),
+		"equipment":        s.equipmentUpdate(playerState),
+		"achievements":     achievementStatuses(playerState),
+		"crafting":         craftingStatus(playerState, "sync", nil, time.Now()),
+		"sect":             playerState.Sect,
+	}
+}
+
+// applyGameAction 在玩家 Actor 内执行游戏动作
+// 客户端只能提交由服务端校验的意图，等级、经验和货币只能由游戏系统修改
+func (s *Service) applyGameAction(playerState *PlayerState, action string, params map[string]interface{}) (interface{}, error) {
+	log.Printf("Processing game action '%s' for player %s", action, playerState.PlayerID)
 
 	// 处理不同的游戏动作
 	switch action {
-	case "update_level":
-		return s.handleUpdateLevel(playerState, params)
-	case "add_resource":
-		return s.handleAddResource(playerState, params)
 	case "save_progress":
 		return s.handleSaveProgress(playerState, params)
+	case questActionList, questActionAccept, questActionTrack, questActionComplete, questActionClaim:
+		return s.applyQuestAction(playerState, action, params)
+	case craftActionStart, craftActionCancel, craftActionStatus:
+		return s.applyCraftingAction(playerState, action, params)
+	case mailActionList, mailActionClaim:
+		return s.applyMailAction(playerState, action, params)
+	case shopActionList, shopActionBuy, shopActionHistory:
+		return s.applyShopAction(playerState, action, params)
+	case marketActionList, marketActionBuy, marketActionBid, marketActionCancel, marketActionSearch, marketActionMine:
+		return s.applyMarketAction(playerState, action, params)
+	case sectActionCreate, sectActionList, sectActionInfo, sectActionApply, sectActionApplications, sectActionReview,
+		sectActionLeave, sectActionKick, sectActionSetRank, sectActionTransfer, sectActionDisband, sectActionDonate,
+		sectActionWithdraw, sectActionUpgrade, sectActionNotice:
+		return s.applySectAction(playerState, action, params)
 	default:
 		return nil, fmt.Errorf("unknown game action: %s", action)
 	}
@@ -257,60 +418,8 @@ func (s *Service) handleGameAction(playerID, action string, params map[string]in
 
 // 游戏动作处理方法
 
-func (s *Service) handleUpdateLevel(playerState *PlayerState, params map[string]interface{}) (interface{}, error) {
-	level, ok := params["level"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("invalid level parameter")
-	}
-
-	playerState.GameData["level"] = int(level)
-
-	return map[string]interface{}{
-		"action": "update_level",
-		"result": "success",
-		"level":  int(level),
-	}, nil
-}
-
-func (s *Service) handleAddResource(playerState *PlayerState, params map[string]interface{}) (interface{}, error) {
-	resourceType, ok := params["resource_type"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid resource_type parameter")
-	}
-
-	amount, ok := params["amount"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("invalid amount parameter")
-	}
-
-	// 确保资源字典存在
-	if playerState.GameData["resources"] == nil {
-		playerState.GameData["resources"] = make(map[string]interface{})
-	}
-
-	resources := playerState.GameData["resources"].(map[string]interface{})
-	currentAmount := float64(0)
-	if current, exists := resources[resourceType]; exists {
-		if currentFloat, ok := current.(float64); ok {
-			currentAmount = currentFloat
-		}
-	}
-
-	newAmount := currentAmount + amount
-	resources[resourceType] = newAmount
-
-	return map[string]interface{}{
-		"action":        "add_resource",
-		"result":        "success",
-		"resource_type": resourceType,
-		"new_amount":    newAmount,
-	}, nil
-}
-
 func (s *Service) handleSaveProgress(playerState *PlayerState, params map[string]interface{}) (interface{}, error) {
-	playerID := playerState.PlayerID
-
-	if err := s.savePlayerData(playerID, playerState.GameData); err != nil {
+	if err := s.savePlayerData(playerState); err != nil {
 		return nil, fmt.Errorf("failed to save progress: %w", err)
 	}
 
@@ -323,21 +432,24 @@ func (s *Service) handleSaveProgress(playerState *PlayerState, params map[string
 
 // 数据管理方法
 
-func (s *Service) initDefaultGameData() map[string]interface{} {
-	return map[string]interface{}{
-		"level":      1,
-		"experience": 0,
-		"resources": map[string]interface{}{
-			"gold":   100,
-			"gems":   10,
-			"energy": 100,
-		},
-		"achievements": []interface{}{},
-		"last_save":    time.Now().Unix(),
+// applyPlayerData 将 Persist 加载的角色数据恢复到玩家状态，存档按版本迁移后解析
+func (s *Service) applyPlayerData(playerState *PlayerState, playerData *common.PlayerData) error {
+	save, err := decodeSave(playerData.GameData)
+	if err != nil {
+		return fmt.Errorf("failed to load save of player %s: %w", playerState.PlayerID, err)
+	}
+	if save != nil {
+		applySave(playerState, save)
+	} else if playerData.Level > 0 {
+		// 尚未由 Game 保存过的角色：等级与经验以 players 表为准
+		playerState.Level = playerData.Level
+		playerState.Experience = playerData.Exp
 	}
+	playerState.Aptitude = playerData.Aptitude
+	return nil
 }
 
-func (s *Service) loadPlayerData(playerID string) error {
+func (s *Service) loadPlayerData(playerID string) (*common.PlayerData, error) {
 	log.Printf("Game: Loading player data for %s", playerID)
 
 	// 从 persist 服务加载玩家数据
@@ -347,10 +459,10 @@ func (s *Service) loadPlayerData(playerID string) error {
 	}
 
 	var result map[string]interface{}
-	err := s.natsManager.RequestWithReply(common.PersistLoadPlayerSubject, req, &result, 5*time.Second)
+	err := s.bus.RequestWithReply(common.PersistLoadPlayerSubject, req, &result, 5*time.Second)
 	if err != nil {
 		log.Printf("Game: Failed to get response from Persist: %v", err)
-		return err
+		return nil, err
 	}
 
 	log.Printf("Game: Received load response from Persist: %+v", result)
@@ -358,60 +470,105 @@ func (s *Service) loadPlayerData(playerID string) error {
 	// 解析响应
 	success, ok := result["success"].(bool)
 	if !ok || !success {
-		message, _ := result["message"].(string)
+		message, _ := result["error"].(string)
 		log.Printf("Game: Failed to load player data: %s", message)
-		return fmt.Errorf("failed to load player data: %s", message)
+		return nil, fmt.Errorf("failed to load player data: %s", message)
 	}
 
-	// 获取玩家数据并更新到玩家状态
-	s.playersMutex.Lock()
-	defer s.playersMutex.Unlock()
-
-	if playerState, exists := s.players[playerID]; exists {
-		if data, ok := result["data"].(map[string]interface{}); ok {
-			if playerData, ok := data["data"].(map[string]interface{}); ok {
-				playerState.GameData = playerData
-				log.Printf("Game: Successfully loaded player data for %s", playerID)
-				return nil
+	// 解析玩家数据
+	if data, ok := result["data"].(map[string]interface{}); ok {
+		if raw, ok := data["data"].(map[string]interface{}); ok {
+			encoded, err := json.Marshal(raw)
+			if err == nil {
+				var playerData common.PlayerData
+				if err := json.Unmarshal(encoded, &playerData); err == nil {
+					log.Printf("Game: Successfully loaded player data for %s", playerID)
+					return &playerData, nil
+				}
 			}
 		}
 	}
 
 	log.Printf("Game: Invalid response data format for player %s", playerID)
-	return fmt.Errorf("invalid response data format")
+	return nil, fmt.Errorf("invalid response data format")
 }
 
-func (s *Service) savePlayerData(playerID string, gameData map[string]interface{}) error {
+// savePlayerData 保存玩家数据，仅在玩家 Actor 内调用；只发布保存请求，不等待 Persist 写入
+func (s *Service) savePlayerData(playerState *PlayerState) error {
+	playerID := playerState.PlayerID
 	log.Printf("Game: Saving player data for %s", playerID)
 
-	// 保存到 persist 服务
-	req := map[string]interface{}{
-		"type":      "C_SavePlayer",
-		"player_id": playerID,
-		"data":      gameData,
+	req, err := savePlayerRequest(playerState)
+	if err != nil {
+		log.Printf("Game: Failed to encode save for %s: %v", playerID, err)
+		return err
 	}
 
-	err := s.natsManager.Publish(common.PersistSavePlayerSubject, req)
+	err = s.bus.Publish(common.PersistSavePlayerSubject, req)
 	if err != nil {
 		log.Printf("Game: Failed to publish save request: %v", err)
 		return err
 	}
 
+	if err := s.saveProgress(playerState); err != nil {
+		log.Printf("Game: Failed to save progress for %s: %v", playerID, err)
+		return err
+	}
+
 	log.Printf("Game: Successfully sent save request for player %s", playerID)
 	return nil
 }
 
-func (s *Service) saveAllPlayerData() {
-	s.playersMutex.RLock()
-	defer s.playersMutex.RUnlock()
+// savePlayerDataConfirmed 保存玩家数据并等待 Persist 确认写入，仅在玩家 Actor 内调用。
+// 托管物品或货币后必须先确认存档已写入再提交操作，否则保存丢失时重新加载的旧存档会与已完成的操作重复
+func (s *Service) savePlayerDataConfirmed(playerState *PlayerState) error {
+	playerID := playerState.PlayerID
+	req, err := savePlayerRequest(playerState)
+	if err != nil {
+		return fmt.Errorf("failed to encode save: %w", err)
+	}
 
-	for playerID, playerState := range s.players {
-		if err := s.savePlayerData(playerID, playerState.GameData); err != nil {
-			log.Printf("Failed to save data for player %s: %v", playerID, err)
-		}
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := s.bus.RequestWithReply(common.PersistSavePlayerSubject, req, &result, 5*time.Second); err != nil {
+		return fmt.Errorf("failed to save player data: %w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("failed to save player data: %s", result.Error)
+	}
+	for _, op := range playerState.Market.Pending {
+		op.unsaved = false
+	}
+	for _, op := range playerState.SectOps.Pending {
+		op.unsaved = false
 	}
 
-	log.Printf("All player data saved during shutdown")
+	if err := s.saveProgress(playerState); err != nil {
+		log.Printf("Game: Failed to save progress for %s: %v", playerID, err)
+	}
+	log.Printf("Game: Player data for %s saved and confirmed", playerID)
+	return nil
+}
+
+// savePlayerRequest 生成保存玩家数据的请求
+func savePlayerRequest(playerState *PlayerState) (map[string]interface{}, error) {
+	gameData, err := encodeSave(snapshotSave(playerState, time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"type":      "C_SavePlayer",
+		"player_id": playerState.PlayerID,
+		"data": &common.PlayerData{
+			PlayerID: playerState.PlayerID,
+			Level:    playerState.Level,
+			Exp:      playerState.Experience,
+			Aptitude: playerState.Aptitude,
+			GameData: gameData,
+		},
+	}, nil
 }
 
 // GetConnectedPlayers 获取连接的玩家数量（用于调试和监控）
@@ -420,12 +577,3 @@ func (s *Service) GetConnectedPlayers() int {
 	defer s.playersMutex.RUnlock()
 	return len(s.players)
 }
-
-// GetPlayerState 获取玩家状态（用于调试和监控）
-func (s *Service) GetPlayerState(playerID string) (*PlayerState, bool) {
-	s.playersMutex.RLock()
-	defer s.playersMutex.RUnlock()
-
-	state, exists := s.players[playerID]
-	return state, exists
-}
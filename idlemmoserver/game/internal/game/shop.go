@@ -0,0 +1,277 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 商店（仅在玩家 Actor 内调用） ============
+// 购买在玩家 Actor 内串行执行：先校验上架、等级、限购、价格和背包空间，全部满足后才扣除货币并发放物品。
+// 客户端为每次购买生成请求ID，最近的购买与货币、背包保存在同一份存档中，重试的请求直接返回原结果而不重复扣费；
+// 存档中找不到的请求ID在扣费前再向 Persist 查询 purchase_history 表（(player_id, request_id) 唯一），
+// 已有记录时同样返回原结果，无法确认时拒绝购买。购买成功后写入 purchase_history 表
+
+// 商店相关的游戏动作
+const (
+	shopActionList    = "shop_list"
+	shopActionBuy     = "shop_buy"
+	shopActionHistory = "shop_history"
+)
+
+// ShopState 玩家的限购次数与最近的购买，随存档保存
+type ShopState struct {
+	Limits map[string]*ShopLimit   `json:"limits,omitempty"` // 商店ID/商品ID -> 本周期购买次数
+	Recent []common.PurchaseRecord `json:"recent,omitempty"` // 最近的购买，用于识别重试的请求
+}
+
+// ShopLimit 商品在某个周期内的购买次数
+type ShopLimit struct {
+	Period string `json:"period"` // 周期开始日期（服务器时区）
+	Count  int    `json:"count"`
+}
+
+// newShopState 创建空的商店状态
+func newShopState() *ShopState {
+	return &ShopState{Limits: make(map[string]*ShopLimit)}
+}
+
+// purchased 商品在周期 period 内的购买次数，记录的周期不同时视为已重置
+func (s *ShopState) purchased(shopID, offerID, period string) int {
+	if limit, ok := s.Limits[shopID+"/"+offerID]; ok && limit.Period == period {
+		return limit.Count
+	}
+	return 0
+}
+
+// recordPurchase 记录购买次数与最近的购买
+func (s *ShopState) recordPurchase(record common.PurchaseRecord, period string, times int) {
+	if s.Limits == nil {
+		s.Limits = make(map[string]*ShopLimit)
+	}
+	key := record.ShopID + "/" + record.OfferID
+	s.Limits[key] = &ShopLimit{Period: period, Count: s.purchased(record.ShopID, record.OfferID, period) + times}
+
+	s.Recent = append(s.Recent, record)
+	if len(s.Recent) > common.ShopRecentPurchases {
+		s.Recent = s.Recent[len(s.Recent)-common.ShopRecentPurchases:]
+	}
+}
+
+// applyShopAction 执行商店动作并推送结果
+func (s *Service) applyShopAction(playerState *PlayerState, action string, params map[string]interface{}) (interface{}, error) {
+	now := time.Now()
+	var result interface{}
+	switch action {
+	case shopActionList:
+		result = shopList(playerState, now)
+	case shopActionBuy:
+		purchase, err := s.purchase(playerState, params, now)
+		if err != nil {
+			return nil, err
+		}
+		result = purchase
+	case shopActionHistory:
+		purchases, err := s.purchaseHistory(playerState.PlayerID)
+		if err != nil {
+			return nil, err
+		}
+		result = &common.S_PurchaseHistory{Type: common.ServerMsgTypePurchaseHistory, Purchases: purchases}
+	default:
+		return nil, fmt.Errorf("unknown shop action: %s", action)
+	}
+
+	playerState.LastActive = now
+	s.pushToClient(playerState.PlayerID, result)
+	return result, nil
+}
+
+// shopList 全部商店当前周期的商品与玩家的购买次数
+func shopList(playerState *PlayerState, now time.Time) *common.S_ShopList {
+	content := common.CurrentContent()
+	list := &common.S_ShopList{Type: common.ServerMsgTypeShopList, Shops: make([]common.ShopStatus, 0, len(content.Shops))}
+	for _, def := range content.Shops {
+		period, resetsAt := content.Schedule.Period(def.ResetPeriod(), now)
+		status := common.ShopStatus{ID: def.ID, Name: def.Name, Type: def.Type, ResetsAt: resetsAt.Unix()}
+		for _, offer := range def.ActiveOffers(period) {
+			status.Offers = append(status.Offers, common.ShopOfferStatus{
+				OfferID:   offer.ID,
+				ItemID:    offer.ItemID,
+				Count:     offer.Count,
+				Price:     offer.Price,
+				Limit:     offer.Limit,
+				Purchased: playerState.Shop.purchased(def.ID, offer.ID, period),
+				MinLevel:  offer.MinLevel,
+			})
+		}
+		list.Shops = append(list.Shops, status)
+	}
+	return list
+}
+
+// purchase 购买商品，同一请求ID已处理过时返回原结果
+func (s *Service) purchase(playerState *PlayerState, params map[string]interface{}, now time.Time) (*common.S_PurchaseResult, error) {
+	requestID, _ := params["request_id"].(string)
+	if requestID == "" || len(requestID) > common.ShopRequestIDMaxLength {
+		return nil, fmt.Errorf("request_id is required (at most %d characters)", common.ShopRequestIDMaxLength)
+	}
+	for _, record := range playerState.Shop.Recent {
+		if record.RequestID == requestID {
+			return &common.S_PurchaseResult{
+				Type:      common.ServerMsgTypePurchaseResult,
+				Purchase:  record,
+				Balances:  currencyBalances(playerState, record.Price, now),
+				Duplicate: true,
+			}, nil
+		}
+	}
+	// 存档只保留最近的购买，更早的请求ID以 purchase_history 为准
+	recorded, err := s.findPurchase(playerState.PlayerID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if recorded != nil {
+		return &common.S_PurchaseResult{
+			Type:      common.ServerMsgTypePurchaseResult,
+			Purchase:  *recorded,
+			Balances:  currencyBalances(playerState, recorded.Price, now),
+			Duplicate: true,
+		}, nil
+	}
+
+	shopID, _ := params["shop_id"].(string)
+	offerID, _ := params["offer_id"].(string)
+	count, _ := params["count"].(float64)
+	times := max(int(count), 1)
+	if times > common.ShopMaxPurchaseCount {
+		return nil, fmt.Errorf("at most %d per purchase", common.ShopMaxPurchaseCount)
+	}
+
+	content := common.CurrentContent()
+	def, ok := content.Shops[shopID]
+	if !ok {
+		return nil, fmt.Errorf("unknown shop: %s", shopID)
+	}
+	period, _ := content.Schedule.Period(def.ResetPeriod(), now)
+	var offer *common.ShopOffer
+	for _, active := range def.ActiveOffers(period) {
+		if active.ID == offerID {
+			offer = &active
+			break
+		}
+	}
+	if offer == nil {
+		return nil, fmt.Errorf("offer %s is not available in shop %s", offerID, shopID)
+	}
+	if playerState.Level < offer.MinLevel {
+		return nil, fmt.Errorf("requires level %d", offer.MinLevel)
+	}
+	if purchased := playerState.Shop.purchased(shopID, offerID, period); offer.Limit > 0 && purchased+times > offer.Limit {
+		return nil, fmt.Errorf("purchase limit reached (%d/%d)", purchased, offer.Limit)
+	}
+
+	// 先校验价格与背包空间，全部满足后再扣费发货
+	price := make(map[string]int64, len(offer.Price))
+	for resource, amount := range offer.Price {
+		price[resource] = amount * int64(times)
+		if settleStamina(playerState, resource, now) < price[resource] {
+			return nil, fmt.Errorf("insufficient %s", resource)
+		}
+	}
+	items := map[string]int{offer.ItemID: offer.Count * times}
+	if !playerState.Inventory.Fits(items) {
+		return nil, fmt.Errorf("inventory full")
+	}
+
+	for resource, amount := range price {
+		if _, err := s.changeCurrency(playerState, resource, -amount, common.CurrencySourceShop); err != nil {
+			return nil, err
+		}
+	}
+	update := s.grantItems(playerState, items, "shop")
+	s.pushToClient(playerState.PlayerID, update)
+
+	record := common.PurchaseRecord{
+		RequestID:   requestID,
+		PlayerID:    playerState.PlayerID,
+		ShopID:      shopID,
+		OfferID:     offerID,
+		ItemID:      offer.ItemID,
+		Count:       offer.Count * times,
+		Price:       price,
+		PurchasedAt: now.Unix(),
+	}
+	playerState.Shop.recordPurchase(record, period, times)
+	log.Printf("Player %s bought %s/%s x%d (%s)", playerState.PlayerID, shopID, offerID, times, requestID)
+
+	req := map[string]interface{}{
+		"type":     "C_RecordPurchase",
+		"purchase": record,
+	}
+	if err := s.bus.Publish(common.PersistRecordPurchaseSubject, req); err != nil {
+		log.Printf("Failed to record purchase %s for %s: %v", requestID, playerState.PlayerID, err)
+	}
+
+	return &common.S_PurchaseResult{
+		Type:     common.ServerMsgTypePurchaseResult,
+		Purchase: record,
+		Balances: currencyBalances(playerState, price, now),
+		Overflow: update.Overflow,
+	}, nil
+}
+
+// currencyBalances 价格涉及的货币的当前余额
+func currencyBalances(playerState *PlayerState, price map[string]int64, now time.Time) map[string]int64 {
+	balances := make(map[string]int64, len(price))
+	for resource := range price {
+		balances[resource] = settleStamina(playerState, resource, now)
+	}
+	return balances
+}
+
+// findPurchase 向 Persist 查询请求ID对应的购买记录，不存在时返回 nil
+func (s *Service) findPurchase(playerID, requestID string) (*common.PurchaseRecord, error) {
+	req := map[string]interface{}{
+		"type":       "C_FindPurchase",
+		"player_id":  playerID,
+		"request_id": requestID,
+	}
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Purchase *common.PurchaseRecord `json:"purchase"`
+		} `json:"data"`
+	}
+	if err := s.bus.RequestWithReply(common.PersistFindPurchaseSubject, req, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to verify purchase request: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to verify purchase request: %s", result.Error)
+	}
+	return result.Data.Purchase, nil
+}
+
+// purchaseHistory 从 Persist 查询玩家最近的购买记录
+func (s *Service) purchaseHistory(playerID string) ([]common.PurchaseRecord, error) {
+	req := map[string]interface{}{
+		"type":      "C_PurchaseHistory",
+		"player_id": playerID,
+	}
+	var result struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Purchases []common.PurchaseRecord `json:"purchases"`
+		} `json:"data"`
+	}
+	if err := s.bus.RequestWithReply(common.PersistPurchaseHistorySubject, req, &result, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("failed to load purchase history: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to load purchase history: %s", result.Error)
+	}
+	return result.Data.Purchases, nil
+}
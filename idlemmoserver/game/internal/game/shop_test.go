@@ -0,0 +1,69 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// noRecordedPurchase purchase_history 中没有该请求ID的回复
+func noRecordedPurchase(req map[string]interface{}) interface{} {
+	return map[string]interface{}{"success": true, "data": map[string]interface{}{"purchase": nil}}
+}
+
+func TestShopPurchaseCheckedAgainstHistoryBeforeDebit(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	bus.handlers[common.PersistFindPurchaseSubject] = noRecordedPurchase
+
+	result, err := s.purchase(state, map[string]interface{}{
+		"request_id": "req_1", "shop_id": "general_store", "offer_id": "spirit_herb_10",
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("shop_buy: %v", err)
+	}
+	if result.Duplicate || state.Resources["gold"] != 50 || state.Inventory.Count("spirit_herb") != 10 {
+		t.Errorf("duplicate = %t, gold = %d, spirit_herb = %d; want false, 50, 10",
+			result.Duplicate, state.Resources["gold"], state.Inventory.Count("spirit_herb"))
+	}
+	if got := bus.subjects(); len(got) != 1 || got[0] != common.PersistFindPurchaseSubject {
+		t.Errorf("requests = %v, want the history lookup", got)
+	}
+}
+
+func TestShopPurchaseOlderThanSaveIsDuplicate(t *testing.T) {
+	s, bus, state := newMarketTestService(t)
+	// 请求ID已不在存档的最近购买中，但 purchase_history 中有记录
+	bus.handlers[common.PersistFindPurchaseSubject] = func(req map[string]interface{}) interface{} {
+		return map[string]interface{}{"success": true, "data": map[string]interface{}{"purchase": common.PurchaseRecord{
+			RequestID: req["request_id"].(string), PlayerID: "p1", ShopID: "general_store", OfferID: "spirit_herb_10",
+			ItemID: "spirit_herb", Count: 10, Price: map[string]int64{"gold": 50},
+		}}}
+	}
+
+	result, err := s.purchase(state, map[string]interface{}{
+		"request_id": "req_old", "shop_id": "general_store", "offer_id": "spirit_herb_10",
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("shop_buy: %v", err)
+	}
+	if !result.Duplicate || result.Purchase.RequestID != "req_old" {
+		t.Errorf("result = %+v, want the recorded purchase as a duplicate", result)
+	}
+	if state.Resources["gold"] != 100 || state.Inventory.Count("spirit_herb") != 0 {
+		t.Errorf("gold = %d, spirit_herb = %d; want 100, 0", state.Resources["gold"], state.Inventory.Count("spirit_herb"))
+	}
+}
+
+func TestShopPurchaseRefusedWhenHistoryUnavailable(t *testing.T) {
+	s, _, state := newMarketTestService(t)
+
+	if _, err := s.purchase(state, map[string]interface{}{
+		"request_id": "req_1", "shop_id": "general_store", "offer_id": "spirit_herb_10",
+	}, time.Now()); err == nil {
+		t.Fatalf("purchase succeeded without verifying the request ID")
+	}
+	if state.Resources["gold"] != 100 || state.Inventory.Count("spirit_herb") != 0 {
+		t.Errorf("gold = %d, spirit_herb = %d; want 100, 0", state.Resources["gold"], state.Inventory.Count("spirit_herb"))
+	}
+}
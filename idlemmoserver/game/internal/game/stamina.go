@@ -0,0 +1,80 @@
+package game
+
+import (
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 体力回复（仅在玩家 Actor 内调用） ============
+// 回复资源的数值保存在 Resources 中，StaminaRegen 记录各资源的回复计时起点。
+// 不随 Tick 推进：读取或变动前调用 settleStamina，按起点到现在经过的完整间隔数补足，
+// 起点随之前移（不足一个间隔的时间保留）；达到自然回复上限时起点停在当前时间，消耗后从此刻开始计时
+
+// settleStamina 结算资源到 now 为止的自然回复，返回结算后的数值；不是回复资源时原样返回
+func settleStamina(playerState *PlayerState, resource string, now time.Time) int64 {
+	value := playerState.Resources[resource]
+	def, ok := common.GetStaminaDef(resource)
+	if !ok {
+		return value
+	}
+	if playerState.StaminaRegen == nil {
+		playerState.StaminaRegen = make(map[string]int64)
+	}
+
+	anchor, ok := playerState.StaminaRegen[resource]
+	if !ok || anchor > now.Unix() {
+		anchor = now.Unix()
+	}
+	if value < def.Max {
+		if ticks := (now.Unix() - anchor) / def.RegenSeconds; ticks > 0 {
+			value = min(value+ticks*def.RegenAmount, def.Max)
+			anchor += ticks * def.RegenSeconds
+			playerState.Resources[resource] = value
+		}
+	}
+	if value >= def.Max {
+		anchor = now.Unix()
+	}
+	playerState.StaminaRegen[resource] = anchor
+	return value
+}
+
+// settleAllStamina 结算全部回复资源
+func settleAllStamina(playerState *PlayerState, now time.Time) {
+	for resource := range common.CurrentContent().Stamina {
+		settleStamina(playerState, resource, now)
+	}
+}
+
+// staminaStatus 生成全部回复资源的状态，调用前需已结算
+func staminaStatus(playerState *PlayerState) map[string]common.StaminaStatus {
+	content := common.CurrentContent()
+	statuses := make(map[string]common.StaminaStatus, len(content.Stamina))
+	for resource, def := range content.Stamina {
+		status := common.StaminaStatus{
+			Current:      playerState.Resources[resource],
+			Max:          def.Max,
+			Cap:          def.Cap,
+			RegenAmount:  def.RegenAmount,
+			RegenSeconds: def.RegenSeconds,
+		}
+		if status.Current < def.Max {
+			anchor := playerState.StaminaRegen[resource]
+			status.NextRegenAt = anchor + def.RegenSeconds
+			ticks := (def.Max - status.Current + def.RegenAmount - 1) / def.RegenAmount
+			status.FullAt = anchor + ticks*def.RegenSeconds
+		}
+		statuses[resource] = status
+	}
+	return statuses
+}
+
+// staminaUpdate 结算并生成回复资源的推送
+func staminaUpdate(playerState *PlayerState, now time.Time) *common.S_StaminaUpdate {
+	settleAllStamina(playerState, now)
+	return &common.S_StaminaUpdate{
+		Type:    common.ServerMsgTypeStaminaUpdate,
+		Stamina: staminaStatus(playerState),
+	}
+}
@@ -9,13 +9,15 @@ import (
 
 // MessageHandler 消息处理器接口
 type MessageHandler interface {
-	HandleMessage(playerID string, data []byte) error
+	HandleMessage(conn *ClientConnection, data []byte) error
 }
 
 // ClientConnection 客户端连接 - 纯 WebSocket 连接管理
 type ClientConnection struct {
 	conn           *websocket.Conn
 	playerID       string
+	sessionID      string // 绑定令牌的登录会话，会话被撤销时关闭连接
+	displayName    string // 角色显示名称，聊天等公开场景使用
 	messageHandler MessageHandler
 	onClose        func(playerID string)
 	done           chan struct{}
@@ -84,6 +86,26 @@ func (c *ClientConnection) GetPlayerID() string {
 	return c.playerID
 }
 
+// SetSessionID 设置登录会话ID
+func (c *ClientConnection) SetSessionID(sessionID string) {
+	c.sessionID = sessionID
+}
+
+// GetSessionID 获取登录会话ID
+func (c *ClientConnection) GetSessionID() string {
+	return c.sessionID
+}
+
+// SetDisplayName 设置角色显示名称
+func (c *ClientConnection) SetDisplayName(name string) {
+	c.displayName = name
+}
+
+// GetDisplayName 获取角色显示名称
+func (c *ClientConnection) GetDisplayName() string {
+	return c.displayName
+}
+
 // readPump 读取消息循环
 func (c *ClientConnection) readPump() {
 	defer c.Close()
@@ -108,7 +130,7 @@ func (c *ClientConnection) readPump() {
 			return
 		}
 
-		log.Printf("Read WebSocket message: %s", string(data))
+		log.Printf("Read WebSocket message: %s", redactMessage(data))
 
 		// 处理消息
 		if err := c.handleMessage(data); err != nil {
@@ -124,5 +146,5 @@ func (c *ClientConnection) handleMessage(data []byte) error {
 		return nil
 	}
 
-	return c.messageHandler.HandleMessage(c.playerID, data)
+	return c.messageHandler.HandleMessage(c, data)
 }
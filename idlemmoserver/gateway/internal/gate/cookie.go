@@ -0,0 +1,122 @@
+package gate
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/idle-server/common"
+)
+
+// ============ 浏览器 Cookie 会话模式 ============
+// 客户端通过 X-Auth-Mode: cookie 声明后，登录、刷新令牌和切换角色时令牌写入
+// HttpOnly Cookie 而不在响应体中返回；同时下发可读的 CSRF Cookie，
+// 使用 Cookie 认证的写操作必须在 X-CSRF-Token 头中回传该值（双重提交校验）
+
+// cookieMode 客户端是否请求 Cookie 会话模式
+func cookieMode(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader(common.AuthModeHeader), common.AuthModeCookie)
+}
+
+// sessionCookieToken 读取会话 Cookie 中的令牌
+func sessionCookieToken(c *gin.Context) string {
+	token, err := c.Cookie(common.SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// cookieAuthenticated 本次请求是否通过会话 Cookie 认证（未携带 Authorization 头）
+func cookieAuthenticated(c *gin.Context) bool {
+	return c.GetHeader("Authorization") == "" && sessionCookieToken(c) != ""
+}
+
+// setSessionCookies 写入会话 Cookie 和新的 CSRF Cookie
+func setSessionCookies(c *gin.Context, token string) error {
+	csrfToken, err := generateCSRFToken()
+	if err != nil {
+		return err
+	}
+
+	maxAge := common.SessionTTLHours * 3600
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     common.SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     common.CSRFCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// clearSessionCookies 清除会话 Cookie 和 CSRF Cookie
+func clearSessionCookies(c *gin.Context) {
+	for _, name := range []string{common.SessionCookieName, common.CSRFCookieName} {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == common.SessionCookieName,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
+
+// issueToken 按客户端的认证模式下发令牌：Cookie 模式写入 Cookie 并返回空字符串，否则原样返回
+func issueToken(c *gin.Context, token string) (string, error) {
+	if token == "" || !(cookieMode(c) || cookieAuthenticated(c)) {
+		return token, nil
+	}
+	if err := setSessionCookies(c, token); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// csrfMiddleware 对使用会话 Cookie 认证的写操作校验 CSRF 令牌
+func (s *Service) csrfMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if !cookieAuthenticated(c) {
+			c.Next()
+			return
+		}
+
+		expected, err := c.Cookie(common.CSRFCookieName)
+		provided := c.GetHeader(common.CSRFHeaderName)
+		if err != nil || expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(provided)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// generateCSRFToken 生成 CSRF 令牌
+func generateCSRFToken() (string, error) {
+	bytes := make([]byte, common.CSRFTokenLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
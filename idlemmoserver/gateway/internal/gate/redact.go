@@ -0,0 +1,49 @@
+package gate
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============ 日志脱敏 ============
+// 访问日志和消息日志中的令牌、密码等凭据替换为占位符，避免写入日志文件
+
+const redactedValue = "[REDACTED]"
+
+var (
+	// sensitiveQueryPattern 匹配 URL 查询串中的凭据参数
+	sensitiveQueryPattern = regexp.MustCompile(`(?i)([?&](?:token|access_token|challenge_token|enrollment_token|api_key|key|key_secret|secret)=)[^&#]*`)
+	// sensitiveJSONPattern 匹配 JSON 消息中的凭据字段，值为字符串或字符串数组（恢复码）；
+	// 字段名同时覆盖请求中的下划线写法和响应中的驼峰写法
+	sensitiveJSONPattern = regexp.MustCompile(`(?i)("(?:token|challenge_?token|enrollment_?token|password|old_password|new_password|code|key|api_?key|key_?secret|secret|totp_?secret|provisioning_?uri|recovery_?codes)"\s*:\s*)` +
+		`(?:"(?:[^"\\]|\\.)*"|\[\s*(?:"(?:[^"\\]|\\.)*"\s*,?\s*)*\])`)
+)
+
+// redactURL 脱敏 URL 查询串中的凭据
+func redactURL(url string) string {
+	return sensitiveQueryPattern.ReplaceAllString(url, "${1}"+redactedValue)
+}
+
+// redactMessage 脱敏 JSON 消息中的凭据字段
+func redactMessage(data []byte) string {
+	return sensitiveJSONPattern.ReplaceAllString(string(data), `${1}"`+redactedValue+`"`)
+}
+
+// redactedLogFormatter Gin 访问日志格式，与默认格式一致但对请求路径脱敏
+func redactedLogFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redactURL(param.Path),
+		param.ErrorMessage,
+	)
+}
@@ -0,0 +1,65 @@
+package gate
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// loggedMessage 按 connection.go 的方式记录消息并返回日志内容
+func loggedMessage(t *testing.T, data string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	log.Printf("Read WebSocket message: %s", redactMessage([]byte(data)))
+	return buf.String()
+}
+
+func TestRedactTwoFactorEnrollment(t *testing.T) {
+	secrets := []string{"enroll-7f3a", "JBSWY3DPEHPK3PXP", "otpauth://totp/IdleServer:alice?secret=JBSWY3DPEHPK3PXP", "rc-one", "rc-two", "chal-9c1d"}
+	payloads := []string{
+		`{"challenge_token":"chal-9c1d","enrollment_token":"enroll-7f3a"}`,
+		`{"success":true,"challengeToken":"chal-9c1d","enrollmentRequired":true,` +
+			`"twoFactorSetup":{"secret":"JBSWY3DPEHPK3PXP","provisioningUri":"otpauth://totp/IdleServer:alice?secret=JBSWY3DPEHPK3PXP"}}`,
+		`{"success":true,"enrollmentToken":"enroll-7f3a","recoveryCodes":["rc-one", "rc-two"]}`,
+		`{"totp_secret":"JBSWY3DPEHPK3PXP","recovery_codes":["rc-one","rc-two"]}`,
+	}
+	for _, payload := range payloads {
+		logged := loggedMessage(t, payload)
+		for _, secret := range secrets {
+			if strings.Contains(logged, secret) {
+				t.Errorf("log contains %q: %s", secret, logged)
+			}
+		}
+		if !strings.Contains(logged, redactedValue) {
+			t.Errorf("nothing redacted: %s", logged)
+		}
+	}
+}
+
+func TestRedactAPIKeyCreation(t *testing.T) {
+	payloads := []string{
+		`{"api_key":"ik_live_51f0","key_secret":"ks_2b7e"}`,
+		`{"success":true,"key":"ik_live_51f0","keySecret":"ks_2b7e","api_key":{"key_id":"ak_1","name":"stats"}}`,
+	}
+	for _, payload := range payloads {
+		logged := loggedMessage(t, payload)
+		if strings.Contains(logged, "ik_live_51f0") || strings.Contains(logged, "ks_2b7e") {
+			t.Errorf("log contains the API key: %s", logged)
+		}
+	}
+	// 非凭据字段保留，便于排查
+	if logged := loggedMessage(t, payloads[1]); !strings.Contains(logged, `"name":"stats"`) {
+		t.Errorf("non-secret fields redacted: %s", logged)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	url := redactURL("/ws?token=abc&enrollment_token=def&secret=ghi&page=2")
+	if strings.Contains(url, "abc") || strings.Contains(url, "def") || strings.Contains(url, "ghi") || !strings.Contains(url, "page=2") {
+		t.Errorf("redactURL = %s", url)
+	}
+}
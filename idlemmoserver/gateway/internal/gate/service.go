@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -88,17 +90,77 @@ func (s *Service) GetHTTPHandler() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 
+	// Gin 默认信任所有代理，客户端可伪造 X-Forwarded-For；会话与审计记录的IP取自 ClientIP，只信任配置的代理
+	proxies := common.EnvList(common.GatewayTrustedProxiesEnv)
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Printf("Invalid %s %v, trusting no proxies: %v", common.GatewayTrustedProxiesEnv, proxies, err)
+		r.SetTrustedProxies(nil)
+	}
+
 	// 添加中间件
-	r.Use(gin.Logger())
+	r.Use(gin.LoggerWithFormatter(redactedLogFormatter))
 	r.Use(gin.Recovery())
 	r.Use(s.corsMiddleware())
+	r.Use(s.csrfMiddleware())
 
 	// WebSocket 升级端点
 	r.GET("/ws", s.handleWebSocket)
 
 	// 认证端点（保持原有路径）
 	r.POST("/login", s.handleLogin)
+	r.POST("/login/2fa", s.handleLoginTwoFactor)
 	r.POST("/register", s.handleRegister)
+	r.POST("/logout", s.handleLogout)
+
+	// 双因素认证管理端点（需要 Bearer Token）
+	r.POST("/2fa/setup", s.handleTwoFactorManage(common.AuthTwoFactorSetupSubject, "C_TwoFactorSetup"))
+	r.POST("/2fa/enable", s.handleTwoFactorManage(common.AuthTwoFactorEnableSubject, "C_TwoFactorEnable"))
+	r.POST("/2fa/disable", s.handleTwoFactorManage(common.AuthTwoFactorDisableSubject, "C_TwoFactorDisable"))
+	r.POST("/admin/2fa/require", s.handleTwoFactorRequire)
+
+	// 账号管理端点（需要 Bearer Token）
+	r.POST("/token/refresh", s.handleTokenRefresh)
+	r.POST("/account/password", s.handleChangePassword)
+
+	// 客服/GM 端点：封禁与审计查询
+	r.POST("/admin/ban", s.handleBanUser)
+	r.GET("/admin/audit/events", s.handleAuditQuery(common.AuthAuditEventsSubject, "C_AuditEvents"))
+	r.GET("/admin/audit/devices", s.handleAuditQuery(common.AuthAuditDevicesSubject, "C_AuditDevices"))
+
+	// 运维端点：热更新游戏配置表、向角色发放补偿（仅管理员）
+	r.POST("/admin/content/reload", s.handleContentReload)
+	r.POST("/admin/players/:id/grant", s.handleAdminGrant)
+
+	// 角色管理端点（需要 Bearer Token）
+	r.GET("/characters", s.handleCharacter(common.AuthCharacterListSubject, "C_CharacterList", ""))
+	r.POST("/characters", s.handleCharacter(common.AuthCharacterCreateSubject, "C_CharacterCreate", "name"))
+	r.DELETE("/characters/:id", s.handleCharacter(common.AuthCharacterDeleteSubject, "C_CharacterDelete", "player_id"))
+	r.POST("/characters/:id/restore", s.handleCharacter(common.AuthCharacterRestoreSubject, "C_CharacterRestore", "player_id"))
+	r.POST("/characters/:id/select", s.handleCharacter(common.AuthCharacterSelectSubject, "C_CharacterSelect", "player_id"))
+	r.POST("/characters/:id/rename", s.handleCharacter(common.AuthCharacterRenameSubject, "C_CharacterRename", "rename"))
+	r.GET("/characters/:id/names", s.handleCharacter(common.AuthCharacterNamesSubject, "C_CharacterNames", "player_id"))
+	r.POST("/characters/:id/reroll", s.handleCharacter(common.AuthCharacterRerollSubject, "C_CharacterReroll", "player_id"))
+
+	// 数据导出与账号删除端点（需要 Bearer Token）
+	r.POST("/account/export", s.handleDataRequest(common.AuthDataExportSubject, "C_DataExport", ""))
+	r.POST("/account/erasure", s.handleDataRequest(common.AuthDataErasureSubject, "C_DataErasure", "password"))
+	r.POST("/account/erasure/:id/cancel", s.handleDataRequest(common.AuthDataErasureCancelSubject, "C_DataErasureCancel", "request_id"))
+	r.GET("/account/data-requests", s.handleDataRequest(common.AuthDataRequestStatusSubject, "C_DataRequestStatus", ""))
+	r.GET("/account/data-requests/:id", s.handleDataRequest(common.AuthDataRequestStatusSubject, "C_DataRequestStatus", "request_id"))
+
+	// 登录会话与设备管理端点（需要 Bearer Token）
+	r.GET("/sessions", s.handleSession(common.AuthSessionListSubject, "C_SessionList", ""))
+	r.DELETE("/sessions/:id", s.handleSession(common.AuthSessionRevokeSubject, "C_SessionRevoke", "session_id"))
+	r.POST("/sessions/revoke-others", s.handleSession(common.AuthSessionRevokeOthersSubject, "C_SessionRevokeOthers", ""))
+
+	// API密钥管理端点（仅管理员）
+	r.POST("/admin/api-keys", s.handleAPIKeyCreate)
+	r.GET("/admin/api-keys", s.handleAPIKey(common.AuthAPIKeyListSubject, "C_APIKeyList", ""))
+	r.DELETE("/admin/api-keys/:id", s.handleAPIKey(common.AuthAPIKeyRevokeSubject, "C_APIKeyRevoke", "key_id"))
+
+	// 公开只读端点（玩家 Bearer Token 或 X-API-Key）
+	r.GET("/leaderboard", s.requireReadAccess(common.APIKeyScopeLeaderboard), s.handleLeaderboard)
+	r.GET("/players/:id/profile", s.requireReadAccess(common.APIKeyScopeProfile), s.handlePlayerProfile)
 
 	// 健康检查端点
 	r.GET("/health", s.handleHealth)
@@ -108,14 +170,25 @@ func (s *Service) GetHTTPHandler() *gin.Engine {
 }
 
 // handleWebSocket 处理 WebSocket 连接
+// 携带会话 Cookie 的升级请求在握手时完成认证，无需再发送 C_Login
 func (s *Service) handleWebSocket(c *gin.Context) {
-	log.Printf("=== WebSocket Connection Request ===")
-	log.Printf("URL: %s", c.Request.URL.String())
-	log.Printf("Headers:")
-	for name, values := range c.Request.Header {
-		log.Printf("  %s: %v", name, values)
+	log.Printf("WebSocket connection request: %s", redactURL(c.Request.URL.String()))
+
+	// Cookie 认证的握手必须来自允许的页面来源，防止跨站 WebSocket 劫持
+	var verified *common.MsgVerifyTokenResult
+	if token := sessionCookieToken(c); token != "" {
+		if !isAllowedOrigin(c.GetHeader("Origin")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Origin not allowed"})
+			return
+		}
+		var err error
+		verified, err = s.verifyToken(token)
+		if err != nil {
+			log.Printf("WebSocket cookie authentication failed: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
 	}
-	log.Printf("Query params: %s", c.Request.URL.RawQuery)
 
 	// 升级到 WebSocket 连接
 	conn, err := s.upgrader.Upgrade(c.Writer, c.Request)
@@ -136,7 +209,10 @@ func (s *Service) handleWebSocket(c *gin.Context) {
 	// 添加到连接管理器
 	s.connections.Store(conn.RemoteAddr().String(), clientConn)
 	log.Printf("WebSocket connection added to manager")
-	log.Printf("===============================")
+
+	if verified != nil {
+		s.bindConnection(clientConn, verified)
+	}
 }
 
 // handleHealth 处理健康检查
@@ -154,8 +230,9 @@ func (s *Service) handleDebug(c *gin.Context) {
 	s.connections.Range(func(key, value interface{}) bool {
 		if conn, ok := value.(*ClientConnection); ok {
 			connections = append(connections, gin.H{
-				"addr":     key.(string),
-				"playerID": conn.GetPlayerID(),
+				"addr":      key.(string),
+				"playerID":  conn.GetPlayerID(),
+				"sessionID": conn.GetSessionID(),
 			})
 		}
 		return true
@@ -180,7 +257,13 @@ func (s *Service) registerNATSHandlers() error {
 		broadcastCh: s.broadcastCh,
 	}
 
-	_, err := s.natsManager.Subscribe(common.GatewayBroadcastSubject, broadcastHandler)
+	if _, err := s.natsManager.Subscribe(common.GatewayBroadcastSubject, broadcastHandler); err != nil {
+		return err
+	}
+
+	// 订阅会话撤销通知
+	sessionRevokedHandler := &sessionRevokedHandler{service: s}
+	_, err := s.natsManager.Subscribe(common.GatewaySessionRevokedSubject, sessionRevokedHandler)
 	return err
 }
 
@@ -214,21 +297,799 @@ func (s *Service) handleLogin(c *gin.Context) {
 
 	log.Printf("Login request for user: %s", req.Username)
 
-	result, err := s.authenticateUser(req.Username, req.Password)
+	result, err := s.authenticateUser(req.Username, req.Password, clientMetadata(c))
 	if err != nil {
 		log.Printf("Failed to authenticate user: %v", err)
 		c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
 		return
 	}
 
-	// 返回结果
-	if result.Success {
+	if result.Success && !result.TwoFactorRequired {
+		if result.Token, err = issueToken(c, result.Token); err != nil {
+			log.Printf("Failed to set session cookie: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+			return
+		}
+	}
+
+	// 返回结果 - 需要双因素认证时返回 202，客户端需调用 /login/2fa 完成登录
+	if result.Success && result.TwoFactorRequired {
+		c.JSON(http.StatusAccepted, result)
+	} else if result.Success {
 		c.JSON(http.StatusOK, result)
 	} else {
 		c.JSON(http.StatusUnauthorized, result)
 	}
 }
 
+// handleLoginTwoFactor 处理登录第二步（提交动态码或恢复码）
+// 尚未绑定的强制2FA账号先提交 enrollment_token 换取绑定信息（202），再提交首个动态码完成绑定与登录
+func (s *Service) handleLoginTwoFactor(c *gin.Context) {
+	var req struct {
+		ChallengeToken  string `json:"challenge_token" binding:"required"`
+		Code            string `json:"code"`
+		EnrollmentToken string `json:"enrollment_token"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Code == "" && req.EnrollmentToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or enrollment_token is required"})
+		return
+	}
+
+	var result common.MsgAuthenticateUserResult
+	failure, err := s.requestAuth(common.AuthTwoFactorVerifySubject, map[string]interface{}{
+		"type":            "C_TwoFactorVerify",
+		"challenge_token":  req.ChallengeToken,
+		"code":             req.Code,
+		"enrollment_token": req.EnrollmentToken,
+		"metadata":         clientMetadata(c),
+	}, &result)
+	if err != nil {
+		log.Printf("Failed to verify two-factor login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		c.JSON(http.StatusUnauthorized, common.MsgAuthenticateUserResult{Success: false, Message: failure})
+		return
+	}
+
+	if result.TwoFactorRequired {
+		c.JSON(http.StatusAccepted, result)
+		return
+	}
+
+	if result.Token, err = issueToken(c, result.Token); err != nil {
+		log.Printf("Failed to set session cookie: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// handleTwoFactorManage 处理双因素认证的绑定、确认和解绑
+func (s *Service) handleTwoFactorManage(subject, msgType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		var result common.MsgTwoFactorResult
+		failure, err := s.requestAuth(subject, map[string]interface{}{
+			"type":     msgType,
+			"token":    token,
+			"code":     req.Code,
+			"metadata": clientMetadata(c),
+		}, &result)
+		if err != nil {
+			log.Printf("Failed to call two-factor service: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+			return
+		}
+
+		if failure != "" {
+			c.JSON(http.StatusBadRequest, common.MsgTwoFactorResult{Success: false, Message: failure})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// handleTwoFactorRequire 处理GM/管理员强制开启双因素认证
+func (s *Service) handleTwoFactorRequire(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+		return
+	}
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Required *bool  `json:"required" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result common.MsgTwoFactorResult
+	failure, err := s.requestAuth(common.AuthTwoFactorRequireSubject, map[string]interface{}{
+		"type":     "C_TwoFactorRequire",
+		"token":    token,
+		"username": req.Username,
+		"required": *req.Required,
+		"metadata": clientMetadata(c),
+	}, &result)
+	if err != nil {
+		log.Printf("Failed to update two-factor requirement: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		c.JSON(http.StatusForbidden, common.MsgTwoFactorResult{Success: false, Message: failure})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// handleTokenRefresh 处理令牌刷新
+func (s *Service) handleTokenRefresh(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+		return
+	}
+
+	var result common.MsgAccountResult
+	failure, err := s.requestAuth(common.AuthRefreshTokenSubject, map[string]interface{}{
+		"type":     "C_RefreshToken",
+		"token":    token,
+		"metadata": clientMetadata(c),
+	}, &result)
+	if err != nil {
+		log.Printf("Failed to refresh token: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		result = common.MsgAccountResult{Success: false, Message: failure}
+	}
+	if !result.Success {
+		c.JSON(http.StatusUnauthorized, result)
+		return
+	}
+
+	if result.Token, err = issueToken(c, result.Token); err != nil {
+		log.Printf("Failed to set session cookie: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// handleChangePassword 处理修改密码
+func (s *Service) handleChangePassword(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+		return
+	}
+
+	var req struct {
+		OldPassword string `json:"old_password" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result common.MsgAccountResult
+	failure, err := s.requestAuth(common.AuthChangePasswordSubject, map[string]interface{}{
+		"type":         "C_ChangePassword",
+		"token":        token,
+		"old_password": req.OldPassword,
+		"new_password": req.NewPassword,
+		"metadata":     clientMetadata(c),
+	}, &result)
+	if err != nil {
+		log.Printf("Failed to change password: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		result = common.MsgAccountResult{Success: false, Message: failure}
+	}
+	if !result.Success {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// handleBanUser 处理客服/GM封禁或解封账号
+func (s *Service) handleBanUser(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+		return
+	}
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Banned   *bool  `json:"banned" binding:"required"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result common.MsgAccountResult
+	failure, err := s.requestAuth(common.AuthBanUserSubject, map[string]interface{}{
+		"type":     "C_BanUser",
+		"token":    token,
+		"username": req.Username,
+		"banned":   *req.Banned,
+		"reason":   req.Reason,
+		"metadata": clientMetadata(c),
+	}, &result)
+	if err != nil {
+		log.Printf("Failed to update ban status: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		result = common.MsgAccountResult{Success: false, Message: failure}
+	}
+	if !result.Success {
+		c.JSON(http.StatusForbidden, result)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// handleContentReload 处理管理员热更新游戏配置表，新表校验失败时 Game 保留当前版本
+func (s *Service) handleContentReload(c *gin.Context) {
+	caller, ok := s.requireAdminCaller(c)
+	if !ok {
+		return
+	}
+
+	var result common.MsgContentReloadResult
+	failure, err := s.requestService(common.GameContentReloadSubject, map[string]interface{}{
+		"type": common.ClientMsgTypeContentReload,
+	}, &result)
+	if err != nil {
+		log.Printf("Failed to reload game content: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Game service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		c.JSON(http.StatusUnprocessableEntity, common.MsgContentReloadResult{Success: false, Message: failure})
+		return
+	}
+
+	log.Printf("Game content reloaded by %s: %s -> %s", caller.Username, result.PreviousVersion, result.Version)
+	c.JSON(http.StatusOK, result)
+}
+
+// handleAdminGrant 处理管理员向角色发放经验、货币（amount 可为负数）或物品
+func (s *Service) handleAdminGrant(c *gin.Context) {
+	caller, ok := s.requireAdminCaller(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Exp      int64  `json:"exp"`
+		Resource string `json:"resource"`
+		Amount   int64  `json:"amount"`
+		ItemID   string `json:"item_id"`
+		Count    int    `json:"count"`
+		Reason   string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result common.MsgAdminGrantResult
+	failure, err := s.requestService(common.GameAdminGrantSubject, map[string]interface{}{
+		"type":      common.ClientMsgTypeAdminGrant,
+		"player_id": c.Param("id"),
+		"exp":       req.Exp,
+		"resource":  req.Resource,
+		"amount":    req.Amount,
+		"item_id":   req.ItemID,
+		"count":     req.Count,
+	}, &result)
+	if err != nil {
+		log.Printf("Failed to grant to player %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Game service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		c.JSON(http.StatusUnprocessableEntity, common.MsgAdminGrantResult{Success: false, Message: failure})
+		return
+	}
+
+	log.Printf("Admin %s granted player %s (exp %d, %s %d, item %s x%d): %s",
+		caller.Username, c.Param("id"), req.Exp, req.Resource, req.Amount, req.ItemID, req.Count, req.Reason)
+	c.JSON(http.StatusOK, result)
+}
+
+// requireAdminCaller 校验请求来自管理员，失败时已写入响应
+func (s *Service) requireAdminCaller(c *gin.Context) (*common.MsgVerifyTokenResult, bool) {
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+		return nil, false
+	}
+
+	caller, err := s.verifyToken(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if caller.Role != common.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return nil, false
+	}
+	return caller, true
+}
+
+// handleAuditQuery 处理客服查询认证事件和设备历史
+// 查询参数: username, player_id, event_type, since, until (RFC3339), limit, offset
+func (s *Service) handleAuditQuery(subject, msgType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+
+		filters := make(map[string]interface{})
+		for _, key := range []string{"username", "player_id", "event_type", "since", "until"} {
+			if value := c.Query(key); value != "" {
+				filters[key] = value
+			}
+		}
+		for _, key := range []string{"limit", "offset"} {
+			if value := c.Query(key); value != "" {
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + key})
+					return
+				}
+				filters[key] = n
+			}
+		}
+
+		var result map[string]interface{}
+		failure, err := s.requestAuth(subject, map[string]interface{}{
+			"type":    msgType,
+			"token":   token,
+			"filters": filters,
+		}, &result)
+		if err != nil {
+			log.Printf("Failed to query audit log: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+			return
+		}
+
+		if failure != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": failure})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// handleCharacter 处理角色列表、创建、删除、恢复、选择、改名、改名记录和资质重随
+// argKey 为 "name" 时从请求体读取角色名，为 "player_id" 时取路径参数 :id，为 "rename" 时两者都需要
+func (s *Service) handleCharacter(subject, msgType, argKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+
+		msg := map[string]interface{}{
+			"type":     msgType,
+			"token":    token,
+			"metadata": clientMetadata(c),
+		}
+
+		switch argKey {
+		case "name", "rename":
+			var req struct {
+				Name string `json:"name" binding:"required"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			msg["name"] = req.Name
+			if argKey == "rename" {
+				msg["player_id"] = c.Param("id")
+			}
+		case "player_id":
+			msg["player_id"] = c.Param("id")
+		}
+
+		var result common.MsgCharacterResult
+		failure, err := s.requestAuth(subject, msg, &result)
+		if err != nil {
+			log.Printf("Failed to call character service: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+			return
+		}
+
+		if failure != "" {
+			c.JSON(http.StatusBadRequest, common.MsgCharacterResult{Success: false, Message: failure})
+			return
+		}
+
+		if msgType == "C_CharacterCreate" {
+			c.JSON(http.StatusCreated, result)
+			return
+		}
+		if result.Token, err = issueToken(c, result.Token); err != nil {
+			log.Printf("Failed to set session cookie: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch character"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// handleDataRequest 处理数据导出、账号删除、撤销删除和状态查询
+// argKey 为 "password" 时从请求体读取密码，为 "request_id" 时取路径参数 :id
+func (s *Service) handleDataRequest(subject, msgType, argKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+
+		msg := map[string]interface{}{
+			"type":     msgType,
+			"token":    token,
+			"metadata": clientMetadata(c),
+		}
+
+		switch argKey {
+		case "password":
+			var req struct {
+				Password string `json:"password" binding:"required"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			msg["password"] = req.Password
+		case "request_id":
+			msg["request_id"] = c.Param("id")
+		}
+
+		var result common.MsgDataRequestResult
+		failure, err := s.requestAuth(subject, msg, &result)
+		if err != nil {
+			log.Printf("Failed to call data request service: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+			return
+		}
+
+		if failure != "" {
+			c.JSON(http.StatusBadRequest, common.MsgDataRequestResult{Success: false, Message: failure})
+			return
+		}
+
+		// 申请类请求异步处理，返回 202
+		if msgType == "C_DataExport" || msgType == "C_DataErasure" {
+			c.JSON(http.StatusAccepted, result)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// handleSession 处理登录会话列表、撤销指定会话和撤销其他会话
+// argKey 为 "session_id" 时取路径参数 :id
+func (s *Service) handleSession(subject, msgType, argKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+
+		msg := map[string]interface{}{
+			"type":     msgType,
+			"token":    token,
+			"metadata": clientMetadata(c),
+		}
+		if argKey == "session_id" {
+			msg["session_id"] = c.Param("id")
+		}
+
+		var result common.MsgSessionResult
+		failure, err := s.requestAuth(subject, msg, &result)
+		if err != nil {
+			log.Printf("Failed to call session service: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+			return
+		}
+
+		if failure != "" {
+			c.JSON(http.StatusBadRequest, common.MsgSessionResult{Success: false, Message: failure})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// handleAPIKeyCreate 处理管理员签发API密钥
+func (s *Service) handleAPIKeyCreate(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+		return
+	}
+
+	var req struct {
+		Name           string   `json:"name" binding:"required"`
+		Scopes         []string `json:"scopes" binding:"required"`
+		RateLimit      int      `json:"rate_limit"`
+		ExpiresInHours int      `json:"expires_in_hours"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result common.MsgAPIKeyResult
+	failure, err := s.requestAuth(common.AuthAPIKeyCreateSubject, map[string]interface{}{
+		"type":             "C_APIKeyCreate",
+		"token":            token,
+		"name":             req.Name,
+		"scopes":           req.Scopes,
+		"rate_limit":       req.RateLimit,
+		"expires_in_hours": req.ExpiresInHours,
+		"metadata":         clientMetadata(c),
+	}, &result)
+	if err != nil {
+		log.Printf("Failed to create API key: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		c.JSON(http.StatusBadRequest, common.MsgAPIKeyResult{Success: false, Message: failure})
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// handleAPIKey 处理API密钥列表和吊销
+// argKey 为 "key_id" 时取路径参数 :id
+func (s *Service) handleAPIKey(subject, msgType, argKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+
+		msg := map[string]interface{}{
+			"type":     msgType,
+			"token":    token,
+			"metadata": clientMetadata(c),
+		}
+		if argKey == "key_id" {
+			msg["key_id"] = c.Param("id")
+		}
+
+		var result common.MsgAPIKeyResult
+		failure, err := s.requestAuth(subject, msg, &result)
+		if err != nil {
+			log.Printf("Failed to call API key service: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+			return
+		}
+
+		if failure != "" {
+			c.JSON(http.StatusForbidden, common.MsgAPIKeyResult{Success: false, Message: failure})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// requireReadAccess 只读端点的鉴权中间件：接受 X-API-Key（需具备 scope 权限并受限流约束）或玩家 Bearer Token
+func (s *Service) requireReadAccess(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader("X-API-Key")
+		token := bearerToken(c)
+		if apiKey == "" && strings.HasPrefix(token, common.APIKeyPrefix) {
+			apiKey = token
+		}
+
+		if apiKey != "" {
+			var result common.MsgAPIKeyResult
+			failure, err := s.requestAuth(common.AuthAPIKeyValidateSubject, map[string]interface{}{
+				"type":  "C_APIKeyValidate",
+				"key":   apiKey,
+				"scope": scope,
+			}, &result)
+			if err != nil {
+				log.Printf("Failed to validate API key: %v", err)
+				c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
+				return
+			}
+
+			if failure == common.APIKeyRateLimitedMessage {
+				c.Header("Retry-After", strconv.Itoa(60-time.Now().Second()))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": failure})
+				return
+			}
+			if failure != "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": failure})
+				return
+			}
+
+			if result.APIKey != nil {
+				c.Header("X-RateLimit-Limit", strconv.Itoa(result.APIKey.RateLimit))
+			}
+			c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			c.Next()
+			return
+		}
+
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token or API key"})
+			return
+		}
+		if _, _, err := s.extractPlayerIDFromToken(token); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleLeaderboard 处理等级排行榜查询
+// 查询参数: limit
+func (s *Service) handleLeaderboard(c *gin.Context) {
+	msg := map[string]interface{}{
+		"type": "C_Leaderboard",
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		msg["limit"] = limit
+	}
+
+	var result map[string]interface{}
+	failure, err := s.requestService(common.PersistLeaderboardSubject, msg, &result)
+	if err != nil {
+		log.Printf("Failed to query leaderboard: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Persist service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": failure})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// handlePlayerProfile 处理角色公开资料查询
+func (s *Service) handlePlayerProfile(c *gin.Context) {
+	var result common.PlayerProfile
+	failure, err := s.requestService(common.PersistPlayerProfileSubject, map[string]interface{}{
+		"type":      "C_PlayerProfile",
+		"player_id": c.Param("id"),
+	}, &result)
+	if err != nil {
+		log.Printf("Failed to query player profile: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Persist service unavailable"})
+		return
+	}
+
+	if failure != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": failure})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// closeRevokedSessions 关闭已撤销会话的 WebSocket 连接
+func (s *Service) closeRevokedSessions(sessionIDs []string, reason string) {
+	revoked := make(map[string]bool, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		revoked[sessionID] = true
+	}
+
+	s.connections.Range(func(key, value interface{}) bool {
+		if conn, ok := value.(*ClientConnection); ok {
+			if sessionID := conn.GetSessionID(); sessionID != "" && revoked[sessionID] {
+				log.Printf("Closing connection %s: session %s %s", key, sessionID, reason)
+				conn.Send(s.createErrorMessage("Session " + reason))
+				conn.Close()
+				s.connections.Delete(key)
+			}
+		}
+		return true
+	})
+}
+
+// handleLogout 退出登录：撤销当前令牌绑定的会话并清除会话 Cookie
+func (s *Service) handleLogout(c *gin.Context) {
+	token := bearerToken(c)
+	if token != "" {
+		if _, sessionID, err := s.extractPlayerIDFromToken(token); err == nil && sessionID != "" {
+			var result common.MsgSessionResult
+			failure, err := s.requestAuth(common.AuthSessionRevokeSubject, map[string]interface{}{
+				"type":       "C_SessionRevoke",
+				"token":      token,
+				"session_id": sessionID,
+				"metadata":   clientMetadata(c),
+			}, &result)
+			if err != nil {
+				log.Printf("Failed to revoke session on logout: %v", err)
+			} else if failure != "" {
+				log.Printf("Failed to revoke session on logout: %s", failure)
+			}
+		}
+	}
+
+	clearSessionCookies(c)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
 // handleRegister 处理注册请求
 func (s *Service) handleRegister(c *gin.Context) {
 	var req struct {
@@ -241,7 +1102,7 @@ func (s *Service) handleRegister(c *gin.Context) {
 		return
 	}
 
-	result, err := s.registerUser(req.Username, req.Password)
+	result, err := s.registerUser(req.Username, req.Password, clientMetadata(c))
 	if err != nil {
 		log.Printf("Failed to register user: %v", err)
 		c.JSON(http.StatusBadGateway, gin.H{"error": "Auth service unavailable"})
@@ -257,7 +1118,9 @@ func (s *Service) handleRegister(c *gin.Context) {
 }
 
 // HandleMessage 实现 MessageHandler 接口 - 处理来自 WebSocket 连接的消息
-func (s *Service) HandleMessage(playerID string, data []byte) error {
+func (s *Service) HandleMessage(conn *ClientConnection, data []byte) error {
+	playerID := conn.GetPlayerID()
+
 	// 解析客户端消息
 	var clientMsg map[string]interface{}
 	if err := json.Unmarshal(data, &clientMsg); err != nil {
@@ -273,11 +1136,19 @@ func (s *Service) HandleMessage(playerID string, data []byte) error {
 
 	switch msgType {
 	case "C_Login":
-		return s.handleWSLogin(playerID, data)
+		return s.handleWSLogin(conn, data)
 	case "C_Ping":
-		return s.handleWSPing(playerID)
+		return s.handleWSPing(conn)
 	case "C_ClientPayload":
 		return s.handleWSClientPayload(playerID, data)
+	case common.ClientMsgTypeStartSeq, common.ClientMsgTypeStopSeq:
+		return s.handleWSSequence(conn, msgType, data)
+	case common.ClientMsgTypeInventoryUse, common.ClientMsgTypeInventoryDiscard, common.ClientMsgTypeInventorySort:
+		return s.handleWSInventory(conn, msgType, data)
+	case common.ClientMsgTypeEquip, common.ClientMsgTypeUnequip:
+		return s.handleWSEquipment(conn, msgType, data)
+	case common.ClientMsgTypeGameAction:
+		return s.handleWSGameAction(conn, data)
 	default:
 		log.Printf("Unknown message type: %s", msgType)
 		return fmt.Errorf("unknown message type: %s", msgType)
@@ -287,6 +1158,12 @@ func (s *Service) HandleMessage(playerID string, data []byte) error {
 // onConnectionClose 连接关闭回调
 func (s *Service) onConnectionClose(playerID string) {
 	log.Printf("Connection closed for player: %s", playerID)
+	// 通知 Game 服务保存并卸载玩家，离线期间的收益在下次上线时结算
+	if playerID != "" {
+		if err := s.unregisterPlayerFromGame(playerID); err != nil {
+			log.Printf("Failed to unregister player %s from game service: %v", playerID, err)
+		}
+	}
 	// 从连接管理器中移除连接
 	s.connections.Range(func(key, value interface{}) bool {
 		if conn, ok := value.(*ClientConnection); ok {
@@ -299,8 +1176,8 @@ func (s *Service) onConnectionClose(playerID string) {
 	})
 }
 
-// handleWSLogin 处理 WebSocket 登录消息
-func (s *Service) handleWSLogin(playerID string, data []byte) error {
+// handleWSLogin 处理 WebSocket 登录消息，连接绑定到令牌中选择的角色
+func (s *Service) handleWSLogin(conn *ClientConnection, data []byte) error {
 	var loginMsg struct {
 		Token string `json:"token"`
 	}
@@ -309,39 +1186,47 @@ func (s *Service) handleWSLogin(playerID string, data []byte) error {
 		return err
 	}
 
-	// 验证 token 并获取 playerID
-	extractedPlayerID, err := s.extractPlayerIDFromToken(loginMsg.Token)
+	// 验证 token 并获取绑定的角色 playerID 和登录会话
+	verified, err := s.verifyToken(loginMsg.Token)
 	if err != nil {
 		log.Printf("Failed to extract playerID from token: %v", err)
-		s.sendToConnection(playerID, s.createErrorMessage("Invalid token"))
+		conn.Send(s.createErrorMessage(err.Error()))
 		return err
 	}
 
+	return s.bindConnection(conn, verified)
+}
+
+// bindConnection 将已认证的连接绑定到令牌中的角色和登录会话，并注册到 Game 服务
+func (s *Service) bindConnection(conn *ClientConnection, verified *common.MsgVerifyTokenResult) error {
+	playerID := verified.PlayerID
 	// 注册玩家到 Game 服务
-	if err := s.registerPlayerToGame(extractedPlayerID); err != nil {
+	if err := s.registerPlayerToGame(playerID); err != nil {
 		log.Printf("Failed to register player to game service: %v", err)
-		s.sendToConnection(playerID, s.createErrorMessage("Failed to register player"))
+		conn.Send(s.createErrorMessage("Failed to register player"))
 		return err
 	}
 
-	// 更新连接的 playerID
-	s.updateConnectionPlayerID(playerID, extractedPlayerID)
+	// 更新连接的 playerID、会话和显示名称
+	conn.SetPlayerID(playerID)
+	conn.SetSessionID(verified.SessionID)
+	conn.SetDisplayName(verified.Name)
 
 	// 发送登录成功消息
-	s.sendToConnection(extractedPlayerID, s.createLoginSuccessMessage(extractedPlayerID))
-	log.Printf("Player %s logged in and registered to game service", extractedPlayerID)
+	conn.Send(s.createLoginSuccessMessage(playerID, verified.Name))
+	log.Printf("Player %s logged in and registered to game service", playerID)
 
 	return nil
 }
 
 // handleWSPing 处理 WebSocket ping 消息
-func (s *Service) handleWSPing(playerID string) error {
+func (s *Service) handleWSPing(conn *ClientConnection) error {
 	pongMsg := map[string]interface{}{
 		"type": "S_Pong",
 		"time": time.Now().Unix(),
 	}
 	data, _ := json.Marshal(pongMsg)
-	s.sendToConnection(playerID, data)
+	conn.Send(data)
 	return nil
 }
 
@@ -370,32 +1255,89 @@ func (s *Service) handleWSClientPayload(playerID string, data []byte) error {
 	return nil
 }
 
-// 辅助方法
-func (s *Service) extractPlayerIDFromToken(tokenString string) (string, error) {
-	// 简化的 JWT 解析实现
-	// 在生产环境中应该向 Auth 服务验证
-	parts := strings.Split(tokenString, ".")
-	if len(parts) != 3 {
-		return "", fmt.Errorf("invalid token format")
+// handleWSSequence 将开始/停止修炼序列转发给 Game 服务，结算结果由 Game 通过广播推送
+func (s *Service) handleWSSequence(conn *ClientConnection, msgType string, data []byte) error {
+	var seqMsg common.CStartSeq
+	if err := json.Unmarshal(data, &seqMsg); err != nil {
+		return err
 	}
 
-	// 这里应该实现完整的 JWT 解析和验证
-	// 为了简化，暂时返回一个假的 playerID
-	return "temp_player_id", nil
+	return s.forwardToGame(conn, common.GameSequenceSubject, map[string]interface{}{
+		"type":        msgType,
+		"sequence_id": seqMsg.SequenceID,
+	})
 }
 
-func (s *Service) updateConnectionPlayerID(oldPlayerID, newPlayerID string) {
-	s.connections.Range(func(key, value interface{}) bool {
-		if conn, ok := value.(*ClientConnection); ok {
-			if conn.GetPlayerID() == oldPlayerID {
-				conn.SetPlayerID(newPlayerID)
-				return false
-			}
-		}
-		return true
+// handleWSInventory 将背包操作转发给 Game 服务，背包变化由 Game 通过 S_InventoryUpdate 推送
+func (s *Service) handleWSInventory(conn *ClientConnection, msgType string, data []byte) error {
+	var invMsg common.CInventoryAction
+	if err := json.Unmarshal(data, &invMsg); err != nil {
+		return err
+	}
+
+	return s.forwardToGame(conn, common.GameInventorySubject, map[string]interface{}{
+		"type":  msgType,
+		"slot":  invMsg.Slot,
+		"count": invMsg.Count,
 	})
 }
 
+// handleWSEquipment 将穿戴/卸下装备转发给 Game 服务，结果由 Game 通过 S_EquipmentUpdate 推送
+func (s *Service) handleWSEquipment(conn *ClientConnection, msgType string, data []byte) error {
+	var equipMsg common.CEquipAction
+	if err := json.Unmarshal(data, &equipMsg); err != nil {
+		return err
+	}
+
+	return s.forwardToGame(conn, common.GameEquipmentSubject, map[string]interface{}{
+		"type":       msgType,
+		"slot":       equipMsg.Slot,
+		"equip_slot": equipMsg.EquipSlot,
+	})
+}
+
+// handleWSGameAction 将游戏动作（任务等）转发给 Game 服务，结果由 Game 推送
+func (s *Service) handleWSGameAction(conn *ClientConnection, data []byte) error {
+	var actionMsg common.CGameAction
+	if err := json.Unmarshal(data, &actionMsg); err != nil {
+		return err
+	}
+	if actionMsg.Action == "" {
+		conn.Send(s.createErrorMessage("missing action"))
+		return fmt.Errorf("missing action")
+	}
+
+	return s.forwardToGame(conn, common.GameActionSubject, map[string]interface{}{
+		"type":   common.ClientMsgTypeGameAction,
+		"action": actionMsg.Action,
+		"params": actionMsg.Params,
+	})
+}
+
+// forwardToGame 以连接的玩家身份向 Game 服务发送请求，失败时向客户端返回 S_Error
+func (s *Service) forwardToGame(conn *ClientConnection, subject string, msg map[string]interface{}) error {
+	playerID := conn.GetPlayerID()
+	if playerID == "" {
+		conn.Send(s.createErrorMessage("Player not authenticated"))
+		return fmt.Errorf("player not authenticated")
+	}
+
+	// playerID 取自已认证的连接，不信任客户端上报的值
+	msg["player_id"] = playerID
+
+	var result map[string]interface{}
+	failure, err := s.requestService(subject, msg, &result)
+	if err != nil {
+		log.Printf("Failed to forward %v for player %s: %v", msg["type"], playerID, err)
+		conn.Send(s.createErrorMessage("Game service unavailable"))
+		return err
+	}
+	if failure != "" {
+		conn.Send(s.createErrorMessage(failure))
+	}
+	return nil
+}
+
 func (s *Service) sendToConnection(playerID string, data []byte) {
 	s.connections.Range(func(key, value interface{}) bool {
 		if conn, ok := value.(*ClientConnection); ok {
@@ -417,44 +1359,47 @@ func (s *Service) createErrorMessage(message string) []byte {
 	return data
 }
 
-func (s *Service) createLoginSuccessMessage(playerID string) []byte {
+func (s *Service) createLoginSuccessMessage(playerID, name string) []byte {
 	response := map[string]interface{}{
 		"type":      "S_LoginOK",
 		"token":     "", // 由 Game 服务填充
 		"player_id": playerID,
+		"name":      name,
 	}
 	data, _ := json.Marshal(response)
 	return data
 }
 
+// allowedOrigins 允许跨域访问和 Cookie 认证握手的页面来源
+var allowedOrigins = []string{
+	"http://localhost:5173",
+	"http://localhost:3000",
+	"http://127.0.0.1:5173",
+	"http://127.0.0.1:3000",
+}
+
+// isAllowedOrigin 来源是否在允许列表中
+func isAllowedOrigin(origin string) bool {
+	for _, allowedOrigin := range allowedOrigins {
+		if origin == allowedOrigin {
+			return true
+		}
+	}
+	return false
+}
+
 // corsMiddleware CORS 中间件
 func (s *Service) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
 
-		// 允许的来源
-		allowedOrigins := []string{
-			"http://localhost:5173",
-			"http://localhost:3000",
-			"http://127.0.0.1:5173",
-			"http://127.0.0.1:3000",
-		}
-
 		// 检查来源是否在允许列表中
-		allowed := false
-		for _, allowedOrigin := range allowedOrigins {
-			if origin == allowedOrigin {
-				allowed = true
-				break
-			}
-		}
-
-		if allowed {
+		if isAllowedOrigin(origin) {
 			c.Header("Access-Control-Allow-Origin", origin)
 		}
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Device-Name, X-API-Key, X-Auth-Mode, X-CSRF-Token")
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
@@ -518,14 +1463,32 @@ func (h *broadcastMessageHandler) Handle(msg *natsio.Msg) error {
 	return nil
 }
 
+// sessionRevokedHandler 会话撤销通知处理器
+type sessionRevokedHandler struct {
+	service *Service
+}
+
+// Handle 实现MessageHandler接口
+func (h *sessionRevokedHandler) Handle(msg *natsio.Msg) error {
+	var revoked common.MsgSessionRevoked
+	if err := common.Unmarshal(msg.Data, &revoked); err != nil {
+		log.Printf("Failed to unmarshal session revoked message: %v", err)
+		return err
+	}
+
+	h.service.closeRevokedSessions(revoked.SessionIDs, revoked.Reason)
+	return nil
+}
+
 // 辅助方法 - 使用统一的NATS管理器
 
 // authenticateUser 认证用户
-func (s *Service) authenticateUser(username, password string) (*common.MsgAuthenticateUserResult, error) {
+func (s *Service) authenticateUser(username, password string, metadata map[string]interface{}) (*common.MsgAuthenticateUserResult, error) {
 	authMsg := map[string]interface{}{
 		"type":     "C_Login",
 		"username": username,
 		"password": password,
+		"metadata": metadata,
 	}
 
 	response, err := s.natsManager.Request(common.AuthLoginSubject, authMsg, 5*time.Second)
@@ -589,11 +1552,12 @@ func (s *Service) authenticateUser(username, password string) (*common.MsgAuthen
 }
 
 // registerUser 注册用户
-func (s *Service) registerUser(username, password string) (*common.MsgRegisterUserResult, error) {
+func (s *Service) registerUser(username, password string, metadata map[string]interface{}) (*common.MsgRegisterUserResult, error) {
 	regMsg := map[string]interface{}{
 		"type":     "C_Register",
 		"username": username,
 		"password": password,
+		"metadata": metadata,
 	}
 
 	response, err := s.natsManager.Request(common.AuthRegisterSubject, regMsg, 5*time.Second)
@@ -656,16 +1620,112 @@ func (s *Service) registerUser(username, password string) (*common.MsgRegisterUs
 	return nil, fmt.Errorf("failed to parse handler response: unknown format")
 }
 
+// requestAuth 调用认证服务并解析统一 Response 格式
+// 业务失败时返回失败原因（failure 非空），通信或解析失败时返回 error
+func (s *Service) requestAuth(subject string, msg map[string]interface{}, result interface{}) (string, error) {
+	return s.requestService(subject, msg, result)
+}
+
+// requestService 调用后端服务并解析统一 Response 格式，返回值约定同 requestAuth
+func (s *Service) requestService(subject string, msg map[string]interface{}, result interface{}) (string, error) {
+	response, err := s.natsManager.Request(subject, msg, 5*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("failed to call %s: %w", subject, err)
+	}
+
+	var handlerResponse struct {
+		Success bool        `json:"success"`
+		Data    interface{} `json:"data,omitempty"`
+		Error   string      `json:"error,omitempty"`
+	}
+	if err := common.Unmarshal(response.Data, &handlerResponse); err != nil {
+		return "", fmt.Errorf("failed to parse handler response: %w", err)
+	}
+
+	if !handlerResponse.Success {
+		if handlerResponse.Error == "" {
+			return "request failed", nil
+		}
+		return handlerResponse.Error, nil
+	}
+
+	dataBytes, err := json.Marshal(handlerResponse.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal response data: %w", err)
+	}
+	if err := json.Unmarshal(dataBytes, result); err != nil {
+		return "", fmt.Errorf("failed to unmarshal %s result: %w", subject, err)
+	}
+
+	return "", nil
+}
+
+// extractPlayerIDFromToken 向 Auth 服务校验令牌，返回令牌绑定的角色 playerID 和登录会话ID
+func (s *Service) extractPlayerIDFromToken(tokenString string) (string, string, error) {
+	result, err := s.verifyToken(tokenString)
+	if err != nil {
+		return "", "", err
+	}
+	return result.PlayerID, result.SessionID, nil
+}
+
+// verifyToken 向 Auth 服务校验令牌，返回令牌绑定的角色、登录会话和显示名称
+func (s *Service) verifyToken(tokenString string) (*common.MsgVerifyTokenResult, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid token format")
+	}
+
+	var result common.MsgVerifyTokenResult
+	failure, err := s.requestAuth(common.AuthValidateTokenSubject, map[string]interface{}{
+		"type":  "C_ValidateToken",
+		"token": tokenString,
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("auth service unavailable: %w", err)
+	}
+	if failure != "" {
+		return nil, errors.New(failure)
+	}
+
+	return &result, nil
+}
+
+// bearerToken 从 Authorization 头中提取 Bearer Token
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return sessionCookieToken(c)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+// clientMetadata 收集客户端IP和设备信息，随请求转发给认证服务用于审计和会话记录
+// 设备名称由客户端通过 X-Device-Name 头上报
+func clientMetadata(c *gin.Context) map[string]interface{} {
+	return map[string]interface{}{
+		"ip":          c.ClientIP(),
+		"user_agent":  c.Request.UserAgent(),
+		"device_name": c.GetHeader("X-Device-Name"),
+	}
+}
+
 // registerPlayerToGame 向游戏服务注册玩家
 func (s *Service) registerPlayerToGame(playerID string) error {
-	playerConnectMsg := &common.MsgPlayerConnect{
-		PlayerID: playerID,
+	playerConnectMsg := map[string]interface{}{
+		"type":      "C_PlayerConnect",
+		"player_id": playerID,
 	}
 
-	connectData, err := common.Marshal(playerConnectMsg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal player connect message: %w", err)
+	return s.natsManager.Publish(common.GamePlayerConnectSubject, playerConnectMsg)
+}
+
+// unregisterPlayerFromGame 通知游戏服务玩家已断开连接
+func (s *Service) unregisterPlayerFromGame(playerID string) error {
+	playerDisconnectMsg := map[string]interface{}{
+		"type":      "C_PlayerDisconnect",
+		"player_id": playerID,
 	}
 
-	return s.natsManager.Publish(common.GamePlayerConnectSubject, connectData)
+	return s.natsManager.Publish(common.GamePlayerDisconnectSubject, playerDisconnectMsg)
 }
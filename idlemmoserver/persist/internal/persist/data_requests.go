@@ -0,0 +1,153 @@
+package persist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+// ============ 数据导出与删除请求处理 ============
+
+// startDataRequestWorker 定时处理到期的数据导出/删除请求
+func (s *Service) startDataRequestWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Data request worker stopped")
+			return
+		case <-ticker.C:
+			if err := s.processDataRequests(); err != nil {
+				log.Printf("Failed to process data requests: %v", err)
+			}
+		}
+	}
+}
+
+// triggerDataRequests 收到 Auth 通知后异步处理到期请求
+func (s *Service) triggerDataRequests() error {
+	go func() {
+		if err := s.processDataRequests(); err != nil {
+			log.Printf("Failed to process data requests: %v", err)
+		}
+	}()
+	return nil
+}
+
+// processDataRequests 领取并处理到期请求，同时清理过期的导出档案
+func (s *Service) processDataRequests() error {
+	ctx := s.healthCheckCtx
+
+	requests, err := s.dataRequestRepo.ClaimDueRequests(ctx, common.DataRequestBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for i := range requests {
+		s.processDataRequest(ctx, &requests[i])
+	}
+
+	expiredBefore := time.Now().Add(-common.DataExportRetentionHours * time.Hour)
+	if purged, err := s.dataRequestRepo.PurgeExpiredArchives(ctx, expiredBefore); err != nil {
+		log.Printf("Failed to purge expired export archives: %v", err)
+	} else if purged > 0 {
+		log.Printf("Purged %d expired export archives", purged)
+	}
+
+	return nil
+}
+
+// processDataRequest 处理单个请求，失败时记录原因
+func (s *Service) processDataRequest(ctx context.Context, request *database.DataRequest) {
+	var archive string
+	var err error
+
+	switch request.Type {
+	case common.DataRequestTypeExport:
+		archive, err = s.exportAccountData(ctx, request.Username)
+	case common.DataRequestTypeErasure:
+		err = s.eraseAccountData(ctx, request.Username)
+	default:
+		err = fmt.Errorf("unknown data request type: %s", request.Type)
+	}
+
+	if err != nil {
+		log.Printf("Data request %s (%s) failed: %v", request.RequestID, request.Type, err)
+		if failErr := s.dataRequestRepo.FailRequest(ctx, request.ID, err.Error()); failErr != nil {
+			log.Printf("Failed to mark data request %s as failed: %v", request.RequestID, failErr)
+		}
+		return
+	}
+
+	if err := s.dataRequestRepo.CompleteRequest(ctx, request.ID, archive); err != nil {
+		log.Printf("Failed to mark data request %s as completed: %v", request.RequestID, err)
+		return
+	}
+
+	log.Printf("Data request %s (%s) completed", request.RequestID, request.Type)
+}
+
+// exportAccountData 生成账号数据档案
+func (s *Service) exportAccountData(ctx context.Context, username string) (string, error) {
+	export, err := s.accountDataRepo.ExportAccountData(ctx, username)
+	if err != nil {
+		return "", err
+	}
+
+	archive, err := json.Marshal(export)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal export archive: %w", err)
+	}
+
+	log.Printf("Exported account data for %s (%d bytes)", username, len(archive))
+	return string(archive), nil
+}
+
+// eraseAccountData 删除账号在 MySQL 和 Redis 中的全部数据
+func (s *Service) eraseAccountData(ctx context.Context, username string) error {
+	_, sessionIDs, err := s.accountDataRepo.EraseAccountData(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	// 会话已从 Redis 删除，还需通知 Gateway 断开仍在线的连接
+	if len(sessionIDs) > 0 {
+		if err := s.natsManager.Publish(common.GatewaySessionRevokedSubject, common.MsgSessionRevoked{
+			SessionIDs: sessionIDs,
+			Reason:     "account_erased",
+		}); err != nil {
+			log.Printf("Failed to notify gateway of erased sessions for %s: %v", username, err)
+		}
+	}
+	return nil
+}
+
+// scheduleAccountErasure 登记删除请求，冷静期结束后由 worker 执行
+func (s *Service) scheduleAccountErasure(ctx context.Context, username, requestedBy string) (*database.DataRequest, error) {
+	active, err := s.dataRequestRepo.FindActiveRequest(ctx, username, common.DataRequestTypeErasure)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return active, nil
+	}
+
+	request := &database.DataRequest{
+		RequestID:   fmt.Sprintf("dr_%d", time.Now().UnixNano()),
+		Username:    username,
+		Type:        common.DataRequestTypeErasure,
+		RequestedBy: requestedBy,
+		ScheduledAt: time.Now().Add(common.ErasureCoolingOffHours * time.Hour),
+	}
+	if err := s.dataRequestRepo.CreateRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
@@ -0,0 +1,34 @@
+package persist
+
+import (
+	"context"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 公开只读数据（排行榜、角色资料） ============
+// 供 Gateway 的只读接口使用，玩家令牌和 API 密钥均可访问
+
+// getLeaderboard 获取等级排行榜
+func (s *Service) getLeaderboard(limit int) ([]common.PlayerRanking, error) {
+	if limit <= 0 {
+		limit = common.LeaderboardDefaultLimit
+	}
+	if limit > common.LeaderboardMaxLimit {
+		limit = common.LeaderboardMaxLimit
+	}
+
+	rankings, err := s.playerRepo.GetTopPlayersByLevel(context.Background(), limit)
+	if err != nil {
+		return nil, err
+	}
+	if rankings == nil {
+		rankings = []common.PlayerRanking{}
+	}
+	return rankings, nil
+}
+
+// getPlayerProfile 获取角色公开资料
+func (s *Service) getPlayerProfile(playerID string) (*common.PlayerProfile, error) {
+	return s.playerRepo.GetPlayerProfile(context.Background(), playerID)
+}
@@ -0,0 +1,51 @@
+package persist
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 玩家邮件（mails 表） ============
+// Game 服务投递邮件并在领取时发放附件；领取以条件更新标记，同一封邮件的附件只发放一次
+
+// sendMail 写入一封邮件
+func (s *Service) sendMail(mail common.Mail) error {
+	if err := s.mailRepo.SendMail(context.Background(), mail); err != nil {
+		log.Printf("Failed to send mail %s to %s: %v", mail.MailID, mail.PlayerID, err)
+		return err
+	}
+	return nil
+}
+
+// listMail 玩家未过期的邮件
+func (s *Service) listMail(playerID string) ([]common.Mail, error) {
+	return s.mailRepo.ListMail(context.Background(), playerID, common.MailListLimit)
+}
+
+// claimMail 标记邮件已领取并返回附件
+func (s *Service) claimMail(playerID, mailID string) (*common.Mail, error) {
+	return s.mailRepo.ClaimMail(context.Background(), playerID, mailID)
+}
+
+// startMailCleanup 定时删除过期邮件
+func (s *Service) startMailCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Mail cleanup stopped")
+			return
+		case now := <-ticker.C:
+			if deleted, err := s.mailRepo.DeleteExpiredMail(ctx, now); err != nil {
+				log.Printf("Failed to delete expired mail: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Deleted %d expired mails", deleted)
+			}
+		}
+	}
+}
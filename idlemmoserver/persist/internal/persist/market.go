@@ -0,0 +1,110 @@
+package persist
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/idle-server/common"
+	"github.com/idle-server/common/database"
+)
+
+// ============ 玩家市场（market_listings、market_bids、market_trades 表） ============
+// Game 服务先在本地托管物品或货币再提交操作，每个操作在单个事务内完成并以操作ID去重；
+// 成交、退还的物品与货币写入收件人的邮件，写入后推送给在线的收件人
+
+// createMarketListing 创建挂单
+func (s *Service) createMarketListing(req common.MarketRequest) (interface{}, error) {
+	if req.Listing == nil {
+		return nil, fmt.Errorf("%w: missing listing", common.ErrMarketRejected)
+	}
+	listing := *req.Listing
+	listing.SellerID = req.PlayerID
+	return s.marketOutcome(s.marketRepo.CreateListing(context.Background(), listing))
+}
+
+// buyMarketListing 一口价购买
+func (s *Service) buyMarketListing(req common.MarketRequest) (interface{}, error) {
+	return s.marketOutcome(s.marketRepo.BuyListing(context.Background(), req.OpID, req.PlayerID, req.ListingID, req.Amount))
+}
+
+// bidMarketListing 出价
+func (s *Service) bidMarketListing(req common.MarketRequest) (interface{}, error) {
+	return s.marketOutcome(s.marketRepo.PlaceBid(context.Background(), req.OpID, req.PlayerID, req.ListingID, req.Amount))
+}
+
+// cancelMarketListing 撤单
+func (s *Service) cancelMarketListing(req common.MarketRequest) (interface{}, error) {
+	return s.marketOutcome(s.marketRepo.CancelListing(context.Background(), req.PlayerID, req.ListingID))
+}
+
+// searchMarket 搜索在售的挂单
+func (s *Service) searchMarket(req common.MarketRequest) (interface{}, error) {
+	var query common.MarketQuery
+	if req.Query != nil {
+		query = *req.Query
+	}
+	listings, total, err := s.marketRepo.Search(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"listings": listings, "total": total}, nil
+}
+
+// listPlayerMarket 玩家在售的挂单和领先出价的挂单
+func (s *Service) listPlayerMarket(req common.MarketRequest) (interface{}, error) {
+	listings, err := s.marketRepo.ListByPlayer(context.Background(), req.PlayerID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"listings": listings, "total": len(listings)}, nil
+}
+
+// marketOutcome 通知邮件收件人并返回操作后的挂单
+func (s *Service) marketOutcome(outcome *database.MarketOutcome, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	s.notifyMail(outcome.Mails)
+	return map[string]interface{}{"listing": outcome.Listing}, nil
+}
+
+// notifyMail 向在线的收件人推送新邮件，离线玩家在下次查看邮件时看到
+func (s *Service) notifyMail(mails []common.Mail) {
+	for _, mail := range mails {
+		data, err := common.Marshal(&common.S_MailReceived{Type: common.ServerMsgTypeMailReceived, Mail: mail})
+		if err != nil {
+			log.Printf("Failed to marshal mail %s: %v", mail.MailID, err)
+			continue
+		}
+		if err := s.natsManager.Publish(common.GatewayBroadcastSubject, common.MsgToClient{
+			PlayerID: mail.PlayerID,
+			Data:     data,
+		}); err != nil {
+			log.Printf("Failed to notify %s of mail %s: %v", mail.PlayerID, mail.MailID, err)
+		}
+	}
+}
+
+// startMarketSettlement 定时结算到期的挂单
+func (s *Service) startMarketSettlement(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Market settlement stopped")
+			return
+		case now := <-ticker.C:
+			mails, err := s.marketRepo.SettleExpired(ctx, now, common.MarketSettleBatch)
+			s.notifyMail(mails)
+			if err != nil {
+				log.Printf("Failed to settle expired listings: %v", err)
+			} else if len(mails) > 0 {
+				log.Printf("Settled expired listings, %d mails sent", len(mails))
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package persist
+
+import (
+	"context"
+	"log"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 玩家进度（game_progress 表） ============
+// Game 服务在玩家上线时整体加载，保存时只写入变化的记录
+
+// loadProgress 加载玩家的全部进度记录
+func (s *Service) loadProgress(playerID string) ([]common.ProgressEntry, error) {
+	return s.progressRepo.LoadProgress(context.Background(), playerID)
+}
+
+// saveProgress 保存玩家变化的进度记录
+func (s *Service) saveProgress(playerID string, entries []common.ProgressEntry) error {
+	if err := s.progressRepo.SaveProgress(context.Background(), playerID, entries); err != nil {
+		log.Printf("Failed to save progress for %s: %v", playerID, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package persist
+
+import (
+	"context"
+	"log"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 商店购买记录（purchase_history 表） ============
+// Game 服务在扣费前按请求ID查找已有记录以识别重试的请求，购买成功后写入；同一请求ID重复写入时报告已存在
+
+// recordPurchase 写入购买记录
+func (s *Service) recordPurchase(record common.PurchaseRecord) error {
+	if err := s.purchaseRepo.RecordPurchase(context.Background(), record); err != nil {
+		log.Printf("Failed to record purchase %s for %s: %v", record.RequestID, record.PlayerID, err)
+		return err
+	}
+	return nil
+}
+
+// findPurchase 按请求ID查找玩家的购买记录
+func (s *Service) findPurchase(playerID, requestID string) (*common.PurchaseRecord, error) {
+	return s.purchaseRepo.FindPurchase(context.Background(), playerID, requestID)
+}
+
+// listPurchases 玩家最近的购买记录
+func (s *Service) listPurchases(playerID string) ([]common.PurchaseRecord, error) {
+	return s.purchaseRepo.ListPurchases(context.Background(), playerID, common.ShopHistoryLimit)
+}
@@ -0,0 +1,124 @@
+package persist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/idle-server/common"
+)
+
+// ============ 宗门（sects、sect_members、sect_applications、sect_treasury_logs 表） ============
+// 每个操作在单个事务内锁定宗门、校验职位权限后修改；创建宗门与捐献消耗的金币由 Game 先托管，
+// 以宗门ID或流水ID去重，操作被拒绝时 Game 退还托管的金币
+
+// createSect 创建宗门
+func (s *Service) createSect(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.CreateSect(context.Background(), req)
+}
+
+// applySect 申请加入宗门
+func (s *Service) applySect(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.Apply(context.Background(), req)
+}
+
+// reviewSectApplication 审核入门申请
+func (s *Service) reviewSectApplication(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.Review(context.Background(), req)
+}
+
+// leaveSect 退出宗门
+func (s *Service) leaveSect(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.Leave(context.Background(), req)
+}
+
+// kickSectMember 逐出成员
+func (s *Service) kickSectMember(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.Kick(context.Background(), req)
+}
+
+// setSectRank 任免长老
+func (s *Service) setSectRank(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.SetRank(context.Background(), req)
+}
+
+// transferSect 传位
+func (s *Service) transferSect(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.Transfer(context.Background(), req)
+}
+
+// disbandSect 解散宗门
+func (s *Service) disbandSect(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.Disband(context.Background(), req)
+}
+
+// setSectNotice 修改宗门公告
+func (s *Service) setSectNotice(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.SetNotice(context.Background(), req)
+}
+
+// donateSect 向宝库捐献金币
+func (s *Service) donateSect(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.Donate(context.Background(), req)
+}
+
+// withdrawSect 从宝库拨发金币，通知收件人
+func (s *Service) withdrawSect(req common.SectRequest) (interface{}, error) {
+	result, mail, err := s.sectRepo.Withdraw(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	if mail != nil {
+		s.notifyMail([]common.Mail{*mail})
+	}
+	return result, nil
+}
+
+// upgradeSect 升级宗门
+func (s *Service) upgradeSect(req common.SectRequest) (interface{}, error) {
+	return s.sectRepo.Upgrade(context.Background(), req)
+}
+
+// getSectInfo 宗门概况与成员，未指定宗门时查询玩家所在的宗门
+func (s *Service) getSectInfo(req common.SectRequest) (interface{}, error) {
+	ctx := context.Background()
+	sectID := req.SectID
+	if sectID == "" {
+		membership, err := s.sectRepo.GetMembership(ctx, req.PlayerID)
+		if err != nil {
+			return nil, err
+		}
+		if membership == nil {
+			return nil, fmt.Errorf("%w: not in a sect", common.ErrSectRejected)
+		}
+		sectID = membership.SectID
+	}
+	return s.sectRepo.GetSect(ctx, sectID)
+}
+
+// listSects 按名称搜索宗门
+func (s *Service) listSects(req common.SectRequest) (interface{}, error) {
+	sects, err := s.sectRepo.ListSects(context.Background(), strings.TrimSpace(req.Name), common.SectListLimit)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"sects": sects}, nil
+}
+
+// listSectApplications 玩家所在宗门等待审核的申请
+func (s *Service) listSectApplications(req common.SectRequest) (interface{}, error) {
+	applications, err := s.sectRepo.ListApplications(context.Background(), req.PlayerID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"applications": applications}, nil
+}
+
+// getSectMembership 玩家所在的宗门与职位，Game 在玩家上线时加载
+func (s *Service) getSectMembership(req common.SectRequest) (interface{}, error) {
+	membership, err := s.sectRepo.GetMembership(context.Background(), req.PlayerID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"membership": membership}, nil
+}
@@ -2,6 +2,7 @@ package persist
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
@@ -23,6 +24,13 @@ type Service struct {
 	redis             *database.Redis
 	userRepo          *database.GORMUserRepository
 	playerRepo        *database.GORMPlayerRepository
+	dataRequestRepo   *database.GORMDataRequestRepository
+	accountDataRepo   *database.GORMAccountDataRepository
+	progressRepo      *database.GORMProgressRepository
+	mailRepo          *database.GORMMailRepository
+	purchaseRepo      *database.GORMPurchaseRepository
+	marketRepo        *database.GORMMarketRepository
+	sectRepo          *database.GORMSectRepository
 	healthCheckCtx    context.Context
 	healthCheckCancel context.CancelFunc
 }
@@ -59,11 +67,28 @@ func (s *Service) Start(ctx context.Context) error {
 	}
 	s.redis = redis
 
+	// players.name 建立唯一索引前先整理存量角色名
+	if err := database.PrepareDisplayNames(gormDB.GetDB()); err != nil {
+		return fmt.Errorf("failed to prepare display names: %w", err)
+	}
+
 	// 使用GORM的AutoMigrate功能运行数据库迁移
 	if err := gormDB.AutoMigrate(
 		&database.User{},
 		&database.Player{},
 		&database.GameProgress{},
+		&database.AuthEvent{},
+		&database.DataRequest{},
+		&database.NameHistory{},
+		&database.Mail{},
+		&database.PurchaseHistory{},
+		&database.MarketListing{},
+		&database.MarketBid{},
+		&database.MarketTrade{},
+		&database.Sect{},
+		&database.SectMember{},
+		&database.SectApplication{},
+		&database.SectTreasuryLog{},
 	); err != nil {
 		return fmt.Errorf("failed to run database migrations: %w", err)
 	}
@@ -71,6 +96,13 @@ func (s *Service) Start(ctx context.Context) error {
 	// 创建GORM仓库
 	s.userRepo = database.NewGORMUserRepository(gormDB.GetDB(), redis)
 	s.playerRepo = database.NewGORMPlayerRepository(gormDB.GetDB(), redis)
+	s.dataRequestRepo = database.NewGORMDataRequestRepository(gormDB.GetDB())
+	s.accountDataRepo = database.NewGORMAccountDataRepository(gormDB.GetDB(), redis)
+	s.progressRepo = database.NewGORMProgressRepository(gormDB.GetDB())
+	s.mailRepo = database.NewGORMMailRepository(gormDB.GetDB())
+	s.purchaseRepo = database.NewGORMPurchaseRepository(gormDB.GetDB())
+	s.marketRepo = database.NewGORMMarketRepository(gormDB.GetDB())
+	s.sectRepo = database.NewGORMSectRepository(gormDB.GetDB())
 
 	// 初始化 NATS 管理器
 	s.natsManager, err = nats.NewManager(common.NATSURL)
@@ -95,6 +127,11 @@ func (s *Service) Start(ctx context.Context) error {
 	s.healthCheckCtx, s.healthCheckCancel = context.WithCancel(ctx)
 	go s.startHealthCheck(s.healthCheckCtx, 30*time.Second)
 
+	// 启动数据导出/删除请求处理
+	go s.startDataRequestWorker(s.healthCheckCtx, common.DataRequestPollInterval*time.Second)
+	go s.startMailCleanup(s.healthCheckCtx, time.Hour)
+	go s.startMarketSettlement(s.healthCheckCtx, common.MarketSettleInterval*time.Second)
+
 	log.Printf("Persist Service started successfully with MySQL, Redis and GORM")
 	return nil
 }
@@ -154,10 +191,53 @@ func (s *Service) registerHandlers() error {
 	loadPlayerHandler := handler.NewLoadPlayerHandler(s.natsManager, s.loadPlayerData)
 	s.processor.RegisterHandler(loadPlayerHandler)
 
+	// 注册玩家进度处理器
+	s.processor.RegisterHandler(handler.NewLoadProgressHandler(s.natsManager, s.loadProgress))
+	s.processor.RegisterHandler(handler.NewSaveProgressHandler(s.natsManager, s.saveProgress))
+	s.processor.RegisterHandler(handler.NewSendMailHandler(s.natsManager, s.sendMail))
+	s.processor.RegisterHandler(handler.NewListMailHandler(s.natsManager, s.listMail))
+	s.processor.RegisterHandler(handler.NewClaimMailHandler(s.natsManager, s.claimMail))
+	s.processor.RegisterHandler(handler.NewRecordPurchaseHandler(s.natsManager, s.recordPurchase))
+	s.processor.RegisterHandler(handler.NewPurchaseHistoryHandler(s.natsManager, s.listPurchases))
+	s.processor.RegisterHandler(handler.NewFindPurchaseHandler(s.natsManager, s.findPurchase))
+
+	// 注册玩家市场处理器
+	s.processor.RegisterHandler(handler.NewMarketHandler(s.natsManager, "C_MarketList", s.createMarketListing))
+	s.processor.RegisterHandler(handler.NewMarketHandler(s.natsManager, "C_MarketBuy", s.buyMarketListing))
+	s.processor.RegisterHandler(handler.NewMarketHandler(s.natsManager, "C_MarketBid", s.bidMarketListing))
+	s.processor.RegisterHandler(handler.NewMarketHandler(s.natsManager, "C_MarketCancel", s.cancelMarketListing))
+	s.processor.RegisterHandler(handler.NewMarketHandler(s.natsManager, "C_MarketSearch", s.searchMarket))
+	s.processor.RegisterHandler(handler.NewMarketHandler(s.natsManager, "C_MarketMine", s.listPlayerMarket))
+
+	// 注册宗门处理器
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectCreate", s.createSect))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectApply", s.applySect))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectReview", s.reviewSectApplication))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectLeave", s.leaveSect))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectKick", s.kickSectMember))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectSetRank", s.setSectRank))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectTransfer", s.transferSect))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectDisband", s.disbandSect))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectNotice", s.setSectNotice))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectDonate", s.donateSect))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectWithdraw", s.withdrawSect))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectUpgrade", s.upgradeSect))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectInfo", s.getSectInfo))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectList", s.listSects))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectApplications", s.listSectApplications))
+	s.processor.RegisterHandler(handler.NewSectHandler(s.natsManager, "C_SectMembership", s.getSectMembership))
+
 	// 注册用户删除处理器
 	deleteUserHandler := handler.NewDeleteUserHandler(s.natsManager, s.deleteUserData)
 	s.processor.RegisterHandler(deleteUserHandler)
 
+	// 注册数据请求处理触发器
+	s.processor.RegisterHandler(handler.NewProcessDataRequestsHandler(s.natsManager, s.triggerDataRequests))
+
+	// 注册公开只读数据处理器
+	s.processor.RegisterHandler(handler.NewLeaderboardHandler(s.natsManager, s.getLeaderboard))
+	s.processor.RegisterHandler(handler.NewPlayerProfileHandler(s.natsManager, s.getPlayerProfile))
+
 	return nil
 }
 
@@ -173,10 +253,41 @@ func (s *Service) registerNATSSubscriptions() error {
 		common.PersistUserExistsSubject,
 		common.PersistSaveSubject,
 		common.PersistLoadSubject,
+		common.PersistSavePlayerSubject,
+		common.PersistLoadPlayerSubject,
+		common.PersistLoadProgressSubject,
+		common.PersistSaveProgressSubject,
+		common.PersistSendMailSubject,
+		common.PersistListMailSubject,
+		common.PersistClaimMailSubject,
+		common.PersistRecordPurchaseSubject,
+		common.PersistPurchaseHistorySubject,
+		common.PersistFindPurchaseSubject,
+		common.PersistMarketListSubject,
+		common.PersistMarketBuySubject,
+		common.PersistMarketBidSubject,
+		common.PersistMarketCancelSubject,
+		common.PersistMarketSearchSubject,
+		common.PersistMarketMineSubject,
+		common.PersistSectCreateSubject,
+		common.PersistSectApplySubject,
+		common.PersistSectReviewSubject,
+		common.PersistSectLeaveSubject,
+		common.PersistSectManageSubject,
+		common.PersistSectDonateSubject,
+		common.PersistSectWithdrawSubject,
+		common.PersistSectUpgradeSubject,
+		common.PersistSectInfoSubject,
+		common.PersistSectListSubject,
+		common.PersistSectApplicationsSubject,
+		common.PersistSectMembershipSubject,
 		"persist.create_user",
 		"persist.authenticate_user",
 		"persist.player_exists",
 		"persist.update_player_status",
+		common.PersistDataRequestSubject,
+		common.PersistLeaderboardSubject,
+		common.PersistPlayerProfileSubject,
 	}
 
 	for _, subject := range subjects {
@@ -291,10 +402,24 @@ func (s *Service) loadUserData(userID string) (interface{}, error) {
 
 // savePlayerData 保存玩家数据业务逻辑
 func (s *Service) savePlayerData(playerID string, data interface{}) error {
-	playerData, ok := data.(*common.PlayerData)
-	if !ok {
-		return fmt.Errorf("invalid player data type")
+	var playerData *common.PlayerData
+	switch v := data.(type) {
+	case *common.PlayerData:
+		playerData = v
+	case map[string]interface{}:
+		// Game 服务通过 NATS 发送的 JSON 数据
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to convert player data: %w", err)
+		}
+		playerData = &common.PlayerData{}
+		if err := json.Unmarshal(encoded, playerData); err != nil {
+			return fmt.Errorf("failed to convert player data: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid player data type, got %T", data)
 	}
+	playerData.PlayerID = playerID
 
 	log.Printf("Saving player data for: %s", playerID)
 
@@ -323,15 +448,22 @@ func (s *Service) loadPlayerData(playerID string) (interface{}, error) {
 }
 
 // deleteUserData 删除用户数据业务逻辑
+// 账号立即停用，全部数据在冷静期结束后由数据请求 worker 删除
 func (s *Service) deleteUserData(userID string) error {
 	log.Printf("Deleting user data for: %s", userID)
 
+	request, err := s.scheduleAccountErasure(s.healthCheckCtx, userID, "system")
+	if err != nil {
+		log.Printf("Failed to schedule erasure for user %s: %v", userID, err)
+		return fmt.Errorf("failed to delete user data: %w", err)
+	}
+
 	if err := s.userRepo.DeleteUser(s.healthCheckCtx, userID); err != nil {
 		log.Printf("Failed to delete user %s: %v", userID, err)
 		return fmt.Errorf("failed to delete user data: %w", err)
 	}
 
-	log.Printf("User data deleted successfully for: %s", userID)
+	log.Printf("User %s deactivated, data erasure scheduled at %s", userID, request.ScheduledAt.Format(time.RFC3339))
 	return nil
 }
 